@@ -17,11 +17,18 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/proxy"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
 	"github.com/codeready-toolchain/registration-service/pkg/server"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
+	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	errs "github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap/zapcore"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
@@ -66,7 +73,7 @@ func main() {
 	ctx := controllerruntime.SetupSignalHandler()
 
 	// create cached runtime client
-	cl, err := newCachedClient(ctx, cfg)
+	cl, informers, err := newCachedClient(ctx, cfg)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -75,19 +82,39 @@ func main() {
 	crtConfig := configuration.GetRegistrationServiceConfig()
 	crtConfig.Print()
 
+	nsClient := namespaced.NewClient(cl, configuration.Namespace())
+
+	if err := verificationservice.CheckCodeHashKey(crtConfig.Verification()); err != nil {
+		panic(err.Error())
+	}
+
 	if crtConfig.Verification().CaptchaEnabled() {
-		if err := createCaptchaFileFromSecret(crtConfig); err != nil {
-			panic(fmt.Sprintf("failed to create captcha file: %s", err.Error()))
+		// report the outcome of the signups to the captcha provider
+		if err := captcha.NewFeedbackReporter(nsClient, captcha.Helper{}).Start(ctx, informers); err != nil {
+			panic(errs.Wrap(err, "failed to start the captcha feedback reporter"))
 		}
+	}
 
-		// set application credentials env var required for recaptcha client
-		if err := os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", configuration.CaptchaFilePath); err != nil {
-			panic(fmt.Sprintf("cannot set captcha credentials: %s", err.Error()))
-		}
+	// delete the expired counters of the verification rate limits
+	ratelimit.NewLimiter(nsClient).StartCleanup(ctx)
+
+	// publish the signup lifecycle events to the configured webhooks
+	eventPublisher := events.NewPublisher(&http.Client{
+		Timeout:   10 * time.Second,
+		Transport: http.DefaultTransport,
+	})
+	eventPublisher.Start(ctx)
+	if err := events.NewWatcher(eventPublisher).Start(ctx, informers); err != nil {
+		panic(errs.Wrap(err, "failed to start the signup lifecycle events watcher"))
+	}
+
+	// notify the signup streams of the changes in the UserSignups and MasterUserRecords
+	signupNotifier := signup.NewChangeNotifier()
+	if err := signupNotifier.Start(ctx, informers); err != nil {
+		panic(errs.Wrap(err, "failed to start the signup change notifier"))
 	}
-	nsClient := namespaced.NewClient(cl, configuration.Namespace())
 
-	app := server.NewInClusterApplication(nsClient)
+	app := server.NewInClusterApplication(nsClient, eventPublisher)
 	// Initialize toolchain cluster cache service
 	// let's cache the member clusters before we start the services,
 	// this will speed up the first request
@@ -95,10 +122,12 @@ func main() {
 	cluster.NewToolchainClusterService(cl, cacheLog, configuration.Namespace(), 5*time.Second)
 	cluster.GetMemberClusters()
 
-	_, err = auth.InitializeDefaultTokenParser()
+	tokenParser, err := auth.InitializeDefaultTokenParser()
 	if err != nil {
 		panic(errs.Wrap(err, "failed to init default token parser"))
 	}
+	// keep the public keys up-to-date, so that key rotations in SSO do not require a restart
+	tokenParser.Run(ctx)
 
 	// ---------------------------------------------
 	// API Proxy
@@ -120,7 +149,7 @@ func main() {
 	// ---------------------------------------------
 	regsvcRegistry := prometheus.NewRegistry()
 	regsvcMetricsSrv, _ := server.StartMetricsServer(regsvcRegistry, server.RegSvcMetricsPort)
-	regsvcSrv := server.New(app)
+	regsvcSrv := server.New(app, server.WithSignupNotifier(signupNotifier))
 	err = regsvcSrv.SetupRoutes(proxy.DefaultPort, regsvcRegistry, nsClient)
 	if err != nil {
 		panic(err.Error())
@@ -160,24 +189,31 @@ func gracefulShutdown(ctx context.Context, timeout time.Duration, hs ...*http.Se
 	}
 }
 
-func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, error) {
+func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, cache.Informers, error) {
 	scheme := runtime.NewScheme()
 	var AddToSchemes runtime.SchemeBuilder
 	addToSchemes := append(AddToSchemes,
 		corev1.AddToScheme,
+		authenticationv1.AddToScheme,
+		coordinationv1.AddToScheme,
 		toolchainv1alpha1.AddToScheme)
 	err := addToSchemes.AddToScheme(scheme)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	hostCluster, err := runtimecluster.New(cfg, func(options *runtimecluster.Options) {
 		options.Scheme = scheme
 		// cache only in the host-operator namespace
 		options.Cache.DefaultNamespaces = map[string]cache.Config{configuration.Namespace(): {}}
+		// the ConfigMaps (ie, the denylist of revoked tokens) are read directly, which does not require to list and watch them,
+		// and so are the Leases holding the verification rate limit counters, which must be up to date
+		options.Client.Cache = &client.CacheOptions{
+			DisableFor: []client.Object{&corev1.ConfigMap{}, &coordinationv1.Lease{}},
+		}
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	go func() {
 		if err := hostCluster.Start(ctx); err != nil {
@@ -186,7 +222,7 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 	}()
 
 	if !hostCluster.GetCache().WaitForCacheSync(ctx) {
-		return nil, fmt.Errorf("unable to sync the cache of the client")
+		return nil, nil, fmt.Errorf("unable to sync the cache of the client")
 	}
 
 	// populate the cache backed by shared informers that are initialized lazily on the first call
@@ -208,19 +244,11 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 		log.Infof(nil, "Syncing informer cache with %s resources", resourceName)
 		if err := hostCluster.GetClient().List(ctx, objectsToList[resourceName], client.InNamespace(configuration.Namespace())); err != nil {
 			log.Errorf(nil, err, "Informer cache sync failed for %s", resourceName)
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	log.Info(nil, "Informer caches synced")
 
-	return hostCluster.GetClient(), nil
-}
-
-func createCaptchaFileFromSecret(cfg configuration.RegistrationServiceConfig) error {
-	contents := cfg.Verification().CaptchaServiceAccountFileContents()
-	if err := os.WriteFile(configuration.CaptchaFilePath, []byte(contents), 0600); err != nil {
-		return errs.Wrap(err, "error writing captcha file")
-	}
-	return nil
+	return hostCluster.GetClient(), hostCluster.GetCache(), nil
 }
@@ -9,12 +9,20 @@ import (
 type SignupService interface {
 	Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, error)
 	GetSignup(ctx *gin.Context, username string, checkUserSignupCompleted bool) (*signup.Signup, error)
+	RequestDeactivation(ctx *gin.Context, username string) (*signup.DeactivationConfirmation, error)
+	Deactivate(ctx *gin.Context, username, confirmationToken string) error
+	ExportSignup(ctx *gin.Context, username string) (*signup.Export, error)
+	CreateInvitation(ctx *gin.Context, username string) (*signup.Invitation, error)
+	ListInvitations(ctx *gin.Context, username string) (*signup.Invitations, error)
 }
 
 type VerificationService interface {
-	InitVerification(ctx *gin.Context, username, e164PhoneNumber, countryCode string) error
+	InitVerification(ctx *gin.Context, username, e164PhoneNumber, countryCode, channel string) error
 	VerifyPhoneCode(ctx *gin.Context, username, code string) error
 	VerifyActivationCode(ctx *gin.Context, username, code string) error
+	InitEmailVerification(ctx *gin.Context, username string) error
+	VerifyEmailCode(ctx *gin.Context, username, code string) error
+	VerifyEmailLink(ctx *gin.Context, username, token string) error
 }
 
 type Services interface {
@@ -3,6 +3,8 @@ package auth
 import (
 	"errors"
 	"sync"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 )
 
 // DefaultTokenParserConfiguration represents a partition of the configuration
@@ -23,12 +25,35 @@ var (
 func InitializeDefaultTokenParser() (*TokenParser, error) {
 	var returnErr error
 	initDefaultTokenParserOnce.Do(func() {
-		keyManager, err := NewKeyManager()
+		issuersCfg, err := configuration.GetRegistrationServiceConfig().Auth().TokenIssuers()
 		if err != nil {
 			returnErr = err
 			return
 		}
-		defaultTokenParser, returnErr = NewTokenParser(keyManager)
+		if oidcIssuerURL := configuration.GetRegistrationServiceConfig().Auth().OIDCIssuerURL(); len(issuersCfg) == 0 && oidcIssuerURL != "" {
+			// only trust the issuer whose configuration is obtained via OpenID Connect Discovery
+			issuersCfg = []configuration.TokenIssuerConfig{{Issuer: oidcIssuerURL}}
+		}
+		if len(issuersCfg) == 0 {
+			// no trusted issuers configured: accept the tokens signed with the keys from the configured URL
+			keyManager, err := NewKeyManager()
+			if err != nil {
+				returnErr = err
+				return
+			}
+			defaultTokenParser, returnErr = NewTokenParser(keyManager)
+			return
+		}
+		issuers := make([]*Issuer, 0, len(issuersCfg))
+		for _, issuerCfg := range issuersCfg {
+			issuer, err := NewIssuer(issuerCfg)
+			if err != nil {
+				returnErr = err
+				return
+			}
+			issuers = append(issuers, issuer)
+		}
+		defaultTokenParser, returnErr = NewTokenParserForIssuers(issuers...)
 	})
 	if returnErr != nil {
 		return nil, returnErr
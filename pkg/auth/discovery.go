@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	wellKnownOpenIDConfigurationPath = "/.well-known/openid-configuration"
+	// discoveryMinRetryDelay is the delay before fetching the metadata again after a first failure.
+	// The delay is doubled after each consecutive failure, up to the refresh interval.
+	discoveryMinRetryDelay = time.Second
+)
+
+// ProviderMetadata contains the OpenID Provider Metadata obtained via OpenID Connect Discovery
+// (see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata)
+type ProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// WellKnownURL returns the URL of the OpenID configuration document of the given issuer
+func WellKnownURL(issuerURL string) string {
+	return strings.TrimSuffix(issuerURL, "/") + wellKnownOpenIDConfigurationPath
+}
+
+type cachedProviderMetadata struct {
+	metadata  *ProviderMetadata
+	fetchedAt time.Time
+	// err is the error of the last failed fetch, failures the number of consecutive failed fetches,
+	// and retryAt the time before which the metadata is not fetched again
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+var (
+	providerMetadataMu      sync.Mutex
+	providerMetadataCache   = map[string]cachedProviderMetadata{}
+	providerMetadataFetches singleflight.Group
+)
+
+// DiscoverProvider returns the metadata of the OpenID provider with the given issuer URL.
+// The metadata is cached and fetched again once it is older than the configured refresh interval.
+// Concurrent calls share the same fetch, which is done without holding the cache lock.
+// If the metadata cannot be refreshed, then the cached one is returned, and the fetch is not attempted again
+// until the retry delay is elapsed.
+func DiscoverProvider(issuerURL string) (*ProviderMetadata, error) {
+	refreshInterval := configuration.GetRegistrationServiceConfig().Auth().OIDCDiscoveryRefreshInterval()
+	providerMetadataMu.Lock()
+	cached, found := providerMetadataCache[issuerURL]
+	providerMetadataMu.Unlock()
+	if found && !providerMetadataDue(cached, refreshInterval) {
+		if cached.metadata == nil {
+			return nil, cached.err
+		}
+		return cached.metadata, nil
+	}
+	result, err, _ := providerMetadataFetches.Do(issuerURL, func() (interface{}, error) {
+		return refreshProviderMetadata(issuerURL, refreshInterval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*ProviderMetadata), nil
+}
+
+// providerMetadataDue returns true if the cached metadata should be fetched again
+func providerMetadataDue(cached cachedProviderMetadata, refreshInterval time.Duration) bool {
+	if cached.failures > 0 {
+		return !time.Now().Before(cached.retryAt)
+	}
+	return time.Since(cached.fetchedAt) >= refreshInterval
+}
+
+// refreshProviderMetadata fetches the metadata and updates the cache. If the fetch fails, then the failure is
+// recorded and the previously cached metadata (if any) is returned.
+func refreshProviderMetadata(issuerURL string, refreshInterval time.Duration) (*ProviderMetadata, error) {
+	// the metadata may have been refreshed by a fetch which completed in the meantime
+	providerMetadataMu.Lock()
+	cached, found := providerMetadataCache[issuerURL]
+	providerMetadataMu.Unlock()
+	if found && cached.metadata != nil && !providerMetadataDue(cached, refreshInterval) {
+		return cached.metadata, nil
+	}
+
+	metadata, err := fetchProviderMetadata(issuerURL)
+	providerMetadataMu.Lock()
+	defer providerMetadataMu.Unlock()
+	cached = providerMetadataCache[issuerURL]
+	if err != nil {
+		cached.err = err
+		cached.failures++
+		cached.retryAt = time.Now().Add(discoveryRetryDelay(cached.failures, refreshInterval))
+		providerMetadataCache[issuerURL] = cached
+		if cached.metadata == nil {
+			return nil, err
+		}
+		log.Error(nil, err, "failed to refresh the OpenID provider metadata, using the cached one")
+		return cached.metadata, nil
+	}
+	providerMetadataCache[issuerURL] = cachedProviderMetadata{
+		metadata:  metadata,
+		fetchedAt: time.Now(),
+	}
+	return metadata, nil
+}
+
+// discoveryRetryDelay returns the delay before fetching the metadata again after the given number of
+// consecutive failures: it starts at discoveryMinRetryDelay and is doubled after each failure, up to the refresh interval.
+func discoveryRetryDelay(failures int, refreshInterval time.Duration) time.Duration {
+	maxDelay := max(refreshInterval, discoveryMinRetryDelay)
+	delay := discoveryMinRetryDelay
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// fetchProviderMetadata fetches and validates the OpenID configuration document of the given issuer
+func fetchProviderMetadata(issuerURL string) (*ProviderMetadata, error) {
+	wellKnownURL := WellKnownURL(issuerURL)
+	log.Infof(nil, "fetching OpenID provider metadata from url: %s", wellKnownURL)
+	res, err := newHTTPClient().Get(wellKnownURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		if err := res.Body.Close(); err != nil {
+			log.Error(nil, err, "failed to close response after reading")
+		}
+	}()
+	if res.StatusCode != http.StatusOK {
+		err := errors.New("unable to obtain OpenID provider metadata from remote service")
+		log.WithValues(map[string]interface{}{
+			"response_status": res.Status,
+			"well_known_url":  wellKnownURL,
+		}).Error(nil, err, "")
+		return nil, err
+	}
+	metadata := &ProviderMetadata{}
+	if err := json.NewDecoder(res.Body).Decode(metadata); err != nil {
+		return nil, err
+	}
+	// the issuer in the metadata must be identical to the URL used to retrieve it
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return nil, fmt.Errorf("OpenID provider metadata issuer '%s' does not match the expected issuer '%s'", metadata.Issuer, issuerURL)
+	}
+	if metadata.JWKSURI == "" || metadata.AuthorizationEndpoint == "" {
+		return nil, errors.New("OpenID provider metadata is missing the jwks_uri or authorization_endpoint")
+	}
+	return metadata, nil
+}
@@ -0,0 +1,299 @@
+package auth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestDiscoverySuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunDiscoverySuite(t *testing.T) {
+	suite.Run(t, &TestDiscoverySuite{test.UnitTestSuite{}})
+}
+
+// newOpenIDProvider starts a mock OpenID provider serving its configuration and its keys.
+// The returned counter is incremented every time the configuration is requested.
+func (s *TestDiscoverySuite) newOpenIDProvider(tokenGenerator *authsupport.TokenManager, issuer func(url string) string) (*httptest.Server, *atomic.Int32, *atomic.Bool) {
+	keyServer := tokenGenerator.NewKeyServer()
+	s.T().Cleanup(keyServer.Close)
+	requests := &atomic.Int32{}
+	failing := &atomic.Bool{}
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/sandbox/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		err := json.NewEncoder(w).Encode(auth.ProviderMetadata{
+			Issuer:                issuer(provider.URL + "/realms/sandbox"),
+			AuthorizationEndpoint: provider.URL + "/realms/sandbox/protocol/openid-connect/auth",
+			TokenEndpoint:         provider.URL + "/realms/sandbox/protocol/openid-connect/token",
+			JWKSURI:               keyServer.URL,
+		})
+		assert.NoError(s.T(), err)
+	}))
+	s.T().Cleanup(provider.Close)
+	return provider, requests, failing
+}
+
+func (s *TestDiscoverySuite) setRefreshInterval(interval string) {
+	s.SetSettings(map[string]string{
+		"auth.oidcDiscoveryRefreshInterval": interval,
+	}, testconfig.RegistrationService().Environment(configuration.UnitTestsEnvironment))
+}
+
+func (s *TestDiscoverySuite) TestDiscoverProvider() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	sameIssuer := func(url string) string { return url }
+
+	s.Run("metadata is discovered and cached", func() {
+		// given
+		s.setRefreshInterval("1h")
+		provider, requests, _ := s.newOpenIDProvider(authsupport.NewTokenManager(), sameIssuer)
+
+		// when
+		metadata, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+		require.NoError(s.T(), err)
+		cached, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), provider.URL+"/realms/sandbox", metadata.Issuer)
+		assert.Equal(s.T(), provider.URL+"/realms/sandbox/protocol/openid-connect/auth", metadata.AuthorizationEndpoint)
+		assert.Equal(s.T(), provider.URL+"/realms/sandbox/protocol/openid-connect/token", metadata.TokenEndpoint)
+		assert.NotEmpty(s.T(), metadata.JWKSURI)
+		assert.Same(s.T(), metadata, cached)
+		assert.Equal(s.T(), int32(1), requests.Load())
+	})
+
+	s.Run("metadata is refreshed", func() {
+		// given
+		s.setRefreshInterval("0s")
+		provider, requests, failing := s.newOpenIDProvider(authsupport.NewTokenManager(), sameIssuer)
+		metadata, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), int32(2), requests.Load())
+
+		s.Run("cached metadata is used when refresh fails", func() {
+			// given
+			failing.Store(true)
+
+			// when
+			cached, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), metadata, cached)
+			assert.Equal(s.T(), int32(3), requests.Load())
+
+			s.Run("no new fetch until the retry delay is elapsed", func() {
+				// when
+				cached, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), metadata, cached)
+				assert.Equal(s.T(), int32(3), requests.Load())
+			})
+		})
+	})
+
+	s.Run("concurrent fetches are coalesced", func() {
+		// given
+		s.setRefreshInterval("1h")
+		requests := &atomic.Int32{}
+		release := make(chan struct{})
+		var provider *httptest.Server
+		provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			requests.Add(1)
+			<-release
+			err := json.NewEncoder(w).Encode(auth.ProviderMetadata{
+				Issuer:                provider.URL + "/realms/sandbox",
+				AuthorizationEndpoint: provider.URL + "/realms/sandbox/protocol/openid-connect/auth",
+				JWKSURI:               provider.URL + "/realms/sandbox/protocol/openid-connect/certs",
+			})
+			assert.NoError(s.T(), err)
+		}))
+		defer provider.Close()
+		var wg sync.WaitGroup
+
+		// when
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+				assert.NoError(s.T(), err)
+			}()
+		}
+		require.Eventually(s.T(), func() bool { return requests.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond) // let the other calls wait for the ongoing fetch
+		close(release)
+		wg.Wait()
+
+		// then
+		assert.Equal(s.T(), int32(1), requests.Load())
+	})
+
+	s.Run("failed fetch without cached metadata is not retried until the retry delay is elapsed", func() {
+		// given
+		s.setRefreshInterval("1h")
+		provider, requests, failing := s.newOpenIDProvider(authsupport.NewTokenManager(), sameIssuer)
+		failing.Store(true)
+		_, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+		require.Error(s.T(), err)
+
+		// when
+		_, err = auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+		// then
+		require.EqualError(s.T(), err, "unable to obtain OpenID provider metadata from remote service")
+		assert.Equal(s.T(), int32(1), requests.Load())
+
+		s.Run("fetched again after the retry delay", func() {
+			// given
+			failing.Store(false)
+			time.Sleep(time.Second)
+
+			// when
+			metadata, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), provider.URL+"/realms/sandbox", metadata.Issuer)
+			assert.Equal(s.T(), int32(2), requests.Load())
+		})
+	})
+
+	s.Run("unavailable provider", func() {
+		// given
+		s.setRefreshInterval("1h")
+		provider, _, failing := s.newOpenIDProvider(authsupport.NewTokenManager(), sameIssuer)
+		failing.Store(true)
+
+		// when
+		_, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+		// then
+		require.EqualError(s.T(), err, "unable to obtain OpenID provider metadata from remote service")
+	})
+
+	s.Run("issuer mismatch", func() {
+		// given
+		s.setRefreshInterval("1h")
+		provider, _, _ := s.newOpenIDProvider(authsupport.NewTokenManager(), func(string) string { return "https://evil.com" })
+
+		// when
+		_, err := auth.DiscoverProvider(provider.URL + "/realms/sandbox")
+
+		// then
+		require.EqualError(s.T(), err, "OpenID provider metadata issuer 'https://evil.com' does not match the expected issuer '"+provider.URL+"/realms/sandbox'")
+	})
+
+	s.Run("issuer keys are discovered", func() {
+		// given
+		s.setRefreshInterval("1h")
+		tokenGenerator := authsupport.NewTokenManager()
+		_, err := tokenGenerator.AddPrivateKey("discovered")
+		require.NoError(s.T(), err)
+		provider, _, _ := s.newOpenIDProvider(tokenGenerator, sameIssuer)
+		issuer, err := auth.NewIssuer(configuration.TokenIssuerConfig{Issuer: provider.URL + "/realms/sandbox"})
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParserForIssuers(issuer)
+		require.NoError(s.T(), err)
+		identity := authsupport.NewIdentity()
+		token := tokenGenerator.GenerateToken(*identity, "discovered",
+			authsupport.WithEmailClaim(identity.Email),
+			authsupport.WithSubClaim(uuid.NewString()))
+		token.Claims.(*authsupport.MyClaims).Issuer = provider.URL + "/realms/sandbox"
+		signed, err := tokenGenerator.SignToken(token, "discovered")
+		require.NoError(s.T(), err)
+
+		// when
+		claims, err := tokenParser.FromString(signed)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), identity.Username, claims.PreferredUsername)
+	})
+	s.Run("issuer keys are fetched from the new URL when the provider metadata changes", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.oidcDiscoveryRefreshInterval":  "0s",
+			"auth.publicKeys.minRefreshInterval": "0s",
+		}, testconfig.RegistrationService().Environment(configuration.UnitTestsEnvironment))
+		oldKeys := authsupport.NewTokenManager()
+		_, err := oldKeys.AddPrivateKey("old")
+		require.NoError(s.T(), err)
+		oldKeyServer := oldKeys.NewKeyServer()
+		defer oldKeyServer.Close()
+		newKeys := authsupport.NewTokenManager()
+		_, err = newKeys.AddPrivateKey("new")
+		require.NoError(s.T(), err)
+		newKeyServer := newKeys.NewKeyServer()
+		defer newKeyServer.Close()
+		jwksURI := &atomic.Value{}
+		jwksURI.Store(oldKeyServer.URL)
+		var provider *httptest.Server
+		provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			err := json.NewEncoder(w).Encode(auth.ProviderMetadata{
+				Issuer:                provider.URL + "/realms/sandbox",
+				AuthorizationEndpoint: provider.URL + "/realms/sandbox/protocol/openid-connect/auth",
+				JWKSURI:               jwksURI.Load().(string),
+			})
+			assert.NoError(s.T(), err)
+		}))
+		defer provider.Close()
+		issuer, err := auth.NewIssuer(configuration.TokenIssuerConfig{Issuer: provider.URL + "/realms/sandbox"})
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParserForIssuers(issuer)
+		require.NoError(s.T(), err)
+		identity := authsupport.NewIdentity()
+		token := newKeys.GenerateToken(*identity, "new",
+			authsupport.WithEmailClaim(identity.Email),
+			authsupport.WithSubClaim(uuid.NewString()))
+		token.Claims.(*authsupport.MyClaims).Issuer = provider.URL + "/realms/sandbox"
+		signed, err := newKeys.SignToken(token, "new")
+		require.NoError(s.T(), err)
+
+		// when
+		jwksURI.Store(newKeyServer.URL)
+		claims, err := tokenParser.FromString(signed)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), identity.Username, claims.PreferredUsername)
+	})
+}
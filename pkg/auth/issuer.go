@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Issuer represents a trusted token issuer, with its own public keys, audiences and claim mappings.
+type Issuer struct {
+	name          string
+	keyManager    *KeyManager
+	audiences     []string
+	claimMappings map[string]string
+}
+
+// NewIssuer creates a new Issuer from the given configuration and retrieves its public keys.
+// If the configuration has no public keys URL, then it is obtained via OpenID Connect Discovery.
+func NewIssuer(cfg configuration.TokenIssuerConfig) (*Issuer, error) {
+	publicKeysURL := cfg.PublicKeysURL
+	if publicKeysURL == "" {
+		metadata, err := DiscoverProvider(cfg.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		publicKeysURL = metadata.JWKSURI
+	}
+	keyManager, err := NewIssuerKeyManager(cfg.Issuer, publicKeysURL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PublicKeysURL == "" {
+		// the `jwks_uri` may change when the provider metadata is refreshed
+		keyManager.discoverKeysEndpointURL = func() (string, error) {
+			metadata, err := DiscoverProvider(cfg.Issuer)
+			if err != nil {
+				return "", err
+			}
+			return metadata.JWKSURI, nil
+		}
+	}
+	return &Issuer{
+		name:          cfg.Issuer,
+		keyManager:    keyManager,
+		audiences:     cfg.Audiences,
+		claimMappings: cfg.ClaimMappings,
+	}, nil
+}
+
+// Name returns the name of the issuer, ie, the expected value of the `iss` claim.
+func (i *Issuer) Name() string {
+	return i.name
+}
+
+// acceptsAudience returns true if the issuer has no expected audience,
+// or if at least one of the given audiences is expected.
+func (i *Issuer) acceptsAudience(audiences jwt.ClaimStrings) bool {
+	if len(i.audiences) == 0 {
+		return true
+	}
+	for _, aud := range audiences {
+		if slices.Contains(i.audiences, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenClaims converts the raw claims into TokenClaims, after applying the claim mappings of the issuer.
+func (i *Issuer) tokenClaims(raw jwt.MapClaims) (*TokenClaims, error) {
+	mapped := make(jwt.MapClaims, len(raw))
+	for name, value := range raw {
+		mapped[name] = value
+	}
+	for name, source := range i.claimMappings {
+		if value, found := raw[source]; found {
+			mapped[name] = value
+		} else {
+			delete(mapped, name)
+		}
+	}
+	data, err := json.Marshal(mapped)
+	if err != nil {
+		return nil, err
+	}
+	claims := &TokenClaims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -2,17 +2,27 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/go-jose/go-jose.v2"
 )
 
@@ -23,10 +33,12 @@ type KeyManagerConfiguration interface {
 	GetEnvironment() string
 }
 
-// PublicKey represents an RSA public key with a Key ID
+// PublicKey represents a public key (RSA, ECDSA or Ed25519) with a Key ID
 type PublicKey struct {
 	KeyID string
-	Key   *rsa.PublicKey
+	Key   crypto.PublicKey
+	// Algorithm is the signing algorithm the key is intended for, if specified in the key set
+	Algorithm string
 }
 
 // JSONKeys the remote keys encoded in a json document
@@ -34,17 +46,62 @@ type JSONKeys struct {
 	Keys []interface{} `json:"keys"`
 }
 
+// retiredKey is a key which was removed from the remote key set, but which is still accepted until the end of
+// the grace period, so that the tokens signed before the key rotation remain valid
+type retiredKey struct {
+	key       *PublicKey
+	retiredAt time.Time
+}
+
 // KeyManager manages the public keys for token validation.
+// The keys are refreshed periodically (see `Run`) and on-demand when a token signed with an unknown key is received.
+// It is safe for concurrent use.
 type KeyManager struct {
-	keyMap map[string]*rsa.PublicKey
+	issuer                string
+	keysEndpointURL       string
+	refreshInterval       time.Duration
+	minRefreshInterval    time.Duration
+	retiredKeyGracePeriod time.Duration
+	// discoverKeysEndpointURL returns the current URL of the keys endpoint when it is obtained via OpenID Connect Discovery,
+	// so that a change of the `jwks_uri` in the provider metadata is taken into account. Nil if the URL is configured.
+	discoverKeysEndpointURL func() (string, error)
+
+	// fetchMu serializes the fetches of the remote keys, and guards the discovered URL of the keys endpoint
+	fetchMu                   sync.Mutex
+	discoveredKeysEndpointURL string
+	// mu guards the fields below
+	mu            sync.RWMutex
+	keyMap        map[string]*PublicKey
+	retiredKeys   map[string]retiredKey
+	fetchedAt     time.Time
+	nextRefresh   time.Time
+	lastFetchedAt time.Time
+
+	refreshCounter *prometheus.CounterVec
+	now            func() time.Time
 }
 
-// NewKeyManager creates a new KeyManager and retrieves the public keys from the given URL.
+// NewKeyManager creates a new KeyManager and retrieves the public keys from the URL given in the configuration.
 func NewKeyManager() (*KeyManager, error) {
+	return NewIssuerKeyManager("", configuration.GetRegistrationServiceConfig().Auth().AuthClientPublicKeysURL())
+}
+
+// NewIssuerKeyManager creates a new KeyManager for the given token issuer and retrieves the public keys from the given URL.
+func NewIssuerKeyManager(issuer, keysEndpointURL string) (*KeyManager, error) {
 	cfg := configuration.GetRegistrationServiceConfig()
-	keysEndpointURL := cfg.Auth().AuthClientPublicKeysURL()
 	km := &KeyManager{
-		keyMap: make(map[string]*rsa.PublicKey),
+		issuer:                issuer,
+		refreshInterval:       cfg.Auth().AuthClientPublicKeysRefreshInterval(),
+		minRefreshInterval:    cfg.Auth().AuthClientPublicKeysMinRefreshInterval(),
+		retiredKeyGracePeriod: cfg.Auth().AuthClientPublicKeysRetiredKeyGracePeriod(),
+		keyMap:                make(map[string]*PublicKey),
+		retiredKeys:           make(map[string]retiredKey),
+		refreshCounter: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "sandbox_auth_public_keys_refresh_total",
+			Help:        "Number of attempts to fetch the public keys used to validate the tokens",
+			ConstLabels: issuerLabel(issuer),
+		}, []string{"trigger", "result"}),
+		now: time.Now,
 	}
 	// fetch raw keys
 	if keysEndpointURL != "" {
@@ -54,18 +111,15 @@ func NewKeyManager() (*KeyManager, error) {
 
 			// add them to the kid map
 			for _, key := range keys {
-				km.keyMap[key.KeyID] = key.Key
+				km.keyMap[key.KeyID] = &PublicKey{KeyID: key.KeyID, Key: key.Key}
 			}
+			km.fetchedAt = km.now()
 		} else {
 			log.Infof(nil, "fetching public keys from url: %s", keysEndpointURL)
-			keys, err := km.fetchKeys(keysEndpointURL)
-			if err != nil {
+			km.keysEndpointURL = keysEndpointURL
+			if err := km.refresh("startup"); err != nil {
 				return nil, err
 			}
-			// add them to the kid map
-			for _, key := range keys {
-				km.keyMap[key.KeyID] = key.Key
-			}
 		}
 	} else {
 		log.Info(nil, "no public key url given, not fetching keys")
@@ -74,12 +128,220 @@ func NewKeyManager() (*KeyManager, error) {
 }
 
 // Key retrieves the public key for a given kid.
-func (km *KeyManager) Key(kid string) (*rsa.PublicKey, error) {
-	key, ok := km.keyMap[kid]
-	if !ok {
+func (km *KeyManager) Key(kid string) (crypto.PublicKey, error) {
+	key, err := km.PublicKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return key.Key, nil
+}
+
+// PublicKey retrieves the public key for a given kid, along with the algorithm it is intended for.
+// If the kid is unknown, then the keys are fetched again, unless they were already fetched
+// less than the minimum refresh interval ago.
+func (km *KeyManager) PublicKey(kid string) (*PublicKey, error) {
+	if key, ok := km.lookup(kid); ok {
+		return key, nil
+	}
+	if !km.canFetchOnDemand() {
 		return nil, errors.New("unknown kid")
 	}
-	return key, nil
+	if err := km.refreshOnDemand(kid); err != nil {
+		log.Error(nil, err, "failed to fetch the public keys on-demand")
+	}
+	if key, ok := km.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown kid")
+}
+
+// refreshOnDemand fetches the keys because of a token signed with the given unknown kid.
+// The conditions are checked again once the fetch lock is held, since the keys may have been fetched by a concurrent
+// request in the meantime: a burst of tokens with unknown kids results in a single fetch per minimum refresh interval.
+func (km *KeyManager) refreshOnDemand(kid string) error {
+	km.fetchMu.Lock()
+	defer km.fetchMu.Unlock()
+	if _, ok := km.lookup(kid); ok || !km.canFetchOnDemand() {
+		return nil
+	}
+	// the kid comes from the token, hence is not logged
+	log.Info(nil, "unknown kid, fetching the public keys again")
+	return km.fetchAndReplace("unknown_kid")
+}
+
+// lookup returns the current or retired (but still within its grace period) key with the given kid
+func (km *KeyManager) lookup(kid string) (*PublicKey, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	if key, ok := km.keyMap[kid]; ok {
+		return key, true
+	}
+	if retired, ok := km.retiredKeys[kid]; ok && km.now().Before(retired.retiredAt.Add(km.retiredKeyGracePeriod)) {
+		return retired.key, true
+	}
+	return nil, false
+}
+
+// canFetchOnDemand returns true if the keys are fetched from a remote endpoint and the last fetch
+// is older than the minimum refresh interval
+func (km *KeyManager) canFetchOnDemand() bool {
+	if km.keysEndpointURL == "" {
+		return false
+	}
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	return km.now().Sub(km.lastFetchedAt) >= km.minRefreshInterval
+}
+
+// Run refreshes the public keys on schedule until the given context is done.
+// The keys are refreshed when they reach the refresh interval, or earlier if the `Cache-Control` or `Expires`
+// headers of the keys endpoint response say so. This function is blocking and should be called in a goroutine.
+func (km *KeyManager) Run(ctx context.Context) {
+	if km.keysEndpointURL == "" {
+		// nothing to refresh
+		return
+	}
+	for {
+		km.mu.RLock()
+		delay := km.nextRefresh.Sub(km.now())
+		km.mu.RUnlock()
+		if delay < km.minRefreshInterval {
+			delay = km.minRefreshInterval
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if err := km.refresh("schedule"); err != nil {
+				// keep the current keys and try again later
+				log.Error(nil, err, "failed to refresh the public keys")
+			}
+		}
+	}
+}
+
+// refresh fetches the keys from the remote endpoint and replaces the current ones.
+// The keys which are no longer part of the key set are retired.
+func (km *KeyManager) refresh(trigger string) error {
+	km.fetchMu.Lock()
+	defer km.fetchMu.Unlock()
+	return km.fetchAndReplace(trigger)
+}
+
+// fetchAndReplace fetches the keys and replaces the current ones. The caller must hold the fetch lock.
+func (km *KeyManager) fetchAndReplace(trigger string) error {
+	km.mu.Lock()
+	km.lastFetchedAt = km.now()
+	km.mu.Unlock()
+
+	keys, maxAge, err := km.fetchKeys(km.currentKeysEndpointURL())
+	if err != nil {
+		km.refreshCounter.WithLabelValues(trigger, "failure").Inc()
+		// try again as soon as allowed
+		km.mu.Lock()
+		km.nextRefresh = km.now().Add(km.minRefreshInterval)
+		km.mu.Unlock()
+		return err
+	}
+	km.refreshCounter.WithLabelValues(trigger, "success").Inc()
+
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	now := km.now()
+	keyMap := make(map[string]*PublicKey, len(keys))
+	for _, key := range keys {
+		keyMap[key.KeyID] = key
+		// in case a retired key is back
+		delete(km.retiredKeys, key.KeyID)
+	}
+	for kid, key := range km.keyMap {
+		if _, found := keyMap[kid]; !found {
+			log.Infof(nil, "public key '%s' was removed from the key set, retiring it", kid)
+			km.retiredKeys[kid] = retiredKey{key: key, retiredAt: now}
+		}
+	}
+	for kid, retired := range km.retiredKeys {
+		if !now.Before(retired.retiredAt.Add(km.retiredKeyGracePeriod)) {
+			delete(km.retiredKeys, kid)
+		}
+	}
+	km.keyMap = keyMap
+	km.fetchedAt = now
+	km.nextRefresh = now.Add(km.refreshLifetime(maxAge))
+	return nil
+}
+
+// currentKeysEndpointURL returns the URL to fetch the keys from: the one discovered again from the provider metadata
+// if the keys endpoint was discovered, or the one given when the KeyManager was created.
+// The caller must hold the fetch lock.
+func (km *KeyManager) currentKeysEndpointURL() string {
+	previous := km.keysEndpointURL
+	if km.discoveredKeysEndpointURL != "" {
+		previous = km.discoveredKeysEndpointURL
+	}
+	if km.discoverKeysEndpointURL == nil {
+		return previous
+	}
+	keysEndpointURL, err := km.discoverKeysEndpointURL()
+	if err != nil {
+		log.Error(nil, err, "failed to discover the public keys URL, using the previous one")
+		return previous
+	}
+	if keysEndpointURL != previous {
+		log.Infof(nil, "the public keys URL of issuer '%s' changed to: %s", km.issuer, keysEndpointURL)
+		km.discoveredKeysEndpointURL = keysEndpointURL
+	}
+	return keysEndpointURL
+}
+
+// refreshLifetime returns how long the fetched keys can be used before being refreshed:
+// the lifetime given by the response headers (if any) within the bounds of the configured refresh intervals.
+func (km *KeyManager) refreshLifetime(maxAge *time.Duration) time.Duration {
+	if maxAge == nil || *maxAge > km.refreshInterval {
+		return km.refreshInterval
+	}
+	if *maxAge < km.minRefreshInterval {
+		return km.minRefreshInterval
+	}
+	return *maxAge
+}
+
+// Collectors returns the Prometheus collectors exposing the state of the public keys
+func (km *KeyManager) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "sandbox_auth_public_keys_age_seconds",
+			Help:        "Time elapsed since the public keys used to validate the tokens were fetched",
+			ConstLabels: issuerLabel(km.issuer),
+		}, func() float64 {
+			km.mu.RLock()
+			defer km.mu.RUnlock()
+			if km.fetchedAt.IsZero() {
+				return 0
+			}
+			return km.now().Sub(km.fetchedAt).Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "sandbox_auth_public_keys",
+			Help:        "Number of public keys used to validate the tokens, including the retired ones",
+			ConstLabels: issuerLabel(km.issuer),
+		}, func() float64 {
+			km.mu.RLock()
+			defer km.mu.RUnlock()
+			return float64(len(km.keyMap) + len(km.retiredKeys))
+		}),
+		km.refreshCounter,
+	}
+}
+
+// issuerLabel returns the label identifying the issuer of the keys in the metrics
+func issuerLabel(issuer string) prometheus.Labels {
+	if issuer == "" {
+		return prometheus.Labels{"issuer": "default"}
+	}
+	return prometheus.Labels{"issuer": issuer}
 }
 
 // unmarshalKeys unmarshals keys from given JSON.
@@ -97,7 +359,9 @@ func (km *KeyManager) unmarshalKeys(jsonData []byte) ([]*PublicKey, error) {
 		}
 		publicKey, err := km.unmarshalKey(jsonKeyData)
 		if err != nil {
-			return nil, err
+			// do not reject the whole key set because of a single key (eg, of an unsupported type)
+			log.Error(nil, err, "ignoring public key")
+			continue
 		}
 		keys = append(keys, publicKey)
 	}
@@ -111,11 +375,13 @@ func (km *KeyManager) unmarshalKey(jsonData []byte) (*PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	rsaKey, ok := key.Key.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("Key is not an *rsa.PublicKey")
+	switch key.Key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return &PublicKey{KeyID: key.KeyID, Key: key.Key, Algorithm: key.Algorithm}, nil
+	default:
+		// symmetric and private keys are never accepted
+		return nil, fmt.Errorf("key '%s' has an unsupported type: %T", key.KeyID, key.Key)
 	}
-	return &PublicKey{key.KeyID, rsaKey}, nil
 }
 
 // unmarshalls the keys from a byte array.
@@ -131,24 +397,17 @@ func (km *KeyManager) fetchKeysFromBytes(keysBytes []byte) ([]*PublicKey, error)
 }
 
 // fetchKeys fetches the keys from the given URL, unmarshalling them.
-func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
+// It also returns the max age of the keys, if the response specified it.
+func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, *time.Duration, error) {
 	// use httpClient to perform request
-	transport := http.DefaultTransport
-	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // nolint:gosec
-			},
-		}
-	}
-	httpClient := &http.Client{Transport: transport}
+	httpClient := newHTTPClient()
 	req, err := http.NewRequest("GET", keysEndpointURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	res, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// cleanup and close after being done
 	defer func() {
@@ -165,7 +424,7 @@ func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
 	buf := new(bytes.Buffer)
 	_, err = buf.ReadFrom(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	bodyString := buf.String()
 	// if status code was not OK, bail out
@@ -176,8 +435,59 @@ func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
 			"response_body":   bodyString,
 			"keys_url":        keysEndpointURL,
 		}).Error(nil, err, "")
-		return nil, err
+		return nil, nil, err
 	}
 	// unmarshal the keys
-	return km.fetchKeysFromBytes([]byte(bodyString))
+	keys, err := km.fetchKeysFromBytes([]byte(bodyString))
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, maxAge(res.Header, km.now()), nil
+}
+
+// newHTTPClient returns the client used to call the SSO endpoints.
+// TLS certificates are not verified outside of the production environment.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport
+	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
+		transport = &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true, // nolint:gosec
+			},
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// maxAge returns the lifetime of the response given by its `Cache-Control` header,
+// or by its `Expires` header if there is no `max-age` directive.
+// Returns nil if the response headers do not specify any lifetime.
+func maxAge(header http.Header, now time.Time) *time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			noCache := time.Duration(0)
+			return &noCache
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil && seconds >= 0 {
+				age := time.Duration(seconds) * time.Second
+				return &age
+			}
+		}
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			// an invalid date means that the response is already expired
+			expiresAt = now
+		}
+		age := expiresAt.Sub(now)
+		if age < 0 {
+			age = 0
+		}
+		return &age
+	}
+	return nil
 }
@@ -1,11 +1,14 @@
 package auth_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
@@ -18,6 +21,8 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -298,3 +303,208 @@ func (s *TestKeyManagerSuite) TestE2EKeyFetching() {
 		checkE2EKeysNotFound()
 	})
 }
+
+func (s *TestKeyManagerSuite) TestKeyRotation() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	setup := func(settings map[string]string) (*authsupport.TokenManager, *httptest.Server) {
+		tokengenerator := authsupport.NewTokenManager()
+		_, err := tokengenerator.AddPrivateKey("initial")
+		require.NoError(s.T(), err)
+		keyServer := tokengenerator.NewKeyServer()
+		s.T().Cleanup(keyServer.Close)
+
+		s.SetSettings(settings, testconfig.RegistrationService().
+			Environment(configuration.UnitTestsEnvironment).
+			Auth().AuthClientPublicKeysURL(keyServer.URL))
+		return tokengenerator, keyServer
+	}
+
+	s.Run("unknown kid triggers a fetch", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.minRefreshInterval": "0s",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key("rotated")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("on-demand fetches are rate limited", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.minRefreshInterval": "1h",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key("rotated")
+
+		// then
+		require.EqualError(s.T(), err, "unknown kid")
+	})
+
+	s.Run("concurrent on-demand fetches are coalesced", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.minRefreshInterval": "500ms",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(keyManager.Collectors()...)
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+		time.Sleep(500 * time.Millisecond) // wait until an on-demand fetch is allowed
+
+		// when
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = keyManager.Key(fmt.Sprintf("unknown-%d", i))
+			}(i)
+		}
+		wg.Wait()
+
+		// then
+		families, err := reg.Gather()
+		require.NoError(s.T(), err)
+		onDemandFetches := 0.0
+		for _, family := range families {
+			if family.GetName() != "sandbox_auth_public_keys_refresh_total" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "trigger" && l.GetValue() == "unknown_kid" {
+						onDemandFetches += m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		assert.InDelta(s.T(), float64(1), onDemandFetches, 0.01)
+		_, err = keyManager.Key("rotated")
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("retired keys are accepted during the grace period", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.minRefreshInterval":    "0s",
+			"auth.publicKeys.retiredKeyGracePeriod": "1h",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		tokengenerator.RemovePrivateKey("initial")
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key("rotated")
+
+		// then
+		require.NoError(s.T(), err)
+		_, err = keyManager.Key("initial")
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("retired keys are rejected after the grace period", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.minRefreshInterval":    "0s",
+			"auth.publicKeys.retiredKeyGracePeriod": "0s",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		tokengenerator.RemovePrivateKey("initial")
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key("rotated")
+
+		// then
+		require.NoError(s.T(), err)
+		_, err = keyManager.Key("initial")
+		require.EqualError(s.T(), err, "unknown kid")
+	})
+
+	s.Run("keys are refreshed on schedule", func() {
+		// given
+		tokengenerator, _ := setup(map[string]string{
+			"auth.publicKeys.refreshInterval":    "10ms",
+			"auth.publicKeys.minRefreshInterval": "10ms",
+		})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(keyManager.Collectors()...)
+		// the key is rotated before the refresh loop starts, since the key server of the token generator is not safe
+		// for concurrent use
+		_, err = tokengenerator.AddPrivateKey("rotated")
+		require.NoError(s.T(), err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// when
+		go keyManager.Run(ctx)
+
+		// then
+		assert.Eventually(s.T(), func() bool {
+			return promtestutil.CollectAndCount(keyManager.Collectors()[2], "sandbox_auth_public_keys_refresh_total") == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Eventually(s.T(), func() bool {
+			_, err := keyManager.Key("rotated")
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		count, err := promtestutil.GatherAndCount(reg, "sandbox_auth_public_keys")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, count)
+	})
+
+	s.Run("cache-control header is honored", func() {
+		// given
+		tokengenerator := authsupport.NewTokenManager()
+		_, err := tokengenerator.AddPrivateKey("initial")
+		require.NoError(s.T(), err)
+		keyServer := tokengenerator.NewKeyServer()
+		defer keyServer.Close()
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "public, max-age=0")
+			keyServer.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+		// the refresh interval is long enough to not trigger any refresh if the header was ignored
+		s.SetSettings(map[string]string{
+			"auth.publicKeys.refreshInterval":    "1h",
+			"auth.publicKeys.minRefreshInterval": "10ms",
+		}, testconfig.RegistrationService().
+			Environment(configuration.UnitTestsEnvironment).
+			Auth().AuthClientPublicKeysURL(ts.URL))
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// when
+		go keyManager.Run(ctx)
+
+		// then
+		assert.Eventually(s.T(), func() bool {
+			return promtestutil.ToFloat64(keyManager.Collectors()[2].(*prometheus.CounterVec).WithLabelValues("schedule", "success")) > 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
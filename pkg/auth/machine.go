@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ScopeUsernamesRead allows the principal to check whether a username exists
+	ScopeUsernamesRead = "usernames:read"
+	// ScopeSignupsRead allows the principal to get the signup of any user
+	ScopeSignupsRead = "signups:read"
+)
+
+const (
+	// PrincipalKindClient is the kind of the principals authenticated with a token issued to an SSO client
+	PrincipalKindClient = "client"
+	// PrincipalKindServiceAccount is the kind of the principals authenticated with a Kubernetes ServiceAccount token
+	PrincipalKindServiceAccount = "serviceaccount"
+)
+
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// Principal represents a non-human caller of the registration service API, such as an automation tool.
+type Principal struct {
+	// Name is the ID of the client or the username of the ServiceAccount
+	Name string
+	// Kind is either PrincipalKindClient or PrincipalKindServiceAccount
+	Kind string
+	// Scopes are the operations that the principal is allowed to perform
+	Scopes []string
+}
+
+// HasScope returns true if the principal is allowed to perform the operations of the given scope
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// TokenReviewer verifies the Kubernetes ServiceAccount tokens and returns the information about their user.
+type TokenReviewer interface {
+	Review(ctx context.Context, token string, audiences []string) (*authenticationv1.UserInfo, error)
+}
+
+type kubernetesTokenReviewer struct {
+	client client.Client
+}
+
+// NewTokenReviewer returns a TokenReviewer which creates a TokenReview with the given client
+func NewTokenReviewer(cl client.Client) TokenReviewer {
+	return &kubernetesTokenReviewer{
+		client: cl,
+	}
+}
+
+func (r *kubernetesTokenReviewer) Review(ctx context.Context, token string, audiences []string) (*authenticationv1.UserInfo, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: audiences,
+		},
+	}
+	if err := r.client.Create(ctx, review); err != nil {
+		return nil, fmt.Errorf("unable to review the token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("token not authenticated: %s", review.Status.Error)
+	}
+	return &review.Status.User, nil
+}
+
+// MachineAuthenticator authenticates the calls made by the clients with a token obtained via the client credentials grant,
+// and by the Kubernetes ServiceAccounts if enabled in the configuration.
+// Only the principals listed in the configuration are accepted, with the scopes they are granted there.
+type MachineAuthenticator struct {
+	tokenParser       *TokenParser
+	tokenReviewer     TokenReviewer
+	revocationChecker *RevocationChecker
+}
+
+// NewMachineAuthenticator creates a new MachineAuthenticator
+func NewMachineAuthenticator(tokenParser *TokenParser, tokenReviewer TokenReviewer, revocationChecker *RevocationChecker) *MachineAuthenticator {
+	return &MachineAuthenticator{
+		tokenParser:       tokenParser,
+		tokenReviewer:     tokenReviewer,
+		revocationChecker: revocationChecker,
+	}
+}
+
+// Authenticate returns the principal identified by the given token,
+// or an error if the token is not valid or if the principal is not allowed to call the machine API.
+func (a *MachineAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	principalsCfg, err := cfg.MachinePrincipals()
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := a.authenticate(ctx, cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	for _, principalCfg := range principalsCfg {
+		if principalCfg.Name == principal.Name {
+			principal.Scopes = principalCfg.Scopes
+			return principal, nil
+		}
+	}
+	return nil, fmt.Errorf("%s '%s' is not allowed to call the API", principal.Kind, principal.Name)
+}
+
+func (a *MachineAuthenticator) authenticate(ctx context.Context, cfg configuration.AuthConfig, token string) (*Principal, error) {
+	claims, err := a.tokenParser.ClientFromString(token)
+	if err == nil {
+		if err := a.revocationChecker.CheckToken(ctx, token, claims); err != nil {
+			return nil, err
+		}
+		return &Principal{
+			Name: claims.Client(),
+			Kind: PrincipalKindClient,
+		}, nil
+	}
+	if !cfg.ServiceAccountTokenReview() {
+		return nil, err
+	}
+
+	// the token was not issued by a trusted issuer, it may be a ServiceAccount token
+	userInfo, reviewErr := a.tokenReviewer.Review(ctx, token, cfg.ServiceAccountTokenAudiences())
+	if reviewErr != nil {
+		return nil, errors.Join(err, reviewErr)
+	}
+	if !strings.HasPrefix(userInfo.Username, serviceAccountUsernamePrefix) {
+		return nil, fmt.Errorf("token not issued to a ServiceAccount: '%s'", userInfo.Username)
+	}
+	return &Principal{
+		Name: userInfo.Username,
+		Kind: PrincipalKindServiceAccount,
+	}, nil
+}
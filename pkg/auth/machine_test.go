@@ -0,0 +1,224 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+type TestMachineAuthenticatorSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunMachineAuthenticatorSuite(t *testing.T) {
+	suite.Run(t, &TestMachineAuthenticatorSuite{test.UnitTestSuite{}})
+}
+
+// fakeTokenReviewer authenticates the tokens which are keys of its users
+type fakeTokenReviewer struct {
+	users     map[string]string
+	audiences []string
+}
+
+func (r *fakeTokenReviewer) Review(_ context.Context, token string, audiences []string) (*authenticationv1.UserInfo, error) {
+	r.audiences = audiences
+	username, found := r.users[token]
+	if !found {
+		return nil, errors.New("token not authenticated: invalid bearer token")
+	}
+	return &authenticationv1.UserInfo{Username: username}, nil
+}
+
+func (s *TestMachineAuthenticatorSuite) TestAuthenticate() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// create the keys of the issuer
+	tokenGenerator := authsupport.NewTokenManager()
+	_, err := tokenGenerator.AddPrivateKey("sso")
+	require.NoError(s.T(), err)
+	keyServer := tokenGenerator.NewKeyServer()
+	defer keyServer.Close()
+	issuer, err := auth.NewIssuer(configuration.TokenIssuerConfig{
+		Issuer:        "https://sso.devsandbox.dev/auth/realms/sandbox-dev",
+		PublicKeysURL: keyServer.URL,
+	})
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParserForIssuers(issuer)
+	require.NoError(s.T(), err)
+
+	clientToken := func(claims jwt.MapClaims) string {
+		token, err := tokenGenerator.GenerateSignedToken(authsupport.Identity{ID: uuid.New()}, "sso", func(token *jwt.Token) {
+			claims["iss"] = "https://sso.devsandbox.dev/auth/realms/sandbox-dev"
+			claims["exp"] = time.Now().Add(time.Hour).Unix()
+			claims["jti"] = uuid.NewString()
+			token.Claims = claims
+		})
+		require.NoError(s.T(), err)
+		return token
+	}
+	tokenReviewer := &fakeTokenReviewer{
+		users: map[string]string{
+			"sa-token":   "system:serviceaccount:events:event-tooling",
+			"user-token": "john",
+		},
+	}
+	authenticator := auth.NewMachineAuthenticator(tokenParser, tokenReviewer,
+		auth.NewRevocationChecker(namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)))
+	principals := `[
+		{"name": "onboarding-bot", "scopes": ["usernames:read", "signups:read"]},
+		{"name": "system:serviceaccount:events:event-tooling", "scopes": ["signups:read"]}
+	]`
+
+	s.Run("client credentials token", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.machinePrincipals": principals,
+		})
+
+		s.Run("with client_id claim", func() {
+			// when
+			principal, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{"client_id": "onboarding-bot", "azp": "other"}))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), &auth.Principal{
+				Name:   "onboarding-bot",
+				Kind:   auth.PrincipalKindClient,
+				Scopes: []string{"usernames:read", "signups:read"},
+			}, principal)
+			assert.True(s.T(), principal.HasScope(auth.ScopeUsernamesRead))
+		})
+
+		s.Run("with azp claim", func() {
+			// when
+			principal, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{"azp": "onboarding-bot"}))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "onboarding-bot", principal.Name)
+		})
+
+		s.Run("with the username of the client service account", func() {
+			// when
+			principal, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{
+				"azp":                "onboarding-bot",
+				"preferred_username": "service-account-onboarding-bot",
+			}))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "onboarding-bot", principal.Name)
+		})
+
+		s.Run("user token issued to an allowed client", func() {
+			// when
+			_, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{
+				"azp":                "onboarding-bot",
+				"preferred_username": "johnsmith",
+				"email":              "johnsmith@redhat.com",
+			}))
+
+			// then
+			require.EqualError(s.T(), err, "token does not comply to expected claims: not issued via the client credentials grant")
+		})
+
+		s.Run("client not allowed", func() {
+			// when
+			_, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{"client_id": "sandbox-public"}))
+
+			// then
+			require.EqualError(s.T(), err, "client 'sandbox-public' is not allowed to call the API")
+		})
+
+		s.Run("no client id", func() {
+			// when
+			_, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{}))
+
+			// then
+			require.EqualError(s.T(), err, "token does not comply to expected claims: client id missing")
+		})
+
+		s.Run("no principal configured", func() {
+			// given
+			s.SetSettings(map[string]string{})
+
+			// when
+			_, err := authenticator.Authenticate(context.TODO(), clientToken(jwt.MapClaims{"client_id": "onboarding-bot"}))
+
+			// then
+			require.EqualError(s.T(), err, "client 'onboarding-bot' is not allowed to call the API")
+		})
+	})
+
+	s.Run("service account token", func() {
+		s.Run("token review disabled", func() {
+			// given
+			s.SetSettings(map[string]string{
+				"auth.machinePrincipals": principals,
+			})
+
+			// when
+			_, err := authenticator.Authenticate(context.TODO(), "sa-token")
+
+			// then
+			require.EqualError(s.T(), err, "token is malformed: token contains an invalid number of segments")
+		})
+
+		s.Run("token review enabled", func() {
+			// given
+			s.SetSettings(map[string]string{
+				"auth.machinePrincipals":            principals,
+				"auth.serviceAccountTokenReview":    "true",
+				"auth.serviceAccountTokenAudiences": "registration-service",
+			})
+
+			s.Run("service account allowed", func() {
+				// when
+				principal, err := authenticator.Authenticate(context.TODO(), "sa-token")
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), &auth.Principal{
+					Name:   "system:serviceaccount:events:event-tooling",
+					Kind:   auth.PrincipalKindServiceAccount,
+					Scopes: []string{"signups:read"},
+				}, principal)
+				assert.False(s.T(), principal.HasScope(auth.ScopeUsernamesRead))
+				assert.Equal(s.T(), []string{"registration-service"}, tokenReviewer.audiences)
+			})
+
+			s.Run("not a service account", func() {
+				// when
+				_, err := authenticator.Authenticate(context.TODO(), "user-token")
+
+				// then
+				require.EqualError(s.T(), err, "token not issued to a ServiceAccount: 'john'")
+			})
+
+			s.Run("token not authenticated", func() {
+				// when
+				_, err := authenticator.Authenticate(context.TODO(), "unknown-token")
+
+				// then
+				require.ErrorContains(s.T(), err, "token not authenticated: invalid bearer token")
+			})
+		})
+	})
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/lru"
+)
+
+const (
+	introspectionTimeout = 5 * time.Second
+	// introspectionCacheMaxSize is the maximum number of cached introspection results, the least recently used ones being evicted
+	introspectionCacheMaxSize = 1000
+	// denylistCacheTTL is how long the denylist of tokens is kept in memory before being read again
+	denylistCacheTTL = 10 * time.Second
+)
+
+var (
+	// ErrTokenRevoked is returned when the token has been revoked
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrRevocationCheckUnavailable is returned when the revocation status of the token could not be determined,
+	// ie, when the denylist or the token introspection endpoint is not available
+	ErrRevocationCheckUnavailable = errors.New("unable to check whether the token has been revoked")
+)
+
+// RevocationChecker checks whether the tokens have been revoked, using the RFC 7662 token introspection endpoint
+// and/or the local denylist of token IDs (`jti` claim) stored in a ConfigMap in the host operator namespace.
+// Both are optional and enabled in the configuration.
+// The ConfigMap is read directly from the API server (it is not part of the client cache) and kept in memory
+// for a few seconds, hence a revocation takes effect within denylistCacheTTL.
+type RevocationChecker struct {
+	client namespaced.Client
+
+	mu                sync.Mutex
+	denylistName      string
+	denylist          map[string]string
+	denylistFetchedAt time.Time
+}
+
+// NewRevocationChecker creates a new RevocationChecker which reads the denylist of tokens with the given client
+func NewRevocationChecker(client namespaced.Client) *RevocationChecker {
+	return &RevocationChecker{
+		client: client,
+	}
+}
+
+// CheckToken returns ErrTokenRevoked if the given token has been revoked,
+// or an error wrapping ErrRevocationCheckUnavailable if the revocation status of the token could not be determined.
+func (c *RevocationChecker) CheckToken(ctx context.Context, rawToken string, claims *TokenClaims) error {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	if err := c.checkDenylist(ctx, cfg.RevokedTokensConfigMap(), claims.ID); err != nil {
+		return err
+	}
+	if cfg.TokenIntrospectionURL() == "" {
+		return nil
+	}
+	active, err := introspect(ctx, cfg, rawToken, claims)
+	if err != nil {
+		if cfg.TokenIntrospectionFailOpen() {
+			log.Error(nil, err, "unable to introspect the token, accepting it anyway")
+			return nil
+		}
+		return fmt.Errorf("%w: %w", ErrRevocationCheckUnavailable, err)
+	}
+	if !active {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// checkDenylist returns ErrTokenRevoked if the given token ID is a key of the ConfigMap with the given name
+func (c *RevocationChecker) checkDenylist(ctx context.Context, configMapName, tokenID string) error {
+	if configMapName == "" || tokenID == "" {
+		return nil
+	}
+	denylist, err := c.getDenylist(ctx, configMapName)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get the list of revoked tokens: %w", ErrRevocationCheckUnavailable, err)
+	}
+	if _, revoked := denylist[tokenID]; revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// getDenylist returns the data of the ConfigMap with the given name, read again once older than denylistCacheTTL.
+// A missing ConfigMap is an empty denylist.
+func (c *RevocationChecker) getDenylist(ctx context.Context, configMapName string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.denylistName == configMapName && time.Since(c.denylistFetchedAt) < denylistCacheTTL {
+		return c.denylist, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.client.Get(ctx, c.client.NamespacedName(configMapName), cm); err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	c.denylistName = configMapName
+	c.denylist = cm.Data
+	c.denylistFetchedAt = time.Now()
+	return c.denylist, nil
+}
+
+type introspectionResult struct {
+	active    bool
+	expiresAt time.Time
+}
+
+var introspectionCache = lru.New(introspectionCacheMaxSize)
+
+// introspect returns whether the given token is active according to the token introspection endpoint.
+// The result is cached for the configured TTL, but never beyond the expiration of the token.
+func introspect(ctx context.Context, cfg configuration.AuthConfig, rawToken string, claims *TokenClaims) (bool, error) {
+	// do not keep the tokens themselves in memory
+	sum := sha256.Sum256([]byte(rawToken))
+	cacheKey := hex.EncodeToString(sum[:])
+	now := time.Now()
+
+	if cached, found := introspectionCache.Get(cacheKey); found {
+		if result := cached.(introspectionResult); now.Before(result.expiresAt) {
+			return result.active, nil
+		}
+	}
+
+	active, err := requestIntrospection(ctx, cfg, rawToken)
+	if err != nil {
+		return false, err
+	}
+	expiresAt := now.Add(cfg.TokenIntrospectionCacheTTL())
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	introspectionCache.Add(cacheKey, introspectionResult{
+		active:    active,
+		expiresAt: expiresAt,
+	})
+	return active, nil
+}
+
+// requestIntrospection sends the given token to the token introspection endpoint (see https://www.rfc-editor.org/rfc/rfc7662)
+func requestIntrospection(ctx context.Context, cfg configuration.AuthConfig, rawToken string) (bool, error) {
+	form := url.Values{}
+	form.Set("token", rawToken)
+	form.Set("token_type_hint", "access_token")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenIntrospectionURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.TokenIntrospectionClientID() != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.TokenIntrospectionClientID()), url.QueryEscape(cfg.TokenIntrospectionClientSecret()))
+	}
+	httpClient := newHTTPClient()
+	httpClient.Timeout = introspectionTimeout
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, res.Body)
+		if err := res.Body.Close(); err != nil {
+			log.Error(nil, err, "failed to close response after reading")
+		}
+	}()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected response from the token introspection endpoint: %s", res.Status)
+	}
+	result := struct {
+		Active bool `json:"active"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("invalid response from the token introspection endpoint: %w", err)
+	}
+	return result.Active, nil
+}
@@ -0,0 +1,329 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/test"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestRevocationCheckerSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunRevocationCheckerSuite(t *testing.T) {
+	suite.Run(t, &TestRevocationCheckerSuite{test.UnitTestSuite{}})
+}
+
+// newIntrospectionEndpoint starts a mock token introspection endpoint which returns the given status of the tokens.
+// The returned counter is incremented every time a token is introspected.
+func (s *TestRevocationCheckerSuite) newIntrospectionEndpoint(active map[string]bool) (*httptest.Server, *atomic.Int32) {
+	requests := &atomic.Int32{}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "registration-service" || clientSecret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(s.T(), "access_token", r.FormValue("token_type_hint"))
+		err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"active": active[r.FormValue("token")],
+		})
+		assert.NoError(s.T(), err)
+	}))
+	s.T().Cleanup(endpoint.Close)
+	return endpoint, requests
+}
+
+func newClaims(jti string) *auth.TokenClaims {
+	return &auth.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func (s *TestRevocationCheckerSuite) TestDenylist() {
+	fakeClient := commontest.NewFakeClient(s.T(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "revoked-tokens",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string]string{
+			"revoked-jti": "stolen laptop",
+		},
+	})
+	checker := auth.NewRevocationChecker(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+	s.Run("not configured", func() {
+		// given
+		s.SetSettings(map[string]string{})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims("revoked-jti"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("token revoked", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims("revoked-jti"))
+
+		// then
+		require.ErrorIs(s.T(), err, auth.ErrTokenRevoked)
+	})
+
+	s.Run("token not revoked", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims(uuid.NewString()))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("token without jti", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims(""))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("configmap does not exist", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "unknown",
+		})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims("revoked-jti"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("configmap cannot be read", func() {
+		// given
+		fakeClient := commontest.NewFakeClient(s.T())
+		fakeClient.MockGet = func(_ context.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+			return errors.New("mock error")
+		}
+		checker := auth.NewRevocationChecker(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		})
+
+		// when
+		err := checker.CheckToken(context.TODO(), "token", newClaims("revoked-jti"))
+
+		// then
+		require.ErrorIs(s.T(), err, auth.ErrRevocationCheckUnavailable)
+		require.EqualError(s.T(), err, "unable to check whether the token has been revoked: unable to get the list of revoked tokens: mock error")
+	})
+
+	s.Run("configmap is kept in memory", func() {
+		// given
+		fakeClient := commontest.NewFakeClient(s.T(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "revoked-tokens",
+				Namespace: commontest.HostOperatorNs,
+			},
+		})
+		gets := 0
+		fakeClient.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			gets++
+			return fakeClient.Client.Get(ctx, key, obj, opts...)
+		}
+		checker := auth.NewRevocationChecker(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+		s.SetSettings(map[string]string{
+			"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		})
+
+		// when
+		for i := 0; i < 3; i++ {
+			err := checker.CheckToken(context.TODO(), "token", newClaims(uuid.NewString()))
+			require.NoError(s.T(), err)
+		}
+
+		// then
+		assert.Equal(s.T(), 1, gets)
+	})
+}
+
+func (s *TestRevocationCheckerSuite) TestIntrospection() {
+	checker := auth.NewRevocationChecker(namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs))
+	activeToken := uuid.NewString()
+	revokedToken := uuid.NewString()
+	endpoint, requests := s.newIntrospectionEndpoint(map[string]bool{
+		activeToken: true,
+	})
+	settings := map[string]string{
+		"auth.revocation.introspectionURL":          endpoint.URL,
+		"auth.revocation.introspectionClientID":     "registration-service",
+		"auth.revocation.introspectionClientSecret": "s3cr3t",
+	}
+
+	s.Run("active token", func() {
+		// given
+		s.SetSettings(settings)
+		requests.Store(0)
+
+		// when
+		err := checker.CheckToken(context.TODO(), activeToken, newClaims(uuid.NewString()))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), int32(1), requests.Load())
+
+		s.Run("result is cached", func() {
+			// when
+			err := checker.CheckToken(context.TODO(), activeToken, newClaims(uuid.NewString()))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), int32(1), requests.Load())
+		})
+	})
+
+	s.Run("revoked token", func() {
+		// given
+		s.SetSettings(settings)
+		requests.Store(0)
+
+		// when
+		err := checker.CheckToken(context.TODO(), revokedToken, newClaims(uuid.NewString()))
+
+		// then
+		require.ErrorIs(s.T(), err, auth.ErrTokenRevoked)
+		assert.Equal(s.T(), int32(1), requests.Load())
+	})
+
+	s.Run("result is not cached beyond the expiration of the token", func() {
+		// given
+		s.SetSettings(settings)
+		requests.Store(0)
+		token := uuid.NewString()
+		claims := newClaims(uuid.NewString())
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Second))
+
+		// when
+		_ = checker.CheckToken(context.TODO(), token, claims)
+		_ = checker.CheckToken(context.TODO(), token, claims)
+
+		// then
+		assert.Equal(s.T(), int32(2), requests.Load())
+	})
+
+	s.Run("least recently used results are evicted", func() {
+		// given
+		s.SetSettings(settings)
+		first := uuid.NewString()
+		_ = checker.CheckToken(context.TODO(), first, newClaims(uuid.NewString()))
+		for i := 0; i < 1000; i++ {
+			_ = checker.CheckToken(context.TODO(), uuid.NewString(), newClaims(uuid.NewString()))
+		}
+		requests.Store(0)
+
+		// when
+		_ = checker.CheckToken(context.TODO(), first, newClaims(uuid.NewString()))
+
+		// then
+		assert.Equal(s.T(), int32(1), requests.Load())
+	})
+
+	s.Run("introspection fails", func() {
+		failingSettings := map[string]string{
+			"auth.revocation.introspectionURL":          endpoint.URL,
+			"auth.revocation.introspectionClientID":     "registration-service",
+			"auth.revocation.introspectionClientSecret": "wrong",
+		}
+
+		s.Run("token is rejected by default", func() {
+			// given
+			s.SetSettings(failingSettings)
+
+			// when
+			err := checker.CheckToken(context.TODO(), uuid.NewString(), newClaims(uuid.NewString()))
+
+			// then
+			require.ErrorIs(s.T(), err, auth.ErrRevocationCheckUnavailable)
+			require.EqualError(s.T(), err, "unable to check whether the token has been revoked: unexpected response from the token introspection endpoint: 401 Unauthorized")
+		})
+
+		s.Run("token is accepted when failing open", func() {
+			// given
+			failingSettings["auth.revocation.introspectionFailOpen"] = "true"
+			s.SetSettings(failingSettings)
+
+			// when
+			err := checker.CheckToken(context.TODO(), uuid.NewString(), newClaims(uuid.NewString()))
+
+			// then
+			require.NoError(s.T(), err)
+		})
+	})
+
+	s.Run("denylist is checked before introspection", func() {
+		// given
+		fakeClient := commontest.NewFakeClient(s.T(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "revoked-tokens",
+				Namespace: commontest.HostOperatorNs,
+			},
+			Data: map[string]string{
+				"revoked-jti": "",
+			},
+		})
+		checker := auth.NewRevocationChecker(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+		s.SetSettings(map[string]string{
+			"auth.revocation.introspectionURL":          endpoint.URL,
+			"auth.revocation.introspectionClientID":     "registration-service",
+			"auth.revocation.introspectionClientSecret": "s3cr3t",
+			"auth.revocation.revokedTokensConfigMap":    "revoked-tokens",
+		})
+		requests.Store(0)
+
+		// when
+		err := checker.CheckToken(context.TODO(), activeToken, newClaims("revoked-jti"))
+
+		// then
+		require.ErrorIs(s.T(), err, auth.ErrTokenRevoked)
+		assert.Equal(s.T(), int32(0), requests.Load())
+	})
+}
@@ -1,14 +1,28 @@
 package auth
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-const leeway = 5 * time.Second
+const (
+	leeway = 5 * time.Second
+	// clientServiceAccountUsernamePrefix is the prefix of the username of the Keycloak service account of a client,
+	// ie, of the `preferred_username` claim of the tokens obtained by the client via the client credentials grant
+	clientServiceAccountUsernamePrefix = "service-account-"
+)
 
 // TokenClaims represents access token claims
 type TokenClaims struct {
@@ -23,35 +37,161 @@ type TokenClaims struct {
 	UserID            string `json:"user_id"`
 	AccountID         string `json:"account_id"`
 	AccountNumber     string `json:"account_number,omitempty"`
+	ClientID          string `json:"client_id,omitempty"`
+	AuthorizedParty   string `json:"azp,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Client returns the ID of the client the token was issued to, ie, the `client_id` claim or the `azp` claim if there is none.
+func (c *TokenClaims) Client() string {
+	if c.ClientID != "" {
+		return c.ClientID
+	}
+	return c.AuthorizedParty
+}
+
+// isClientCredentials returns true if the token was obtained by the client for itself via the client credentials grant,
+// as opposed to a token issued to a user (which also has an `azp` claim): either there is no username at all,
+// or the username is the one of the service account of the client.
+func (c *TokenClaims) isClientCredentials() bool {
+	return c.PreferredUsername == "" || strings.EqualFold(c.PreferredUsername, clientServiceAccountUsernamePrefix+c.Client())
+}
+
 // TokenParser represents a parser for JWT tokens.
 type TokenParser struct {
-	keyManager *KeyManager
+	// defaultIssuer, when set, accepts the tokens regardless of their `iss` claim
+	defaultIssuer     *Issuer
+	issuers           map[string]*Issuer
+	signingAlgorithms []string
 }
 
-// NewTokenParser creates a new TokenParser.
+// NewTokenParser creates a new TokenParser which accepts the tokens signed with the keys of the given KeyManager,
+// regardless of their issuer.
 func NewTokenParser(keyManager *KeyManager) (*TokenParser, error) {
 	if keyManager == nil {
 		return nil, errors.New("no keyManager given when creating TokenParser")
 	}
 	return &TokenParser{
-		keyManager: keyManager,
+		defaultIssuer:     &Issuer{keyManager: keyManager},
+		signingAlgorithms: configuration.GetRegistrationServiceConfig().Auth().AuthClientSigningAlgorithms(),
 	}, nil
 }
 
-// FromString parses a JWT, validates the signature and returns the claims struct.
+// NewTokenParserForIssuers creates a new TokenParser which only accepts the tokens from the given issuers.
+func NewTokenParserForIssuers(issuers ...*Issuer) (*TokenParser, error) {
+	if len(issuers) == 0 {
+		return nil, errors.New("no issuer given when creating TokenParser")
+	}
+	tp := &TokenParser{
+		issuers:           make(map[string]*Issuer, len(issuers)),
+		signingAlgorithms: configuration.GetRegistrationServiceConfig().Auth().AuthClientSigningAlgorithms(),
+	}
+	for _, issuer := range issuers {
+		if _, exists := tp.issuers[issuer.name]; exists {
+			return nil, fmt.Errorf("duplicate issuer '%s' given when creating TokenParser", issuer.name)
+		}
+		tp.issuers[issuer.name] = issuer
+	}
+	return tp, nil
+}
+
+// keyManagers returns the KeyManagers of all the issuers
+func (tp *TokenParser) keyManagers() []*KeyManager {
+	var keyManagers []*KeyManager
+	if tp.defaultIssuer != nil {
+		keyManagers = append(keyManagers, tp.defaultIssuer.keyManager)
+	}
+	for _, issuer := range tp.issuers {
+		keyManagers = append(keyManagers, issuer.keyManager)
+	}
+	return keyManagers
+}
+
+// Run refreshes the public keys of all the issuers on schedule until the given context is done.
+// This function is non-blocking.
+func (tp *TokenParser) Run(ctx context.Context) {
+	for _, keyManager := range tp.keyManagers() {
+		go keyManager.Run(ctx)
+	}
+}
+
+// Collectors returns the Prometheus collectors exposing the state of the public keys of all the issuers
+func (tp *TokenParser) Collectors() []prometheus.Collector {
+	var collectors []prometheus.Collector
+	for _, keyManager := range tp.keyManagers() {
+		collectors = append(collectors, keyManager.Collectors()...)
+	}
+	return collectors
+}
+
+// issuer returns the trusted issuer with the given name
+func (tp *TokenParser) issuer(name string) (*Issuer, error) {
+	if tp.defaultIssuer != nil {
+		return tp.defaultIssuer, nil
+	}
+	if issuer, found := tp.issuers[name]; found {
+		return issuer, nil
+	}
+	return nil, fmt.Errorf("token issued by an untrusted issuer: '%s'", name)
+}
+
+// FromString parses a JWT issued to a user, validates the signature and returns the claims struct.
 func (tp *TokenParser) FromString(jwtEncoded string) (*TokenClaims, error) {
+	claims, err := tp.parse(jwtEncoded)
+	if err != nil {
+		return nil, err
+	}
+	// we need username and email, so check if those are contained in the claims
+	if claims.PreferredUsername == "" {
+		return nil, errors.New("token does not comply to expected claims: username missing")
+	}
+	if claims.Email == "" {
+		return nil, errors.New("token does not comply to expected claims: email missing")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token does not comply to expected claims: subject missing")
+	}
+	return claims, nil
+}
+
+// ClientFromString parses a JWT issued to a client (eg, via the client credentials grant),
+// validates the signature and returns the claims struct.
+func (tp *TokenParser) ClientFromString(jwtEncoded string) (*TokenClaims, error) {
+	claims, err := tp.parse(jwtEncoded)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Client() == "" {
+		return nil, errors.New("token does not comply to expected claims: client id missing")
+	}
+	if !claims.isClientCredentials() {
+		return nil, errors.New("token does not comply to expected claims: not issued via the client credentials grant")
+	}
+	return claims, nil
+}
+
+// parse parses a JWT, validates the signature, the issuer and the audience, and returns the claims struct.
+func (tp *TokenParser) parse(jwtEncoded string) (*TokenClaims, error) {
+	var issuer *Issuer
 	token, err := jwt.ParseWithClaims(
 		jwtEncoded,
-		&TokenClaims{},
+		jwt.MapClaims{},
 		func(token *jwt.Token) (interface{}, error) {
 			// validate the alg is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			if !slices.Contains(tp.signingAlgorithms, token.Method.Alg()) {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 
+			// find the issuer of the token
+			iss, err := token.Claims.GetIssuer()
+			if err != nil {
+				return nil, err
+			}
+			issuer, err = tp.issuer(iss)
+			if err != nil {
+				return nil, err
+			}
+
 			kid := token.Header["kid"]
 			if kid == nil {
 				return nil, errors.New("no key id given in the token")
@@ -60,30 +200,58 @@ func (tp *TokenParser) FromString(jwtEncoded string) (*TokenClaims, error) {
 			if !ok {
 				return nil, errors.New("given key id has unknown type")
 			}
-			// get the public key for kid from keyManager
-			publicKey, err := tp.keyManager.Key(kidStr)
+			// get the public key for kid from the keyManager of the issuer
+			publicKey, err := issuer.keyManager.PublicKey(kidStr)
 			if err != nil {
 				return nil, err
 			}
-			return publicKey, nil
+			// make sure that the key is used with the algorithm it is intended for,
+			// to prevent algorithm confusion attacks
+			if err := checkKeyMatchesSigningMethod(publicKey, token.Method); err != nil {
+				return nil, err
+			}
+			return publicKey.Key, nil
 		},
 		jwt.WithLeeway(leeway),
 	)
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
-		// we need username and email, so check if those are contained in the claims
-		if claims.PreferredUsername == "" {
-			return nil, errors.New("token does not comply to expected claims: username missing")
-		}
-		if claims.Email == "" {
-			return nil, errors.New("token does not comply to expected claims: email missing")
-		}
-		if claims.Subject == "" {
-			return nil, errors.New("token does not comply to expected claims: subject missing")
-		}
-		return claims, nil
+	rawClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("token does not comply to expected claims")
+	}
+	claims, err := issuer.tokenClaims(rawClaims)
+	if err != nil {
+		return nil, fmt.Errorf("token does not comply to expected claims: %w", err)
+	}
+	if !issuer.acceptsAudience(claims.Audience) {
+		return nil, errors.New("token does not comply to expected claims: audience not accepted")
+	}
+	return claims, nil
+}
+
+// checkKeyMatchesSigningMethod verifies that the type (and the curve, for ECDSA keys) of the given key matches
+// the signing method, and that the key is not restricted to another algorithm.
+func checkKeyMatchesSigningMethod(publicKey *PublicKey, method jwt.SigningMethod) error {
+	if publicKey.Algorithm != "" && publicKey.Algorithm != method.Alg() {
+		return fmt.Errorf("key '%s' is intended for the %s signing method, not %s", publicKey.KeyID, publicKey.Algorithm, method.Alg())
+	}
+	var ok bool
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		_, ok = publicKey.Key.(*rsa.PublicKey)
+	case *jwt.SigningMethodECDSA:
+		var key *ecdsa.PublicKey
+		key, ok = publicKey.Key.(*ecdsa.PublicKey)
+		ok = ok && key.Curve.Params().BitSize == m.CurveBits
+	case *jwt.SigningMethodEd25519:
+		_, ok = publicKey.Key.(ed25519.PublicKey)
+	default:
+		return fmt.Errorf("unexpected signing method: %v", method.Alg())
+	}
+	if !ok {
+		return fmt.Errorf("key '%s' cannot be used with the %s signing method", publicKey.KeyID, method.Alg())
 	}
-	return nil, errors.New("token does not comply to expected claims")
+	return nil
 }
@@ -1,6 +1,14 @@
 package auth_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -19,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/go-jose/go-jose.v2"
 )
 
 type TestTokenParserSuite struct {
@@ -396,3 +405,295 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		require.Equal(s.T(), "123456789", claims.AccountNumber)
 	})
 }
+
+func (s *TestTokenParserSuite) TestTokenParserSigningAlgorithms() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// create test keys of various types
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(s.T(), err)
+	ed25519PublicKey, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(s.T(), err)
+
+	// startup public key service
+	keySet := jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{KeyID: "rsa", Key: &rsaKey.PublicKey},
+			{KeyID: "p256", Key: &p256Key.PublicKey},
+			{KeyID: "p256-es256", Key: &p256Key.PublicKey, Algorithm: "ES256"},
+			{KeyID: "p384", Key: &p384Key.PublicKey},
+			{KeyID: "ed25519", Key: ed25519PublicKey},
+			{KeyID: "hmac", Key: []byte("secret")},
+		},
+	}
+	keyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(keySet)
+		assert.NoError(s.T(), err)
+	}))
+	defer keyServer.Close()
+
+	newTokenParser := func(signingAlgorithms string) *auth.TokenParser {
+		s.SetSettings(map[string]string{
+			"auth.signingAlgorithms": signingAlgorithms,
+		}, testconfig.RegistrationService().
+			Environment(configuration.UnitTestsEnvironment).
+			Auth().AuthClientPublicKeysURL(keyServer.URL))
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+		return tokenParser
+	}
+
+	signToken := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, &auth.TokenClaims{
+			PreferredUsername: "johnsmith",
+			Email:             "johnsmith@redhat.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   uuid.NewString(),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(s.T(), err)
+		return signed
+	}
+
+	s.Run("default signing algorithms", func() {
+		tokenParser := newTokenParser("")
+
+		for name, tc := range map[string]struct {
+			method jwt.SigningMethod
+			kid    string
+			key    interface{}
+		}{
+			"RS256":                                {method: jwt.SigningMethodRS256, kid: "rsa", key: rsaKey},
+			"PS512":                                {method: jwt.SigningMethodPS512, kid: "rsa", key: rsaKey},
+			"ES256":                                {method: jwt.SigningMethodES256, kid: "p256", key: p256Key},
+			"ES256 with a key restricted to ES256": {method: jwt.SigningMethodES256, kid: "p256-es256", key: p256Key},
+			"ES384":                                {method: jwt.SigningMethodES384, kid: "p384", key: p384Key},
+			"EdDSA":                                {method: jwt.SigningMethodEdDSA, kid: "ed25519", key: ed25519Key},
+		} {
+			s.Run(name, func() {
+				// when
+				claims, err := tokenParser.FromString(signToken(tc.method, tc.kid, tc.key))
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "johnsmith", claims.PreferredUsername)
+			})
+		}
+	})
+
+	s.Run("key does not match signing method", func() {
+		tokenParser := newTokenParser("")
+
+		for name, tc := range map[string]struct {
+			method      jwt.SigningMethod
+			kid         string
+			key         interface{}
+			expectedErr string
+		}{
+			"ES256 with RSA key": {
+				method:      jwt.SigningMethodES256,
+				kid:         "rsa",
+				key:         p256Key,
+				expectedErr: "token is unverifiable: error while executing keyfunc: key 'rsa' cannot be used with the ES256 signing method",
+			},
+			"ES256 with P-384 key": {
+				method:      jwt.SigningMethodES256,
+				kid:         "p384",
+				key:         p256Key,
+				expectedErr: "token is unverifiable: error while executing keyfunc: key 'p384' cannot be used with the ES256 signing method",
+			},
+			"RS256 with Ed25519 key": {
+				method:      jwt.SigningMethodRS256,
+				kid:         "ed25519",
+				key:         rsaKey,
+				expectedErr: "token is unverifiable: error while executing keyfunc: key 'ed25519' cannot be used with the RS256 signing method",
+			},
+			"ES384 with a key restricted to ES256": {
+				method:      jwt.SigningMethodES384,
+				kid:         "p256-es256",
+				key:         p384Key,
+				expectedErr: "token is unverifiable: error while executing keyfunc: key 'p256-es256' is intended for the ES256 signing method, not ES384",
+			},
+			"HS256 with symmetric key": {
+				method:      jwt.SigningMethodHS256,
+				kid:         "hmac",
+				key:         []byte("secret"),
+				expectedErr: "token is unverifiable: error while executing keyfunc: unexpected signing method: HS256",
+			},
+		} {
+			s.Run(name, func() {
+				// when
+				_, err := tokenParser.FromString(signToken(tc.method, tc.kid, tc.key))
+
+				// then
+				require.EqualError(s.T(), err, tc.expectedErr)
+			})
+		}
+	})
+
+	s.Run("symmetric keys are ignored", func() {
+		newTokenParser("") // configures the URL of the key set
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key("hmac")
+
+		// then
+		require.EqualError(s.T(), err, "unknown kid")
+	})
+
+	s.Run("signing algorithm not allowed", func() {
+		tokenParser := newTokenParser("RS256, EdDSA")
+
+		// when
+		_, err := tokenParser.FromString(signToken(jwt.SigningMethodES256, "p256", p256Key))
+
+		// then
+		require.EqualError(s.T(), err, "token is unverifiable: error while executing keyfunc: unexpected signing method: ES256")
+
+		// other algorithms are still allowed
+		_, err = tokenParser.FromString(signToken(jwt.SigningMethodEdDSA, "ed25519", ed25519Key))
+		require.NoError(s.T(), err)
+	})
+}
+
+func (s *TestTokenParserSuite) TestTokenParserIssuers() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// create the keys of the issuers
+	publicTokenGenerator := authsupport.NewTokenManager()
+	_, err := publicTokenGenerator.AddPrivateKey("public")
+	require.NoError(s.T(), err)
+	publicKeyServer := publicTokenGenerator.NewKeyServer()
+	defer publicKeyServer.Close()
+	ciTokenGenerator := authsupport.NewTokenManager()
+	_, err = ciTokenGenerator.AddPrivateKey("ci")
+	require.NoError(s.T(), err)
+	ciKeyServer := ciTokenGenerator.NewKeyServer()
+	defer ciKeyServer.Close()
+
+	publicIssuer, err := auth.NewIssuer(configuration.TokenIssuerConfig{
+		Issuer:        "https://sso.devsandbox.dev/auth/realms/sandbox-dev",
+		PublicKeysURL: publicKeyServer.URL,
+		Audiences:     []string{"sandbox-public"},
+	})
+	require.NoError(s.T(), err)
+	ciIssuer, err := auth.NewIssuer(configuration.TokenIssuerConfig{
+		Issuer:        "https://ci.devsandbox.dev",
+		PublicKeysURL: ciKeyServer.URL,
+		ClaimMappings: map[string]string{
+			"preferred_username": "client_id",
+			"email":              "contact",
+		},
+	})
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParserForIssuers(publicIssuer, ciIssuer)
+	require.NoError(s.T(), err)
+
+	signToken := func(tokenGenerator *authsupport.TokenManager, kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := tokenGenerator.SignToken(token, kid)
+		require.NoError(s.T(), err)
+		return signed
+	}
+	publicClaims := func(iss string, aud ...string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                iss,
+			"aud":                aud,
+			"sub":                uuid.NewString(),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"preferred_username": "johnsmith",
+			"email":              "johnsmith@redhat.com",
+		}
+	}
+
+	s.Run("invalid arguments to new", func() {
+		_, err := auth.NewTokenParserForIssuers()
+		require.EqualError(s.T(), err, "no issuer given when creating TokenParser")
+		_, err = auth.NewTokenParserForIssuers(publicIssuer, publicIssuer)
+		require.EqualError(s.T(), err, "duplicate issuer 'https://sso.devsandbox.dev/auth/realms/sandbox-dev' given when creating TokenParser")
+	})
+
+	s.Run("token from trusted issuer", func() {
+		// when
+		claims, err := tokenParser.FromString(signToken(publicTokenGenerator, "public",
+			publicClaims("https://sso.devsandbox.dev/auth/realms/sandbox-dev", "sandbox-public")))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "johnsmith", claims.PreferredUsername)
+		assert.Equal(s.T(), "johnsmith@redhat.com", claims.Email)
+	})
+
+	s.Run("token from trusted issuer with claim mappings", func() {
+		// when
+		claims, err := tokenParser.FromString(signToken(ciTokenGenerator, "ci", jwt.MapClaims{
+			"iss":                "https://ci.devsandbox.dev",
+			"sub":                uuid.NewString(),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"client_id":          "onboarding-bot",
+			"contact":            "bots@redhat.com",
+			"preferred_username": "ignored",
+		}))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "onboarding-bot", claims.PreferredUsername)
+		assert.Equal(s.T(), "bots@redhat.com", claims.Email)
+	})
+
+	s.Run("token with mapped claim missing", func() {
+		// when
+		_, err := tokenParser.FromString(signToken(ciTokenGenerator, "ci", jwt.MapClaims{
+			"iss":                "https://ci.devsandbox.dev",
+			"sub":                uuid.NewString(),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"contact":            "bots@redhat.com",
+			"preferred_username": "ignored",
+		}))
+
+		// then
+		require.EqualError(s.T(), err, "token does not comply to expected claims: username missing")
+	})
+
+	s.Run("token from untrusted issuer", func() {
+		// when
+		_, err := tokenParser.FromString(signToken(publicTokenGenerator, "public",
+			publicClaims("https://evil.com", "sandbox-public")))
+
+		// then
+		require.EqualError(s.T(), err, "token is unverifiable: error while executing keyfunc: token issued by an untrusted issuer: 'https://evil.com'")
+	})
+
+	s.Run("token signed with the key of another issuer", func() {
+		// when
+		_, err := tokenParser.FromString(signToken(publicTokenGenerator, "public",
+			publicClaims("https://ci.devsandbox.dev")))
+
+		// then
+		require.EqualError(s.T(), err, "token is unverifiable: error while executing keyfunc: unknown kid")
+	})
+
+	s.Run("token with unexpected audience", func() {
+		// when
+		_, err := tokenParser.FromString(signToken(publicTokenGenerator, "public",
+			publicClaims("https://sso.devsandbox.dev/auth/realms/sandbox-dev", "another-client")))
+
+		// then
+		require.EqualError(s.T(), err, "token does not comply to expected claims: audience not accepted")
+	})
+}
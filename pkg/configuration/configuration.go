@@ -3,11 +3,15 @@
 package configuration
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
@@ -44,12 +48,116 @@ const (
 	UnitTestsEnvironment = "unit-tests"
 )
 
-// captcha specific configuration
+// HTTPProtocols returns the protocols accepted by the registration service and proxy servers: HTTP/2 lets the clients
+// multiplex their requests and watch streams on a single connection, including in cleartext (with prior knowledge)
+// when the TLS is terminated in front of the servers. HTTP/1.1 is still needed for the WebSocket and SPDY upgrades.
+func HTTPProtocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return protocols
+}
+
+// verification code specific configuration
 const (
-	CaptchaFileName = "captcha.json"
-	CaptchaFilePath = "/tmp/" + CaptchaFileName
+	defaultVerificationCodeLength  = 6
+	defaultVerificationCodeCharset = "0123456789"
+	// minVerificationCodeCombinations is the minimum number of possible values of the verification codes
+	minVerificationCodeCombinations = 1_000_000
+)
 
+// captcha specific configuration
+const (
 	defaultScoreThreshold float32 = 0.9
+
+	// captcha providers
+	CaptchaProviderRecaptcha = "recaptcha"
+	CaptchaProviderHCaptcha  = "hcaptcha"
+	CaptchaProviderTurnstile = "turnstile"
+	CaptchaProviderFake      = "fake"
+)
+
+// keys of the settings stored in the registration service secret
+const (
+	authPublicKeysRefreshIntervalKey       = "auth.publicKeys.refreshInterval"
+	authPublicKeysMinRefreshIntervalKey    = "auth.publicKeys.minRefreshInterval"
+	authPublicKeysRetiredKeyGracePeriodKey = "auth.publicKeys.retiredKeyGracePeriod"
+	authSigningAlgorithmsKey               = "auth.signingAlgorithms"
+	authTokenIssuersKey                    = "auth.tokenIssuers"
+	authOIDCIssuerURLKey                   = "auth.oidcIssuerURL"
+	authOIDCDiscoveryRefreshIntervalKey    = "auth.oidcDiscoveryRefreshInterval"
+	authIntrospectionURLKey                = "auth.revocation.introspectionURL"
+	authIntrospectionClientIDKey           = "auth.revocation.introspectionClientID"
+	authIntrospectionClientSecretKey       = "auth.revocation.introspectionClientSecret"
+	authIntrospectionCacheTTLKey           = "auth.revocation.introspectionCacheTTL"
+	authIntrospectionFailOpenKey           = "auth.revocation.introspectionFailOpen"
+	authRevokedTokensConfigMapKey          = "auth.revocation.revokedTokensConfigMap"
+	authMachinePrincipalsKey               = "auth.machinePrincipals"
+	authServiceAccountTokenReviewKey       = "auth.serviceAccountTokenReview"
+	authServiceAccountTokenAudiencesKey    = "auth.serviceAccountTokenAudiences"
+	notificationSendersKey                 = "verification.notificationSenders"
+	notificationSenderCountryOverridesKey  = "verification.notificationSenderCountryOverrides"
+	verificationMethodsKey                 = "verification.methods"
+	smtpHostKey                            = "verification.smtp.host"
+	smtpPortKey                            = "verification.smtp.port"
+	smtpUsernameKey                        = "verification.smtp.username"
+	smtpPasswordKey                        = "verification.smtp.password"
+	smtpFromKey                            = "verification.smtp.from"
+	emailSubjectKey                        = "verification.email.subject"
+	emailMessageTemplateKey                = "verification.email.messageTemplate"
+	emailMagicLinkURLKey                   = "verification.email.magicLinkURL"
+	emailMagicLinkSigningKeyKey            = "verification.email.magicLinkSigningKey"
+	emailMagicLinkExpiresInKey             = "verification.email.magicLinkExpiresIn"
+	captchaProviderKey                     = "verification.captcha.provider"
+	captchaSecretKeyKey                    = "verification.captcha.secretKey"
+	captchaVerifyURLKey                    = "verification.captcha.verifyURL"
+	captchaFakeScoreKey                    = "verification.captcha.fakeScore"
+	rateLimitWindowKey                     = "verification.rateLimit.window"
+	rateLimitClientIPKey                   = "verification.rateLimit.clientIP"
+	rateLimitPhoneNumberKey                = "verification.rateLimit.phoneNumber"
+	rateLimitCountryCodeKey                = "verification.rateLimit.countryCode"
+	rateLimitGlobalPerMinuteKey            = "verification.rateLimit.globalPerMinute"
+	rateLimitMaxCountersKey                = "verification.rateLimit.maxCounters"
+	phoneAllowedCountriesKey               = "verification.phone.allowedCountries"
+	phoneDeniedCountriesKey                = "verification.phone.deniedCountries"
+	phoneBlockedNumberTypesKey             = "verification.phone.blockedNumberTypes"
+	verificationCodeHashKeyKey             = "verification.codeHashKey"
+	verificationCodeLengthKey              = "verification.code.length"
+	verificationCodeCharsetKey             = "verification.code.charset"
+	phoneChannelsKey                       = "verification.phone.channels"
+	voiceDailyLimitKey                     = "verification.voice.dailyLimit"
+	voiceMessageTemplateKey                = "verification.voice.messageTemplate"
+	deliveryStatusCallbackURLKey           = "verification.deliveryStatus.callbackURL"
+	deliveryStatusSNSTopicARNsKey          = "verification.deliveryStatus.snsTopicARNs"
+	eventsWebhooksKey                      = "events.webhooks"
+	eventsSourceKey                        = "events.source"
+	eventsOutboxSizeKey                    = "events.outboxSize"
+	eventsMaxRetriesKey                    = "events.maxRetries"
+	eventsRetryBaseDelayKey                = "events.retryBaseDelay"
+	eventsRetryMaxDelayKey                 = "events.retryMaxDelay"
+	signupStreamHeartbeatIntervalKey       = "signup.stream.heartbeatInterval"
+	signupStreamMaxDurationKey             = "signup.stream.maxDuration"
+	signupDeactivationConfirmationTTLKey   = "signup.deactivation.confirmationTTL"
+	waitlistEnabledKey                     = "signup.waitlist.enabled"
+	waitlistEstimatedWaitPerPositionKey    = "signup.waitlist.estimatedWaitPerPosition"
+	invitationsMaxPerUserKey               = "signup.invitations.maxPerUser"
+	invitationsTTLKey                      = "signup.invitations.ttl"
+	proxyAuthorizationPoliciesConfigMapKey = "proxy.authorizationPoliciesConfigMap"
+	serverTrustedProxiesKey                = "server.trustedProxies"
+)
+
+// verification methods
+const (
+	VerificationMethodPhone = "phone"
+	VerificationMethodEmail = "email"
+)
+
+// channels used to send the verification codes
+const (
+	VerificationChannelSMS   = "sms"
+	VerificationChannelVoice = "voice"
+	VerificationChannelEmail = "email"
 )
 
 var configurationClient client.Client
@@ -128,7 +236,19 @@ func (r RegistrationServiceConfig) Analytics() AnalyticsConfig {
 }
 
 func (r RegistrationServiceConfig) Auth() AuthConfig {
-	return AuthConfig{r.cfg.Host.RegistrationService.Auth}
+	return AuthConfig{c: r.cfg.Host.RegistrationService.Auth, settings: r.settings()}
+}
+
+func (r RegistrationServiceConfig) Events() EventsConfig {
+	return EventsConfig{settings: r.settings()}
+}
+
+func (r RegistrationServiceConfig) Waitlist() WaitlistConfig {
+	return WaitlistConfig{c: r.cfg.Host.AutomaticApproval, settings: r.settings()}
+}
+
+func (r RegistrationServiceConfig) Invitations() InvitationsConfig {
+	return InvitationsConfig{settings: r.settings()}
 }
 
 func (r RegistrationServiceConfig) LogLevel() string {
@@ -140,7 +260,37 @@ func (r RegistrationServiceConfig) RegistrationServiceURL() string {
 }
 
 func (r RegistrationServiceConfig) Verification() VerificationConfig {
-	return VerificationConfig{c: r.cfg.Host.RegistrationService.Verification, secrets: r.secrets}
+	return VerificationConfig{c: r.cfg.Host.RegistrationService.Verification, secrets: r.secrets, settings: r.settings()}
+}
+
+// SignupStreamHeartbeatInterval is the interval between the heartbeats sent in the streams of the signup status,
+// so that the idle connections are not closed by the proxies
+func (r RegistrationServiceConfig) SignupStreamHeartbeatInterval() time.Duration {
+	return r.settings().getDuration(signupStreamHeartbeatIntervalKey, 15*time.Second)
+}
+
+// SignupStreamMaxDuration is the maximum duration of a stream of the signup status. The clients reconnect after that.
+func (r RegistrationServiceConfig) SignupStreamMaxDuration() time.Duration {
+	return r.settings().getDuration(signupStreamMaxDurationKey, 10*time.Minute)
+}
+
+// SignupDeactivationConfirmationTTL is the duration during which the users can confirm the deactivation of their account
+// with the confirmation token returned when they request it
+func (r RegistrationServiceConfig) SignupDeactivationConfirmationTTL() time.Duration {
+	return r.settings().getDuration(signupDeactivationConfirmationTTLKey, 10*time.Minute)
+}
+
+// ProxyAuthorizationPoliciesConfigMap is the name of the ConfigMap in the host operator namespace containing the policies
+// which allow or deny the requests sent to the workspaces through the proxy. If empty, there is no policy.
+func (r RegistrationServiceConfig) ProxyAuthorizationPoliciesConfigMap() string {
+	return r.settings().getString(proxyAuthorizationPoliciesConfigMapKey, "")
+}
+
+// TrustedProxies are the IP addresses or CIDRs of the proxies in front of the server (eg, the ingress routers), whose
+// `X-Forwarded-For` and `X-Real-IP` headers are trusted to obtain the IP address of the client.
+// If empty, then no proxy is trusted, and the IP address of the client is the remote address of the connection.
+func (r RegistrationServiceConfig) TrustedProxies() []string {
+	return r.settings().getStringList(serverTrustedProxiesKey, nil)
 }
 
 func (r RegistrationServiceConfig) UICanaryDeploymentWeight() int {
@@ -151,6 +301,12 @@ func (r RegistrationServiceConfig) WorkatoWebHookURL() string {
 	return commonconfig.GetString(r.cfg.Host.RegistrationService.WorkatoWebHookURL, "")
 }
 
+// settings returns the settings stored in the registration service secret
+func (r RegistrationServiceConfig) settings() settings {
+	secret := commonconfig.GetString(r.cfg.Host.RegistrationService.Verification.Secret.Ref, "")
+	return r.secrets[secret]
+}
+
 type AnalyticsConfig struct {
 	c toolchainv1alpha1.RegistrationServiceAnalyticsConfig
 }
@@ -164,7 +320,8 @@ func (r AnalyticsConfig) DevSpacesSegmentWriteKey() string {
 }
 
 type AuthConfig struct {
-	c toolchainv1alpha1.RegistrationServiceAuthConfig
+	c        toolchainv1alpha1.RegistrationServiceAuthConfig
+	settings settings
 }
 
 func (r AuthConfig) AuthClientLibraryURL() string {
@@ -183,6 +340,152 @@ func (r AuthConfig) AuthClientPublicKeysURL() string {
 	return commonconfig.GetString(r.c.AuthClientPublicKeysURL, "https://sso.devsandbox.dev/auth/realms/sandbox-dev/protocol/openid-connect/certs")
 }
 
+// AuthClientPublicKeysRefreshInterval is the maximum age of the public keys before they are fetched again
+func (r AuthConfig) AuthClientPublicKeysRefreshInterval() time.Duration {
+	return r.settings.getDuration(authPublicKeysRefreshIntervalKey, 30*time.Minute)
+}
+
+// AuthClientPublicKeysMinRefreshInterval is the minimum delay between two fetches of the public keys,
+// which also applies when the keys are fetched on-demand because of a token signed with an unknown key
+func (r AuthConfig) AuthClientPublicKeysMinRefreshInterval() time.Duration {
+	return r.settings.getDuration(authPublicKeysMinRefreshIntervalKey, 30*time.Second)
+}
+
+// AuthClientPublicKeysRetiredKeyGracePeriod is how long the public keys which were removed from the key set
+// are still accepted, so that the tokens which were signed before a key rotation remain valid
+func (r AuthConfig) AuthClientPublicKeysRetiredKeyGracePeriod() time.Duration {
+	return r.settings.getDuration(authPublicKeysRetiredKeyGracePeriodKey, time.Hour)
+}
+
+// AuthClientSigningAlgorithms is the list of the algorithms that the tokens can be signed with
+func (r AuthConfig) AuthClientSigningAlgorithms() []string {
+	return r.settings.getStringList(authSigningAlgorithmsKey, []string{
+		"RS256", "RS384", "RS512",
+		"PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512",
+		"EdDSA",
+	})
+}
+
+// TokenIssuerConfig contains the configuration of a trusted token issuer
+type TokenIssuerConfig struct {
+	// Issuer is the expected value of the `iss` claim of the tokens
+	Issuer string `json:"issuer"`
+	// PublicKeysURL is the URL of the JWKS endpoint of the issuer.
+	// If empty, it is obtained via OpenID Connect Discovery.
+	PublicKeysURL string `json:"publicKeysURL,omitempty"`
+	// Audiences are the accepted values of the `aud` claim. If empty, the `aud` claim is not checked.
+	Audiences []string `json:"audiences,omitempty"`
+	// ClaimMappings maps the name of a claim expected by the registration service (eg, `preferred_username`)
+	// to the name of the claim holding the value in the tokens of this issuer (eg, `username`)
+	ClaimMappings map[string]string `json:"claimMappings,omitempty"`
+}
+
+// TokenIssuers returns the list of trusted token issuers, stored as a JSON array in the registration service secret.
+// If no issuers are configured, then the tokens signed with the keys from AuthClientPublicKeysURL are accepted
+// regardless of their `iss` and `aud` claims.
+func (r AuthConfig) TokenIssuers() ([]TokenIssuerConfig, error) {
+	raw := r.settings.getString(authTokenIssuersKey, "")
+	if raw == "" {
+		return nil, nil
+	}
+	var issuers []TokenIssuerConfig
+	if err := json.Unmarshal([]byte(raw), &issuers); err != nil {
+		return nil, fmt.Errorf("invalid token issuers configuration: %w", err)
+	}
+	for _, issuer := range issuers {
+		if issuer.Issuer == "" {
+			return nil, fmt.Errorf("invalid token issuers configuration: issuer is required")
+		}
+	}
+	return issuers, nil
+}
+
+// OIDCIssuerURL is the URL of the OpenID provider. When set, the public keys URL, the authorization endpoint
+// and the token endpoint are obtained via OpenID Connect Discovery, instead of the AuthClientPublicKeysURL,
+// SSOBaseURL and SSORealm settings.
+func (r AuthConfig) OIDCIssuerURL() string {
+	return r.settings.getString(authOIDCIssuerURLKey, "")
+}
+
+// OIDCDiscoveryRefreshInterval is the maximum age of the OpenID provider metadata before it is fetched again
+func (r AuthConfig) OIDCDiscoveryRefreshInterval() time.Duration {
+	return r.settings.getDuration(authOIDCDiscoveryRefreshIntervalKey, time.Hour)
+}
+
+// TokenIntrospectionURL is the URL of the RFC 7662 token introspection endpoint used to check that the tokens
+// have not been revoked. If empty, the tokens are not introspected.
+func (r AuthConfig) TokenIntrospectionURL() string {
+	return r.settings.getString(authIntrospectionURLKey, "")
+}
+
+// TokenIntrospectionClientID is the ID of the client used to authenticate against the token introspection endpoint
+func (r AuthConfig) TokenIntrospectionClientID() string {
+	return r.settings.getString(authIntrospectionClientIDKey, "")
+}
+
+// TokenIntrospectionClientSecret is the secret of the client used to authenticate against the token introspection endpoint
+func (r AuthConfig) TokenIntrospectionClientSecret() string {
+	return r.settings.getString(authIntrospectionClientSecretKey, "")
+}
+
+// TokenIntrospectionCacheTTL is how long the result of a token introspection is cached
+func (r AuthConfig) TokenIntrospectionCacheTTL() time.Duration {
+	return r.settings.getDuration(authIntrospectionCacheTTLKey, time.Minute)
+}
+
+// TokenIntrospectionFailOpen specifies whether the tokens are accepted when the token introspection endpoint
+// cannot be reached. By default, such tokens are rejected.
+func (r AuthConfig) TokenIntrospectionFailOpen() bool {
+	return r.settings.getBool(authIntrospectionFailOpenKey, false)
+}
+
+// RevokedTokensConfigMap is the name of the ConfigMap in the host operator namespace listing the IDs (`jti` claim)
+// of the revoked tokens as its keys. If empty, there is no local denylist of tokens.
+func (r AuthConfig) RevokedTokensConfigMap() string {
+	return r.settings.getString(authRevokedTokensConfigMapKey, "")
+}
+
+// MachinePrincipalConfig contains the configuration of a client or a ServiceAccount allowed to call the machine API
+type MachinePrincipalConfig struct {
+	// Name is the ID of the client (`client_id` or `azp` claim of its tokens),
+	// or the username of the ServiceAccount (eg, `system:serviceaccount:<namespace>:<name>`)
+	Name string `json:"name"`
+	// Scopes are the operations that the principal is allowed to perform (eg, `usernames:read`)
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// MachinePrincipals returns the list of clients and ServiceAccounts allowed to call the machine API,
+// stored as a JSON array in the registration service secret.
+func (r AuthConfig) MachinePrincipals() ([]MachinePrincipalConfig, error) {
+	raw := r.settings.getString(authMachinePrincipalsKey, "")
+	if raw == "" {
+		return nil, nil
+	}
+	var principals []MachinePrincipalConfig
+	if err := json.Unmarshal([]byte(raw), &principals); err != nil {
+		return nil, fmt.Errorf("invalid machine principals configuration: %w", err)
+	}
+	for _, principal := range principals {
+		if principal.Name == "" {
+			return nil, fmt.Errorf("invalid machine principals configuration: name is required")
+		}
+	}
+	return principals, nil
+}
+
+// ServiceAccountTokenReview specifies whether the Kubernetes ServiceAccount tokens are accepted by the machine API,
+// in which case they are verified with a TokenReview
+func (r AuthConfig) ServiceAccountTokenReview() bool {
+	return r.settings.getBool(authServiceAccountTokenReviewKey, false)
+}
+
+// ServiceAccountTokenAudiences are the audiences that the ServiceAccount tokens must be issued for.
+// If empty, the audience of the Kubernetes API server is expected.
+func (r AuthConfig) ServiceAccountTokenAudiences() []string {
+	return r.settings.getStringList(authServiceAccountTokenAudiencesKey, nil)
+}
+
 func (r AuthConfig) SSOBaseURL() string {
 	return commonconfig.GetString(r.c.SSOBaseURL, "https://sso.devsandbox.dev")
 }
@@ -191,9 +494,115 @@ func (r AuthConfig) SSORealm() string {
 	return commonconfig.GetString(r.c.SSORealm, "sandbox-dev")
 }
 
+// EventsConfig contains the configuration of the signup lifecycle events sent to the webhook sinks
+type EventsConfig struct {
+	settings settings
+}
+
+// EventWebhookConfig contains the configuration of a webhook receiving the signup lifecycle events
+type EventWebhookConfig struct {
+	// URL is the endpoint the events are posted to
+	URL string `json:"url"`
+	// Secret is the key of the HMAC-SHA256 signature of the deliveries
+	Secret string `json:"secret"`
+	// Types are the types of the events sent to this webhook. If empty, all the events are sent.
+	Types []string `json:"types,omitempty"`
+}
+
+// Accepts returns true if the events of the given type are sent to this webhook
+func (w EventWebhookConfig) Accepts(eventType string) bool {
+	return len(w.Types) == 0 || slices.Contains(w.Types, eventType)
+}
+
+// Webhooks returns the webhooks receiving the signup lifecycle events, stored as a JSON array in the registration service secret.
+// The events are not published if no webhooks are configured.
+func (r EventsConfig) Webhooks() ([]EventWebhookConfig, error) {
+	raw := r.settings.getString(eventsWebhooksKey, "")
+	if raw == "" {
+		return nil, nil
+	}
+	var webhooks []EventWebhookConfig
+	if err := json.Unmarshal([]byte(raw), &webhooks); err != nil {
+		return nil, fmt.Errorf("invalid events webhooks configuration: %w", err)
+	}
+	for _, webhook := range webhooks {
+		if webhook.URL == "" || webhook.Secret == "" {
+			return nil, fmt.Errorf("invalid events webhooks configuration: url and secret are required")
+		}
+	}
+	return webhooks, nil
+}
+
+// Source is the `source` attribute of the events, which identifies the instance of the registration service. Defaults to `registration-service`.
+func (r EventsConfig) Source() string {
+	return r.settings.getString(eventsSourceKey, "registration-service")
+}
+
+// OutboxSize is the maximum number of deliveries waiting to be sent or retried. The new events are dropped when the outbox is full.
+func (r EventsConfig) OutboxSize() int {
+	return r.settings.getInt(eventsOutboxSizeKey, 1000)
+}
+
+// MaxRetries is the number of times a failed delivery is retried before it's abandoned
+func (r EventsConfig) MaxRetries() int {
+	return r.settings.getInt(eventsMaxRetriesKey, 8)
+}
+
+// RetryBaseDelay is the delay before the first retry of a failed delivery. It's doubled after each attempt, up to RetryMaxDelay.
+func (r EventsConfig) RetryBaseDelay() time.Duration {
+	return r.settings.getDuration(eventsRetryBaseDelayKey, time.Second)
+}
+
+// RetryMaxDelay is the maximum delay between two attempts of a delivery
+func (r EventsConfig) RetryMaxDelay() time.Duration {
+	return r.settings.getDuration(eventsRetryMaxDelayKey, 5*time.Minute)
+}
+
+// WaitlistConfig contains the configuration of the waitlist of the signups, which are put on it when the member clusters are full
+type WaitlistConfig struct {
+	c        toolchainv1alpha1.AutomaticApprovalConfig
+	settings settings
+}
+
+// Enabled returns true if the new signups are put on the waitlist when the capacity thresholds of the SpaceProvisionerConfigs
+// of all the enabled member clusters are exceeded
+func (r WaitlistConfig) Enabled() bool {
+	return r.settings.getBool(waitlistEnabledKey, false)
+}
+
+// AdmitInOrder returns true if the registration service approves the users of the waitlist in order as soon as there is
+// enough capacity for them, ie, if the host operator approves all the new signups automatically. Otherwise, the users of
+// the waitlist are still approved manually.
+func (r WaitlistConfig) AdmitInOrder() bool {
+	return commonconfig.GetBool(r.c.Enabled, false) && commonconfig.GetString(r.c.Domains, "") == ""
+}
+
+// EstimatedWaitPerPosition is the average time it takes to admit one user of the waitlist, used to estimate the wait
+// of the users depending on their position
+func (r WaitlistConfig) EstimatedWaitPerPosition() time.Duration {
+	return r.settings.getDuration(waitlistEstimatedWaitPerPositionKey, 10*time.Minute)
+}
+
+// InvitationsConfig contains the configuration of the invitation codes created by the users to invite other users
+type InvitationsConfig struct {
+	settings settings
+}
+
+// MaxPerUser is the default number of invitations a user can create, which can be overridden per user with an annotation
+// on their UserSignup. The invitations which expired without being redeemed don't count.
+func (r InvitationsConfig) MaxPerUser() int {
+	return r.settings.getInt(invitationsMaxPerUserKey, 5)
+}
+
+// TTL is the duration during which an invitation code can be redeemed
+func (r InvitationsConfig) TTL() time.Duration {
+	return r.settings.getDuration(invitationsTTLKey, 7*24*time.Hour)
+}
+
 type VerificationConfig struct {
-	c       toolchainv1alpha1.RegistrationServiceVerificationConfig
-	secrets map[string]map[string]string
+	c        toolchainv1alpha1.RegistrationServiceVerificationConfig
+	secrets  map[string]map[string]string
+	settings settings
 }
 
 func (r VerificationConfig) registrationServiceSecret(secretKey string) string {
@@ -229,10 +638,211 @@ func (r VerificationConfig) CodeExpiresInMin() int {
 	return commonconfig.GetInt(r.c.CodeExpiresInMin, 5)
 }
 
+// VerificationCodeLength is the number of characters of the verification codes. Defaults to 6.
+func (r VerificationConfig) VerificationCodeLength() int {
+	length, _ := r.verificationCodeFormat()
+	return length
+}
+
+// VerificationCodeCharset contains the characters of the verification codes. Defaults to the digits.
+// The characters are upper-cased and deduplicated, since the codes are compared case-insensitively.
+func (r VerificationConfig) VerificationCodeCharset() string {
+	_, charset := r.verificationCodeFormat()
+	return charset
+}
+
+// verificationCodeFormat returns the configured length and characters of the verification codes, or the defaults
+// if the codes would have fewer than minVerificationCodeCombinations possible values
+func (r VerificationConfig) verificationCodeFormat() (int, string) {
+	length := r.settings.getInt(verificationCodeLengthKey, defaultVerificationCodeLength)
+	var charset []rune
+	for _, c := range strings.ToUpper(r.settings.getString(verificationCodeCharsetKey, defaultVerificationCodeCharset)) {
+		if !unicode.IsSpace(c) && !slices.Contains(charset, c) {
+			charset = append(charset, c)
+		}
+	}
+	combinations := 1.0
+	for i := 0; i < length; i++ {
+		combinations *= float64(len(charset))
+	}
+	if length <= 0 || combinations < minVerificationCodeCombinations {
+		logger.Error(fmt.Errorf("verification codes of %d characters among '%s' have fewer than %d possible values", length, string(charset), minVerificationCodeCombinations),
+			"invalid verification code length and charset, using default values",
+			"length", defaultVerificationCodeLength, "charset", defaultVerificationCodeCharset)
+		return defaultVerificationCodeLength, defaultVerificationCodeCharset
+	}
+	return length, string(charset)
+}
+
+// PhoneChannels are the channels that the users can choose to receive the verification code on their phone,
+// ie, `sms` and/or `voice`. Defaults to `sms`.
+func (r VerificationConfig) PhoneChannels() []string {
+	return r.settings.getStringList(phoneChannelsKey, []string{VerificationChannelSMS})
+}
+
+// PhoneChannelAllowed returns true if the verification codes can be sent with the given channel in this deployment
+func (r VerificationConfig) PhoneChannelAllowed(channel string) bool {
+	for _, c := range r.PhoneChannels() {
+		if strings.EqualFold(c, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// VoiceDailyLimit is the maximum number of verification codes that a user can receive by voice call in 24 hours.
+// The codes sent by SMS are limited by DailyLimit.
+func (r VerificationConfig) VoiceDailyLimit() int {
+	return r.settings.getInt(voiceDailyLimitKey, 3)
+}
+
+// VoiceMessageTemplate is the message read to the users during the voice calls, where `%[1]s` is the verification code
+func (r VerificationConfig) VoiceMessageTemplate() string {
+	return r.settings.getString(voiceMessageTemplateKey,
+		"Your Developer Sandbox verification code is %[1]s. Once again, your verification code is %[1]s.")
+}
+
+// DeliveryStatusCallbackURL is the public URL of the endpoint receiving the delivery status of the SMS from the providers,
+// eg. `https://registration-service.example.com/api/v1/signup/verification/delivery-status`.
+// The status is not tracked if the URL is empty.
+func (r VerificationConfig) DeliveryStatusCallbackURL() string {
+	return strings.TrimSuffix(r.settings.getString(deliveryStatusCallbackURLKey, ""), "/")
+}
+
+// DeliveryStatusSNSTopicARNs are the ARNs of the Amazon SNS topics which are allowed to send the delivery status of the SMS.
+// The notifications from any other topic are rejected.
+func (r VerificationConfig) DeliveryStatusSNSTopicARNs() []string {
+	return r.settings.getStringList(deliveryStatusSNSTopicARNsKey, nil)
+}
+
+// VerificationCodeHashKey is the key of the HMAC used to hash the verification codes stored in the UserSignups.
+// It is required when the verification is enabled. Rotating the key invalidates the verification codes already sent.
+func (r VerificationConfig) VerificationCodeHashKey() string {
+	return r.settings.getString(verificationCodeHashKeyKey, "")
+}
+
 func (r VerificationConfig) NotificationSender() string {
 	return commonconfig.GetString(r.c.NotificationSender, "twilio")
 }
 
+// NotificationSenders is the ordered list of the providers used to send the notifications:
+// if a provider is unavailable, then the notification is sent with the next one.
+// Defaults to the single provider given by NotificationSender, ie, `aws` or `twilio` (for any other value).
+func (r VerificationConfig) NotificationSenders() []string {
+	defaultSender := "twilio"
+	if strings.ToLower(r.NotificationSender()) == "aws" {
+		defaultSender = "aws"
+	}
+	return r.settings.getStringList(notificationSendersKey, []string{defaultSender})
+}
+
+// NotificationSenderCountryOverride is the ordered list of the providers used to send the notifications to the given countries,
+// instead of NotificationSenders
+type NotificationSenderCountryOverride struct {
+	// CountryCodes are the calling codes of the countries (eg, `44`)
+	CountryCodes []string `json:"countryCodes"`
+	// Senders are the names of the providers, in order (eg, `aws`, `twilio`)
+	Senders []string `json:"senders"`
+}
+
+// NotificationSenderCountryOverrides returns the per-country orders of the notification providers,
+// stored as a JSON array in the registration service secret.
+func (r VerificationConfig) NotificationSenderCountryOverrides() ([]NotificationSenderCountryOverride, error) {
+	raw := r.settings.getString(notificationSenderCountryOverridesKey, "")
+	if raw == "" {
+		return nil, nil
+	}
+	var overrides []NotificationSenderCountryOverride
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return nil, fmt.Errorf("invalid notification sender country overrides configuration: %w", err)
+	}
+	for _, override := range overrides {
+		if len(override.CountryCodes) == 0 || len(override.Senders) == 0 {
+			return nil, fmt.Errorf("invalid notification sender country overrides configuration: countryCodes and senders are required")
+		}
+	}
+	return overrides, nil
+}
+
+// VerificationMethods returns the methods that the users can use to verify their account, ie, `phone` and/or `email`.
+// Defaults to `phone`.
+func (r VerificationConfig) VerificationMethods() []string {
+	return r.settings.getStringList(verificationMethodsKey, []string{VerificationMethodPhone})
+}
+
+// VerificationMethodAllowed returns true if the given verification method is allowed in this deployment
+func (r VerificationConfig) VerificationMethodAllowed(method string) bool {
+	for _, m := range r.VerificationMethods() {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// PhoneAllowedCountries are the countries of the phone numbers that can be used for the verification, given as
+// calling codes (eg. `44`) or as region codes (eg. `GB`). All countries are allowed if the list is empty.
+func (r VerificationConfig) PhoneAllowedCountries() []string {
+	return r.settings.getStringList(phoneAllowedCountriesKey, nil)
+}
+
+// PhoneDeniedCountries are the countries of the phone numbers that cannot be used for the verification, given as
+// calling codes (eg. `44`) or as region codes (eg. `GB`).
+func (r VerificationConfig) PhoneDeniedCountries() []string {
+	return r.settings.getStringList(phoneDeniedCountriesKey, nil)
+}
+
+// PhoneBlockedNumberTypes are the types of phone numbers that cannot be used for the verification, among `premium_rate`,
+// `shared_cost`, `voip`, `toll_free`, `personal_number`, `pager`, `uan`, `voicemail`, `fixed_line`, `mobile`,
+// `fixed_line_or_mobile` and `unknown`. By default, no type is blocked.
+func (r VerificationConfig) PhoneBlockedNumberTypes() []string {
+	return r.settings.getStringList(phoneBlockedNumberTypesKey, nil)
+}
+
+func (r VerificationConfig) SMTPHost() string {
+	return r.settings.getString(smtpHostKey, "")
+}
+
+func (r VerificationConfig) SMTPPort() int {
+	return r.settings.getInt(smtpPortKey, 587)
+}
+
+func (r VerificationConfig) SMTPUsername() string {
+	return r.settings.getString(smtpUsernameKey, "")
+}
+
+func (r VerificationConfig) SMTPPassword() string {
+	return r.settings.getString(smtpPasswordKey, "")
+}
+
+func (r VerificationConfig) SMTPFrom() string {
+	return r.settings.getString(smtpFromKey, "")
+}
+
+func (r VerificationConfig) EmailSubject() string {
+	return r.settings.getString(emailSubjectKey, "Developer Sandbox verification code")
+}
+
+func (r VerificationConfig) EmailMessageTemplate() string {
+	return r.settings.getString(emailMessageTemplateKey, "Your Developer Sandbox verification code is %s")
+}
+
+// EmailMagicLinkURL is the URL of the page which verifies the email address with the token of the magic link.
+// The magic link is not sent if this URL or the signing key is not set.
+func (r VerificationConfig) EmailMagicLinkURL() string {
+	return r.settings.getString(emailMagicLinkURLKey, "")
+}
+
+// EmailMagicLinkSigningKey is the key used to sign the tokens of the magic links
+func (r VerificationConfig) EmailMagicLinkSigningKey() string {
+	return r.settings.getString(emailMagicLinkSigningKeyKey, "")
+}
+
+// EmailMagicLinkExpiresIn is how long the magic links are valid, regardless of the expiry of the code they contain
+func (r VerificationConfig) EmailMagicLinkExpiresIn() time.Duration {
+	return r.settings.getDuration(emailMagicLinkExpiresInKey, time.Hour)
+}
+
 func (r VerificationConfig) TwilioAccountSID() string {
 	key := commonconfig.GetString(r.c.Secret.TwilioAccountSID, "")
 	return r.registrationServiceSecret(key)
@@ -317,6 +927,69 @@ func (r VerificationConfig) CaptchaProjectID() string {
 	return commonconfig.GetString(r.c.Captcha.ProjectID, "")
 }
 
+// CaptchaProvider is the provider used to assess the captcha tokens: `recaptcha` (reCAPTCHA Enterprise, the default),
+// `hcaptcha`, `turnstile` (Cloudflare Turnstile) or `fake` (deterministic assessments, for the test environments only)
+func (r VerificationConfig) CaptchaProvider() string {
+	return strings.ToLower(r.settings.getString(captchaProviderKey, CaptchaProviderRecaptcha))
+}
+
+// CaptchaSecretKey is the secret key used to verify the tokens with the hCaptcha and Turnstile providers
+func (r VerificationConfig) CaptchaSecretKey() string {
+	return r.settings.getString(captchaSecretKeyKey, "")
+}
+
+// CaptchaVerifyURL overrides the URL of the endpoint used to verify the tokens with the hCaptcha and Turnstile providers
+func (r VerificationConfig) CaptchaVerifyURL() string {
+	return r.settings.getString(captchaVerifyURLKey, "")
+}
+
+// CaptchaFakeScore is the score of the assessments made by the fake provider for the tokens which are not a score themselves
+func (r VerificationConfig) CaptchaFakeScore() float32 {
+	const defaultFakeScore float32 = 1
+	score := r.settings.getString(captchaFakeScoreKey, "")
+	scoreFloat, err := strconv.ParseFloat(score, 32)
+	if err != nil {
+		if score != "" {
+			log.Error(nil, err, fmt.Sprintf("unable to parse fake captcha score, using default value '%.1f'", defaultFakeScore))
+		}
+		return defaultFakeScore
+	}
+	return float32(scoreFloat)
+}
+
+// RateLimitWindow is the duration of the windows in which the verification requests are counted
+// for each client IP address, phone number and country code
+func (r VerificationConfig) RateLimitWindow() time.Duration {
+	return r.settings.getDuration(rateLimitWindowKey, time.Hour)
+}
+
+// RateLimitClientIP is the maximum number of verification requests per client IP address in a window, or 0 if not limited
+func (r VerificationConfig) RateLimitClientIP() int {
+	return r.settings.getInt(rateLimitClientIPKey, 0)
+}
+
+// RateLimitPhoneNumber is the maximum number of verification requests per phone number in a window, or 0 if not limited
+func (r VerificationConfig) RateLimitPhoneNumber() int {
+	return r.settings.getInt(rateLimitPhoneNumberKey, 0)
+}
+
+// RateLimitCountryCode is the maximum number of verification requests per country code in a window, or 0 if not limited
+func (r VerificationConfig) RateLimitCountryCode() int {
+	return r.settings.getInt(rateLimitCountryCodeKey, 0)
+}
+
+// RateLimitGlobalPerMinute is the maximum number of verification messages sent per minute by all the replicas,
+// or 0 if not limited
+func (r VerificationConfig) RateLimitGlobalPerMinute() int {
+	return r.settings.getInt(rateLimitGlobalPerMinuteKey, 0)
+}
+
+// RateLimitMaxCounters is the maximum number of rate limit counters, so that the requests made from many IP addresses
+// or for many phone numbers cannot create an unbounded number of them, or 0 if not limited
+func (r VerificationConfig) RateLimitMaxCounters() int {
+	return r.settings.getInt(rateLimitMaxCountersKey, 10000)
+}
+
 func (r VerificationConfig) CaptchaServiceAccountFileContents() string {
 	key := commonconfig.GetString(r.c.Secret.RecaptchaServiceAccountFile, "")
 	content := r.registrationServiceSecret(key)
@@ -2,6 +2,7 @@ package configuration_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
@@ -53,12 +54,43 @@ func TestRegistrationService(t *testing.T) {
 		assert.Equal(t, "https://sso.devsandbox.dev/auth/realms/sandbox-dev/protocol/openid-connect/certs", regServiceCfg.Auth().AuthClientPublicKeysURL())
 		assert.Equal(t, "https://sso.devsandbox.dev", regServiceCfg.Auth().SSOBaseURL())
 		assert.Equal(t, "sandbox-dev", regServiceCfg.Auth().SSORealm())
+		assert.Equal(t, 30*time.Minute, regServiceCfg.Auth().AuthClientPublicKeysRefreshInterval())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Auth().AuthClientPublicKeysMinRefreshInterval())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().AuthClientPublicKeysRetiredKeyGracePeriod())
+		assert.Empty(t, regServiceCfg.Auth().OIDCIssuerURL())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().OIDCDiscoveryRefreshInterval())
+		assert.Empty(t, regServiceCfg.Auth().TokenIntrospectionURL())
+		assert.Empty(t, regServiceCfg.Auth().TokenIntrospectionClientID())
+		assert.Empty(t, regServiceCfg.Auth().TokenIntrospectionClientSecret())
+		assert.Equal(t, time.Minute, regServiceCfg.Auth().TokenIntrospectionCacheTTL())
+		assert.False(t, regServiceCfg.Auth().TokenIntrospectionFailOpen())
+		assert.Empty(t, regServiceCfg.Auth().RevokedTokensConfigMap())
+		assert.False(t, regServiceCfg.Auth().ServiceAccountTokenReview())
+		assert.Empty(t, regServiceCfg.Auth().ServiceAccountTokenAudiences())
 		assert.False(t, regServiceCfg.Verification().Enabled())
 		assert.Equal(t, 5, regServiceCfg.Verification().DailyLimit())
 		assert.Equal(t, 3, regServiceCfg.Verification().AttemptsAllowed())
 		assert.Equal(t, "Your Developer Sandbox verification code is %s", regServiceCfg.Verification().MessageTemplate())
 		assert.Empty(t, regServiceCfg.Verification().ExcludedEmailDomains())
 		assert.Equal(t, 5, regServiceCfg.Verification().CodeExpiresInMin())
+		assert.Equal(t, "twilio", regServiceCfg.Verification().NotificationSender())
+		assert.Equal(t, []string{"twilio"}, regServiceCfg.Verification().NotificationSenders())
+		overrides, err := regServiceCfg.Verification().NotificationSenderCountryOverrides()
+		require.NoError(t, err)
+		assert.Empty(t, overrides)
+		assert.Equal(t, []string{"phone"}, regServiceCfg.Verification().VerificationMethods())
+		assert.True(t, regServiceCfg.Verification().VerificationMethodAllowed(configuration.VerificationMethodPhone))
+		assert.False(t, regServiceCfg.Verification().VerificationMethodAllowed(configuration.VerificationMethodEmail))
+		assert.Empty(t, regServiceCfg.Verification().SMTPHost())
+		assert.Equal(t, 587, regServiceCfg.Verification().SMTPPort())
+		assert.Empty(t, regServiceCfg.Verification().SMTPUsername())
+		assert.Empty(t, regServiceCfg.Verification().SMTPPassword())
+		assert.Empty(t, regServiceCfg.Verification().SMTPFrom())
+		assert.Equal(t, "Developer Sandbox verification code", regServiceCfg.Verification().EmailSubject())
+		assert.Equal(t, "Your Developer Sandbox verification code is %s", regServiceCfg.Verification().EmailMessageTemplate())
+		assert.Empty(t, regServiceCfg.Verification().EmailMagicLinkURL())
+		assert.Empty(t, regServiceCfg.Verification().EmailMagicLinkSigningKey())
+		assert.Equal(t, time.Hour, regServiceCfg.Verification().EmailMagicLinkExpiresIn())
 		assert.Empty(t, regServiceCfg.Verification().TwilioAccountSID())
 		assert.Empty(t, regServiceCfg.Verification().TwilioAuthToken())
 		assert.Empty(t, regServiceCfg.Verification().TwilioFromNumber())
@@ -69,6 +101,48 @@ func TestRegistrationService(t *testing.T) {
 		assert.InDelta(t, float32(0), regServiceCfg.Verification().CaptchaRequiredScore(), 0.01)
 		assert.True(t, regServiceCfg.Verification().CaptchaAllowLowScoreReactivation())
 		assert.Empty(t, regServiceCfg.Verification().CaptchaServiceAccountFileContents())
+		assert.Equal(t, "recaptcha", regServiceCfg.Verification().CaptchaProvider())
+		assert.Empty(t, regServiceCfg.Verification().CaptchaSecretKey())
+		assert.Empty(t, regServiceCfg.Verification().CaptchaVerifyURL())
+		assert.InDelta(t, float32(1), regServiceCfg.Verification().CaptchaFakeScore(), 0.01)
+		assert.Equal(t, time.Hour, regServiceCfg.Verification().RateLimitWindow())
+		assert.Equal(t, 0, regServiceCfg.Verification().RateLimitClientIP())
+		assert.Equal(t, 0, regServiceCfg.Verification().RateLimitPhoneNumber())
+		assert.Equal(t, 0, regServiceCfg.Verification().RateLimitCountryCode())
+		assert.Equal(t, 0, regServiceCfg.Verification().RateLimitGlobalPerMinute())
+		assert.Equal(t, 10000, regServiceCfg.Verification().RateLimitMaxCounters())
+		assert.Empty(t, regServiceCfg.Verification().PhoneAllowedCountries())
+		assert.Empty(t, regServiceCfg.Verification().PhoneDeniedCountries())
+		assert.Empty(t, regServiceCfg.Verification().PhoneBlockedNumberTypes())
+		assert.Empty(t, regServiceCfg.Verification().VerificationCodeHashKey())
+		assert.Equal(t, 6, regServiceCfg.Verification().VerificationCodeLength())
+		assert.Equal(t, "0123456789", regServiceCfg.Verification().VerificationCodeCharset())
+		assert.Equal(t, []string{"sms"}, regServiceCfg.Verification().PhoneChannels())
+		assert.True(t, regServiceCfg.Verification().PhoneChannelAllowed(configuration.VerificationChannelSMS))
+		assert.False(t, regServiceCfg.Verification().PhoneChannelAllowed(configuration.VerificationChannelVoice))
+		assert.Equal(t, 3, regServiceCfg.Verification().VoiceDailyLimit())
+		assert.Equal(t, "Your Developer Sandbox verification code is %[1]s. Once again, your verification code is %[1]s.",
+			regServiceCfg.Verification().VoiceMessageTemplate())
+		assert.Empty(t, regServiceCfg.Verification().DeliveryStatusCallbackURL())
+		assert.Empty(t, regServiceCfg.Verification().DeliveryStatusSNSTopicARNs())
+		webhooks, err := regServiceCfg.Events().Webhooks()
+		require.NoError(t, err)
+		assert.Empty(t, webhooks)
+		assert.Equal(t, "registration-service", regServiceCfg.Events().Source())
+		assert.Equal(t, 1000, regServiceCfg.Events().OutboxSize())
+		assert.Equal(t, 8, regServiceCfg.Events().MaxRetries())
+		assert.Equal(t, time.Second, regServiceCfg.Events().RetryBaseDelay())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.Events().RetryMaxDelay())
+		assert.Equal(t, 15*time.Second, regServiceCfg.SignupStreamHeartbeatInterval())
+		assert.Equal(t, 10*time.Minute, regServiceCfg.SignupStreamMaxDuration())
+		assert.Equal(t, 10*time.Minute, regServiceCfg.SignupDeactivationConfirmationTTL())
+		assert.False(t, regServiceCfg.Waitlist().Enabled())
+		assert.False(t, regServiceCfg.Waitlist().AdmitInOrder())
+		assert.Equal(t, 10*time.Minute, regServiceCfg.Waitlist().EstimatedWaitPerPosition())
+		assert.Equal(t, 5, regServiceCfg.Invitations().MaxPerUser())
+		assert.Equal(t, 7*24*time.Hour, regServiceCfg.Invitations().TTL())
+		assert.Empty(t, regServiceCfg.ProxyAuthorizationPoliciesConfigMap())
+		assert.Empty(t, regServiceCfg.TrustedProxies())
 		assert.False(t, regServiceCfg.PublicViewerEnabled())
 	})
 	t.Run("non-default", func(t *testing.T) {
@@ -114,6 +188,65 @@ func TestRegistrationService(t *testing.T) {
 		verificationSecretValues["aws.accesskeyid"] = "foo"
 		verificationSecretValues["aws.secretaccesskey"] = "bar"
 		verificationSecretValues["captcha.json"] = "example-content"
+		verificationSecretValues["auth.publicKeys.refreshInterval"] = "10m"
+		verificationSecretValues["auth.publicKeys.minRefreshInterval"] = "1m"
+		verificationSecretValues["auth.publicKeys.retiredKeyGracePeriod"] = "not-a-duration"
+		verificationSecretValues["auth.revocation.introspectionURL"] = "https://sso.test.org/introspect"
+		verificationSecretValues["auth.revocation.introspectionClientID"] = "registration-service"
+		verificationSecretValues["auth.revocation.introspectionClientSecret"] = "s3cr3t"
+		verificationSecretValues["auth.revocation.introspectionCacheTTL"] = "15s"
+		verificationSecretValues["auth.revocation.introspectionFailOpen"] = "true"
+		verificationSecretValues["auth.revocation.revokedTokensConfigMap"] = "revoked-tokens"
+		verificationSecretValues["auth.serviceAccountTokenReview"] = "true"
+		verificationSecretValues["auth.serviceAccountTokenAudiences"] = "registration-service, sandbox"
+		verificationSecretValues["verification.notificationSenders"] = "twilio,aws"
+		verificationSecretValues["verification.notificationSenderCountryOverrides"] = `[{"countryCodes": ["44", "49"], "senders": ["aws", "twilio"]}]`
+		verificationSecretValues["verification.methods"] = "phone, Email"
+		verificationSecretValues["verification.smtp.host"] = "smtp.test.org"
+		verificationSecretValues["verification.smtp.port"] = "2525"
+		verificationSecretValues["verification.smtp.username"] = "sandbox"
+		verificationSecretValues["verification.smtp.password"] = "p4ssw0rd"
+		verificationSecretValues["verification.smtp.from"] = "noreply@test.org"
+		verificationSecretValues["verification.email.subject"] = "Verify your email address"
+		verificationSecretValues["verification.email.messageTemplate"] = "Developer Sandbox verification code: %s"
+		verificationSecretValues["verification.email.magicLinkURL"] = "https://sandbox.test.org/verify-email"
+		verificationSecretValues["verification.email.magicLinkSigningKey"] = "signing-key"
+		verificationSecretValues["verification.email.magicLinkExpiresIn"] = "24h"
+		verificationSecretValues["verification.captcha.provider"] = "Turnstile"
+		verificationSecretValues["verification.captcha.secretKey"] = "captcha-secret"
+		verificationSecretValues["verification.captcha.verifyURL"] = "https://captcha.test.org/siteverify"
+		verificationSecretValues["verification.captcha.fakeScore"] = "0.3"
+		verificationSecretValues["verification.rateLimit.window"] = "30m"
+		verificationSecretValues["verification.rateLimit.clientIP"] = "20"
+		verificationSecretValues["verification.rateLimit.phoneNumber"] = "5"
+		verificationSecretValues["verification.rateLimit.countryCode"] = "500"
+		verificationSecretValues["verification.rateLimit.globalPerMinute"] = "100"
+		verificationSecretValues["verification.rateLimit.maxCounters"] = "500"
+		verificationSecretValues["verification.phone.allowedCountries"] = "44, US"
+		verificationSecretValues["verification.phone.deniedCountries"] = "JM"
+		verificationSecretValues["verification.phone.blockedNumberTypes"] = "premium_rate,voip"
+		verificationSecretValues["verification.codeHashKey"] = "code-hash-key"
+		verificationSecretValues["verification.code.length"] = "8"
+		verificationSecretValues["verification.code.charset"] = "ABCDEF"
+		verificationSecretValues["verification.phone.channels"] = "sms,Voice"
+		verificationSecretValues["verification.voice.dailyLimit"] = "2"
+		verificationSecretValues["verification.voice.messageTemplate"] = "Your code is %[1]s"
+		verificationSecretValues["verification.deliveryStatus.callbackURL"] = "https://registration.example.com/api/v1/signup/verification/delivery-status/"
+		verificationSecretValues["verification.deliveryStatus.snsTopicARNs"] = "arn:aws:sns:us-east-1:123456789012:sms-status"
+		verificationSecretValues["events.source"] = "https://registration.example.com"
+		verificationSecretValues["events.outboxSize"] = "50"
+		verificationSecretValues["events.maxRetries"] = "3"
+		verificationSecretValues["events.retryBaseDelay"] = "100ms"
+		verificationSecretValues["events.retryMaxDelay"] = "10s"
+		verificationSecretValues["signup.stream.heartbeatInterval"] = "30s"
+		verificationSecretValues["signup.stream.maxDuration"] = "1h"
+		verificationSecretValues["signup.deactivation.confirmationTTL"] = "5m"
+		verificationSecretValues["signup.waitlist.enabled"] = "true"
+		verificationSecretValues["signup.waitlist.estimatedWaitPerPosition"] = "30m"
+		verificationSecretValues["signup.invitations.maxPerUser"] = "10"
+		verificationSecretValues["signup.invitations.ttl"] = "48h"
+		verificationSecretValues["proxy.authorizationPoliciesConfigMap"] = "proxy-policies"
+		verificationSecretValues["server.trustedProxies"] = "10.128.0.0/14,10.0.0.1"
 		secrets := make(map[string]map[string]string)
 		secrets["verification-secrets"] = verificationSecretValues
 
@@ -131,6 +264,17 @@ func TestRegistrationService(t *testing.T) {
 		assert.Equal(t, "https://sso.openshift.com/certs", regServiceCfg.Auth().AuthClientPublicKeysURL())
 		assert.Equal(t, "https://sso.test.org", regServiceCfg.Auth().SSOBaseURL())
 		assert.Equal(t, "my-realm", regServiceCfg.Auth().SSORealm())
+		assert.Equal(t, 10*time.Minute, regServiceCfg.Auth().AuthClientPublicKeysRefreshInterval())
+		assert.Equal(t, time.Minute, regServiceCfg.Auth().AuthClientPublicKeysMinRefreshInterval())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().AuthClientPublicKeysRetiredKeyGracePeriod()) // invalid value, default is used
+		assert.Equal(t, "https://sso.test.org/introspect", regServiceCfg.Auth().TokenIntrospectionURL())
+		assert.Equal(t, "registration-service", regServiceCfg.Auth().TokenIntrospectionClientID())
+		assert.Equal(t, "s3cr3t", regServiceCfg.Auth().TokenIntrospectionClientSecret())
+		assert.Equal(t, 15*time.Second, regServiceCfg.Auth().TokenIntrospectionCacheTTL())
+		assert.True(t, regServiceCfg.Auth().TokenIntrospectionFailOpen())
+		assert.Equal(t, "revoked-tokens", regServiceCfg.Auth().RevokedTokensConfigMap())
+		assert.True(t, regServiceCfg.Auth().ServiceAccountTokenReview())
+		assert.Equal(t, []string{"registration-service", "sandbox"}, regServiceCfg.Auth().ServiceAccountTokenAudiences())
 
 		assert.True(t, regServiceCfg.Verification().Enabled())
 		assert.Equal(t, 15, regServiceCfg.Verification().DailyLimit())
@@ -141,6 +285,25 @@ func TestRegistrationService(t *testing.T) {
 		assert.Equal(t, "Developer Sandbox verification code: %s", regServiceCfg.Verification().MessageTemplate())
 		assert.Equal(t, []string{"redhat.com", "ibm.com"}, regServiceCfg.Verification().ExcludedEmailDomains())
 		assert.Equal(t, 151, regServiceCfg.Verification().CodeExpiresInMin())
+		assert.Equal(t, []string{"twilio", "aws"}, regServiceCfg.Verification().NotificationSenders())
+		overrides, err := regServiceCfg.Verification().NotificationSenderCountryOverrides()
+		require.NoError(t, err)
+		assert.Equal(t, []configuration.NotificationSenderCountryOverride{
+			{CountryCodes: []string{"44", "49"}, Senders: []string{"aws", "twilio"}},
+		}, overrides)
+		assert.Equal(t, []string{"phone", "Email"}, regServiceCfg.Verification().VerificationMethods())
+		assert.True(t, regServiceCfg.Verification().VerificationMethodAllowed(configuration.VerificationMethodPhone))
+		assert.True(t, regServiceCfg.Verification().VerificationMethodAllowed(configuration.VerificationMethodEmail))
+		assert.Equal(t, "smtp.test.org", regServiceCfg.Verification().SMTPHost())
+		assert.Equal(t, 2525, regServiceCfg.Verification().SMTPPort())
+		assert.Equal(t, "sandbox", regServiceCfg.Verification().SMTPUsername())
+		assert.Equal(t, "p4ssw0rd", regServiceCfg.Verification().SMTPPassword())
+		assert.Equal(t, "noreply@test.org", regServiceCfg.Verification().SMTPFrom())
+		assert.Equal(t, "Verify your email address", regServiceCfg.Verification().EmailSubject())
+		assert.Equal(t, "Developer Sandbox verification code: %s", regServiceCfg.Verification().EmailMessageTemplate())
+		assert.Equal(t, "https://sandbox.test.org/verify-email", regServiceCfg.Verification().EmailMagicLinkURL())
+		assert.Equal(t, "signing-key", regServiceCfg.Verification().EmailMagicLinkSigningKey())
+		assert.Equal(t, 24*time.Hour, regServiceCfg.Verification().EmailMagicLinkExpiresIn())
 		assert.Equal(t, "def", regServiceCfg.Verification().TwilioAccountSID())
 		assert.Equal(t, "ghi", regServiceCfg.Verification().TwilioAuthToken())
 		assert.Equal(t, "jkl", regServiceCfg.Verification().TwilioFromNumber())
@@ -153,6 +316,42 @@ func TestRegistrationService(t *testing.T) {
 		assert.InDelta(t, float32(0.5), regServiceCfg.Verification().CaptchaRequiredScore(), 0.01)
 		assert.False(t, regServiceCfg.Verification().CaptchaAllowLowScoreReactivation())
 		assert.Equal(t, "example-content", regServiceCfg.Verification().CaptchaServiceAccountFileContents())
+		assert.Equal(t, "turnstile", regServiceCfg.Verification().CaptchaProvider())
+		assert.Equal(t, "captcha-secret", regServiceCfg.Verification().CaptchaSecretKey())
+		assert.Equal(t, "https://captcha.test.org/siteverify", regServiceCfg.Verification().CaptchaVerifyURL())
+		assert.InDelta(t, float32(0.3), regServiceCfg.Verification().CaptchaFakeScore(), 0.01)
+		assert.Equal(t, 30*time.Minute, regServiceCfg.Verification().RateLimitWindow())
+		assert.Equal(t, 20, regServiceCfg.Verification().RateLimitClientIP())
+		assert.Equal(t, 5, regServiceCfg.Verification().RateLimitPhoneNumber())
+		assert.Equal(t, 500, regServiceCfg.Verification().RateLimitCountryCode())
+		assert.Equal(t, 100, regServiceCfg.Verification().RateLimitGlobalPerMinute())
+		assert.Equal(t, 500, regServiceCfg.Verification().RateLimitMaxCounters())
+		assert.Equal(t, []string{"44", "US"}, regServiceCfg.Verification().PhoneAllowedCountries())
+		assert.Equal(t, []string{"JM"}, regServiceCfg.Verification().PhoneDeniedCountries())
+		assert.Equal(t, []string{"premium_rate", "voip"}, regServiceCfg.Verification().PhoneBlockedNumberTypes())
+		assert.Equal(t, "code-hash-key", regServiceCfg.Verification().VerificationCodeHashKey())
+		assert.Equal(t, 8, regServiceCfg.Verification().VerificationCodeLength())
+		assert.Equal(t, "ABCDEF", regServiceCfg.Verification().VerificationCodeCharset())
+		assert.Equal(t, []string{"sms", "Voice"}, regServiceCfg.Verification().PhoneChannels())
+		assert.True(t, regServiceCfg.Verification().PhoneChannelAllowed(configuration.VerificationChannelVoice))
+		assert.Equal(t, 2, regServiceCfg.Verification().VoiceDailyLimit())
+		assert.Equal(t, "Your code is %[1]s", regServiceCfg.Verification().VoiceMessageTemplate())
+		assert.Equal(t, "https://registration.example.com/api/v1/signup/verification/delivery-status", regServiceCfg.Verification().DeliveryStatusCallbackURL())
+		assert.Equal(t, []string{"arn:aws:sns:us-east-1:123456789012:sms-status"}, regServiceCfg.Verification().DeliveryStatusSNSTopicARNs())
+		assert.Equal(t, "https://registration.example.com", regServiceCfg.Events().Source())
+		assert.Equal(t, 50, regServiceCfg.Events().OutboxSize())
+		assert.Equal(t, 3, regServiceCfg.Events().MaxRetries())
+		assert.Equal(t, 100*time.Millisecond, regServiceCfg.Events().RetryBaseDelay())
+		assert.Equal(t, 10*time.Second, regServiceCfg.Events().RetryMaxDelay())
+		assert.Equal(t, 30*time.Second, regServiceCfg.SignupStreamHeartbeatInterval())
+		assert.Equal(t, time.Hour, regServiceCfg.SignupStreamMaxDuration())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.SignupDeactivationConfirmationTTL())
+		assert.True(t, regServiceCfg.Waitlist().Enabled())
+		assert.Equal(t, 30*time.Minute, regServiceCfg.Waitlist().EstimatedWaitPerPosition())
+		assert.Equal(t, 10, regServiceCfg.Invitations().MaxPerUser())
+		assert.Equal(t, 48*time.Hour, regServiceCfg.Invitations().TTL())
+		assert.Equal(t, "proxy-policies", regServiceCfg.ProxyAuthorizationPoliciesConfigMap())
+		assert.Equal(t, []string{"10.128.0.0/14", "10.0.0.1"}, regServiceCfg.TrustedProxies())
 		assert.False(t, regServiceCfg.PublicViewerEnabled())
 	})
 }
@@ -192,3 +391,277 @@ func TestPublicViewerConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestWaitlistAdmitInOrder(t *testing.T) {
+	tt := map[string]struct {
+		expectedValue bool
+		options       []testconfig.ToolchainConfigOption
+	}{
+		"automatic approval enabled": {
+			expectedValue: true,
+			options:       []testconfig.ToolchainConfigOption{testconfig.AutomaticApproval().Enabled(true)},
+		},
+		"automatic approval restricted to some domains": {
+			expectedValue: false,
+			options:       []testconfig.ToolchainConfigOption{testconfig.AutomaticApproval().Enabled(true).Domains("redhat.com")},
+		},
+		"automatic approval disabled": {
+			expectedValue: false,
+			options:       []testconfig.ToolchainConfigOption{testconfig.AutomaticApproval().Enabled(false)},
+		},
+		"automatic approval not set": {
+			expectedValue: false,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			// given
+			cfg := commonconfig.NewToolchainConfigObjWithReset(t, tc.options...)
+
+			// when
+			regServiceCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{})
+
+			// then
+			assert.Equal(t, tc.expectedValue, regServiceCfg.Waitlist().AdmitInOrder())
+		})
+	}
+}
+
+func TestVerificationCodeFormat(t *testing.T) {
+	newVerificationConfig := func(t *testing.T, length, charset string) configuration.VerificationConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Verification().Secret().Ref("registration-service-secret"))
+		secrets := map[string]map[string]string{
+			"registration-service-secret": {
+				"verification.code.length":  length,
+				"verification.code.charset": charset,
+			},
+		}
+		return configuration.NewRegistrationServiceConfig(cfg, secrets).Verification()
+	}
+
+	tt := map[string]struct {
+		length          string
+		charset         string
+		expectedLength  int
+		expectedCharset string
+	}{
+		"default": {
+			expectedLength:  6,
+			expectedCharset: "0123456789",
+		},
+		"enough possible values": {
+			length:          "5",
+			charset:         "ABCDEFGHJKLMNPQRSTUVWXYZ",
+			expectedLength:  5,
+			expectedCharset: "ABCDEFGHJKLMNPQRSTUVWXYZ",
+		},
+		"upper-cased and deduplicated characters": {
+			length:          "8",
+			charset:         "abcdefABC DEF",
+			expectedLength:  8,
+			expectedCharset: "ABCDEF",
+		},
+		"too short": {
+			length:          "4",
+			expectedLength:  6,
+			expectedCharset: "0123456789",
+		},
+		"too few characters once deduplicated": {
+			length:          "10",
+			charset:         "aAbB",
+			expectedLength:  6,
+			expectedCharset: "0123456789",
+		},
+		"invalid length": {
+			length:          "-1",
+			expectedLength:  6,
+			expectedCharset: "0123456789",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			// when
+			cfg := newVerificationConfig(t, tc.length, tc.charset)
+
+			// then
+			assert.Equal(t, tc.expectedLength, cfg.VerificationCodeLength())
+			assert.Equal(t, tc.expectedCharset, cfg.VerificationCodeCharset())
+		})
+	}
+}
+
+func TestTokenIssuers(t *testing.T) {
+	newAuthConfig := func(t *testing.T, issuers string) configuration.AuthConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Verification().Secret().Ref("registration-service-secret"))
+		secrets := map[string]map[string]string{
+			"registration-service-secret": {
+				"auth.tokenIssuers": issuers,
+			},
+		}
+		return configuration.NewRegistrationServiceConfig(cfg, secrets).Auth()
+	}
+
+	t.Run("no issuers", func(t *testing.T) {
+		// when
+		issuers, err := newAuthConfig(t, "").TokenIssuers()
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, issuers)
+	})
+
+	t.Run("multiple issuers", func(t *testing.T) {
+		// when
+		issuers, err := newAuthConfig(t, `[
+			{"issuer": "https://sso.devsandbox.dev/auth/realms/sandbox-dev", "publicKeysURL": "https://sso.devsandbox.dev/certs", "audiences": ["sandbox-public"]},
+			{"issuer": "https://ci.devsandbox.dev", "publicKeysURL": "https://ci.devsandbox.dev/keys", "claimMappings": {"preferred_username": "client_id"}}
+		]`).TokenIssuers()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []configuration.TokenIssuerConfig{
+			{
+				Issuer:        "https://sso.devsandbox.dev/auth/realms/sandbox-dev",
+				PublicKeysURL: "https://sso.devsandbox.dev/certs",
+				Audiences:     []string{"sandbox-public"},
+			},
+			{
+				Issuer:        "https://ci.devsandbox.dev",
+				PublicKeysURL: "https://ci.devsandbox.dev/keys",
+				ClaimMappings: map[string]string{"preferred_username": "client_id"},
+			},
+		}, issuers)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		// when
+		_, err := newAuthConfig(t, `[{"issuer": `).TokenIssuers()
+
+		// then
+		require.EqualError(t, err, "invalid token issuers configuration: unexpected end of JSON input")
+	})
+
+	t.Run("missing issuer", func(t *testing.T) {
+		// when
+		_, err := newAuthConfig(t, `[{"publicKeysURL": "https://ci.devsandbox.dev/keys"}]`).TokenIssuers()
+
+		// then
+		require.EqualError(t, err, "invalid token issuers configuration: issuer is required")
+	})
+}
+
+func TestMachinePrincipals(t *testing.T) {
+	newAuthConfig := func(t *testing.T, principals string) configuration.AuthConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Verification().Secret().Ref("registration-service-secret"))
+		secrets := map[string]map[string]string{
+			"registration-service-secret": {
+				"auth.machinePrincipals": principals,
+			},
+		}
+		return configuration.NewRegistrationServiceConfig(cfg, secrets).Auth()
+	}
+
+	t.Run("no principals", func(t *testing.T) {
+		// when
+		principals, err := newAuthConfig(t, "").MachinePrincipals()
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, principals)
+	})
+
+	t.Run("multiple principals", func(t *testing.T) {
+		// when
+		principals, err := newAuthConfig(t, `[
+			{"name": "onboarding-bot", "scopes": ["usernames:read", "signups:read"]},
+			{"name": "system:serviceaccount:events:event-tooling"}
+		]`).MachinePrincipals()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []configuration.MachinePrincipalConfig{
+			{
+				Name:   "onboarding-bot",
+				Scopes: []string{"usernames:read", "signups:read"},
+			},
+			{
+				Name: "system:serviceaccount:events:event-tooling",
+			},
+		}, principals)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		// when
+		_, err := newAuthConfig(t, `[{"name": `).MachinePrincipals()
+
+		// then
+		require.EqualError(t, err, "invalid machine principals configuration: unexpected end of JSON input")
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		// when
+		_, err := newAuthConfig(t, `[{"scopes": ["usernames:read"]}]`).MachinePrincipals()
+
+		// then
+		require.EqualError(t, err, "invalid machine principals configuration: name is required")
+	})
+}
+
+func TestEventWebhooks(t *testing.T) {
+	newEventsConfig := func(t *testing.T, webhooks string) configuration.EventsConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Verification().Secret().Ref("registration-service-secret"))
+		secrets := map[string]map[string]string{
+			"registration-service-secret": {
+				"events.webhooks": webhooks,
+			},
+		}
+		return configuration.NewRegistrationServiceConfig(cfg, secrets).Events()
+	}
+
+	t.Run("multiple webhooks", func(t *testing.T) {
+		// when
+		webhooks, err := newEventsConfig(t, `[
+			{"url": "https://crm.example.com/events", "secret": "crm-secret"},
+			{"url": "https://marketing.example.com/hooks", "secret": "marketing-secret", "types": ["com.openshift.dev.toolchain.usersignup.verified"]}
+		]`).Webhooks()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []configuration.EventWebhookConfig{
+			{
+				URL:    "https://crm.example.com/events",
+				Secret: "crm-secret",
+			},
+			{
+				URL:    "https://marketing.example.com/hooks",
+				Secret: "marketing-secret",
+				Types:  []string{"com.openshift.dev.toolchain.usersignup.verified"},
+			},
+		}, webhooks)
+		assert.True(t, webhooks[0].Accepts("com.openshift.dev.toolchain.usersignup.created"))
+		assert.False(t, webhooks[1].Accepts("com.openshift.dev.toolchain.usersignup.created"))
+		assert.True(t, webhooks[1].Accepts("com.openshift.dev.toolchain.usersignup.verified"))
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		// when
+		_, err := newEventsConfig(t, `[{"url": `).Webhooks()
+
+		// then
+		require.EqualError(t, err, "invalid events webhooks configuration: unexpected end of JSON input")
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		// when
+		_, err := newEventsConfig(t, `[{"url": "https://crm.example.com/events"}]`).Webhooks()
+
+		// then
+		require.EqualError(t, err, "invalid events webhooks configuration: url and secret are required")
+	})
+}
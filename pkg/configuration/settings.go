@@ -0,0 +1,73 @@
+package configuration
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings holds the registration service settings which do not have a dedicated field in the ToolchainConfig API (yet).
+// They are read from the registration service secret (the one referenced by `registrationService.verification.secret.ref`),
+// where each key is the name of a setting.
+type settings map[string]string
+
+func (s settings) getString(key, defaultValue string) string {
+	if v, ok := s[key]; ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return defaultValue
+}
+
+func (s settings) getInt(key string, defaultValue int) int {
+	v := s.getString(key, "")
+	if v == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Error(err, "unable to parse setting, using default value", "key", key, "default", defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+func (s settings) getBool(key string, defaultValue bool) bool {
+	v := s.getString(key, "")
+	if v == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logger.Error(err, "unable to parse setting, using default value", "key", key, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func (s settings) getDuration(key string, defaultValue time.Duration) time.Duration {
+	v := s.getString(key, "")
+	if v == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Error(err, "unable to parse setting, using default value", "key", key, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
+
+// getStringList returns the comma-separated values of the setting, ignoring the empty ones.
+func (s settings) getStringList(key string, defaultValue []string) []string {
+	v := s.getString(key, "")
+	if v == "" {
+		return defaultValue
+	}
+	var values []string
+	for _, value := range strings.Split(v, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
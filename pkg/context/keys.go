@@ -25,6 +25,8 @@ const (
 	JWTClaimsKey = "jwtClaims"
 	// WorkspaceKey is the context key for the workspace name in echo.Context
 	WorkspaceKey = "workspace"
+	// ProxyPluginKey is the context key for the name of the proxy plugin targeted by the request in echo.Context
+	ProxyPluginKey = "proxyPlugin"
 	// RequestReceivedTime is the context key for the starting time of a request made
 	RequestReceivedTime = "requestReceivedTime"
 	// PublicViewerEnabled is a boolean value indicating whether PublicViewer support is enabled
@@ -33,4 +35,12 @@ const (
 	ImpersonateUser = "impersonateUser"
 	// SocialEvent is the context key for the activation code provided in UI
 	SocialEvent = "socialEvent"
+	// InvitationCode is the context key for the invitation code provided in UI as an activation code
+	InvitationCode = "invitationCode"
+	// PrincipalKey is the context key for the name of the client or ServiceAccount calling the machine API
+	PrincipalKey = "principal"
+	// PrincipalKindKey is the context key for the kind of the principal calling the machine API
+	PrincipalKindKey = "principalKind"
+	// PrincipalScopesKey is the context key for the scopes granted to the principal calling the machine API
+	PrincipalScopesKey = "principalScopes"
 )
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/gin-gonic/gin"
+)
+
+// DeliveryStatus implements the unsecured endpoints receiving the delivery status of the verification codes sent by SMS,
+// from the notification providers. The callbacks are authenticated with the signatures of the providers.
+type DeliveryStatus struct {
+	recorder    *delivery.Recorder
+	snsVerifier *delivery.SNSVerifier
+}
+
+// NewDeliveryStatus returns a new DeliveryStatus instance.
+func NewDeliveryStatus(nsClient namespaced.Client) *DeliveryStatus {
+	return &DeliveryStatus{
+		recorder: delivery.NewRecorder(nsClient),
+		snsVerifier: delivery.NewSNSVerifier(&http.Client{
+			Timeout:   10 * time.Second,
+			Transport: http.DefaultTransport,
+		}),
+	}
+}
+
+// TwilioHandler records the delivery status sent by Twilio to the status callback of a message
+func (d *DeliveryStatus) TwilioHandler(ctx *gin.Context) {
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	callbackURL := cfg.DeliveryStatusCallbackURL()
+	if callbackURL != "" {
+		callbackURL += delivery.TwilioCallbackPath
+	}
+	phoneNumber, messageID, status, err := delivery.ParseTwilioCallback(ctx.Request, callbackURL, cfg.TwilioAuthToken())
+	if err != nil {
+		log.Error(ctx, err, "invalid Twilio status callback")
+		if errors.Is(err, delivery.ErrInvalidSignature) {
+			crterrors.AbortWithError(ctx, http.StatusForbidden, err, "invalid Twilio signature")
+			return
+		}
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "invalid Twilio status callback")
+		return
+	}
+	d.record(ctx, "twilio", phoneNumber, messageID, status)
+}
+
+// SNSHandler records the delivery status forwarded by Amazon SNS, and confirms the subscriptions to the allowed topics
+func (d *DeliveryStatus) SNSHandler(ctx *gin.Context) {
+	// Amazon SNS sends the messages with the `text/plain` content type
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 256*1024))
+	if err != nil {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	msg := &delivery.SNSMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		log.Error(ctx, err, "invalid Amazon SNS message")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "invalid Amazon SNS message")
+		return
+	}
+
+	// anybody can sign messages with the Amazon SNS certificates by creating a topic, so only the configured topics are trusted
+	if !slices.Contains(configuration.GetRegistrationServiceConfig().Verification().DeliveryStatusSNSTopicARNs(), msg.TopicArn) {
+		log.Infof(ctx, "Amazon SNS message received from an unknown topic: %s", msg.TopicArn)
+		crterrors.AbortWithError(ctx, http.StatusForbidden, fmt.Errorf("unknown topic '%s'", msg.TopicArn), "invalid Amazon SNS message")
+		return
+	}
+	if err := d.snsVerifier.Verify(msg); err != nil {
+		log.Error(ctx, err, "invalid Amazon SNS signature")
+		if errors.Is(err, delivery.ErrInvalidSignature) {
+			crterrors.AbortWithError(ctx, http.StatusForbidden, err, "invalid Amazon SNS signature")
+			return
+		}
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unable to verify the Amazon SNS signature")
+		return
+	}
+
+	switch msg.Type {
+	case delivery.SNSTypeSubscriptionConfirmation:
+		if err := d.snsVerifier.ConfirmSubscription(msg); err != nil {
+			log.Error(ctx, err, "unable to confirm the Amazon SNS subscription")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unable to confirm the Amazon SNS subscription")
+			return
+		}
+		log.Infof(ctx, "Amazon SNS subscription confirmed for the topic %s", msg.TopicArn)
+		ctx.Status(http.StatusNoContent)
+		ctx.Writer.WriteHeaderNow()
+	case delivery.SNSTypeNotification:
+		phoneNumber, messageID, status, err := delivery.ParseSNSDeliveryStatus(msg.Message)
+		if err != nil {
+			log.Error(ctx, err, "invalid Amazon SNS notification")
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "invalid Amazon SNS notification")
+			return
+		}
+		d.record(ctx, "aws", phoneNumber, messageID, status)
+	default:
+		ctx.Status(http.StatusNoContent)
+		ctx.Writer.WriteHeaderNow()
+	}
+}
+
+// record records the final delivery status of the message in the UserSignup it was sent to. The intermediate statuses are ignored.
+func (d *DeliveryStatus) record(ctx *gin.Context, provider, phoneNumber, messageID, status string) {
+	if status != "" {
+		if err := d.recorder.Record(ctx, provider, phoneNumber, messageID, status); err != nil {
+			log.Error(ctx, err, "unable to record the delivery status")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unable to record the delivery status")
+			return
+		}
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
@@ -0,0 +1,201 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kevinburke/twilio-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const deliveryStatusCallbackURL = "https://registration.example.com/api/v1/signup/verification/delivery-status"
+
+type TestDeliveryStatusSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunDeliveryStatusSuite(t *testing.T) {
+	suite.Run(t, &TestDeliveryStatusSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestDeliveryStatusSuite) setSettings(data map[string]string) {
+	s.SetSettings(data, testconfig.RegistrationService().Verification().Secret().TwilioAuthToken("twilio.token"))
+}
+
+func (s *TestDeliveryStatusSuite) TestTwilioHandler() {
+	// given
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, hash.EncodeString("+441234567890")),
+		testusersignup.WithAnnotation(delivery.StatusAnnotationKey, delivery.StatusSent),
+		testusersignup.WithAnnotation(delivery.MessageIDAnnotationKey, "SM123"),
+		testusersignup.VerificationRequiredAgo(time.Second))
+	callback := func(ctrl *controller.DeliveryStatus, signedURL, token string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"MessageSid":    {"SM123"},
+			"MessageStatus": {"undelivered"},
+			"ErrorCode":     {"30006"},
+			"To":            {"+441234567890"},
+		}
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/signup/verification/delivery-status/twilio", strings.NewReader(form.Encode()))
+		ctx.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx.Request.Header.Set("X-Twilio-Signature", twilio.GetExpectedTwilioSignature("", token, signedURL, form))
+		ctrl.TwilioHandler(ctx)
+		return rr
+	}
+
+	s.Run("delivery status recorded", func() {
+		// given
+		s.setSettings(map[string]string{
+			"twilio.token": "auth-token",
+			"verification.deliveryStatus.callbackURL": deliveryStatusCallbackURL,
+		})
+		fakeClient := commontest.NewFakeClient(s.T(), userSignup.DeepCopy())
+		ctrl := controller.NewDeliveryStatus(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		rr := callback(ctrl, deliveryStatusCallbackURL+"/twilio", "auth-token")
+
+		// then
+		assert.Equal(s.T(), http.StatusNoContent, rr.Code)
+		actual := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(userSignup), actual))
+		assert.Equal(s.T(), delivery.StatusFailed, actual.Annotations[delivery.StatusAnnotationKey])
+	})
+
+	s.Run("status of a previous message ignored", func() {
+		// given
+		s.setSettings(map[string]string{
+			"twilio.token": "auth-token",
+			"verification.deliveryStatus.callbackURL": deliveryStatusCallbackURL,
+		})
+		newCode := userSignup.DeepCopy()
+		newCode.Annotations[delivery.MessageIDAnnotationKey] = "SM456"
+		fakeClient := commontest.NewFakeClient(s.T(), newCode)
+		ctrl := controller.NewDeliveryStatus(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		rr := callback(ctrl, deliveryStatusCallbackURL+"/twilio", "auth-token")
+
+		// then
+		assert.Equal(s.T(), http.StatusNoContent, rr.Code)
+		actual := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(userSignup), actual))
+		assert.Equal(s.T(), delivery.StatusSent, actual.Annotations[delivery.StatusAnnotationKey])
+	})
+
+	s.Run("invalid signature", func() {
+		// given
+		s.setSettings(map[string]string{
+			"twilio.token": "auth-token",
+			"verification.deliveryStatus.callbackURL": deliveryStatusCallbackURL,
+		})
+		fakeClient := commontest.NewFakeClient(s.T(), userSignup.DeepCopy())
+		ctrl := controller.NewDeliveryStatus(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		rr := callback(ctrl, deliveryStatusCallbackURL+"/twilio", "other-token")
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+		actual := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(userSignup), actual))
+		assert.Equal(s.T(), delivery.StatusSent, actual.Annotations[delivery.StatusAnnotationKey])
+	})
+
+	s.Run("callbacks not enabled", func() {
+		// given
+		s.setSettings(map[string]string{
+			"twilio.token": "auth-token",
+		})
+		fakeClient := commontest.NewFakeClient(s.T(), userSignup.DeepCopy())
+		ctrl := controller.NewDeliveryStatus(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		rr := callback(ctrl, "/twilio", "auth-token")
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+}
+
+func (s *TestDeliveryStatusSuite) TestSNSHandler() {
+	notify := func(ctrl *controller.DeliveryStatus, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/signup/verification/delivery-status/aws", strings.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", "text/plain; charset=UTF-8")
+		ctrl.SNSHandler(ctx)
+		return rr
+	}
+	s.setSettings(map[string]string{
+		"verification.deliveryStatus.snsTopicARNs": "arn:aws:sns:us-east-1:123456789012:sms-delivery-status",
+	})
+	ctrl := controller.NewDeliveryStatus(namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs))
+
+	s.Run("unknown topic", func() {
+		// given
+		msg, err := json.Marshal(&delivery.SNSMessage{
+			Type:           delivery.SNSTypeNotification,
+			TopicArn:       "arn:aws:sns:us-east-1:999999999999:other-topic",
+			Message:        `{"delivery":{"destination":"+441234567890"},"status":"FAILURE"}`,
+			SigningCertURL: "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-0123456789.pem",
+		})
+		require.NoError(s.T(), err)
+
+		// when
+		rr := notify(ctrl, string(msg))
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+
+	s.Run("invalid signature", func() {
+		// given
+		msg, err := json.Marshal(&delivery.SNSMessage{
+			Type:             delivery.SNSTypeNotification,
+			TopicArn:         "arn:aws:sns:us-east-1:123456789012:sms-delivery-status",
+			Message:          `{"delivery":{"destination":"+441234567890"},"status":"FAILURE"}`,
+			SignatureVersion: "1",
+			Signature:        "c2lnbmF0dXJl",
+			SigningCertURL:   "https://attacker.example.com/cert.pem",
+		})
+		require.NoError(s.T(), err)
+
+		// when
+		rr := notify(ctrl, string(msg))
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+
+	s.Run("invalid message", func() {
+		// when
+		rr := notify(ctrl, "not json")
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+}
@@ -2,14 +2,18 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/phonepolicy"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nyaruka/phonenumbers"
@@ -24,6 +28,14 @@ type Signup struct {
 type Phone struct {
 	CountryCode string `form:"country_code" json:"country_code" binding:"required"`
 	PhoneNumber string `form:"phone_number" json:"phone_number" binding:"required"`
+	// Channel is the channel used to send the verification code, ie, `sms` (the default) or `voice`
+	Channel string `form:"channel" json:"channel"`
+}
+
+// EmailVerification contains either the verification code sent by email, or the token of the magic link
+type EmailVerification struct {
+	Code  string `form:"code" json:"code"`
+	Token string `form:"token" json:"token"`
 }
 
 // NewSignup returns a new Signup instance.
@@ -43,7 +55,8 @@ func (s *Signup) PostHandler(ctx *gin.Context) {
 	}
 	if err != nil {
 		log.Error(ctx, err, "error creating UserSignup resource")
-		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error creating UserSignup resource")
+		// eg. the provided social event or invitation code is invalid
+		abortWithSignupError(ctx, err, "error creating UserSignup resource")
 		return
 	}
 	if _, exists := userSignup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; !exists {
@@ -70,6 +83,22 @@ func (s *Signup) InitVerificationHandler(ctx *gin.Context) {
 		return
 	}
 
+	channel := strings.ToLower(strings.TrimSpace(phone.Channel))
+	switch channel {
+	case "":
+		channel = configuration.VerificationChannelSMS
+	case configuration.VerificationChannelSMS, configuration.VerificationChannelVoice:
+	default:
+		log.Errorf(ctx, nil, "invalid channel value: %s", phone.Channel)
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the channel must be 'sms' or 'voice'"), "invalid channel")
+		return
+	}
+	if !configuration.GetRegistrationServiceConfig().Verification().PhoneChannelAllowed(channel) {
+		log.Infof(ctx, "%s verification attempted while it is not allowed", channel)
+		crterrors.AbortWithError(ctx, http.StatusForbidden, fmt.Errorf("%s verification is not available", channel), "verification channel not allowed")
+		return
+	}
+
 	countryCode, err := strconv.Atoi(phone.CountryCode)
 	if err != nil {
 		log.Errorf(ctx, err, "invalid country_code value")
@@ -85,8 +114,14 @@ func (s *Signup) InitVerificationHandler(ctx *gin.Context) {
 		return
 	}
 
+	if err := phonepolicy.Check(ctx, number); err != nil {
+		log.Errorf(ctx, err, "phone number not allowed for %s", username)
+		crterrors.AbortWithError(ctx, err.Code, err, err.Message)
+		return
+	}
+
 	e164Number := phonenumbers.Format(number, phonenumbers.E164)
-	err = s.app.VerificationService().InitVerification(ctx, username, e164Number, strconv.Itoa(countryCode))
+	err = s.app.VerificationService().InitVerification(ctx, username, e164Number, strconv.Itoa(countryCode), channel)
 	if err != nil {
 		log.Errorf(ctx, err, "Verification for %s could not be sent", username)
 		e := &crterrors.Error{}
@@ -109,6 +144,22 @@ func (s *Signup) GetHandler(ctx *gin.Context) {
 
 	// Get the UserSignup resource from the service by the username
 	username := ctx.GetString(context.UsernameKey)
+	s.getSignup(ctx, username)
+}
+
+// GetByUsernameHandler returns the Signup resource of the user given in the path.
+// This handler is meant for the machine API, where the caller is not the user.
+func (s *Signup) GetByUsernameHandler(ctx *gin.Context) {
+	username := ctx.Param("username")
+	if username == "" {
+		log.Info(ctx, "empty username provided")
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	s.getSignup(ctx, username)
+}
+
+func (s *Signup) getSignup(ctx *gin.Context, username string) {
 	signupResource, err := s.app.SignupService().GetSignup(ctx, username, true)
 	if err != nil {
 		log.Error(ctx, err, "error getting UserSignup resource")
@@ -127,6 +178,83 @@ func (s *Signup) GetHandler(ctx *gin.Context) {
 	}
 }
 
+// DeleteHandler deactivates the Signup resource of the user. Without the `confirmation` query parameter, it returns the
+// token that the user must provide in this parameter to confirm the deactivation in a second request.
+func (s *Signup) DeleteHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+
+	confirmationToken := ctx.Query("confirmation")
+	if confirmationToken == "" {
+		confirmation, err := s.app.SignupService().RequestDeactivation(ctx, username)
+		if err != nil {
+			log.Errorf(ctx, err, "deactivation of %s could not be requested", username)
+			abortWithSignupError(ctx, err, "error while requesting the deactivation")
+			return
+		}
+		ctx.JSON(http.StatusOK, confirmation)
+		return
+	}
+
+	if err := s.app.SignupService().Deactivate(ctx, username, confirmationToken); err != nil {
+		log.Errorf(ctx, err, "%s could not be deactivated", username)
+		abortWithSignupError(ctx, err, "error while deactivating the user")
+		return
+	}
+	log.Infof(ctx, "user %s deactivated", username)
+	ctx.Status(http.StatusAccepted)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// ExportHandler returns everything the registration service knows about the user
+func (s *Signup) ExportHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+
+	export, err := s.app.SignupService().ExportSignup(ctx, username)
+	if err != nil {
+		log.Errorf(ctx, err, "data of %s could not be exported", username)
+		abortWithSignupError(ctx, err, "error while exporting the user data")
+		return
+	}
+	ctx.JSON(http.StatusOK, export)
+}
+
+// CreateInvitationHandler creates an invitation code which the user can share with someone else to let them sign up
+// without the phone verification. The code is only returned once.
+func (s *Signup) CreateInvitationHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+
+	invitation, err := s.app.SignupService().CreateInvitation(ctx, username)
+	if err != nil {
+		log.Errorf(ctx, err, "invitation could not be created by %s", username)
+		abortWithSignupError(ctx, err, "error while creating the invitation")
+		return
+	}
+	ctx.JSON(http.StatusCreated, invitation)
+}
+
+// ListInvitationsHandler returns the invitations created by the user, with their quota
+func (s *Signup) ListInvitationsHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+
+	invitations, err := s.app.SignupService().ListInvitations(ctx, username)
+	if err != nil {
+		log.Errorf(ctx, err, "invitations of %s could not be listed", username)
+		abortWithSignupError(ctx, err, "error while listing the invitations")
+		return
+	}
+	ctx.JSON(http.StatusOK, invitations)
+}
+
+// abortWithSignupError aborts with the code of the given error if it's an Error, or 500
+func abortWithSignupError(ctx *gin.Context, err error, details string) {
+	e := &crterrors.Error{}
+	if errors.As(err, &e) {
+		crterrors.AbortWithError(ctx, e.Code, err, details)
+		return
+	}
+	crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, details)
+}
+
 // VerifyPhoneCodeHandler validates the phone verification code passed in by the user
 func (s *Signup) VerifyPhoneCodeHandler(ctx *gin.Context) {
 	log.Info(ctx, "Verifying phone code")
@@ -154,6 +282,62 @@ func (s *Signup) VerifyPhoneCodeHandler(ctx *gin.Context) {
 	log.Info(ctx, "Verified phone code")
 }
 
+// InitEmailVerificationHandler starts the email verification process for a user, by sending a verification code
+// to the email address of the account of the user.
+func (s *Signup) InitEmailVerificationHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+
+	err := s.app.VerificationService().InitEmailVerification(ctx, username)
+	if err != nil {
+		log.Errorf(ctx, err, "Email verification for %s could not be sent", username)
+		abortWithSignupError(ctx, err, "error while initiating verification")
+		return
+	}
+
+	log.Infof(ctx, "email verification has been sent for username %s", username)
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// VerifyEmailHandler validates the verification code or the magic link token passed in by the user
+func (s *Signup) VerifyEmailHandler(ctx *gin.Context) {
+	var verification EmailVerification
+	err := ctx.ShouldBindJSON(&verification)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("invalid request body: %w", err)
+	case verification.Code == "" && verification.Token == "":
+		err = errors.New("the request body contains neither the code nor the token")
+	case verification.Code != "" && verification.Token != "":
+		err = errors.New("the request body contains both the code and the token")
+	}
+	if err != nil {
+		log.Error(ctx, err, "request body must contain either the code or the token")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "request body must contain either the code or the token")
+		return
+	}
+
+	username := ctx.GetString(context.UsernameKey)
+
+	if verification.Token != "" {
+		err = s.app.VerificationService().VerifyEmailLink(ctx, username, verification.Token)
+	} else {
+		err = s.app.VerificationService().VerifyEmailCode(ctx, username, verification.Code)
+	}
+	if err != nil {
+		e := &crterrors.Error{}
+		switch {
+		case errors.As(err, &e):
+			crterrors.AbortWithError(ctx, int(e.Code), err, "error while verifying email code")
+		default:
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unexpected error while verifying email code")
+		}
+		return
+	}
+	ctx.Status(http.StatusOK)
+	log.Info(ctx, "Verified email code")
+}
+
 // VerifyActivationCodeHandler validates the activation code passed in by the user as a form value
 func (s *Signup) VerifyActivationCodeHandler(ctx *gin.Context) {
 	body := map[string]interface{}{}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// names of the Server-Sent Events sent in the signup streams
+const (
+	// SignupStreamEventSignup contains a snapshot of the Signup resource while the user is not ready
+	SignupStreamEventSignup = "signup"
+	// SignupStreamEventComplete contains the snapshot of the Signup resource once the user is ready. It's the last event of the stream.
+	SignupStreamEventComplete = "complete"
+	// SignupStreamEventError contains the error which occurred while getting the Signup resource. It's the last event of the stream.
+	SignupStreamEventError = "error"
+)
+
+// writeTimeout is the maximum duration of a write in the signup streams
+const writeTimeout = 30 * time.Second
+
+// SignupStream implements the endpoint streaming the status of the signup of the user with Server-Sent Events,
+// so that the clients don't need to poll the signup endpoint until the user is provisioned.
+type SignupStream struct {
+	app      application.Application
+	notifier *signup.ChangeNotifier
+}
+
+// NewSignupStream returns a new SignupStream instance, which is notified of the changes in the signups by the given notifier.
+func NewSignupStream(app application.Application, notifier *signup.ChangeNotifier) *SignupStream {
+	return &SignupStream{
+		app:      app,
+		notifier: notifier,
+	}
+}
+
+// GetHandler streams a snapshot of the Signup resource each time the UserSignup or the MasterUserRecord of the user change,
+// with heartbeats in-between. The stream is closed once the user is ready, or after the configured max duration.
+func (s *SignupStream) GetHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	// subscribe before getting the first snapshot, so that no change is missed
+	changes, unsubscribe := s.notifier.Subscribe(signupcommon.EncodeUserIdentifier(username))
+	defer unsubscribe()
+
+	snapshot, err := s.app.SignupService().GetSignup(ctx, username, true)
+	if err != nil {
+		log.Error(ctx, err, "error getting UserSignup resource")
+		crterrors.AbortWithError(ctx, signupErrorCode(err), err, "error getting UserSignup resource")
+		return
+	}
+	if snapshot == nil {
+		log.Infof(ctx, "UserSignup resource for username '%s' resource not found", username)
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	cfg := configuration.GetRegistrationServiceConfig()
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // disable the buffering in the reverse proxies
+	ctx.Status(http.StatusOK)
+	rc := http.NewResponseController(ctx.Writer)
+	heartbeat := time.NewTicker(cfg.SignupStreamHeartbeatInterval())
+	defer heartbeat.Stop()
+	maxDuration := time.NewTimer(cfg.SignupStreamMaxDuration())
+	defer maxDuration.Stop()
+
+	var lastSent []byte
+	for {
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			log.Error(ctx, err, "unable to marshal the Signup resource")
+			return
+		}
+		// the MasterUserRecords change more often than the snapshots
+		if !bytes.Equal(data, lastSent) {
+			event := SignupStreamEventSignup
+			if snapshot.Status.Ready {
+				event = SignupStreamEventComplete
+			}
+			if err := writeServerSentEvent(ctx, rc, event, data); err != nil {
+				log.Error(ctx, err, "unable to send the Signup resource")
+				return
+			}
+			lastSent = data
+		}
+		if snapshot.Status.Ready {
+			return
+		}
+
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Request.Context().Done():
+				return
+			case <-maxDuration.C:
+				return
+			case <-heartbeat.C:
+				if err := write(rc, ctx.Writer, []byte(": heartbeat\n\n")); err != nil {
+					return
+				}
+			case <-changes:
+				changed = true
+			}
+		}
+
+		snapshot, err = s.app.SignupService().GetSignup(ctx, username, true)
+		if err != nil || snapshot == nil {
+			code, message := http.StatusNotFound, fmt.Sprintf("UserSignup resource for username '%s' resource not found", username)
+			if err != nil {
+				log.Error(ctx, err, "error getting UserSignup resource")
+				code, message = signupErrorCode(err), err.Error()
+			}
+			data, _ := json.Marshal(&crterrors.Error{
+				Status:  http.StatusText(code),
+				Code:    code,
+				Message: message,
+				Details: "error getting UserSignup resource",
+			})
+			_ = writeServerSentEvent(ctx, rc, SignupStreamEventError, data)
+			return
+		}
+	}
+}
+
+// signupErrorCode returns the status code of the given error if it's a StatusError, or 500
+func signupErrorCode(err error) int {
+	e := &apierrors.StatusError{}
+	if errors.As(err, &e) {
+		return int(e.Status().Code)
+	}
+	return http.StatusInternalServerError
+}
+
+// writeServerSentEvent sends an event with the given name and data, which must not contain line breaks
+func writeServerSentEvent(ctx *gin.Context, rc *http.ResponseController, event string, data []byte) error {
+	return write(rc, ctx.Writer, []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)))
+}
+
+// write writes and flushes the given content, with a deadline which replaces the write timeout of the server
+func write(rc *http.ResponseController, w http.ResponseWriter, content []byte) error {
+	// the deadline can't be set in the tests
+	if err := rc.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
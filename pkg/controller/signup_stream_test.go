@@ -0,0 +1,246 @@
+package controller_test
+
+import (
+	"bufio"
+	gocontext "context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestSignupStreamSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunSignupStreamSuite(t *testing.T) {
+	suite.Run(t, &TestSignupStreamSuite{test.UnitTestSuite{}})
+}
+
+// openStream starts a server with the stream of the given user, and returns the response of the request to the stream
+func (s *TestSignupStreamSuite) openStream(stream *controller.SignupStream, username string) *http.Response {
+	router := gin.New()
+	router.GET("/api/v1/signup/events", func(ctx *gin.Context) {
+		ctx.Set(context.UsernameKey, username)
+		stream.GetHandler(ctx)
+	})
+	srv := httptest.NewServer(router)
+	s.T().Cleanup(srv.Close)
+
+	resp, err := (&http.Client{Transport: &http.Transport{}}).Get(srv.URL + "/api/v1/signup/events")
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	return resp
+}
+
+// serverSentEvent is an event or a comment received in a stream
+type serverSentEvent struct {
+	event   string
+	data    string
+	comment string
+}
+
+// readEvent reads the next event or comment of the stream
+func (s *TestSignupStreamSuite) readEvent(reader *bufio.Reader) (serverSentEvent, error) {
+	sse := serverSentEvent{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return sse, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return sse, nil
+		case strings.HasPrefix(line, ": "):
+			sse.comment = strings.TrimPrefix(line, ": ")
+		case strings.HasPrefix(line, "event: "):
+			sse.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			sse.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func (s *TestSignupStreamSuite) readSignup(reader *bufio.Reader, expectedEvent string) *signup.Signup {
+	sse, err := s.readEvent(reader)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), expectedEvent, sse.event)
+	snapshot := &signup.Signup{}
+	require.NoError(s.T(), json.Unmarshal([]byte(sse.data), snapshot))
+	return snapshot
+}
+
+func (s *TestSignupStreamSuite) TestGetHandler() {
+	newUserSignup := func() *toolchainv1alpha1.UserSignup {
+		return testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("ted@kubesaw"),
+			testusersignup.SignupIncomplete(toolchainv1alpha1.UserSignupProvisioningSpaceReason, ""),
+			testusersignup.ApprovedAutomaticallyAgo(time.Second),
+			testusersignup.WithCompliantUsername("ted"))
+	}
+
+	s.Run("stream closed once provisioned", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		userSignup := newUserSignup()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		notifier := signup.NewChangeNotifier()
+		resp := s.openStream(controller.NewSignupStream(application, notifier), "ted@kubesaw")
+		require.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(s.T(), "text/event-stream", resp.Header.Get("Content-Type"))
+		reader := bufio.NewReader(resp.Body)
+
+		// then
+		snapshot := s.readSignup(reader, controller.SignupStreamEventSignup)
+		assert.Equal(s.T(), userSignup.Name, snapshot.Name)
+		assert.Equal(s.T(), toolchainv1alpha1.UserSignupProvisioningSpaceReason, snapshot.Status.Reason)
+		assert.False(s.T(), snapshot.Status.Ready)
+
+		s.Run("snapshot sent when the MasterUserRecord is created", func() {
+			// when
+			userSignup.Status.Conditions, _ = condition.AddOrUpdateStatusConditions(userSignup.Status.Conditions, toolchainv1alpha1.Condition{
+				Type:   toolchainv1alpha1.UserSignupComplete,
+				Status: corev1.ConditionTrue,
+			})
+			require.NoError(s.T(), fakeClient.Status().Update(gocontext.TODO(), userSignup))
+			mur := fake.NewMasterUserRecord("ted")
+			mur.Status.Conditions = []toolchainv1alpha1.Condition{{
+				Type:   toolchainv1alpha1.MasterUserRecordReady,
+				Status: corev1.ConditionFalse,
+				Reason: toolchainv1alpha1.MasterUserRecordProvisioningReason,
+			}}
+			require.NoError(s.T(), fakeClient.Create(gocontext.TODO(), mur))
+			notifier.Notify(userSignup.Name)
+
+			// then
+			snapshot := s.readSignup(reader, controller.SignupStreamEventSignup)
+			assert.Equal(s.T(), toolchainv1alpha1.MasterUserRecordProvisioningReason, snapshot.Status.Reason)
+			assert.False(s.T(), snapshot.Status.Ready)
+
+			s.Run("complete snapshot sent when the MasterUserRecord is ready", func() {
+				// when
+				mur.Status.Conditions[0].Status = corev1.ConditionTrue
+				mur.Status.Conditions[0].Reason = toolchainv1alpha1.MasterUserRecordProvisionedReason
+				require.NoError(s.T(), fakeClient.Status().Update(gocontext.TODO(), mur))
+				notifier.Notify(userSignup.Name)
+
+				// then
+				snapshot := s.readSignup(reader, controller.SignupStreamEventComplete)
+				assert.True(s.T(), snapshot.Status.Ready)
+				_, err := s.readEvent(reader)
+				assert.Equal(s.T(), io.EOF, err)
+			})
+		})
+	})
+
+	s.Run("heartbeats sent", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"signup.stream.heartbeatInterval": "10ms",
+		})
+		_, application := testutil.PrepareInClusterApp(s.T(), newUserSignup())
+		resp := s.openStream(controller.NewSignupStream(application, signup.NewChangeNotifier()), "ted@kubesaw")
+		reader := bufio.NewReader(resp.Body)
+		s.readSignup(reader, controller.SignupStreamEventSignup)
+
+		// when
+		sse, err := s.readEvent(reader)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), serverSentEvent{comment: "heartbeat"}, sse)
+	})
+
+	s.Run("stream closed after the max duration", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"signup.stream.maxDuration": "50ms",
+		})
+		_, application := testutil.PrepareInClusterApp(s.T(), newUserSignup())
+		resp := s.openStream(controller.NewSignupStream(application, signup.NewChangeNotifier()), "ted@kubesaw")
+		reader := bufio.NewReader(resp.Body)
+		s.readSignup(reader, controller.SignupStreamEventSignup)
+
+		// when
+		_, err := s.readEvent(reader)
+
+		// then
+		assert.Equal(s.T(), io.EOF, err)
+	})
+
+	s.Run("error sent when the UserSignup is deleted", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		userSignup := newUserSignup()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		notifier := signup.NewChangeNotifier()
+		resp := s.openStream(controller.NewSignupStream(application, notifier), "ted@kubesaw")
+		reader := bufio.NewReader(resp.Body)
+		s.readSignup(reader, controller.SignupStreamEventSignup)
+
+		// when
+		require.NoError(s.T(), fakeClient.Delete(gocontext.TODO(), userSignup))
+		notifier.Notify(userSignup.Name)
+
+		// then
+		sse, err := s.readEvent(reader)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), controller.SignupStreamEventError, sse.event)
+		e := &crterrors.Error{}
+		require.NoError(s.T(), json.Unmarshal([]byte(sse.data), e))
+		assert.Equal(s.T(), http.StatusNotFound, e.Code)
+		_, err = s.readEvent(reader)
+		assert.Equal(s.T(), io.EOF, err)
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		resp := s.openStream(controller.NewSignupStream(application, signup.NewChangeNotifier()), "ted@kubesaw")
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("signup service error", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		fakeClient, application := testutil.PrepareInClusterApp(s.T())
+		fakeClient.MockGet = func(_ gocontext.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+			return io.ErrUnexpectedEOF
+		}
+
+		// when
+		resp := s.openStream(controller.NewSignupStream(application, signup.NewChangeNotifier()), "ted@kubesaw")
+
+		// then
+		assert.Equal(s.T(), http.StatusInternalServerError, resp.StatusCode)
+	})
+}
@@ -250,9 +250,77 @@ func (s *TestSignupSuite) TestSignupGetHandler() {
 	})
 }
 
+func (s *TestSignupSuite) TestSignupGetByUsernameHandler() {
+	// given
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/machine/signups/ted@kubesaw", nil)
+	require.NoError(s.T(), err)
+
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("ted@kubesaw"),
+		testusersignup.SignupIncomplete("Provisioning", ""),
+		testusersignup.ApprovedAutomaticallyAgo(time.Second),
+		testusersignup.WithCompliantUsername("ted"),
+		testusersignup.WithHomeSpace("ted"),
+	)
+	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+	// Create Signup controller instance.
+	ctrl := controller.NewSignup(application)
+	handler := gin.HandlerFunc(ctrl.GetByUsernameHandler)
+
+	s.Run("signup found", func() {
+		// given
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+		ctx.Params = gin.Params{{Key: "username", Value: "ted@kubesaw"}}
+		ctx.Set(context.PrincipalKey, "onboarding-bot")
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusOK, rr.Code, "handler returned wrong status code")
+		data := &signup.Signup{}
+		err = json.Unmarshal(rr.Body.Bytes(), &data)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "ted@kubesaw", data.Username)
+		assert.Equal(s.T(), "ted", data.CompliantUsername)
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+		ctx.Params = gin.Params{{Key: "username", Value: "dummy"}}
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code, "handler returned wrong status code")
+	})
+
+	s.Run("no username", func() {
+		// given
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code, "handler returned wrong status code")
+	})
+}
+
 func (s *TestSignupSuite) TestInitVerificationHandler() {
 	// call override config to ensure the factory option takes effect
-	s.OverrideApplicationDefault()
+	s.SetSettings(map[string]string{
+		"verification.codeHashKey": "hash-key",
+	})
 
 	// Create UserSignup
 	userSignup := testusersignup.NewUserSignup(
@@ -371,6 +439,67 @@ func (s *TestSignupSuite) TestInitVerificationHandler() {
 		assert.Equal(s.T(), http.StatusForbidden, rr.Code, "handler returned wrong status code")
 	})
 
+	s.Run("init verification handler fails when premium rate number provided", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.phone.blockedNumberTypes": "premium_rate",
+			"verification.codeHashKey":              "hash-key",
+		})
+		_, handler := prepareVerificationHandler(s.T(), userSignup)
+		data := []byte(`{"phone_number": "9098765432", "country_code": "44"}`)
+
+		// when
+		rr := initPhoneVerification(s.T(), handler, gin.Param{}, data, "johnny@kubesaw", http.MethodPut, "/api/v1/signup/verification")
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+
+		bodyParams := make(map[string]interface{})
+		err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+		require.NoError(s.T(), err)
+
+		require.Equal(s.T(), "phone number not allowed: premium rate numbers cannot be used for the verification", bodyParams["message"])
+		require.Equal(s.T(), "phone number not allowed", bodyParams["details"])
+	})
+
+	s.Run("init verification handler fails when invalid channel provided", func() {
+		// given
+		_, handler := prepareVerificationHandler(s.T(), userSignup)
+		data := []byte(`{"phone_number": "2268213044", "country_code": "1", "channel": "fax"}`)
+
+		// when
+		rr := initPhoneVerification(s.T(), handler, gin.Param{}, data, "johnny@kubesaw", http.MethodPut, "/api/v1/signup/verification")
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+
+		bodyParams := make(map[string]interface{})
+		err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+		require.NoError(s.T(), err)
+
+		require.Equal(s.T(), "the channel must be 'sms' or 'voice'", bodyParams["message"])
+		require.Equal(s.T(), "invalid channel", bodyParams["details"])
+	})
+
+	s.Run("init verification handler fails when voice channel not allowed", func() {
+		// given
+		_, handler := prepareVerificationHandler(s.T(), userSignup)
+		data := []byte(`{"phone_number": "2268213044", "country_code": "1", "channel": "voice"}`)
+
+		// when
+		rr := initPhoneVerification(s.T(), handler, gin.Param{}, data, "johnny@kubesaw", http.MethodPut, "/api/v1/signup/verification")
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+
+		bodyParams := make(map[string]interface{})
+		err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+		require.NoError(s.T(), err)
+
+		require.Equal(s.T(), "voice verification is not available", bodyParams["message"])
+		require.Equal(s.T(), "verification channel not allowed", bodyParams["details"])
+	})
+
 	s.Run("init verification handler fails when verification not required", func() {
 		// given
 		// Create UserSignup
@@ -799,3 +928,312 @@ func initActivationCodeVerification(t *testing.T, handler gin.HandlerFunc, usern
 	handler(ctx)
 	return rr
 }
+
+func (s *TestSignupSuite) setVerificationMethods(methods string) {
+	s.SetSettings(map[string]string{
+		"verification.methods": methods,
+	})
+}
+
+func (s *TestSignupSuite) TestInitEmailVerificationHandler() {
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.VerificationRequiredAgo(time.Second))
+
+	s.Run("email verification not allowed", func() {
+		// given
+		s.setVerificationMethods("phone")
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.InitEmailVerificationHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodPut, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusForbidden, rr.Code)
+		bodyParams := make(map[string]interface{})
+		err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), "verification method not allowed: email verification is not available", bodyParams["message"])
+	})
+
+	s.Run("verification not required", func() {
+		// given
+		s.setVerificationMethods("phone,email")
+		_, application := testutil.PrepareInClusterApp(s.T(), testusersignup.NewUserSignup(testusersignup.WithEncodedName("johnny@kubesaw")))
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.InitEmailVerificationHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodPut, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+}
+
+func (s *TestSignupSuite) TestVerifyEmailHandler() {
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.VerificationRequiredAgo(time.Second),
+		testusersignup.WithAnnotation(crtapi.UserVerificationAttemptsAnnotationKey, "0"),
+		testusersignup.WithAnnotation(crtapi.UserSignupVerificationCodeAnnotationKey, "999888"),
+		testusersignup.WithAnnotation(service.VerificationMethodAnnotationKey, "email"),
+		testusersignup.WithAnnotation(crtapi.UserVerificationExpiryAnnotationKey, time.Now().Add(10*time.Second).Format(service.TimestampLayout)))
+
+	s.Run("verification successful", func() {
+		// given
+		s.setVerificationMethods("email")
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.VerifyEmailHandler, gin.Param{}, []byte(`{"code":"999888"}`), "johnny@kubesaw", http.MethodPost, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		updatedUserSignup := &crtapi.UserSignup{}
+		err := fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updatedUserSignup)
+		require.NoError(s.T(), err)
+		require.False(s.T(), states.VerificationRequired(updatedUserSignup))
+		require.Empty(s.T(), updatedUserSignup.Annotations[crtapi.UserSignupVerificationCodeAnnotationKey])
+	})
+
+	s.Run("invalid code", func() {
+		// given
+		s.setVerificationMethods("email")
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.VerifyEmailHandler, gin.Param{}, []byte(`{"code":"111111"}`), "johnny@kubesaw", http.MethodPost, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusForbidden, rr.Code)
+		bodyParams := make(map[string]interface{})
+		err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), "error while verifying email code", bodyParams["details"])
+		updatedUserSignup := &crtapi.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updatedUserSignup)
+		require.NoError(s.T(), err)
+		require.True(s.T(), states.VerificationRequired(updatedUserSignup))
+		require.Equal(s.T(), "1", updatedUserSignup.Annotations[crtapi.UserVerificationAttemptsAnnotationKey])
+	})
+
+	s.Run("invalid link", func() {
+		// given
+		s.setVerificationMethods("email")
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.VerifyEmailHandler, gin.Param{}, []byte(`{"token":"invalid"}`), "johnny@kubesaw", http.MethodPost, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+
+	s.Run("email verification not allowed", func() {
+		// given
+		s.setVerificationMethods("phone")
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.VerifyEmailHandler, gin.Param{}, []byte(`{"code":"999888"}`), "johnny@kubesaw", http.MethodPost, "/api/v1/signup/verification/email")
+
+		// then
+		require.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+
+	s.Run("neither code nor token provided", func() {
+		for name, tc := range map[string]struct {
+			body    string
+			message string
+		}{
+			"empty body": {
+				body:    `{}`,
+				message: "the request body contains neither the code nor the token",
+			},
+			"code and token": {
+				body:    `{"code":"999888","token":"abc"}`,
+				message: "the request body contains both the code and the token",
+			},
+			"invalid body": {
+				body:    `code`,
+				message: "invalid request body: invalid character 'c' looking for beginning of value",
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				s.setVerificationMethods("email")
+				_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+				ctrl := controller.NewSignup(application)
+
+				// when
+				rr := initPhoneVerification(s.T(), ctrl.VerifyEmailHandler, gin.Param{}, []byte(tc.body), "johnny@kubesaw", http.MethodPost, "/api/v1/signup/verification/email")
+
+				// then
+				require.Equal(s.T(), http.StatusBadRequest, rr.Code)
+				bodyParams := make(map[string]interface{})
+				err := json.Unmarshal(rr.Body.Bytes(), &bodyParams)
+				require.NoError(s.T(), err)
+				require.Equal(s.T(), tc.message, bodyParams["message"])
+				require.Equal(s.T(), "request body must contain either the code or the token", bodyParams["details"])
+			})
+		}
+	})
+}
+
+func (s *TestSignupSuite) TestDeleteHandler() {
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.ApprovedAutomaticallyAgo(time.Second))
+
+	s.Run("deactivation requested then confirmed", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.DeleteHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodDelete, "/api/v1/signup")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		confirmation := &signup.DeactivationConfirmation{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), confirmation))
+		require.NotEmpty(s.T(), confirmation.Token)
+		require.NotEmpty(s.T(), confirmation.ExpiresAt)
+
+		s.Run("invalid confirmation token", func() {
+			// when
+			rr := initPhoneVerification(s.T(), ctrl.DeleteHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodDelete, "/api/v1/signup?confirmation=invalid")
+
+			// then
+			require.Equal(s.T(), http.StatusForbidden, rr.Code)
+			bodyParams := make(map[string]interface{})
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &bodyParams))
+			require.Equal(s.T(), "error while deactivating the user", bodyParams["details"])
+		})
+
+		s.Run("valid confirmation token", func() {
+			// when
+			rr := initPhoneVerification(s.T(), ctrl.DeleteHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodDelete, "/api/v1/signup?confirmation="+confirmation.Token)
+
+			// then
+			require.Equal(s.T(), http.StatusAccepted, rr.Code)
+			updatedUserSignup := &crtapi.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updatedUserSignup))
+			require.True(s.T(), states.Deactivated(updatedUserSignup))
+		})
+	})
+
+	s.Run("usersignup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.DeleteHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodDelete, "/api/v1/signup")
+
+		// then
+		require.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+}
+
+func (s *TestSignupSuite) TestExportHandler() {
+	s.Run("data exported", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.ApprovedAutomaticallyAgo(time.Second),
+			testusersignup.WithAnnotation(crtapi.UserSignupVerificationCodeAnnotationKey, "999888"))
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.ExportHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodGet, "/api/v1/signup/export")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		export := &signup.Export{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), export))
+		assert.Equal(s.T(), userSignup.Name, export.Name)
+		assert.Equal(s.T(), userSignup.Spec.IdentityClaims, export.IdentityClaims)
+		assert.NotContains(s.T(), export.Annotations, crtapi.UserSignupVerificationCodeAnnotationKey)
+	})
+
+	s.Run("usersignup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.ExportHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodGet, "/api/v1/signup/export")
+
+		// then
+		require.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+}
+
+func (s *TestSignupSuite) TestInvitationHandlers() {
+	// given
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.ApprovedAutomaticallyAgo(time.Second),
+		testusersignup.SignupComplete(""))
+	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+	ctrl := controller.NewSignup(application)
+
+	s.Run("invitation created", func() {
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.CreateInvitationHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodPost, "/api/v1/signup/invitations")
+
+		// then
+		require.Equal(s.T(), http.StatusCreated, rr.Code)
+		invitation := &signup.Invitation{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), invitation))
+		assert.True(s.T(), signup.IsInvitationCode(invitation.Code))
+		assert.NotEmpty(s.T(), invitation.ExpiresAt)
+
+		s.Run("invitations listed", func() {
+			// when
+			rr := initPhoneVerification(s.T(), ctrl.ListInvitationsHandler, gin.Param{}, nil, "johnny@kubesaw", http.MethodGet, "/api/v1/signup/invitations")
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rr.Code)
+			invitations := &signup.Invitations{}
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), invitations))
+			require.Len(s.T(), invitations.Items, 1)
+			assert.Equal(s.T(), invitation.ID, invitations.Items[0].ID)
+			assert.Empty(s.T(), invitations.Items[0].Code)
+		})
+	})
+
+	s.Run("user not provisioned", func() {
+		// given
+		pending := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jane@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, application := testutil.PrepareInClusterApp(s.T(), pending)
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.CreateInvitationHandler, gin.Param{}, nil, "jane@kubesaw", http.MethodPost, "/api/v1/signup/invitations")
+
+		// then
+		require.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+
+	s.Run("usersignup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		ctrl := controller.NewSignup(application)
+
+		// when
+		rr := initPhoneVerification(s.T(), ctrl.ListInvitationsHandler, gin.Param{}, nil, "jane@kubesaw", http.MethodGet, "/api/v1/signup/invitations")
+
+		// then
+		require.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+}
@@ -1,8 +1,12 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,10 +16,16 @@ type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details"`
+	// RetryAfter is the delay after which the client may retry the request, returned in the `Retry-After` header
+	RetryAfter time.Duration `json:"-"`
 }
 
 // AbortWithError stops the chain, writes the status code and the given error
 func AbortWithError(ctx *gin.Context, code int, err error, details string) {
+	e := &Error{}
+	if errors.As(err, &e) && e.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
 	ctx.AbortWithStatusJSON(code, &Error{
 		Status:  http.StatusText(code),
 		Code:    code,
@@ -58,6 +68,12 @@ func NewTooManyRequestsError(message, details string) *Error {
 	}
 }
 
+// WithRetryAfter sets the delay after which the client may retry the request
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	e.RetryAfter = retryAfter
+	return e
+}
+
 func NewInternalError(err error, details string) *Error {
 	return &Error{
 		Status:  http.StatusText(http.StatusInternalServerError),
@@ -67,6 +83,15 @@ func NewInternalError(err error, details string) *Error {
 	}
 }
 
+func NewServiceUnavailableError(err error, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusServiceUnavailable),
+		Code:    http.StatusServiceUnavailable,
+		Message: err.Error(),
+		Details: details,
+	}
+}
+
 func NewNotFoundError(err error, details string) *Error {
 	return &Error{
 		Status:  http.StatusText(http.StatusNotFound),
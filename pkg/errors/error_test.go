@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	errs "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/test"
@@ -43,6 +44,17 @@ func (s *TestErrorsSuite) TestErrors() {
 		assert.Equal(s.T(), res.Details, details)
 		assert.Equal(s.T(), res.Message, errMsg)
 		assert.Equal(s.T(), res.Status, http.StatusText(code))
+		assert.Equal(s.T(), "", rr.Header().Get("Retry-After"))
+	})
+
+	s.Run("check retry after header", func() {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+
+		errs.AbortWithError(ctx, http.StatusTooManyRequests, errs.NewTooManyRequestsError("foo", "bar").WithRetryAfter(1500*time.Millisecond), "bar")
+
+		assert.Equal(s.T(), http.StatusTooManyRequests, rr.Code)
+		assert.Equal(s.T(), "2", rr.Header().Get("Retry-After"))
 	})
 
 	s.Run("check specific error types", func() {
@@ -72,6 +84,12 @@ func (s *TestErrorsSuite) TestErrors() {
 		require.Equal(s.T(), http.StatusInternalServerError, err.Code)
 		require.Equal(s.T(), http.StatusText(http.StatusInternalServerError), err.Status)
 
+		err = errs.NewServiceUnavailableError(errors.New("some error"), "bar")
+		require.Equal(s.T(), "some error", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusServiceUnavailable, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusServiceUnavailable), err.Status)
+
 		err = errs.NewNotFoundError(errors.New("some error"), "bar")
 		require.Equal(s.T(), "some error", err.Message)
 		require.Equal(s.T(), "bar", err.Details)
@@ -143,6 +143,10 @@ func (l *Logger) InfoEchof(ctx echo.Context, msg string, args ...string) {
 	ctxFields = append(ctxFields, "workspace")
 	ctxFields = append(ctxFields, workspace)
 
+	if proxyPlugin, ok := ctx.Get(context.ProxyPluginKey).(string); ok && proxyPlugin != "" {
+		ctxFields = append(ctxFields, "proxy-plugin", proxyPlugin)
+	}
+
 	ctxFields = append(ctxFields, "method")
 	ctxFields = append(ctxFields, ctx.Request().Method)
 
@@ -218,6 +222,9 @@ func addContextInfo(ctx *gin.Context) []interface{} {
 		subject := ctx.GetString(context.SubKey)
 		username := ctx.GetString(context.UsernameKey)
 		fields := genericContext(subject, username)
+		if principal := ctx.GetString(context.PrincipalKey); principal != "" {
+			fields = append(fields, context.PrincipalKey, principal)
+		}
 		if ctx.Request != nil {
 			fields = append(fields, addRequestInfo(ctx.Request)...)
 		}
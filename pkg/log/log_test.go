@@ -87,6 +87,14 @@ func TestLog(t *testing.T) {
 				ctxSet:      map[string]interface{}{},
 				notContains: `public-viewer-enabled`,
 			},
+			"proxy-plugin is set": {
+				ctxSet:   map[string]interface{}{context.ProxyPluginKey: "myplugin"},
+				contains: `"proxy-plugin":"myplugin"`,
+			},
+			"proxy-plugin is empty": {
+				ctxSet:      map[string]interface{}{context.ProxyPluginKey: ""},
+				notContains: `proxy-plugin`,
+			},
 		}
 
 		for _, tc := range tt {
@@ -8,27 +8,31 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 
 	"github.com/gin-gonic/gin"
 )
 
 // JWTMiddleware is the JWT token validation middleware
 type JWTMiddleware struct {
-	tokenParser *auth.TokenParser
+	tokenParser       *auth.TokenParser
+	revocationChecker *auth.RevocationChecker
 }
 
-// NewAuthMiddleware returns a new middleware for JWT authentication
-func NewAuthMiddleware() (*JWTMiddleware, error) {
+// NewAuthMiddleware returns a new middleware for JWT authentication.
+// The given client is used to read the denylist of revoked tokens.
+func NewAuthMiddleware(nsClient namespaced.Client) (*JWTMiddleware, error) {
 	tokenParserInstance, err := auth.DefaultTokenParser()
 	if err != nil {
 		return nil, err
 	}
 	return &JWTMiddleware{
-		tokenParser: tokenParserInstance,
+		tokenParser:       tokenParserInstance,
+		revocationChecker: auth.NewRevocationChecker(nsClient),
 	}, nil
 }
 
-func (m *JWTMiddleware) extractToken(c *gin.Context) (string, error) {
+func extractToken(c *gin.Context) (string, error) {
 	// token lookup: header: Authorization
 	// try header field "Authorization" (will be "" when n/a)
 	headerToken := c.GetHeader("Authorization")
@@ -48,23 +52,37 @@ func (m *JWTMiddleware) extractToken(c *gin.Context) (string, error) {
 	return "", errors.New("no token found")
 }
 
-func (m *JWTMiddleware) respondWithError(c *gin.Context, code int, message interface{}) {
+func respondWithError(c *gin.Context, code int, message interface{}) {
 	c.AbortWithStatusJSON(code, gin.H{"error": message})
 }
 
+// authErrorStatus returns the status of the response to a request which failed the authentication with the given error:
+// 503 if the token could not be checked because a dependency is unavailable, 401 otherwise
+func authErrorStatus(err error) int {
+	if errors.Is(err, auth.ErrRevocationCheckUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnauthorized
+}
+
 // HandlerFunc returns the HanderFunc.
 func (m *JWTMiddleware) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// check if we have a token
-		tokenStr, err := m.extractToken(c)
+		tokenStr, err := extractToken(c)
 		if err != nil {
-			m.respondWithError(c, http.StatusUnauthorized, err.Error())
+			respondWithError(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 		// next, check the token
 		token, err := m.tokenParser.FromString(tokenStr)
 		if err != nil {
-			m.respondWithError(c, http.StatusUnauthorized, err.Error())
+			respondWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		// then, make sure that the token has not been revoked
+		if err := m.revocationChecker.CheckToken(c.Request.Context(), tokenStr, token); err != nil {
+			respondWithError(c, authErrorStatus(err), err.Error())
 			return
 		}
 
@@ -20,12 +20,15 @@ import (
 	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
 	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type TestAuthMiddlewareSuite struct {
@@ -38,7 +41,7 @@ func TestRunAuthMiddlewareSuite(t *testing.T) {
 
 func (s *TestAuthMiddlewareSuite) TestAuthMiddleware() {
 	s.Run("create with DefaultTokenParser failing", func() {
-		authMiddleware, err := middleware.NewAuthMiddleware()
+		authMiddleware, err := middleware.NewAuthMiddleware(namespaced.NewClient(s.ConfigClient, commontest.HostOperatorNs))
 		require.Nil(s.T(), authMiddleware)
 		require.Error(s.T(), err)
 		require.Equal(s.T(), "no default TokenParser created, call `InitializeDefaultTokenParser()` first", err.Error())
@@ -72,6 +75,24 @@ func (s *TestAuthMiddlewareSuite) TestAuthMiddlewareService() {
 	tokenInvalidExpiredJWT := tokengenerator.GenerateToken(identity0, kid0, emailClaim0, expClaim)
 	tokenInvalidExpired, err := tokengenerator.SignToken(tokenInvalidExpiredJWT, kid0)
 	require.NoError(s.T(), err)
+	// invalid token - revoked
+	tokenRevoked, err := tokengenerator.GenerateSignedToken(identity0, kid0, emailClaim0, func(token *jwt.Token) {
+		token.Claims.(*authsupport.MyClaims).ID = "revoked-jti"
+	})
+	require.NoError(s.T(), err)
+	// client credentials tokens
+	clientToken := func(clientID string) string {
+		token, err := tokengenerator.GenerateSignedToken(identity0, kid0, func(token *jwt.Token) {
+			token.Claims = jwt.MapClaims{
+				"client_id": clientID,
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			}
+		})
+		require.NoError(s.T(), err)
+		return token
+	}
+	tokenOnboardingBot := clientToken("onboarding-bot")
+	tokenUnknownClient := clientToken("unknown")
 
 	// start key service
 	keysEndpointURL := tokengenerator.NewKeyServer().URL
@@ -80,7 +101,10 @@ func (s *TestAuthMiddlewareSuite) TestAuthMiddlewareService() {
 	srv := server.New(util.PrepareInClusterApplication(s.T()))
 
 	// set the key service url in the config
-	s.SetConfig(testconfig.RegistrationService().
+	s.SetSettings(map[string]string{
+		"auth.revocation.revokedTokensConfigMap": "revoked-tokens",
+		"auth.machinePrincipals":                 `[{"name": "onboarding-bot", "scopes": ["usernames:read"]}]`,
+	}, testconfig.RegistrationService().
 		Environment(configuration.UnitTestsEnvironment).
 		Auth().AuthClientPublicKeysURL(keysEndpointURL))
 
@@ -88,7 +112,15 @@ func (s *TestAuthMiddlewareSuite) TestAuthMiddlewareService() {
 	assert.Equal(s.T(), keysEndpointURL, cfg.Auth().AuthClientPublicKeysURL(), "key url not set correctly")
 
 	// Setting up the routes.
-	nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+	nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "revoked-tokens",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string]string{
+			"revoked-jti": "",
+		},
+	}), commontest.HostOperatorNs)
 	err = srv.SetupRoutes(proxy.DefaultPort, prometheus.NewRegistry(), nsClient)
 	require.NoError(s.T(), err)
 
@@ -153,9 +185,16 @@ func (s *TestAuthMiddlewareSuite) TestAuthMiddlewareService() {
 			{"auth_test, valid header auth", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenValid, http.StatusOK},
 			{"auth_test, invalid header auth, no email claim", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenInvalidNoEmail, http.StatusUnauthorized},
 			{"auth_test, invalid header auth, expired", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenInvalidExpired, http.StatusUnauthorized},
+			{"auth_test, invalid header auth, revoked", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenRevoked, http.StatusUnauthorized},
 			{"auth_test, invalid header auth, token garbage", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenInvalidGarbage, http.StatusUnauthorized},
 			{"auth_test, invalid header auth, wrong header format", "/api/v1/auth_test", http.MethodGet, tokenValid, http.StatusUnauthorized},
 			{"auth_test, invalid header auth, bearer but no token", "/api/v1/auth_test", http.MethodGet, "Bearer ", http.StatusUnauthorized},
+			{"auth_test, client credentials token", "/api/v1/auth_test", http.MethodGet, "Bearer " + tokenOnboardingBot, http.StatusUnauthorized},
+			{"machine, no auth, denied", "/api/v1/machine/usernames/john", http.MethodGet, "", http.StatusUnauthorized},
+			{"machine, user token, denied", "/api/v1/machine/usernames/john", http.MethodGet, "Bearer " + tokenValid, http.StatusUnauthorized},
+			{"machine, unknown client, denied", "/api/v1/machine/usernames/john", http.MethodGet, "Bearer " + tokenUnknownClient, http.StatusUnauthorized},
+			{"machine, client with scope", "/api/v1/machine/usernames/john", http.MethodGet, "Bearer " + tokenOnboardingBot, http.StatusNotFound},
+			{"machine, client without scope", "/api/v1/machine/signups/john", http.MethodGet, "Bearer " + tokenOnboardingBot, http.StatusForbidden},
 		}
 		for _, tt := range authtests {
 			s.Run(tt.name, func() {
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MachineAuthMiddleware is the authentication middleware of the machine API, ie, the API called by the automation tools
+// with a client credentials token or with a Kubernetes ServiceAccount token.
+type MachineAuthMiddleware struct {
+	authenticator *auth.MachineAuthenticator
+}
+
+// NewMachineAuthMiddleware returns a new middleware for machine-to-machine authentication.
+// The given client is used to review the ServiceAccount tokens and to read the denylist of revoked tokens.
+func NewMachineAuthMiddleware(nsClient namespaced.Client) (*MachineAuthMiddleware, error) {
+	tokenParserInstance, err := auth.DefaultTokenParser()
+	if err != nil {
+		return nil, err
+	}
+	return &MachineAuthMiddleware{
+		authenticator: auth.NewMachineAuthenticator(tokenParserInstance, auth.NewTokenReviewer(nsClient.Client), auth.NewRevocationChecker(nsClient)),
+	}, nil
+}
+
+// HandlerFunc returns the HandlerFunc which authenticates the principal and adds it to the context
+func (m *MachineAuthMiddleware) HandlerFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenStr, err := extractToken(c)
+		if err != nil {
+			respondWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		principal, err := m.authenticator.Authenticate(c.Request.Context(), tokenStr)
+		if err != nil {
+			log.Error(c, err, "machine authentication failed")
+			respondWithError(c, authErrorStatus(err), err.Error())
+			return
+		}
+		c.Set(context.PrincipalKey, principal.Name)
+		c.Set(context.PrincipalKindKey, principal.Kind)
+		c.Set(context.PrincipalScopesKey, principal.Scopes)
+		// audit the calls made by the principal
+		log.Infof(c, "%s '%s' calling %s %s", principal.Kind, principal.Name, c.Request.Method, c.FullPath())
+		c.Next()
+	}
+}
+
+// RequireScope returns the HandlerFunc which rejects the request if the principal was not granted the given scope.
+// This HandlerFunc must be executed after the one of the MachineAuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(c.GetStringSlice(context.PrincipalScopesKey), scope) {
+			log.Infof(c, "%s '%s' is missing the '%s' scope", c.GetString(context.PrincipalKindKey), c.GetString(context.PrincipalKey), scope)
+			respondWithError(c, http.StatusForbidden, "missing scope: "+scope)
+			return
+		}
+		c.Next()
+	}
+}
@@ -85,6 +85,9 @@ func (s *PromHTTPMiddlewareSuite) TestPromHTTPMiddleware() {
 			"method": "GET",
 			"path":   "/api/v1/segment-write-key",
 		})
+		assertMetricExists(s.T(), resp.Body.Bytes(), "sandbox_auth_public_keys_age_seconds", map[string]string{
+			"issuer": "default",
+		})
 	})
 }
 
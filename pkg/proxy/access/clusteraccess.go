@@ -12,14 +12,42 @@ type ClusterAccess struct { // nolint:revive
 	impersonatorToken string
 	// username is the id of the user to use for impersonation
 	username string
+	// clusterName is the name of the member cluster
+	clusterName string
+	// caData is the PEM-encoded CA bundle used to verify the certificate of the target, if any
+	caData []byte
+	// insecure is true if the certificate of the target must not be verified
+	insecure bool
 }
 
-func NewClusterAccess(apiURL url.URL, impersonatorToken, username string) *ClusterAccess {
-	return &ClusterAccess{
+// Option configures a ClusterAccess
+type Option func(*ClusterAccess)
+
+// WithClusterName sets the name of the member cluster
+func WithClusterName(name string) Option {
+	return func(a *ClusterAccess) {
+		a.clusterName = name
+	}
+}
+
+// WithTLS sets the PEM-encoded CA bundle used to verify the certificate of the target, and whether the verification is skipped
+func WithTLS(caData []byte, insecure bool) Option {
+	return func(a *ClusterAccess) {
+		a.caData = caData
+		a.insecure = insecure
+	}
+}
+
+func NewClusterAccess(apiURL url.URL, impersonatorToken, username string, opts ...Option) *ClusterAccess {
+	a := &ClusterAccess{
 		apiURL:            apiURL,
 		impersonatorToken: impersonatorToken,
 		username:          username,
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 func (a *ClusterAccess) APIURL() url.URL {
@@ -33,3 +61,15 @@ func (a *ClusterAccess) ImpersonatorToken() string {
 func (a *ClusterAccess) Username() string {
 	return a.username
 }
+
+func (a *ClusterAccess) ClusterName() string {
+	return a.clusterName
+}
+
+func (a *ClusterAccess) CAData() []byte {
+	return a.caData
+}
+
+func (a *ClusterAccess) Insecure() bool {
+	return a.insecure
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+
+	"github.com/labstack/echo/v4"
+	errs "github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// authorize checks that the request is read-only if the access to the workspace was granted through the PublicViewer,
+// then checks it against the authorization policies. It returns a Forbidden StatusError if the request is denied.
+func (p *Proxy) authorize(ctx echo.Context, proxyPluginName string, workspace *toolchainv1alpha1.Workspace, cluster *access.ClusterAccess) (*apierrors.StatusError, error) {
+	publicViewer := cluster.Username() == toolchainv1alpha1.KubesawAuthenticatedUsername
+	denied, err := func() (*apierrors.StatusError, error) {
+		if publicViewer {
+			if denied := checkPublicViewerReadOnly(ctx.Request(), workspace); denied != nil {
+				return denied, nil
+			}
+		}
+		// the requests to the proxy plugins are not sent to the Kubernetes API, hence not subject to the policies
+		if len(proxyPluginName) > 0 {
+			return nil, nil
+		}
+		return p.authorizeRequest(ctx, workspace, cluster)
+	}()
+	if publicViewer && workspace != nil {
+		outcome := metrics.MetricLabelAllowed
+		if err != nil || denied != nil {
+			outcome = metrics.MetricLabelRejected
+		}
+		p.metrics.RegServProxyCommunityAccessCounterVec.WithLabelValues(workspace.Name, outcome).Inc()
+	}
+	return denied, err
+}
+
+// checkPublicViewerReadOnly returns a Forbidden StatusError if the given request is not read-only, ie, if it uses
+// a mutating verb or opens a session in a pod with the exec, attach or portforward subresources
+func checkPublicViewerReadOnly(req *http.Request, workspace *toolchainv1alpha1.Workspace) *apierrors.StatusError {
+	info := policy.NewRequestInfo(req)
+	readOnly := req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions
+	switch info.Subresource {
+	case "exec", "attach", "portforward":
+		readOnly = false
+	}
+	if readOnly {
+		return nil
+	}
+	workspaceName := ""
+	if workspace != nil {
+		workspaceName = workspace.Name
+	}
+	log.Infof(nil, "%s request to %s denied in workspace '%s': the public viewer access is read-only", req.Method, req.URL.Path, workspaceName)
+	resource := info.Resource
+	if info.Subresource != "" {
+		resource += "/" + info.Subresource
+	}
+	return apierrors.NewForbidden(schema.GroupResource{Group: info.APIGroup, Resource: resource}, info.Name,
+		fmt.Errorf("the access to workspace '%s' is read-only", workspaceName))
+}
+
+// authorizeRequest checks the request against the authorization policies of the proxy, after the access to the workspace
+// was validated. It returns a Forbidden StatusError if the request is denied by a policy.
+func (p *Proxy) authorizeRequest(ctx echo.Context, workspace *toolchainv1alpha1.Workspace, cluster *access.ClusterAccess) (*apierrors.StatusError, error) {
+	policies, err := p.policies.Get(ctx.Request().Context(), configuration.GetRegistrationServiceConfig().ProxyAuthorizationPoliciesConfigMap())
+	if err != nil {
+		return nil, crterrors.NewInternalError(errs.New("unable to authorize the request"), err.Error())
+	}
+	if len(policies) == 0 || workspace == nil {
+		return nil, nil
+	}
+
+	attrs := policy.Attributes{
+		RequestInfo:  policy.NewRequestInfo(ctx.Request()),
+		Workspace:    workspace.Name,
+		Role:         workspace.Status.Role,
+		PublicViewer: cluster.Username() == toolchainv1alpha1.KubesawAuthenticatedUsername,
+	}
+	space := &toolchainv1alpha1.Space{}
+	if err := p.Get(ctx.Request().Context(), p.NamespacedName(workspace.Name), space); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, crterrors.NewInternalError(errs.New("unable to authorize the request"), err.Error())
+		}
+	} else {
+		attrs.Tier = space.Spec.TierName
+	}
+
+	if allowed, policyName := policies.Authorize(attrs); !allowed {
+		log.Infof(nil, "request to %s %s/%s in workspace '%s' denied by the '%s' policy", attrs.Verb, attrs.APIGroup, attrs.Resource, workspace.Name, policyName)
+		resource := attrs.Resource
+		if attrs.Subresource != "" {
+			resource += "/" + attrs.Subresource
+		}
+		return apierrors.NewForbidden(schema.GroupResource{Group: attrs.APIGroup, Resource: resource}, attrs.Name,
+			fmt.Errorf("denied by the '%s' policy of workspace '%s'", policyName, workspace.Name)), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,306 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test/space"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *TestProxySuite) TestAuthorizeRequest() {
+	// given
+	s.SetSettings(map[string]string{
+		"proxy.authorizationPoliciesConfigMap": "proxy-policies",
+	})
+	policies := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy-policies",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string]string{
+			policy.PoliciesKey: `
+- name: community-viewers
+  publicViewer: true
+  rules:
+  - effect: deny
+    resources: ["pods/exec"]
+- name: base-tier
+  tiers: ["base"]
+  rules:
+  - effect: deny
+    verbs: ["get", "list"]
+    resources: ["secrets"]
+`,
+		},
+	}
+	fakeClient := commontest.NewFakeClient(s.T(), policies,
+		space.NewSpace(commontest.HostOperatorNs, "myworkspace", space.WithTierName("base")),
+		space.NewSpace(commontest.HostOperatorNs, "community", space.WithTierName("appstudio")))
+	nsClient := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+	p := &Proxy{Client: nsClient, policies: policy.NewCache(nsClient)}
+	userAccess := access.NewClusterAccess(url.URL{}, "token", "smith")
+	publicViewerAccess := access.NewClusterAccess(url.URL{}, "token", toolchainv1alpha1.KubesawAuthenticatedUsername)
+	newCtx := func(method, path string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+	}
+	newWorkspace := func(name string) *toolchainv1alpha1.Workspace {
+		return &toolchainv1alpha1.Workspace{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     toolchainv1alpha1.WorkspaceStatus{Role: "viewer"},
+		}
+	}
+
+	s.Run("denied by tier policy", func() {
+		// given
+		ctx := newCtx(http.MethodGet, "/api/v1/namespaces/myworkspace-dev/secrets/mysecret")
+
+		// when
+		denied, err := p.authorizeRequest(ctx, newWorkspace("myworkspace"), userAccess)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), denied)
+		assert.Equal(s.T(), int32(http.StatusForbidden), denied.ErrStatus.Code)
+		assert.Equal(s.T(), metav1.StatusReasonForbidden, denied.ErrStatus.Reason)
+		assert.Equal(s.T(), "secrets", denied.ErrStatus.Details.Kind)
+		assert.Equal(s.T(), "mysecret", denied.ErrStatus.Details.Name)
+		assert.Contains(s.T(), denied.ErrStatus.Message, "denied by the 'base-tier' policy of workspace 'myworkspace'")
+
+		s.Run("returned as a Kubernetes Status", func() {
+			// given
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			// when
+			err := statusResponse(ctx, denied)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusForbidden, rec.Code)
+			assert.Equal(s.T(), "application/json", rec.Header().Get("Content-Type"))
+			status := &metav1.Status{}
+			require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), status))
+			assert.Equal(s.T(), "Status", status.Kind)
+			assert.Equal(s.T(), "v1", status.APIVersion)
+			assert.Equal(s.T(), metav1.StatusFailure, status.Status)
+			assert.Equal(s.T(), metav1.StatusReasonForbidden, status.Reason)
+		})
+	})
+
+	s.Run("denied by public viewer policy", func() {
+		// given
+		ctx := newCtx(http.MethodPost, "/api/v1/namespaces/community-dev/pods/mypod/exec")
+
+		// when
+		denied, err := p.authorizeRequest(ctx, newWorkspace("community"), publicViewerAccess)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), denied)
+		assert.Equal(s.T(), "pods/exec", denied.ErrStatus.Details.Kind)
+	})
+
+	s.Run("allowed", func() {
+		for name, tc := range map[string]struct {
+			method    string
+			path      string
+			workspace string
+			access    *access.ClusterAccess
+		}{
+			"exec as workspace member": {
+				method:    http.MethodPost,
+				path:      "/api/v1/namespaces/community-dev/pods/mypod/exec",
+				workspace: "community",
+				access:    userAccess,
+			},
+			"secrets in other tier": {
+				method:    http.MethodGet,
+				path:      "/api/v1/namespaces/community-dev/secrets",
+				workspace: "community",
+				access:    userAccess,
+			},
+			"secrets created in base tier": {
+				method:    http.MethodPost,
+				path:      "/api/v1/namespaces/myworkspace-dev/secrets",
+				workspace: "myworkspace",
+				access:    userAccess,
+			},
+		} {
+			s.Run(name, func() {
+				// when
+				denied, err := p.authorizeRequest(newCtx(tc.method, tc.path), newWorkspace(tc.workspace), tc.access)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Nil(s.T(), denied)
+			})
+		}
+	})
+
+	s.Run("invalid policies", func() {
+		// given
+		policies.Data[policy.PoliciesKey] = "- name: invalid\n  rules:\n  - effect: forbid"
+		require.NoError(s.T(), fakeClient.Update(context.TODO(), policies))
+		p := &Proxy{Client: nsClient, policies: policy.NewCache(nsClient)}
+
+		// when
+		_, err := p.authorizeRequest(newCtx(http.MethodGet, "/api/v1/namespaces/myworkspace-dev/pods"), newWorkspace("myworkspace"), userAccess)
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to authorize the request")
+	})
+}
+
+func (s *TestProxySuite) TestAuthorizePublicViewer() {
+	// given
+	s.OverrideApplicationDefault(testconfig.RegistrationService())
+	fakeClient := commontest.NewFakeClient(s.T(), space.NewSpace(commontest.HostOperatorNs, "community"))
+	nsClient := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+	p := &Proxy{
+		Client:   nsClient,
+		policies: policy.NewCache(nsClient),
+		metrics:  metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	publicViewerAccess := access.NewClusterAccess(url.URL{}, "token", toolchainv1alpha1.KubesawAuthenticatedUsername)
+	workspace := &toolchainv1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "community"},
+		Status:     toolchainv1alpha1.WorkspaceStatus{Role: "viewer"},
+	}
+	newCtx := func(method, path string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+	}
+	counter := func(outcome string) float64 {
+		return promtestutil.ToFloat64(p.metrics.RegServProxyCommunityAccessCounterVec.WithLabelValues("community", outcome))
+	}
+
+	s.Run("denied", func() {
+		for name, tc := range map[string]struct {
+			method       string
+			path         string
+			expectedKind string
+		}{
+			"create": {
+				method:       http.MethodPost,
+				path:         "/api/v1/namespaces/community-dev/configmaps",
+				expectedKind: "configmaps",
+			},
+			"update": {
+				method:       http.MethodPut,
+				path:         "/apis/apps/v1/namespaces/community-dev/deployments/mydeployment",
+				expectedKind: "deployments",
+			},
+			"patch": {
+				method:       http.MethodPatch,
+				path:         "/api/v1/namespaces/community-dev/pods/mypod",
+				expectedKind: "pods",
+			},
+			"delete": {
+				method:       http.MethodDelete,
+				path:         "/api/v1/namespaces/community-dev/pods/mypod",
+				expectedKind: "pods",
+			},
+			"exec upgrade": {
+				method:       http.MethodGet,
+				path:         "/api/v1/namespaces/community-dev/pods/mypod/exec?command=sh",
+				expectedKind: "pods/exec",
+			},
+			"attach upgrade": {
+				method:       http.MethodGet,
+				path:         "/api/v1/namespaces/community-dev/pods/mypod/attach",
+				expectedKind: "pods/attach",
+			},
+			"portforward upgrade": {
+				method:       http.MethodGet,
+				path:         "/api/v1/namespaces/community-dev/pods/mypod/portforward",
+				expectedKind: "pods/portforward",
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				rejected := counter(metrics.MetricLabelRejected)
+
+				// when
+				denied, err := p.authorize(newCtx(tc.method, tc.path), "", workspace, publicViewerAccess)
+
+				// then
+				require.NoError(s.T(), err)
+				require.NotNil(s.T(), denied)
+				assert.Equal(s.T(), int32(http.StatusForbidden), denied.ErrStatus.Code)
+				assert.Equal(s.T(), metav1.StatusReasonForbidden, denied.ErrStatus.Reason)
+				assert.Equal(s.T(), tc.expectedKind, denied.ErrStatus.Details.Kind)
+				assert.Contains(s.T(), denied.ErrStatus.Message, "the access to workspace 'community' is read-only")
+				assert.InDelta(s.T(), rejected+1, counter(metrics.MetricLabelRejected), 0.01)
+			})
+		}
+
+		s.Run("mutating request to proxy plugin", func() {
+			// when
+			denied, err := p.authorize(newCtx(http.MethodPost, "/plugins/myplugin/community/api"), "myplugin", workspace, publicViewerAccess)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), denied)
+		})
+	})
+
+	s.Run("allowed", func() {
+		for name, tc := range map[string]struct {
+			method string
+			path   string
+		}{
+			"get":       {method: http.MethodGet, path: "/api/v1/namespaces/community-dev/pods/mypod"},
+			"list":      {method: http.MethodGet, path: "/api/v1/namespaces/community-dev/pods"},
+			"watch":     {method: http.MethodGet, path: "/api/v1/namespaces/community-dev/pods?watch=true"},
+			"logs":      {method: http.MethodGet, path: "/api/v1/namespaces/community-dev/pods/mypod/log"},
+			"head":      {method: http.MethodHead, path: "/api/v1/namespaces/community-dev/pods"},
+			"discovery": {method: http.MethodGet, path: "/apis/apps/v1"},
+		} {
+			s.Run(name, func() {
+				// given
+				allowed := counter(metrics.MetricLabelAllowed)
+
+				// when
+				denied, err := p.authorize(newCtx(tc.method, tc.path), "", workspace, publicViewerAccess)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Nil(s.T(), denied)
+				assert.InDelta(s.T(), allowed+1, counter(metrics.MetricLabelAllowed), 0.01)
+			})
+		}
+	})
+
+	s.Run("mutating request as workspace member allowed and not counted", func() {
+		// given
+		allowed := counter(metrics.MetricLabelAllowed)
+		rejected := counter(metrics.MetricLabelRejected)
+
+		// when
+		denied, err := p.authorize(newCtx(http.MethodDelete, "/api/v1/namespaces/community-dev/pods/mypod"), "", workspace,
+			access.NewClusterAccess(url.URL{}, "token", "smith"))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Nil(s.T(), denied)
+		assert.InDelta(s.T(), allowed, counter(metrics.MetricLabelAllowed), 0.01)
+		assert.InDelta(s.T(), rejected, counter(metrics.MetricLabelRejected), 0.01)
+	})
+}
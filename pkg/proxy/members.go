@@ -124,7 +124,7 @@ func (s *MemberClusters) accessForSpace(space *toolchainv1alpha1.Space, username
 			}
 			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
 			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			return access.NewClusterAccess(*apiURL, impersonatorToken, username, memberAccessOptions(proxyPluginName, member)...), nil
 		}
 	}
 
@@ -149,13 +149,23 @@ func (s *MemberClusters) accessForCluster(apiEndpoint, clusterName, username, pr
 			}
 			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
 			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			return access.NewClusterAccess(*apiURL, impersonatorToken, username, memberAccessOptions(proxyPluginName, member)...), nil
 		}
 	}
 
 	return nil, errs.New("no member cluster found for the user")
 }
 
+// memberAccessOptions returns the options of the access to the given member cluster. The CA data of the member cluster is not
+// used for the proxy plugins, since their routes are not served with the certificate of the API server.
+func memberAccessOptions(proxyPluginName string, member *cluster.CachedToolchainCluster) []access.Option {
+	opts := []access.Option{access.WithClusterName(member.Name)}
+	if len(proxyPluginName) == 0 {
+		opts = append(opts, access.WithTLS(member.RestConfig.CAData, member.RestConfig.Insecure))
+	}
+	return opts
+}
+
 func (s *MemberClusters) getMemberURL(proxyPluginName string, member *cluster.CachedToolchainCluster) (*url.URL, error) {
 	if member == nil {
 		return nil, errs.New("nil member provided")
@@ -244,6 +244,9 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 							OperatorNamespace: "member-operator",
 							RestConfig: &rest.Config{
 								BearerToken: "abc123",
+								TLSClientConfig: rest.TLSClientConfig{
+									CAData: []byte("member-2-ca"),
+								},
 							},
 						},
 						Client: memberClient,
@@ -286,6 +289,8 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 					expectedURL, err := url.Parse("https://myservice.endpoint.member-2.com")
 					require.NoError(s.T(), err)
 					assert.Equal(s.T(), "smith2", ca.Username())
+					assert.Equal(s.T(), "member-2", ca.ClusterName())
+					assert.Empty(s.T(), ca.CAData()) // the route is not served with the certificate of the API server
 
 					s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, ""), ca)
 
@@ -345,6 +350,9 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 					expectedURL, err := url.Parse("https://api.endpoint.member-2.com:6443")
 					require.NoError(s.T(), err)
 					assert.Equal(s.T(), "smith2", ca.Username())
+					assert.Equal(s.T(), "member-2", ca.ClusterName())
+					assert.Equal(s.T(), []byte("member-2-ca"), ca.CAData())
+					assert.False(s.T(), ca.Insecure())
 
 					s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, ""), ca)
 
@@ -406,7 +414,7 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 				//given
 				expectedURL, err := url.Parse("https://api.endpoint.member-2.com:6443")
 				require.NoError(s.T(), err)
-				expectedClusterAccess := access.NewClusterAccess(*expectedURL, "token", toolchainv1alpha1.KubesawAuthenticatedUsername)
+				expectedClusterAccess := access.NewClusterAccess(*expectedURL, "token", toolchainv1alpha1.KubesawAuthenticatedUsername, access.WithClusterName("member-2"), access.WithTLS(nil, false))
 
 				// when
 				clusterAccess, err := members.GetClusterAccess(toolchainv1alpha1.KubesawAuthenticatedUsername, "smith2", "", true)
@@ -5,6 +5,7 @@ import (
 )
 
 const (
+	MetricLabelAllowed   = "Allowed"
 	MetricLabelRejected  = "Rejected"
 	MetricsLabelVerbGet  = "Get"
 	MetricsLabelVerbList = "List"
@@ -15,7 +16,9 @@ type ProxyMetrics struct {
 	RegServProxyAPIHistogramVec *prometheus.HistogramVec
 	// RegServWorkspaceHistogramVec measures the response time for either response or error from proxy when there is no routing
 	RegServWorkspaceHistogramVec *prometheus.HistogramVec
-	Reg                          *prometheus.Registry
+	// RegServProxyCommunityAccessCounterVec counts the requests sent to the workspaces through the PublicViewer access
+	RegServProxyCommunityAccessCounterVec *prometheus.CounterVec
+	Reg                                   *prometheus.Registry
 }
 
 const metricsPrefix = "sandbox_"
@@ -23,12 +26,15 @@ const metricsPrefix = "sandbox_"
 func NewProxyMetrics(reg *prometheus.Registry) *ProxyMetrics {
 	regServProxyAPIHistogramVec := newHistogramVec("proxy_api_http_request_time", "time taken by proxy to route to a target cluster", "status_code", "route_to")
 	regServWorkspaceHistogramVec := newHistogramVec("proxy_workspace_http_request_time", "time for response of a request to proxy ", "status_code", "kube_verb")
+	regServProxyCommunityAccessCounterVec := newCounterVec("proxy_community_access_total", "number of requests sent to the workspaces through the public viewer access", "workspace", "outcome")
 	reg.MustRegister(regServProxyAPIHistogramVec)
 	reg.MustRegister(regServWorkspaceHistogramVec)
+	reg.MustRegister(regServProxyCommunityAccessCounterVec)
 	return &ProxyMetrics{
-		RegServWorkspaceHistogramVec: regServWorkspaceHistogramVec,
-		RegServProxyAPIHistogramVec:  regServProxyAPIHistogramVec,
-		Reg:                          reg,
+		RegServWorkspaceHistogramVec:          regServWorkspaceHistogramVec,
+		RegServProxyAPIHistogramVec:           regServProxyAPIHistogramVec,
+		RegServProxyCommunityAccessCounterVec: regServProxyCommunityAccessCounterVec,
+		Reg:                                   reg,
 	}
 }
 
@@ -40,3 +46,10 @@ func newHistogramVec(name, help string, labels ...string) *prometheus.HistogramV
 	}, labels)
 	return v
 }
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + name,
+		Help: help,
+	}, labels)
+}
@@ -57,6 +57,27 @@ func TestHistogramVec(t *testing.T) {
 
 }
 
+func TestCounterVec(t *testing.T) {
+	// given
+	m := newCounterVec("test_counter_vec", "test counter description", "workspace", "outcome")
+
+	// when
+	m.WithLabelValues("community", MetricLabelAllowed).Inc()
+	m.WithLabelValues("community", MetricLabelAllowed).Inc()
+	m.WithLabelValues("community", MetricLabelRejected).Inc()
+
+	// then
+	assert.InDelta(t, float64(2), promtestutil.ToFloat64(m.WithLabelValues("community", MetricLabelAllowed)), 0.01)
+	assert.InDelta(t, float64(1), promtestutil.ToFloat64(m.WithLabelValues("community", MetricLabelRejected)), 0.01)
+	err := promtestutil.CollectAndCompare(m, strings.NewReader(`
+		# HELP sandbox_test_counter_vec test counter description
+		# TYPE sandbox_test_counter_vec counter
+		sandbox_test_counter_vec{outcome="Allowed",workspace="community"} 2
+		sandbox_test_counter_vec{outcome="Rejected",workspace="community"} 1
+		`), "sandbox_test_counter_vec")
+	require.NoError(t, err)
+}
+
 var expectedResponseMetadata = `
 		# HELP sandbox_test_histogram_vec test histogram description
 		# TYPE sandbox_test_histogram_vec histogram`
@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+)
+
+// cacheTTL is how long the policies are kept in memory before being loaded again
+const cacheTTL = 10 * time.Second
+
+// Cache keeps the policies loaded from the ConfigMap in memory, so that they are not read from the API server
+// (the ConfigMaps are not part of the client cache) and parsed for each proxied request.
+// A change of the policies takes effect within cacheTTL. If the policies can't be loaded again, the ones previously
+// loaded from the same ConfigMap are still used until the next attempt.
+type Cache struct {
+	client namespaced.Client
+	ttl    time.Duration
+
+	mu            sync.Mutex
+	configMapName string
+	policies      Policies
+	loaded        bool
+	loadedAt      time.Time
+}
+
+// NewCache creates a new Cache which loads the policies with the given client
+func NewCache(client namespaced.Client) *Cache {
+	return &Cache{
+		client: client,
+		ttl:    cacheTTL,
+	}
+}
+
+// Get returns the policies defined in the ConfigMap with the given name, loaded again once older than cacheTTL
+func (c *Cache) Get(ctx context.Context, configMapName string) (Policies, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sameConfigMap := c.loaded && c.configMapName == configMapName
+	if sameConfigMap && time.Since(c.loadedAt) < c.ttl {
+		return c.policies, nil
+	}
+	policies, err := Load(ctx, c.client, configMapName)
+	if err != nil {
+		if !sameConfigMap {
+			return nil, err
+		}
+		log.Error(nil, err, "unable to load the proxy authorization policies, using the ones previously loaded")
+		c.loadedAt = time.Now()
+		return c.policies, nil
+	}
+	c.configMapName = configMapName
+	c.policies = policies
+	c.loaded = true
+	c.loadedAt = time.Now()
+	return c.policies, nil
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// PoliciesKey is the key of the ConfigMap data containing the list of policies, in YAML or JSON format
+const PoliciesKey = "policies"
+
+// Effect is the effect of a rule matching a request
+type Effect string
+
+const (
+	// Allow lets the request be forwarded to the member cluster, where it is still subject to the RBAC of the user
+	Allow Effect = "allow"
+	// Deny rejects the request before it is forwarded to the member cluster
+	Deny Effect = "deny"
+)
+
+// Policy is a list of rules which applies to the requests sent to the workspaces matching its selectors.
+// An empty selector matches any workspace.
+type Policy struct {
+	// Name identifies the policy in the denial messages
+	Name string `json:"name"`
+	// Workspaces are the names of the workspaces the policy applies to
+	Workspaces []string `json:"workspaces,omitempty"`
+	// Tiers are the names of the NSTemplateTiers of the workspaces the policy applies to
+	Tiers []string `json:"tiers,omitempty"`
+	// Roles are the roles in the workspace of the users the policy applies to, eg. `viewer`
+	Roles []string `json:"roles,omitempty"`
+	// PublicViewer restricts the policy to the requests of the users accessing a community workspace as public viewer
+	PublicViewer bool `json:"publicViewer,omitempty"`
+	// Rules are evaluated in order, the first rule matching the request determines its outcome
+	Rules []Rule `json:"rules"`
+}
+
+// Rule allows or denies the resource requests matching all of its attributes, where an empty attribute or `*` matches any value.
+// Requests which are not for an API resource, such as the discovery requests, never match a rule.
+type Rule struct {
+	// Effect is either `allow` or `deny`
+	Effect Effect `json:"effect"`
+	// Verbs are the Kubernetes verbs, eg. `get`, `list`, `watch`, `create`, `update`, `patch`, `delete`, `deletecollection`
+	Verbs []string `json:"verbs,omitempty"`
+	// APIGroups are the API groups of the resources, where the empty string is the core group
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// Resources are the resources, eg. `secrets`, `pods/exec` for a subresource or `pods/*` for all the subresources.
+	// Like in the RBAC rules, `pods` does not match the subresources of the pods.
+	Resources []string `json:"resources,omitempty"`
+	// Namespaces are the namespaces of the resources, the cluster-scoped resources only match an empty list or `*`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Attributes describes a request sent to a workspace through the proxy
+type Attributes struct {
+	RequestInfo
+	// Workspace is the name of the requested workspace
+	Workspace string
+	// Tier is the name of the NSTemplateTier of the requested workspace
+	Tier string
+	// Role is the role of the user in the requested workspace
+	Role string
+	// PublicViewer is true if the user accesses the workspace as public viewer
+	PublicViewer bool
+}
+
+// Policies is an ordered list of policies
+type Policies []Policy
+
+// Load returns the policies defined in the ConfigMap with the given name in the host operator namespace.
+// There is no policy if the name is empty or if the ConfigMap does not exist.
+func Load(ctx context.Context, cl namespaced.Client, configMapName string) (Policies, error) {
+	if configMapName == "" {
+		return nil, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := cl.Get(ctx, cl.NamespacedName(configMapName), cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to get the proxy authorization policies: %w", err)
+	}
+	return Parse(cm.Data[PoliciesKey])
+}
+
+// Parse returns the policies defined in the given YAML or JSON document
+func Parse(data string) (Policies, error) {
+	policies := Policies{}
+	if err := yaml.Unmarshal([]byte(data), &policies); err != nil {
+		return nil, fmt.Errorf("invalid proxy authorization policies: %w", err)
+	}
+	for _, p := range policies {
+		for _, r := range p.Rules {
+			if r.Effect != Allow && r.Effect != Deny {
+				return nil, fmt.Errorf("invalid proxy authorization policies: unknown effect '%s' in policy '%s'", r.Effect, p.Name)
+			}
+		}
+	}
+	return policies, nil
+}
+
+// Authorize returns whether the request with the given attributes is allowed, and the name of the policy whose rule
+// matched the request, if any.
+// The policies are evaluated in order and the first rule matching the request determines its outcome.
+// The requests matching no rule are allowed.
+func (p Policies) Authorize(attrs Attributes) (bool, string) {
+	for _, policy := range p {
+		if !policy.appliesTo(attrs) {
+			continue
+		}
+		for _, rule := range policy.Rules {
+			if rule.matches(attrs.RequestInfo) {
+				return rule.Effect == Allow, policy.Name
+			}
+		}
+	}
+	return true, ""
+}
+
+func (p Policy) appliesTo(attrs Attributes) bool {
+	return (!p.PublicViewer || attrs.PublicViewer) &&
+		matchesAny(p.Workspaces, attrs.Workspace) &&
+		matchesAny(p.Tiers, attrs.Tier) &&
+		matchesAny(p.Roles, attrs.Role)
+}
+
+func (r Rule) matches(info RequestInfo) bool {
+	return info.IsResourceRequest &&
+		matchesAny(r.Verbs, info.Verb) &&
+		matchesAny(r.APIGroups, info.APIGroup) &&
+		matchesAny(r.Namespaces, info.Namespace) &&
+		r.matchesResource(info)
+}
+
+func (r Rule) matchesResource(info RequestInfo) bool {
+	if len(r.Resources) == 0 {
+		return true
+	}
+	resource := info.Resource
+	if info.Subresource != "" {
+		resource += "/" + info.Subresource
+	}
+	for _, res := range r.Resources {
+		if res == "*" || res == resource || (info.Subresource != "" && res == info.Resource+"/*") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if the given values are empty or contain the given value or `*`
+func matchesAny(values []string, value string) bool {
+	return len(values) == 0 || slices.Contains(values, "*") || slices.Contains(values, value)
+}
@@ -0,0 +1,301 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testPolicies = `
+- name: community-viewers
+  publicViewer: true
+  rules:
+  - effect: deny
+    resources: ["pods/exec", "pods/attach"]
+- name: base-tier
+  tiers: ["base"]
+  roles: ["viewer", "contributor"]
+  rules:
+  - effect: allow
+    resources: ["secrets"]
+    namespaces: ["ns-stage"]
+  - effect: deny
+    verbs: ["get", "list", "watch"]
+    apiGroups: [""]
+    resources: ["secrets"]
+- name: workspace
+  workspaces: ["restricted"]
+  rules:
+  - effect: deny
+    apiGroups: ["apps"]
+    resources: ["*"]
+`
+
+func TestAuthorize(t *testing.T) {
+	// given
+	policies, err := Parse(testPolicies)
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+
+	for name, tc := range map[string]struct {
+		attrs          Attributes
+		expectedPolicy string
+	}{
+		"exec as public viewer denied": {
+			attrs: Attributes{
+				RequestInfo:  RequestInfo{IsResourceRequest: true, Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "ns-dev"},
+				Role:         "viewer",
+				PublicViewer: true,
+			},
+			expectedPolicy: "community-viewers",
+		},
+		"secrets read by viewer in base tier denied": {
+			attrs: Attributes{
+				RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "secrets", Namespace: "ns-dev"},
+				Tier:        "base",
+				Role:        "viewer",
+			},
+			expectedPolicy: "base-tier",
+		},
+		"any resource in group of restricted workspace denied": {
+			attrs: Attributes{
+				RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "delete", APIGroup: "apps", Resource: "deployments", Namespace: "ns-dev"},
+				Workspace:   "restricted",
+			},
+			expectedPolicy: "workspace",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			allowed, policyName := policies.Authorize(tc.attrs)
+
+			// then
+			assert.False(t, allowed)
+			assert.Equal(t, tc.expectedPolicy, policyName)
+		})
+	}
+
+	t.Run("explicitly allowed", func(t *testing.T) {
+		// when
+		allowed, policyName := policies.Authorize(Attributes{
+			RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "secrets", Namespace: "ns-stage"},
+			Tier:        "base",
+			Role:        "viewer",
+		})
+
+		// then
+		assert.True(t, allowed)
+		assert.Equal(t, "base-tier", policyName)
+	})
+
+	for name, attrs := range map[string]Attributes{
+		"exec as member allowed": {
+			RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "ns-dev"},
+			Role:        "viewer",
+		},
+		"pods read as public viewer allowed": {
+			RequestInfo:  RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods", Namespace: "ns-dev"},
+			Role:         "viewer",
+			PublicViewer: true,
+		},
+		"secrets read by admin in base tier allowed": {
+			RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "secrets", Namespace: "ns-dev"},
+			Tier:        "base",
+			Role:        "admin",
+		},
+		"secrets read by viewer in other tier allowed": {
+			RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "secrets", Namespace: "ns-dev"},
+			Tier:        "appstudio",
+			Role:        "viewer",
+		},
+		"secrets created by viewer in base tier allowed": {
+			RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "create", Resource: "secrets", Namespace: "ns-dev"},
+			Tier:        "base",
+			Role:        "viewer",
+		},
+		"non-resource request allowed": {
+			RequestInfo: RequestInfo{Verb: "get", APIGroup: "apps"},
+			Workspace:   "restricted",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			allowed, policyName := policies.Authorize(attrs)
+
+			// then
+			assert.True(t, allowed)
+			assert.Empty(t, policyName)
+		})
+	}
+
+	t.Run("no policy", func(t *testing.T) {
+		// when
+		allowed, _ := Policies(nil).Authorize(Attributes{RequestInfo: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "secrets"}})
+
+		// then
+		assert.True(t, allowed)
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		// when
+		policies, err := Parse(`[{"name": "p", "rules": [{"effect": "deny", "verbs": ["delete"]}]}]`)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, Policies{{Name: "p", Rules: []Rule{{Effect: Deny, Verbs: []string{"delete"}}}}}, policies)
+	})
+
+	t.Run("unknown effect", func(t *testing.T) {
+		// when
+		_, err := Parse(`[{"name": "p", "rules": [{"effect": "forbid"}]}]`)
+
+		// then
+		require.EqualError(t, err, "invalid proxy authorization policies: unknown effect 'forbid' in policy 'p'")
+	})
+
+	t.Run("invalid document", func(t *testing.T) {
+		// when
+		_, err := Parse(`{"name": "p"}`)
+
+		// then
+		require.ErrorContains(t, err, "invalid proxy authorization policies")
+	})
+}
+
+func TestLoad(t *testing.T) {
+	// given
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy-policies",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string]string{
+			PoliciesKey: testPolicies,
+		},
+	}
+	cl := namespaced.NewClient(commontest.NewFakeClient(t, cm), commontest.HostOperatorNs)
+
+	t.Run("loaded from configmap", func(t *testing.T) {
+		// when
+		policies, err := Load(context.TODO(), cl, "proxy-policies")
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, policies, 3)
+	})
+
+	t.Run("no configmap name", func(t *testing.T) {
+		// when
+		policies, err := Load(context.TODO(), cl, "")
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("configmap not found", func(t *testing.T) {
+		// when
+		policies, err := Load(context.TODO(), cl, "unknown")
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
+
+func TestCache(t *testing.T) {
+	log.Init("policy-testing")
+	newConfigMap := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "proxy-policies",
+				Namespace: commontest.HostOperatorNs,
+			},
+			Data: map[string]string{
+				PoliciesKey: testPolicies,
+			},
+		}
+	}
+
+	t.Run("policies kept in memory until the TTL expires", func(t *testing.T) {
+		// given
+		cm := newConfigMap()
+		fakeClient := commontest.NewFakeClient(t, cm)
+		cache := NewCache(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+		_, err := cache.Get(context.TODO(), "proxy-policies")
+		require.NoError(t, err)
+		cm.Data[PoliciesKey] = "- name: single\n  rules:\n  - effect: deny"
+		require.NoError(t, fakeClient.Update(context.TODO(), cm))
+
+		// when
+		cached, err := cache.Get(context.TODO(), "proxy-policies")
+		require.NoError(t, err)
+		cache.ttl = 0
+		reloaded, err := cache.Get(context.TODO(), "proxy-policies")
+		require.NoError(t, err)
+
+		// then
+		assert.Len(t, cached, 3)
+		assert.Len(t, reloaded, 1)
+	})
+
+	t.Run("policies loaded again when the configmap name changes", func(t *testing.T) {
+		// given
+		cache := NewCache(namespaced.NewClient(commontest.NewFakeClient(t, newConfigMap()), commontest.HostOperatorNs))
+		_, err := cache.Get(context.TODO(), "proxy-policies")
+		require.NoError(t, err)
+
+		// when
+		policies, err := cache.Get(context.TODO(), "unknown")
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("previous policies used when they can't be loaded again", func(t *testing.T) {
+		// given
+		fakeClient := commontest.NewFakeClient(t, newConfigMap())
+		cache := NewCache(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+		cache.ttl = 0
+		_, err := cache.Get(context.TODO(), "proxy-policies")
+		require.NoError(t, err)
+		fakeClient.MockGet = func(_ context.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		policies, err := cache.Get(context.TODO(), "proxy-policies")
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, policies, 3)
+	})
+
+	t.Run("error when the policies were never loaded", func(t *testing.T) {
+		// given
+		fakeClient := commontest.NewFakeClient(t, newConfigMap())
+		fakeClient.MockGet = func(_ context.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+			return errors.New("mock error")
+		}
+		cache := NewCache(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		_, err := cache.Get(context.TODO(), "proxy-policies")
+
+		// then
+		require.EqualError(t, err, "unable to get the proxy authorization policies: mock error")
+	})
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestInfo holds the Kubernetes attributes of a request, similar to the ones determined by the API server
+type RequestInfo struct {
+	// IsResourceRequest is false for the requests which are not for an API resource, such as the discovery requests
+	IsResourceRequest bool
+	Verb              string
+	APIGroup          string
+	Resource          string
+	Subresource       string
+	Namespace         string
+	Name              string
+}
+
+// NewRequestInfo returns the Kubernetes attributes of the given request, whose path must not contain the workspace
+// prefix anymore, eg. `/api/v1/namespaces/ns-dev/pods/mypod/exec`
+func NewRequestInfo(req *http.Request) RequestInfo {
+	info := RequestInfo{
+		Verb: strings.ToLower(req.Method),
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		info.Verb = "get"
+	case http.MethodPost:
+		info.Verb = "create"
+	case http.MethodPut:
+		info.Verb = "update"
+	}
+
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	// /api/v1/...
+	case len(segments) >= 2 && segments[0] == "api":
+		segments = segments[2:]
+	// /apis/apps/v1/...
+	case len(segments) >= 3 && segments[0] == "apis":
+		info.APIGroup = segments[1]
+		segments = segments[3:]
+	default:
+		return info
+	}
+	if len(segments) == 0 {
+		return info
+	}
+	info.IsResourceRequest = true
+
+	// the deprecated watch endpoints, eg. /api/v1/watch/namespaces/ns-dev/pods
+	if segments[0] == "watch" {
+		info.Verb = "watch"
+		segments = segments[1:]
+		if len(segments) == 0 {
+			info.IsResourceRequest = false
+			return info
+		}
+	}
+	if segments[0] == "namespaces" {
+		if len(segments) > 1 {
+			info.Namespace = segments[1]
+		}
+		// the namespaced resources, otherwise the namespace itself or its subresources are requested
+		if len(segments) > 2 && segments[2] != "status" && segments[2] != "finalize" {
+			segments = segments[2:]
+		}
+	}
+
+	info.Resource = segments[0]
+	if len(segments) > 1 {
+		info.Name = segments[1]
+	}
+	if len(segments) > 2 {
+		info.Subresource = segments[2]
+	}
+
+	watch := req.URL.Query().Get("watch")
+	switch {
+	case info.Verb == "get" && (watch == "true" || watch == "1"):
+		info.Verb = "watch"
+	case info.Verb == "get" && info.Name == "":
+		info.Verb = "list"
+	case info.Verb == "delete" && info.Name == "":
+		info.Verb = "deletecollection"
+	}
+	return info
+}
@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRequestInfo(t *testing.T) {
+	for name, tc := range map[string]struct {
+		method   string
+		path     string
+		expected RequestInfo
+	}{
+		"get core namespaced resource": {
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/ns-dev/secrets/mysecret",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "secrets", Namespace: "ns-dev", Name: "mysecret"},
+		},
+		"list core namespaced resources": {
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/ns-dev/pods",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods", Namespace: "ns-dev"},
+		},
+		"watch resources": {
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/ns-dev/pods?watch=true",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "watch", Resource: "pods", Namespace: "ns-dev"},
+		},
+		"deprecated watch endpoint": {
+			method:   http.MethodGet,
+			path:     "/api/v1/watch/namespaces/ns-dev/pods",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "watch", Resource: "pods", Namespace: "ns-dev"},
+		},
+		"exec in pod": {
+			method:   http.MethodPost,
+			path:     "/api/v1/namespaces/ns-dev/pods/mypod/exec",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "create", Resource: "pods", Subresource: "exec", Namespace: "ns-dev", Name: "mypod"},
+		},
+		"create resource in group": {
+			method:   http.MethodPost,
+			path:     "/apis/apps/v1/namespaces/ns-dev/deployments",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "create", APIGroup: "apps", Resource: "deployments", Namespace: "ns-dev"},
+		},
+		"delete collection": {
+			method:   http.MethodDelete,
+			path:     "/apis/apps/v1/namespaces/ns-dev/deployments",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "deletecollection", APIGroup: "apps", Resource: "deployments", Namespace: "ns-dev"},
+		},
+		"patch resource": {
+			method:   http.MethodPatch,
+			path:     "/apis/apps/v1/namespaces/ns-dev/deployments/mydeployment",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "patch", APIGroup: "apps", Resource: "deployments", Namespace: "ns-dev", Name: "mydeployment"},
+		},
+		"get namespace": {
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/ns-dev",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "namespaces", Namespace: "ns-dev", Name: "ns-dev"},
+		},
+		"get namespace status": {
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/ns-dev/status",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "namespaces", Subresource: "status", Namespace: "ns-dev", Name: "ns-dev"},
+		},
+		"cluster-scoped resource": {
+			method:   http.MethodGet,
+			path:     "/apis/rbac.authorization.k8s.io/v1/clusterroles",
+			expected: RequestInfo{IsResourceRequest: true, Verb: "list", APIGroup: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		},
+		"discovery": {
+			method:   http.MethodGet,
+			path:     "/apis/apps/v1",
+			expected: RequestInfo{Verb: "get", APIGroup: "apps"},
+		},
+		"non-resource": {
+			method:   http.MethodGet,
+			path:     "/version",
+			expected: RequestInfo{Verb: "get"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+
+			// when
+			info := NewRequestInfo(req)
+
+			// then
+			assert.Equal(t, tc.expected, info)
+		})
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/textproto"
@@ -28,6 +27,7 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
@@ -35,7 +35,6 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 	glog "github.com/labstack/gommon/log"
 	errs "github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/util/httpstream"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"k8s.io/apimachinery/pkg/util/httpstream/wsstream"
@@ -52,24 +51,65 @@ const (
 )
 
 func ssoWellKnownTarget() string {
-	return fmt.Sprintf("%s/auth/realms/%s/.well-known/openid-configuration", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), configuration.GetRegistrationServiceConfig().Auth().SSORealm())
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	if cfg.OIDCIssuerURL() != "" {
+		return auth.WellKnownURL(cfg.OIDCIssuerURL())
+	}
+	return fmt.Sprintf("%s/auth/realms/%s/.well-known/openid-configuration", cfg.SSOBaseURL(), cfg.SSORealm())
 }
 
+// openidAuthEndpoint returns the path of the OpenID Connect authentication endpoint served by the proxy.
+// The path is always the one of the SSO realm, even if the actual endpoint is obtained via OpenID Connect Discovery,
+// so that the proxy routes never depend on a value coming from the OpenID provider.
 func openidAuthEndpoint() string {
 	return fmt.Sprintf("/auth/realms/%s/protocol/openid-connect/auth", configuration.GetRegistrationServiceConfig().Auth().SSORealm())
 }
 
+// authorizationEndpointTarget returns the URL of the authentication endpoint the browser is redirected to:
+// the `authorization_endpoint` of the OpenID provider metadata if available, or the endpoint of the SSO realm.
 func authorizationEndpointTarget() string {
+	if metadata := discoveredProviderMetadata(); metadata != nil {
+		return metadata.AuthorizationEndpoint
+	}
 	return fmt.Sprintf("%s%s", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), openidAuthEndpoint())
 }
 
+// ssoBaseURL returns the base URL of the SSO requests made during web login,
+// ie, the origin of the token endpoint if the OpenID provider metadata is available.
+func ssoBaseURL() string {
+	if metadata := discoveredProviderMetadata(); metadata != nil {
+		if tokenEndpoint, err := url.Parse(metadata.TokenEndpoint); err == nil && tokenEndpoint.Host != "" {
+			return fmt.Sprintf("%s://%s", tokenEndpoint.Scheme, tokenEndpoint.Host)
+		}
+	}
+	return configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL()
+}
+
+// discoveredProviderMetadata returns the metadata of the OpenID provider if an issuer URL is configured.
+// Returns nil if there is no issuer URL configured or if its metadata could not be retrieved.
+func discoveredProviderMetadata() *auth.ProviderMetadata {
+	issuerURL := configuration.GetRegistrationServiceConfig().Auth().OIDCIssuerURL()
+	if issuerURL == "" {
+		return nil
+	}
+	metadata, err := auth.DiscoverProvider(issuerURL)
+	if err != nil {
+		log.Error(nil, err, "unable to retrieve the OpenID provider metadata, using the SSO settings instead")
+		return nil
+	}
+	return metadata
+}
+
 type Proxy struct {
 	namespaced.Client
-	signupService  service.SignupService
-	tokenParser    *auth.TokenParser
-	spaceLister    *handlers.SpaceLister
-	metrics        *metrics.ProxyMetrics
-	getMembersFunc commoncluster.GetMemberClustersFunc
+	signupService     service.SignupService
+	tokenParser       *auth.TokenParser
+	revocationChecker *auth.RevocationChecker
+	policies          *policy.Cache
+	spaceLister       *handlers.SpaceLister
+	metrics           *metrics.ProxyMetrics
+	getMembersFunc    commoncluster.GetMemberClustersFunc
+	transports        *transportPool
 }
 
 func NewProxy(nsClient namespaced.Client, app application.Application, proxyMetrics *metrics.ProxyMetrics, getMembersFunc commoncluster.GetMemberClustersFunc) (*Proxy, error) {
@@ -81,12 +121,15 @@ func NewProxy(nsClient namespaced.Client, app application.Application, proxyMetr
 	// init handlers
 	spaceLister := handlers.NewSpaceLister(nsClient, app, proxyMetrics)
 	return &Proxy{
-		Client:         nsClient,
-		signupService:  app.SignupService(),
-		tokenParser:    tokenParser,
-		spaceLister:    spaceLister,
-		metrics:        proxyMetrics,
-		getMembersFunc: getMembersFunc,
+		Client:            nsClient,
+		signupService:     app.SignupService(),
+		tokenParser:       tokenParser,
+		revocationChecker: auth.NewRevocationChecker(nsClient),
+		policies:          policy.NewCache(nsClient),
+		spaceLister:       spaceLister,
+		metrics:           proxyMetrics,
+		getMembersFunc:    getMembersFunc,
+		transports:        newTransportPool(),
 	}, nil
 }
 
@@ -149,6 +192,7 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 	//    Note: oc uses this hardcoded public (no secret) oauth client name: "openshift-cli-client" which has to exist in SSO to make this flow work.
 	// 6. user provides the login credentials in the sso login page
 	// 7. all following oc requests (<proxy_url>/auth/*) go to the proxy and forwarded to SSO as is. This is used to obtain the generated token by oc.
+	// Note: if an OIDC issuer URL is configured, then the SSO endpoints above are obtained from the issuer's OpenID configuration instead.
 	router.Any(wellKnownOauthConfigEndpoint, p.oauthConfiguration)     // <- this is the step 2 in the flow above
 	router.Any(fmt.Sprintf("%s*", openidAuthEndpoint()), p.openidAuth) // <- this is the step 5 in the flow above
 	router.Any(fmt.Sprintf("%s*", authEndpoint), p.auth)               // <- this is the step 7.
@@ -165,8 +209,8 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 		ReadHeaderTimeout: 2 * time.Second,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
-			NextProtos: []string{"http/1.1"}, // disable HTTP/2 for now
 		},
+		Protocols: configuration.HTTPProtocols(),
 	}
 	// listen concurrently to allow for graceful shutdown
 	go func() {
@@ -183,7 +227,7 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 }
 
 // unsecured returns true if the request does not require authentication
-func unsecured(ctx echo.Context) bool {
+func (p *Proxy) unsecured(ctx echo.Context) bool {
 	uri := ctx.Request().URL.RequestURI()
 	return uri == proxyHealthEndpoint || uri == wellKnownOauthConfigEndpoint || strings.HasPrefix(uri, authEndpoint)
 }
@@ -191,7 +235,7 @@ func unsecured(ctx echo.Context) bool {
 // auth handles requests to SSO. Used by web login.
 func (p *Proxy) auth(ctx echo.Context) error {
 	req := ctx.Request()
-	targetURL, err := url.Parse(configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL())
+	targetURL, err := url.Parse(ssoBaseURL())
 	if err != nil {
 		return err
 	}
@@ -216,7 +260,7 @@ func (p *Proxy) openidAuth(ctx echo.Context) error {
 	if err != nil {
 		return err
 	}
-	targetURL.Path = ctx.Request().URL.Path
+	targetURL.Path += strings.TrimPrefix(ctx.Request().URL.Path, openidAuthEndpoint())
 	targetURL.RawQuery = ctx.Request().URL.RawQuery
 
 	// Let's redirect the browser's request to the SSO authentication page instead of proxying it
@@ -243,7 +287,8 @@ func (p *Proxy) handleSSORequest(targetURL *url.URL) echo.HandlerFunc {
 			req.Host = targetURL.Host
 			log.InfoEchof(ctx, "forwarding %s to %s", origin, req.URL.String())
 		}
-		transport := getTransport(req.Header)
+		// the SSO server is not a member cluster, there is no CA data to verify its certificate with
+		transport := p.transports.get(access.NewClusterAccess(*targetURL, "", ""), req.Header)
 		reverseProxy := &httputil.ReverseProxy{
 			Director:      director,
 			Transport:     transport,
@@ -263,81 +308,85 @@ func (p *Proxy) health(ctx echo.Context) error {
 	return err
 }
 
-func (p *Proxy) processRequest(ctx echo.Context) (string, *access.ClusterAccess, error) {
+func (p *Proxy) processRequest(ctx echo.Context) (string, *access.ClusterAccess, *toolchainv1alpha1.Workspace, error) {
 	// retrieve required information from the HTTP request
 	username, _ := ctx.Get(context.UsernameKey).(string)
 	proxyPluginName, workspaceName, err := getWorkspaceContext(ctx.Request())
+	// set workspace context for logging and for the error handler, since the request path was rewritten
+	ctx.Set(context.WorkspaceKey, workspaceName)
+	ctx.Set(context.ProxyPluginKey, proxyPluginName)
 	if err != nil {
-		return "", nil, crterrors.NewBadRequest("unable to get workspace context", err.Error())
+		return "", nil, nil, crterrors.NewBadRequest("unable to get workspace context", err.Error())
 	}
 
-	// set workspace context for logging
-	ctx.Set(context.WorkspaceKey, workspaceName)
-
 	// if the target workspace is NOT explicitly declared in the HTTP request,
 	// process the request against the user's home workspace
 	if workspaceName == "" {
-		cluster, err := p.processHomeWorkspaceRequest(ctx, username, proxyPluginName)
+		cluster, workspace, err := p.processHomeWorkspaceRequest(ctx, username, proxyPluginName)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
-		return proxyPluginName, cluster, nil
+		return proxyPluginName, cluster, workspace, nil
 	}
 
 	// if the target workspace is explicitly declared in the HTTP request,
 	// process the request against the declared workspace
-	cluster, err := p.processWorkspaceRequest(ctx, username, workspaceName, proxyPluginName)
+	cluster, workspace, err := p.processWorkspaceRequest(ctx, username, workspaceName, proxyPluginName)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
-	return proxyPluginName, cluster, nil
+	return proxyPluginName, cluster, workspace, nil
 }
 
 // processHomeWorkspaceRequest process an HTTP Request targeting the user's home workspace.
-func (p *Proxy) processHomeWorkspaceRequest(ctx echo.Context, username, proxyPluginName string) (*access.ClusterAccess, error) {
+func (p *Proxy) processHomeWorkspaceRequest(ctx echo.Context, username, proxyPluginName string) (*access.ClusterAccess, *toolchainv1alpha1.Workspace, error) {
 	// retrieves the ClusterAccess for the user and their home workspace
 	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc)
 	cluster, err := members.GetClusterAccess(username, "", proxyPluginName, false)
 	if err != nil {
-		return nil, crterrors.NewInternalError(errs.New("unable to get target cluster"), err.Error())
+		return nil, nil, crterrors.NewInternalError(errs.New("unable to get target cluster"), err.Error())
 	}
 
 	// list all workspaces the user has access to
 	workspaces, err := handlers.ListUserWorkspaces(ctx, p.spaceLister)
 	if err != nil {
-		return nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
+		return nil, nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
 	}
 
 	// check whether the user has access to the home workspace
 	if err := validateWorkspaceRequest("", workspaces...); err != nil {
-		return nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
+		return nil, nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
 	}
 
 	// return the cluster access
-	return cluster, nil
+	return cluster, findWorkspace("", workspaces...), nil
 }
 
 // processWorkspaceRequest process an HTTP Request targeting a specific workspace.
-func (p *Proxy) processWorkspaceRequest(ctx echo.Context, username, workspaceName, proxyPluginName string) (*access.ClusterAccess, error) {
+func (p *Proxy) processWorkspaceRequest(ctx echo.Context, username, workspaceName, proxyPluginName string) (*access.ClusterAccess, *toolchainv1alpha1.Workspace, error) {
 	// check that the user is provisioned and the space exists.
 	// if the PublicViewer support is enabled, user check is skipped.
 	if err := p.checkUserIsProvisionedAndSpaceExists(ctx, username, workspaceName); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// retrieve the requested Workspace with SpaceBindings
 	workspace, err := p.getUserWorkspaceWithBindings(ctx, workspaceName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// check whether the user has access to the workspace
 	if err := validateWorkspaceRequest(workspaceName, *workspace); err != nil {
-		return nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
+		return nil, nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
 	}
 
 	// retrieve the ClusterAccess for the user and the target workspace
-	return p.getClusterAccess(ctx, username, proxyPluginName, workspace)
+	cluster, err := p.getClusterAccess(ctx, username, proxyPluginName, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cluster, workspace, nil
 }
 
 // checkUserIsProvisionedAndSpaceExists checks that the user is provisioned and the Space exists.
@@ -470,11 +519,18 @@ func (p *Proxy) getUserWorkspaceWithBindings(ctx echo.Context, workspaceName str
 
 func (p *Proxy) handleRequestAndRedirect(ctx echo.Context) error {
 	requestReceivedTime := ctx.Get(context.RequestReceivedTime).(time.Time)
-	proxyPluginName, cluster, err := p.processRequest(ctx)
+	proxyPluginName, cluster, workspace, err := p.processRequest(ctx)
 	if err != nil {
 		p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusNotAcceptable), metrics.MetricLabelRejected).Observe(time.Since(requestReceivedTime).Seconds())
 		return err
 	}
+	if denied, err := p.authorize(ctx, proxyPluginName, workspace, cluster); err != nil || denied != nil {
+		p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusForbidden), metrics.MetricLabelRejected).Observe(time.Since(requestReceivedTime).Seconds())
+		if err != nil {
+			return err
+		}
+		return denied
+	}
 	reverseProxy := p.newReverseProxy(ctx, cluster, len(proxyPluginName) > 0)
 	routeTime := time.Since(requestReceivedTime)
 	p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusAccepted), cluster.APIURL().Host).Observe(routeTime.Seconds())
@@ -510,16 +566,16 @@ func getWorkspaceContext(req *http.Request) (string, string, error) {
 		segments := strings.Split(path, "/")
 		// there should be at least 4 segments eg. /workspaces/mycoolworkspace/api/clusterroles counts as 4
 		if len(segments) < 4 && len(proxyPluginName) == 0 {
-			return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/api/...", path) // nolint:revive,staticcheck
+			return proxyPluginName, "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/api/...", path) // nolint:revive,staticcheck
 		}
 		// with proxy plugins, the route host is sufficient, and hence do not need api/...
 		if len(segments) < 3 {
-			return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
+			return proxyPluginName, "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
 		}
 		if len(segments) == 3 {
 			// need to distinguish between the third entry being "" vs "<plugin-name>"
 			if len(strings.TrimSpace(segments[2])) == 0 {
-				return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
+				return proxyPluginName, "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
 			}
 		}
 		// get the workspace segment eg. mycoolworkspace
@@ -531,14 +587,25 @@ func getWorkspaceContext(req *http.Request) (string, string, error) {
 	return proxyPluginName, workspace, nil
 }
 
+// customHTTPErrorHandler writes the given error as a Kubernetes Status object for the Kubernetes clients,
+// and as plain text for the other clients
 func customHTTPErrorHandler(cause error, ctx echo.Context) {
-	code := http.StatusInternalServerError
-	ce := &crterrors.Error{}
-	if errors.As(cause, &ce) {
-		code = ce.Code
-	}
 	ctx.Logger().Error(cause)
-	if err := ctx.String(code, cause.Error()); err != nil {
+	// the request path is rewritten once the workspace context is processed, so it can only be parsed
+	// if the request was rejected before
+	workspace, found := ctx.Get(context.WorkspaceKey).(string)
+	proxyPluginName, _ := ctx.Get(context.ProxyPluginKey).(string)
+	if !found {
+		proxyPluginName, workspace, _ = getWorkspaceContext(ctx.Request().Clone(ctx.Request().Context()))
+	}
+	statusErr := newStatusError(cause, workspace)
+	var err error
+	if acceptsStatus(ctx.Request(), proxyPluginName) {
+		err = statusResponse(ctx, statusErr)
+	} else {
+		err = ctx.String(int(statusErr.ErrStatus.Code), statusErr.ErrStatus.Message)
+	}
+	if err != nil {
 		ctx.Logger().Error(err)
 	}
 }
@@ -548,7 +615,7 @@ func customHTTPErrorHandler(cause error, ctx echo.Context) {
 func (p *Proxy) addUserContext() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			if unsecured(ctx) { // skip only for unsecured endpoints
+			if p.unsecured(ctx) { // skip only for unsecured endpoints
 				return next(ctx)
 			}
 
@@ -583,7 +650,7 @@ func (p *Proxy) addPublicViewerContext() echo.MiddlewareFunc {
 func (p *Proxy) ensureUserIsNotBanned() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			if unsecured(ctx) { // skip only for unsecured endpoints
+			if p.unsecured(ctx) { // skip only for unsecured endpoints
 				return next(ctx)
 			}
 
@@ -658,6 +725,12 @@ func (p *Proxy) extractUserToken(req *http.Request) (*auth.TokenClaims, error) {
 	if err != nil {
 		return nil, crterrors.NewUnauthorizedError("unable to extract claims from token", err.Error())
 	}
+	if err := p.revocationChecker.CheckToken(req.Context(), userToken, token); err != nil {
+		if errors.Is(err, auth.ErrRevocationCheckUnavailable) {
+			return nil, crterrors.NewServiceUnavailableError(err, "unable to check the token")
+		}
+		return nil, crterrors.NewUnauthorizedError("token rejected", err.Error())
+	}
 	return token, nil
 }
 
@@ -710,7 +783,7 @@ func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess,
 		// Set impersonation header
 		req.Header.Set("Impersonate-User", target.Username())
 	}
-	transport := getTransport(req.Header)
+	transport := p.transports.get(target, req.Header)
 	m := &responseModifier{req.Header.Get("Origin")}
 	return &httputil.ReverseProxy{
 		Director:       director,
@@ -720,45 +793,6 @@ func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess,
 	}
 }
 
-// TODO: use transport from the cached ToolchainCluster instance
-func noTimeoutDefaultTransport() *http.Transport {
-	transport := http.DefaultTransport.(interface {
-		Clone() *http.Transport
-	}).Clone()
-	transport.DialContext = noTimeoutDialerProxy
-	return transport
-}
-
-var noTimeoutDialerProxy = func(ctx gocontext.Context, network, addr string) (net.Conn, error) {
-	dialer := &net.Dialer{
-		Timeout:   0,
-		KeepAlive: 0,
-	}
-	return dialer.DialContext(ctx, network, addr)
-}
-
-func getTransport(reqHeader http.Header) *http.Transport {
-	// TODO: use transport from the cached ToolchainCluster instance
-	transport := noTimeoutDefaultTransport()
-
-	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
-		transport.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true, // nolint:gosec
-		}
-	}
-
-	// for exec and rsh command we cannot use h2 because it doesn't support "Upgrade: SPDY/3.1" header https://github.com/kubernetes/kubernetes/issues/7452
-	if strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/") {
-		// thus, we need to switch to http/1.1
-		transport.ForceAttemptHTTP2 = false
-		transport.TLSClientConfig = &tls.Config{ // nolint:gosec
-			NextProtos: []string{"http/1.1"},
-		}
-	}
-
-	return transport
-}
-
 func singleJoiningSlash(a, b string) string {
 	aslash := strings.HasSuffix(a, "/")
 	bslash := strings.HasPrefix(b, "/")
@@ -828,18 +862,21 @@ func replaceTokenInWebsocketRequest(req *http.Request, newToken string) {
 // If `requestedWorkspace` is empty, then the home workspace (the one with `status.Type` set to `home`) is assumed.
 func validateWorkspaceRequest(requestedWorkspace string, workspaces ...toolchainv1alpha1.Workspace) error {
 	// check workspace access
-	isHomeWSRequested := requestedWorkspace == ""
+	if findWorkspace(requestedWorkspace, workspaces...) == nil {
+		return fmt.Errorf("access to workspace '%s' is forbidden", requestedWorkspace)
+	}
 
-	allowedWorkspace := -1
+	return nil
+}
+
+// findWorkspace returns the requested workspace from the given list, or nil if it's not found.
+// If `requestedWorkspace` is empty, then the home workspace is returned.
+func findWorkspace(requestedWorkspace string, workspaces ...toolchainv1alpha1.Workspace) *toolchainv1alpha1.Workspace {
+	isHomeWSRequested := requestedWorkspace == ""
 	for i, w := range workspaces {
 		if w.Name == requestedWorkspace || (isHomeWSRequested && w.Status.Type == "home") {
-			allowedWorkspace = i
-			break
+			return &workspaces[i]
 		}
 	}
-	if allowedWorkspace == -1 {
-		return fmt.Errorf("access to workspace '%s' is forbidden", requestedWorkspace)
-	}
-
 	return nil
 }
@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+func (s *TestProxySuite) TestProxyHTTP2() {
+	// given
+	port := "30457"
+
+	env := s.DefaultConfig().Environment()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.E2E))) // We use e2e-test environment just to be able to re-use token generation
+	_, err := auth.InitializeDefaultTokenParser()
+	require.NoError(s.T(), err)
+
+	// the member API server and the proxy plugin route, both served over TLS with HTTP/2 enabled
+	nextWatchEvent := make(chan struct{})
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+			// exec over WebSocket: echo whatever is sent once the connection is upgraded
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if !assert.NoError(s.T(), err) {
+				return
+			}
+			defer conn.Close()
+			_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+				"X-Upstream-Proto: " + r.Proto + "\r\n\r\n")
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), rw.Flush())
+			_, _ = io.Copy(conn, rw)
+		case r.URL.Query().Get("watch") == "true":
+			// watch: the second event is only sent after the client received the first one
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Upstream-Proto", r.Proto)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"type":"ADDED"}` + "\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-nextWatchEvent:
+				_, _ = w.Write([]byte(`{"type":"MODIFIED"}` + "\n"))
+			case <-r.Context().Done():
+			}
+		default:
+			w.Header().Set("X-Upstream-Proto", r.Proto)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("my response"))
+		}
+	}))
+	upstream.EnableHTTP2 = true
+	upstream.StartTLS()
+	defer upstream.Close()
+
+	proxy, server := s.spinUpProxy(port)
+	defer func() {
+		_ = server.Close()
+	}()
+	s.waitForProxyToBeAlive(port)
+	s.setUpHTTP2Upstream(proxy, upstream)
+
+	username := "smith2"
+	token := s.token(username)
+	paths := map[string]string{
+		"workspace context":    fmt.Sprintf("http://localhost:%s/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace/pods", port),
+		"proxy plugin context": fmt.Sprintf("http://localhost:%s/plugins/myplugin/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace/pods", port),
+	}
+	h2cProtocols := &http.Protocols{}
+	h2cProtocols.SetUnencryptedHTTP2(true)
+	clients := map[string]struct {
+		client        *http.Client
+		expectedProto string
+	}{
+		"HTTP/1.1 client": {
+			client:        &http.Client{Timeout: 5 * time.Second},
+			expectedProto: "HTTP/1.1",
+		},
+		"HTTP/2 client": {
+			client:        &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{Protocols: h2cProtocols}},
+			expectedProto: "HTTP/2.0",
+		},
+	}
+
+	for clientName, c := range clients {
+		for pathName, path := range paths {
+			s.Run(clientName+" with "+pathName, func() {
+				s.Run("request", func() {
+					// given
+					req, err := http.NewRequest(http.MethodGet, path, nil)
+					require.NoError(s.T(), err)
+					req.Header.Set("Authorization", "Bearer "+token)
+
+					// when
+					resp, err := c.client.Do(req)
+
+					// then
+					require.NoError(s.T(), err)
+					defer resp.Body.Close()
+					assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+					assert.Equal(s.T(), c.expectedProto, resp.Proto)
+					assert.Equal(s.T(), "HTTP/2.0", resp.Header.Get("X-Upstream-Proto"))
+					s.assertResponseBody(resp, "my response")
+				})
+
+				s.Run("streaming watch", func() {
+					// given
+					req, err := http.NewRequest(http.MethodGet, path+"?watch=true", nil)
+					require.NoError(s.T(), err)
+					req.Header.Set("Authorization", "Bearer "+token)
+
+					// when
+					resp, err := c.client.Do(req)
+
+					// then
+					require.NoError(s.T(), err)
+					defer resp.Body.Close()
+					assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+					assert.Equal(s.T(), c.expectedProto, resp.Proto)
+					assert.Equal(s.T(), "HTTP/2.0", resp.Header.Get("X-Upstream-Proto"))
+					events := bufio.NewReader(resp.Body)
+					event, err := events.ReadString('\n')
+					require.NoError(s.T(), err)
+					assert.JSONEq(s.T(), `{"type":"ADDED"}`, event)
+					// the first event was received before the upstream sent the second one, hence was not buffered by the proxy
+					nextWatchEvent <- struct{}{}
+					event, err = events.ReadString('\n')
+					require.NoError(s.T(), err)
+					assert.JSONEq(s.T(), `{"type":"MODIFIED"}`, event)
+					_, err = events.ReadString('\n')
+					assert.ErrorIs(s.T(), err, io.EOF)
+				})
+			})
+		}
+	}
+
+	for pathName, path := range paths {
+		// the WebSocket and SPDY upgrades are only possible with HTTP/1.1
+		s.Run("upgrades with "+pathName, func() {
+			s.Run("exec over WebSocket", func() {
+				// given
+				conn, err := net.Dial("tcp", "localhost:"+port)
+				require.NoError(s.T(), err)
+				defer conn.Close()
+				require.NoError(s.T(), conn.SetDeadline(time.Now().Add(5*time.Second)))
+				req, err := http.NewRequest(http.MethodGet, path+"/mypod/exec?command=sh", nil)
+				require.NoError(s.T(), err)
+				upgradeToWebsocket(req)
+				req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+				req.Header.Set("Sec-WebSocket-Version", "13")
+				req.Header.Set("Sec-Websocket-Protocol", fmt.Sprintf("base64url.bearer.authorization.k8s.io.%s,v4.channel.k8s.io", base64.RawURLEncoding.EncodeToString([]byte(token))))
+
+				// when
+				require.NoError(s.T(), req.Write(conn))
+				reader := bufio.NewReader(conn)
+				resp, err := http.ReadResponse(reader, req)
+
+				// then
+				require.NoError(s.T(), err)
+				defer resp.Body.Close()
+				require.Equal(s.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+				assert.Equal(s.T(), "HTTP/1.1", resp.Header.Get("X-Upstream-Proto"))
+				_, err = conn.Write([]byte("ls -l"))
+				require.NoError(s.T(), err)
+				received := make([]byte, len("ls -l"))
+				_, err = io.ReadFull(reader, received)
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "ls -l", string(received))
+			})
+
+			s.Run("SPDY upgrade falls back to HTTP/1.1", func() {
+				// given
+				req, err := http.NewRequest(http.MethodPost, path+"/mypod/exec?command=sh", nil)
+				require.NoError(s.T(), err)
+				req.Header.Set("Authorization", "Bearer "+token)
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "SPDY/3.1")
+
+				// when
+				resp, err := clients["HTTP/1.1 client"].client.Do(req)
+
+				// then
+				require.NoError(s.T(), err)
+				defer resp.Body.Close()
+				assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+				assert.Equal(s.T(), "HTTP/1.1", resp.Header.Get("X-Upstream-Proto"))
+			})
+		})
+	}
+}
+
+// setUpHTTP2Upstream routes the requests of user smith2 to the given upstream server, as the API server of member-2
+// and as the route of the `myplugin` proxy plugin
+func (s *TestProxySuite) setUpHTTP2Upstream(proxy *Proxy, upstream *httptest.Server) {
+	proxy.signupService = fake.NewSignupService(
+		&signup.Signup{
+			Name:              "smith2",
+			APIEndpoint:       upstream.URL,
+			ClusterName:       "member-2",
+			CompliantUsername: "smith2",
+			Username:          "smith2@",
+			Status: signup.Status{
+				Ready: true,
+			},
+		},
+	)
+	proxyPlugin := &toolchainv1alpha1.ProxyPlugin{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: commontest.HostOperatorNs,
+			Name:      "myplugin",
+		},
+		Spec: toolchainv1alpha1.ProxyPluginSpec{
+			OpenShiftRouteTargetEndpoint: &toolchainv1alpha1.OpenShiftRouteTarget{
+				Namespace: commontest.MemberOperatorNs,
+				Name:      "proxy-plugin",
+			},
+		},
+	}
+	require.NoError(s.T(), routev1.Install(scheme.Scheme))
+	proxy.Client.Client = commontest.NewFakeClient(s.T(),
+		fake.NewSpace("mycoolworkspace", "member-2", "smith2"),
+		fake.NewSpaceBinding("mycoolworkspace-smith2", "smith2", "mycoolworkspace", "admin"),
+		proxyPlugin,
+		fake.NewBase1NSTemplateTier())
+	proxy.spaceLister = &handlers.SpaceLister{
+		Client:        proxy.Client,
+		GetSignupFunc: proxy.signupService.GetSignup,
+		ProxyMetrics:  proxy.metrics,
+	}
+
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: commontest.MemberOperatorNs,
+			Name:      "proxy-plugin",
+		},
+		Spec: routev1.RouteSpec{
+			Port: &routev1.RoutePort{TargetPort: intstr.FromString("https")},
+		},
+		Status: routev1.RouteStatus{
+			Ingress: []routev1.RouteIngress{
+				{
+					Host: strings.TrimPrefix(upstream.URL, "https://"),
+				},
+			},
+		},
+	}
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw})
+	proxy.getMembersFunc = func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Config: &commoncluster.Config{
+					Name:              "member-2",
+					APIEndpoint:       upstream.URL,
+					OperatorNamespace: "member-operator",
+					RestConfig: &rest.Config{
+						BearerToken: "clusterSAToken",
+						TLSClientConfig: rest.TLSClientConfig{
+							CAData: caData,
+						},
+					},
+				},
+				Client: commontest.NewFakeClient(s.T(), route),
+			},
+		}
+	}
+}
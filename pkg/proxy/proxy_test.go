@@ -3,20 +3,20 @@ package proxy
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
@@ -27,6 +27,7 @@ import (
 	"github.com/codeready-toolchain/registration-service/test/util"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/kubernetes/scheme"
 
@@ -338,6 +339,34 @@ func (s *TestProxySuite) checkPlainHTTPErrors(proxy *Proxy) {
 			assert.Equal(s.T(), http.StatusInternalServerError, resp.StatusCode)
 			s.assertResponseBody(resp, "user access could not be verified: could not define user access")
 		})
+
+		s.Run("forbidden error as Kubernetes Status if user is banned", func() {
+			// given
+			req, err := http.NewRequest("GET", "http://localhost:8081/workspaces/mycoolworkspace/api/v1/pods", nil)
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), req)
+			token := s.token("alice", authsupport.WithSubClaim("alice"), authsupport.WithEmailClaim(bannedUser.Spec.Email))
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set("Accept", "application/json, */*")
+			resp, err := http.DefaultClient.Do(req)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), resp)
+			defer resp.Body.Close()
+			assert.Equal(s.T(), http.StatusForbidden, resp.StatusCode)
+			assert.Equal(s.T(), "application/json", resp.Header.Get("Content-Type"))
+			status := &metav1.Status{}
+			require.NoError(s.T(), json.NewDecoder(resp.Body).Decode(status))
+			assert.Equal(s.T(), "Status", status.Kind)
+			assert.Equal(s.T(), metav1.StatusFailure, status.Status)
+			assert.Equal(s.T(), metav1.StatusReasonForbidden, status.Reason)
+			assert.Equal(s.T(), int32(http.StatusForbidden), status.Code)
+			assert.Equal(s.T(), "user access is forbidden: user access is forbidden", status.Message)
+			require.NotNil(s.T(), status.Details)
+			assert.Equal(s.T(), "workspaces", status.Details.Kind)
+			assert.Equal(s.T(), "mycoolworkspace", status.Details.Name)
+		})
 	})
 }
 
@@ -976,6 +1005,75 @@ func (s *TestProxySuite) TestSingleJoiningSlash() {
 	assert.Equal(s.T(), "/proxy/subpath/api/namespace/pods/", singleJoiningSlash("/proxy/subpath/", "/api/namespace/pods/"))
 }
 
+func (s *TestProxySuite) TestSSOEndpoints() {
+	s.Run("from SSO settings", func() {
+		// given
+		s.SetConfig(testconfig.RegistrationService().
+			Auth().SSOBaseURL("https://sso.devsandbox.dev").
+			Auth().SSORealm("sandbox-dev"))
+
+		// then
+		assert.Equal(s.T(), "https://sso.devsandbox.dev/auth/realms/sandbox-dev/.well-known/openid-configuration", ssoWellKnownTarget())
+		assert.Equal(s.T(), "/auth/realms/sandbox-dev/protocol/openid-connect/auth", openidAuthEndpoint())
+		assert.Equal(s.T(), "https://sso.devsandbox.dev/auth/realms/sandbox-dev/protocol/openid-connect/auth", authorizationEndpointTarget())
+		assert.Equal(s.T(), "https://sso.devsandbox.dev", ssoBaseURL())
+	})
+
+	s.Run("from OpenID provider metadata", func() {
+		// given
+		var provider *httptest.Server
+		provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/realms/sandbox/.well-known/openid-configuration" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			err := json.NewEncoder(w).Encode(auth.ProviderMetadata{
+				Issuer:                provider.URL + "/realms/sandbox",
+				AuthorizationEndpoint: provider.URL + "/realms/sandbox/protocol/openid-connect/auth",
+				TokenEndpoint:         provider.URL + "/realms/sandbox/protocol/openid-connect/token",
+				JWKSURI:               provider.URL + "/realms/sandbox/protocol/openid-connect/certs",
+			})
+			assert.NoError(s.T(), err)
+		}))
+		defer provider.Close()
+		s.SetSettings(map[string]string{
+			"auth.oidcIssuerURL": provider.URL + "/realms/sandbox",
+		}, testconfig.RegistrationService().
+			Auth().SSOBaseURL("https://sso.devsandbox.dev").
+			Auth().SSORealm("sandbox-dev"))
+
+		// then
+		assert.Equal(s.T(), provider.URL+"/realms/sandbox/.well-known/openid-configuration", ssoWellKnownTarget())
+		assert.Equal(s.T(), "/auth/realms/sandbox-dev/protocol/openid-connect/auth", openidAuthEndpoint())
+		assert.Equal(s.T(), provider.URL+"/realms/sandbox/protocol/openid-connect/auth", authorizationEndpointTarget())
+		assert.Equal(s.T(), provider.URL, ssoBaseURL())
+
+		s.Run("authentication requests are redirected to the discovered endpoint", func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/auth/realms/sandbox-dev/protocol/openid-connect/auth?state=mystate&code=mycode", nil)
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(req, rec)
+
+			// when
+			err := (&Proxy{}).openidAuth(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusSeeOther, rec.Code)
+			assert.Equal(s.T(), provider.URL+"/realms/sandbox/protocol/openid-connect/auth?state=mystate&code=mycode", rec.Header().Get("Location"))
+		})
+
+		s.Run("discovered path does not skip the authentication", func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/realms/sandbox/protocol/openid-connect/auth", nil)
+			ctx := echo.New().NewContext(req, httptest.NewRecorder())
+
+			// then
+			assert.False(s.T(), (&Proxy{}).unsecured(ctx))
+		})
+	})
+}
+
 func (s *TestProxySuite) TestGetWorkspaceContext() {
 	tests := map[string]struct {
 		path              string
@@ -1061,7 +1159,7 @@ func (s *TestProxySuite) TestGetWorkspaceContext() {
 			expectedWorkspace: "",
 			expectedPath:      "/workspaces/",
 			expectedErr:       "workspace request path has too few segments '/workspaces/'; expected path format: /workspaces/<workspace_name>/<optional path>",
-			expectedPlugin:    "",
+			expectedPlugin:    "tekton-results",
 		},
 		"plugin and workspaces as the sub path": {
 			path:              "/plugins/tekton-results/workspaces",
@@ -1106,6 +1204,94 @@ func (s *TestProxySuite) TestGetWorkspaceContext() {
 	}
 }
 
+func (s *TestProxySuite) TestHandleRequestAndRedirectError() {
+	// given
+	fakeClient := commontest.NewFakeClient(s.T(), fake.NewSpace("mycoolworkspace", "member-1", "smith"),
+		fake.NewSpaceBinding("mycoolworkspace-smith", "smith", "mycoolworkspace", "admin"))
+	nsClient := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+	signupService := fake.NewSignupService(&signup.Signup{
+		Name:              "smith",
+		APIEndpoint:       "https://api.endpoint.member-1.com:6443",
+		ClusterName:       "member-1",
+		CompliantUsername: "smith",
+		Username:          "smith",
+		Status: signup.Status{
+			Ready: true,
+		},
+	})
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+	proxy := &Proxy{
+		Client:        nsClient,
+		signupService: signupService,
+		spaceLister: &handlers.SpaceLister{
+			Client:        nsClient,
+			GetSignupFunc: signupService.GetSignup,
+			ProxyMetrics:  proxyMetrics,
+		},
+		metrics:        proxyMetrics,
+		getMembersFunc: s.newMemberClustersFunc("https://api.endpoint.member-1.com:6443"),
+	}
+
+	newRequestContext := func(path string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.Set(rcontext.UsernameKey, "smith")
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		return ctx, rec
+	}
+
+	s.Run("workspace as Kubernetes Status", func() {
+		// given
+		ctx, rec := newRequestContext("/workspaces/not-existing-workspace/api/v1/pods")
+
+		// when
+		err := proxy.handleRequestAndRedirect(ctx)
+		require.Error(s.T(), err)
+		customHTTPErrorHandler(err, ctx)
+
+		// then
+		assert.Equal(s.T(), "/api/v1/pods", ctx.Request().URL.Path) // the path was rewritten before the error was handled
+		assert.Equal(s.T(), "application/json", rec.Header().Get("Content-Type"))
+		status := &metav1.Status{}
+		require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), status))
+		assert.Equal(s.T(), "Status", status.Kind)
+		require.NotNil(s.T(), status.Details)
+		assert.Equal(s.T(), "workspaces", status.Details.Kind)
+		assert.Equal(s.T(), "not-existing-workspace", status.Details.Name)
+	})
+
+	s.Run("workspace of proxy plugin as plain text", func() {
+		// given
+		ctx, rec := newRequestContext("/plugins/myplugin/workspaces/not-existing-workspace/api/v1/pods")
+
+		// when
+		err := proxy.handleRequestAndRedirect(ctx)
+		require.Error(s.T(), err)
+		customHTTPErrorHandler(err, ctx)
+
+		// then
+		assert.Equal(s.T(), "/api/v1/pods", ctx.Request().URL.Path) // the path was rewritten before the error was handled
+		assert.Contains(s.T(), rec.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(s.T(), err.Error(), rec.Body.String())
+	})
+
+	s.Run("invalid workspace path of proxy plugin as plain text", func() {
+		// given
+		ctx, rec := newRequestContext("/plugins/myplugin/workspaces/")
+
+		// when
+		err := proxy.handleRequestAndRedirect(ctx)
+		require.Error(s.T(), err)
+		customHTTPErrorHandler(err, ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rec.Code)
+		assert.Contains(s.T(), rec.Header().Get("Content-Type"), "text/plain")
+	})
+}
+
 func (s *TestProxySuite) TestValidateWorkspaceRequest() {
 	tests := map[string]struct {
 		requestedWorkspace string
@@ -1180,106 +1366,6 @@ func (s *TestProxySuite) TestValidateWorkspaceRequest() {
 	}
 }
 
-func (s *TestProxySuite) TestGetTransport() {
-
-	s.Run("when not prod", func() {
-		for _, envName := range []testconfig.EnvName{testconfig.E2E, testconfig.Dev} {
-			s.Run("env "+string(envName), func() {
-				// given
-				env := s.DefaultConfig().Environment()
-				defer s.SetConfig(testconfig.RegistrationService().
-					Environment(env))
-				s.SetConfig(testconfig.RegistrationService().
-					Environment(string(envName)))
-
-				// when
-				transport := getTransport(map[string][]string{})
-
-				// then
-				expectedTransport := noTimeoutDefaultTransport()
-				expectedTransport.TLSClientConfig = &tls.Config{
-					InsecureSkipVerify: true, // nolint:gosec
-				}
-				assertTransport(s.T(), expectedTransport, transport)
-			})
-		}
-	})
-
-	s.Run("for prod", func() {
-		// given
-		env := s.DefaultConfig().Environment()
-		defer s.SetConfig(testconfig.RegistrationService().
-			Environment(env))
-		s.SetConfig(testconfig.RegistrationService().
-			Environment(string(testconfig.Prod)))
-
-		s.Run("upgrade header is set to 'SPDY/3.1'", func() {
-			// when
-			transport := getTransport(map[string][]string{
-				"Connection": {"Upgrade"},
-				"Upgrade":    {"SPDY/3.1"},
-			})
-
-			// then
-			expectedTransport := noTimeoutDefaultTransport().Clone()
-			expectedTransport.TLSClientConfig.NextProtos = []string{"http/1.1"}
-			expectedTransport.ForceAttemptHTTP2 = false
-
-			assertTransport(s.T(), expectedTransport, transport)
-		})
-
-		s.Run("upgrade header is set to 'websocket'", func() {
-			// when
-			transport := getTransport(map[string][]string{
-				"Connection": {"Upgrade"},
-				"Upgrade":    {"websocket"},
-			})
-
-			// then
-			assertTransport(s.T(), noTimeoutDefaultTransport(), transport)
-		})
-
-		s.Run("no upgrade header is set", func() {
-			// when
-			transport := getTransport(map[string][]string{})
-
-			// then
-			assertTransport(s.T(), noTimeoutDefaultTransport(), transport)
-		})
-	})
-
-	s.Run("default transport should be same except for DailContext", func() {
-		// when
-		transport := http.DefaultTransport.(interface {
-			Clone() *http.Transport
-		}).Clone()
-		transport.DialContext = noTimeoutDialerProxy
-
-		// then
-		assertTransport(s.T(), noTimeoutDefaultTransport(), transport)
-	})
-}
-
-func assertTransport(t *testing.T, expected, actual *http.Transport) {
-	// we need to assert TLSClientConfig directly since it's a pointer
-	assert.Equal(t, expected.TLSClientConfig, actual.TLSClientConfig)
-	// and now set it to nil so the different pointer address doesn't cause failures in the last assertion
-	expected.TLSClientConfig = nil
-	actual.TLSClientConfig = nil
-
-	// it's not possible to use assert.Equal for comparing functions, so let's use reflect to get the pointer
-	// and then set them to nil as well
-	assert.Equal(t, reflect.ValueOf(expected.DialContext).Pointer(), reflect.ValueOf(actual.DialContext).Pointer())
-	expected.DialContext = nil
-	actual.DialContext = nil
-	assert.Equal(t, reflect.ValueOf(expected.Proxy).Pointer(), reflect.ValueOf(actual.Proxy).Pointer())
-	expected.Proxy = nil
-	actual.Proxy = nil
-
-	// do final comparison of the rest of the comparable params
-	assert.Equal(t, expected, actual)
-}
-
 func (s *TestProxySuite) request() *http.Request {
 	req, err := http.NewRequest("GET", "http://localhost:8081/api/mycoolworkspace/pods", nil)
 	require.NoError(s.T(), err)
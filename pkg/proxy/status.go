@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newStatusError converts the given error into a StatusError, as returned by the Kubernetes API server.
+// The details of the Status refer to the requested workspace, if any.
+func newStatusError(cause error, workspace string) *apierrors.StatusError {
+	statusErr := &apierrors.StatusError{}
+	if errors.As(cause, &statusErr) {
+		return statusErr
+	}
+	code := http.StatusInternalServerError
+	ce := &crterrors.Error{}
+	if errors.As(cause, &ce) {
+		code = ce.Code
+	}
+	status := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    int32(code), // nolint:gosec
+		Reason:  statusReason(code),
+		Message: cause.Error(),
+	}
+	if workspace != "" || (ce.Details != "" && ce.Details != ce.Message) || ce.RetryAfter > 0 {
+		status.Details = &metav1.StatusDetails{}
+		if workspace != "" {
+			status.Details.Group = toolchainv1alpha1.GroupVersion.Group
+			status.Details.Kind = "workspaces"
+			status.Details.Name = workspace
+		}
+		if ce.Details != "" && ce.Details != ce.Message {
+			status.Details.Causes = []metav1.StatusCause{{Message: ce.Details}}
+		}
+		if ce.RetryAfter > 0 {
+			status.Details.RetryAfterSeconds = int32(math.Ceil(ce.RetryAfter.Seconds())) // nolint:gosec
+		}
+	}
+	return &apierrors.StatusError{ErrStatus: status}
+}
+
+// statusReason returns the reason of a Status with the given code, as set by the Kubernetes API server
+func statusReason(code int) metav1.StatusReason {
+	switch code {
+	case http.StatusBadRequest:
+		return metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		return metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		return metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		return metav1.StatusReasonNotFound
+	case http.StatusNotAcceptable:
+		return metav1.StatusReasonNotAcceptable
+	case http.StatusTooManyRequests:
+		return metav1.StatusReasonTooManyRequests
+	case http.StatusServiceUnavailable:
+		return metav1.StatusReasonServiceUnavailable
+	case http.StatusInternalServerError:
+		return metav1.StatusReasonInternalError
+	default:
+		return metav1.StatusReasonUnknown
+	}
+}
+
+// acceptsStatus returns true if the client of the given request expects the errors as Kubernetes Status objects,
+// ie, if the request is not sent to a proxy plugin and accepts JSON or Protobuf, like the Kubernetes clients do.
+// Browsers and the other clients get the errors as plain text. The name of the proxy plugin is the one parsed from
+// the request path before it was rewritten, since the path doesn't contain it anymore.
+func acceptsStatus(req *http.Request, proxyPluginName string) bool {
+	if proxyPluginName != "" {
+		return false
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/json" || mediaType == "application/vnd.kubernetes.protobuf" {
+			return true
+		}
+	}
+	return false
+}
+
+// statusResponse writes the given error as a Kubernetes Status object, like the API server would
+func statusResponse(ctx echo.Context, err *apierrors.StatusError) error {
+	status := err.ErrStatus
+	status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+	if status.Details != nil && status.Details.RetryAfterSeconds > 0 {
+		ctx.Response().Writer.Header().Set("Retry-After", strconv.Itoa(int(status.Details.RetryAfterSeconds)))
+	}
+	ctx.Response().Writer.Header().Set("Content-Type", "application/json")
+	ctx.Response().Writer.WriteHeader(int(status.Code))
+	return json.NewEncoder(ctx.Response().Writer).Encode(status)
+}
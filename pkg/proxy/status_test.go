@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewStatusError(t *testing.T) {
+	t.Run("from error with workspace", func(t *testing.T) {
+		// when
+		statusErr := newStatusError(crterrors.NewForbiddenError("invalid workspace request", "access to workspace 'myworkspace' is forbidden"), "myworkspace")
+
+		// then
+		assert.Equal(t, metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: "invalid workspace request: access to workspace 'myworkspace' is forbidden",
+			Details: &metav1.StatusDetails{
+				Group:  toolchainv1alpha1.GroupVersion.Group,
+				Kind:   "workspaces",
+				Name:   "myworkspace",
+				Causes: []metav1.StatusCause{{Message: "access to workspace 'myworkspace' is forbidden"}},
+			},
+		}, statusErr.ErrStatus)
+		assert.True(t, apierrors.IsForbidden(statusErr))
+	})
+
+	t.Run("reasons", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			err            error
+			expectedCode   int32
+			expectedReason metav1.StatusReason
+		}{
+			"bad request": {
+				err:            crterrors.NewBadRequest("unable to get workspace context", "invalid path"),
+				expectedCode:   http.StatusBadRequest,
+				expectedReason: metav1.StatusReasonBadRequest,
+			},
+			"unauthorized": {
+				err:            crterrors.NewUnauthorizedError("invalid bearer token", "no token found"),
+				expectedCode:   http.StatusUnauthorized,
+				expectedReason: metav1.StatusReasonUnauthorized,
+			},
+			"internal error": {
+				err:            crterrors.NewInternalError(errors.New("unable to get target cluster"), "user is not provisioned (yet)"),
+				expectedCode:   http.StatusInternalServerError,
+				expectedReason: metav1.StatusReasonInternalError,
+			},
+			"too many requests": {
+				err:            crterrors.NewTooManyRequestsError("too many requests", "try later"),
+				expectedCode:   http.StatusTooManyRequests,
+				expectedReason: metav1.StatusReasonTooManyRequests,
+			},
+			"other error": {
+				err:            errors.New("boom"),
+				expectedCode:   http.StatusInternalServerError,
+				expectedReason: metav1.StatusReasonInternalError,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// when
+				statusErr := newStatusError(tc.err, "")
+
+				// then
+				assert.Equal(t, tc.expectedCode, statusErr.ErrStatus.Code)
+				assert.Equal(t, tc.expectedReason, statusErr.ErrStatus.Reason)
+				assert.Equal(t, tc.err.Error(), statusErr.ErrStatus.Message)
+			})
+		}
+	})
+
+	t.Run("with retry after", func(t *testing.T) {
+		// when
+		statusErr := newStatusError(crterrors.NewTooManyRequestsError("too many requests", "try later").WithRetryAfter(1500*time.Millisecond), "")
+
+		// then
+		require.NotNil(t, statusErr.ErrStatus.Details)
+		assert.Equal(t, int32(2), statusErr.ErrStatus.Details.RetryAfterSeconds)
+	})
+
+	t.Run("from status error", func(t *testing.T) {
+		// given
+		err := apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "mypod", errors.New("denied"))
+
+		// when
+		statusErr := newStatusError(err, "myworkspace")
+
+		// then
+		assert.Equal(t, err, statusErr)
+	})
+}
+
+func TestAcceptsStatus(t *testing.T) {
+	for name, tc := range map[string]struct {
+		path            string
+		proxyPluginName string
+		accept          string
+		expected        bool
+	}{
+		"kubectl": {
+			path:     "/workspaces/myworkspace/api/v1/pods",
+			accept:   "application/json;as=Table;v=v1;g=meta.k8s.io,application/json;as=Table;v=v1beta1;g=meta.k8s.io,application/json",
+			expected: true,
+		},
+		"client-go protobuf": {
+			path:     "/api/v1/pods",
+			accept:   "application/vnd.kubernetes.protobuf, */*",
+			expected: true,
+		},
+		"browser": {
+			path:     "/api/v1/pods",
+			accept:   "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			expected: false,
+		},
+		"no accept header": {
+			path:     "/api/v1/pods",
+			expected: false,
+		},
+		"proxy plugin": {
+			path:            "/api",
+			proxyPluginName: "myplugin",
+			accept:          "application/json",
+			expected:        false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			// when
+			accepts := acceptsStatus(req, tc.proxyPluginName)
+
+			// then
+			assert.Equal(t, tc.expected, accepts)
+		})
+	}
+}
+
+func TestCustomHTTPErrorHandler(t *testing.T) {
+	cause := crterrors.NewForbiddenError("invalid workspace request", "access to workspace 'myworkspace' is forbidden")
+
+	t.Run("as Kubernetes Status", func(t *testing.T) {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/workspaces/myworkspace/api/v1/pods", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+
+		// when
+		customHTTPErrorHandler(cause, echo.New().NewContext(req, rec))
+
+		// then
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		status := &metav1.Status{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), status))
+		assert.Equal(t, "Status", status.Kind)
+		assert.Equal(t, "v1", status.APIVersion)
+		assert.Equal(t, metav1.StatusReasonForbidden, status.Reason)
+		assert.Equal(t, "invalid workspace request: access to workspace 'myworkspace' is forbidden", status.Message)
+		require.NotNil(t, status.Details)
+		assert.Equal(t, "myworkspace", status.Details.Name)
+	})
+
+	t.Run("as plain text", func(t *testing.T) {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/workspaces/myworkspace/api/v1/pods", nil)
+		req.Header.Set("Accept", "text/html")
+		rec := httptest.NewRecorder()
+
+		// when
+		customHTTPErrorHandler(cause, echo.New().NewContext(req, rec))
+
+		// then
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Equal(t, "invalid workspace request: access to workspace 'myworkspace' is forbidden", rec.Body.String())
+	})
+
+	t.Run("retry after header", func(t *testing.T) {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+
+		// when
+		customHTTPErrorHandler(crterrors.NewTooManyRequestsError("too many requests", "try later").WithRetryAfter(time.Minute), echo.New().NewContext(req, rec))
+
+		// then
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	})
+}
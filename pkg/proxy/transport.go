@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+const (
+	transportDialTimeout         = 30 * time.Second
+	transportKeepAlive           = 30 * time.Second
+	transportTLSHandshakeTimeout = 10 * time.Second
+	transportIdleConnTimeout     = 90 * time.Second
+	transportMaxIdleConns        = 200
+	transportMaxIdleConnsPerHost = 50
+	// the HTTP/2 connections are checked with pings after being idle, so the watches multiplexed on a broken connection are closed,
+	// like client-go does
+	transportHTTP2SendPingTimeout = 30 * time.Second
+	transportHTTP2PingTimeout     = 15 * time.Second
+)
+
+// transportPool keeps the transports to the member clusters across the proxied requests, so that the connections
+// to their API servers and to the routes of the proxy plugins are reused.
+// There is one transport per member cluster and target host, and a separate one for the SPDY upgrades which require HTTP/1.1.
+type transportPool struct {
+	mu         sync.Mutex
+	transports map[transportKey]*pooledTransport
+}
+
+type transportKey struct {
+	cluster string
+	host    string
+	spdy    bool
+}
+
+type pooledTransport struct {
+	// fingerprint identifies the credentials and the TLS settings the transport was created with
+	fingerprint string
+	transport   *http.Transport
+}
+
+func newTransportPool() *transportPool {
+	return &transportPool{
+		transports: map[transportKey]*pooledTransport{},
+	}
+}
+
+// get returns the transport to the given target. The transport is created if there is none yet for the member cluster
+// and the host of the target, or if the credentials of the member cluster changed since it was created.
+func (p *transportPool) get(target *access.ClusterAccess, reqHeader http.Header) *http.Transport {
+	apiURL := target.APIURL()
+	key := transportKey{
+		cluster: target.ClusterName(),
+		host:    apiURL.Host,
+		spdy:    isSPDYUpgrade(reqHeader),
+	}
+	fingerprint := transportFingerprint(target)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pooled, found := p.transports[key]; found {
+		if pooled.fingerprint == fingerprint {
+			return pooled.transport
+		}
+		// the connections in use are closed by their requests, the idle ones can be closed right away
+		pooled.transport.CloseIdleConnections()
+		log.Infof(nil, "replacing the transport to %s of cluster '%s' after its credentials changed", key.host, key.cluster)
+	}
+	transport := newTransport(target, key.spdy)
+	p.transports[key] = &pooledTransport{
+		fingerprint: fingerprint,
+		transport:   transport,
+	}
+	return transport
+}
+
+// newTransport returns a transport to the given target, without any timeout for the requests, since they may be watches
+// or streams, but with keep-alive connections. HTTP/2 is used where the target supports it, except for the SPDY upgrades.
+func newTransport(target *access.ClusterAccess, spdy bool) *http.Transport {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   transportDialTimeout,
+			KeepAlive: transportKeepAlive,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          transportMaxIdleConns,
+		MaxIdleConnsPerHost:   transportMaxIdleConnsPerHost,
+		IdleConnTimeout:       transportIdleConnTimeout,
+		TLSHandshakeTimeout:   transportTLSHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       newTLSConfig(target),
+		HTTP2: &http.HTTP2Config{
+			SendPingTimeout: transportHTTP2SendPingTimeout,
+			PingTimeout:     transportHTTP2PingTimeout,
+		},
+	}
+	// for exec and rsh command we cannot use h2 because it doesn't support "Upgrade: SPDY/3.1" header https://github.com/kubernetes/kubernetes/issues/7452
+	if spdy {
+		// thus, we need to switch to http/1.1
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	}
+	return transport
+}
+
+// newTLSConfig returns the TLS configuration verifying the certificate of the target with the CA data of its member cluster.
+// The verification is skipped if the member cluster is configured as insecure, or if there is no CA data outside
+// of the production environment.
+func newTLSConfig(target *access.ClusterAccess) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	switch {
+	case target.Insecure():
+		cfg.InsecureSkipVerify = true // nolint:gosec
+	case len(target.CAData()) > 0:
+		rootCAs := x509.NewCertPool()
+		if rootCAs.AppendCertsFromPEM(target.CAData()) {
+			cfg.RootCAs = rootCAs
+		} else {
+			log.Infof(nil, "invalid CA data for cluster '%s', using the system certificates instead", target.ClusterName())
+		}
+	case !configuration.GetRegistrationServiceConfig().IsProdEnvironment():
+		cfg.InsecureSkipVerify = true // nolint:gosec
+	}
+	return cfg
+}
+
+// transportFingerprint returns the hash of the credentials and of the TLS settings of the given target
+func transportFingerprint(target *access.ClusterAccess) string {
+	h := sha256.New()
+	h.Write(target.CAData())
+	h.Write([]byte(target.ImpersonatorToken()))
+	h.Write([]byte(strconv.FormatBool(target.Insecure())))
+	h.Write([]byte(strconv.FormatBool(configuration.GetRegistrationServiceConfig().IsProdEnvironment())))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isSPDYUpgrade(reqHeader http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/")
+}
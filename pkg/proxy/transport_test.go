@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestTransportPool() {
+	// given
+	env := s.DefaultConfig().Environment()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.Prod)))
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(s.T(), err)
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	memberAccess := func(token string, opts ...access.Option) *access.ClusterAccess {
+		return access.NewClusterAccess(*serverURL, token, "smith", append([]access.Option{access.WithClusterName("member-1")}, opts...)...)
+	}
+	spdyHeader := http.Header{
+		"Connection": {"Upgrade"},
+		"Upgrade":    {"SPDY/3.1"},
+	}
+
+	s.Run("transport reused for same member cluster", func() {
+		// given
+		pool := newTransportPool()
+
+		// when
+		transport := pool.get(memberAccess("token", access.WithTLS(caData, false)), http.Header{})
+
+		// then
+		assert.Same(s.T(), transport, pool.get(memberAccess("token", access.WithTLS(caData, false)), http.Header{}))
+		assert.True(s.T(), transport.ForceAttemptHTTP2)
+		assert.Equal(s.T(), transportMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+		assert.Equal(s.T(), transportIdleConnTimeout, transport.IdleConnTimeout)
+		assert.False(s.T(), transport.DisableKeepAlives)
+		assert.False(s.T(), transport.TLSClientConfig.InsecureSkipVerify)
+		assert.NotNil(s.T(), transport.TLSClientConfig.RootCAs)
+
+		s.Run("certificate verified with the CA data of the member cluster", func() {
+			// when
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+
+			// then
+			require.NoError(s.T(), err)
+			defer resp.Body.Close()
+			assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		})
+	})
+
+	s.Run("certificate not verified without the CA data of the member cluster", func() {
+		// given
+		transport := newTransportPool().get(memberAccess("token"), http.Header{})
+
+		// when
+		_, err := (&http.Client{Transport: transport}).Get(server.URL) // nolint:bodyclose
+
+		// then
+		require.ErrorContains(s.T(), err, "certificate")
+	})
+
+	s.Run("separate transport for SPDY upgrades", func() {
+		// given
+		pool := newTransportPool()
+		transport := pool.get(memberAccess("token", access.WithTLS(caData, false)), http.Header{})
+
+		// when
+		spdyTransport := pool.get(memberAccess("token", access.WithTLS(caData, false)), spdyHeader)
+
+		// then
+		assert.NotSame(s.T(), transport, spdyTransport)
+		assert.False(s.T(), spdyTransport.ForceAttemptHTTP2)
+		assert.Equal(s.T(), []string{"http/1.1"}, spdyTransport.TLSClientConfig.NextProtos)
+		assert.Empty(s.T(), transport.TLSClientConfig.NextProtos)
+		assert.NotNil(s.T(), spdyTransport.TLSClientConfig.RootCAs)
+		assert.Same(s.T(), spdyTransport, pool.get(memberAccess("token", access.WithTLS(caData, false)), spdyHeader))
+	})
+
+	s.Run("websocket upgrade uses default transport", func() {
+		// given
+		pool := newTransportPool()
+		transport := pool.get(memberAccess("token"), http.Header{})
+
+		// when
+		wsTransport := pool.get(memberAccess("token"), http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"websocket"},
+		})
+
+		// then
+		assert.Same(s.T(), transport, wsTransport)
+	})
+
+	s.Run("separate transports for other member cluster and plugin route", func() {
+		// given
+		pool := newTransportPool()
+		transport := pool.get(memberAccess("token"), http.Header{})
+		routeURL, err := url.Parse("https://myservice.endpoint.member-1.com")
+		require.NoError(s.T(), err)
+
+		// when
+		otherMember := pool.get(access.NewClusterAccess(*serverURL, "token", "smith", access.WithClusterName("member-2")), http.Header{})
+		route := pool.get(access.NewClusterAccess(*routeURL, "token", "smith", access.WithClusterName("member-1")), http.Header{})
+
+		// then
+		assert.NotSame(s.T(), transport, otherMember)
+		assert.NotSame(s.T(), transport, route)
+		assert.NotSame(s.T(), otherMember, route)
+	})
+
+	s.Run("transport replaced when credentials change", func() {
+		for name, changed := range map[string]*access.ClusterAccess{
+			"token":    memberAccess("other-token", access.WithTLS(caData, false)),
+			"CA data":  memberAccess("token"),
+			"insecure": memberAccess("token", access.WithTLS(caData, true)),
+		} {
+			s.Run(name, func() {
+				// given
+				pool := newTransportPool()
+				transport := pool.get(memberAccess("token", access.WithTLS(caData, false)), http.Header{})
+
+				// when
+				replaced := pool.get(changed, http.Header{})
+
+				// then
+				assert.NotSame(s.T(), transport, replaced)
+				assert.Same(s.T(), replaced, pool.get(changed, http.Header{}))
+			})
+		}
+	})
+
+	s.Run("insecure member cluster", func() {
+		// when
+		transport := newTransportPool().get(memberAccess("token", access.WithTLS(caData, true)), http.Header{})
+
+		// then
+		assert.True(s.T(), transport.TLSClientConfig.InsecureSkipVerify)
+		assert.Nil(s.T(), transport.TLSClientConfig.RootCAs)
+	})
+
+	s.Run("when not prod", func() {
+		for _, envName := range []testconfig.EnvName{testconfig.E2E, testconfig.Dev} {
+			s.Run("env "+string(envName), func() {
+				// given
+				s.SetConfig(testconfig.RegistrationService().
+					Environment(string(envName)))
+				defer s.SetConfig(testconfig.RegistrationService().
+					Environment(string(testconfig.Prod)))
+				pool := newTransportPool()
+
+				s.Run("without CA data", func() {
+					// when
+					transport := pool.get(memberAccess("token"), http.Header{})
+
+					// then
+					assert.True(s.T(), transport.TLSClientConfig.InsecureSkipVerify)
+				})
+
+				s.Run("with CA data", func() {
+					// when
+					transport := pool.get(memberAccess("token", access.WithTLS(caData, false)), http.Header{})
+
+					// then
+					assert.False(s.T(), transport.TLSClientConfig.InsecureSkipVerify)
+					assert.NotNil(s.T(), transport.TLSClientConfig.RootCAs)
+				})
+			})
+		}
+	})
+}
@@ -4,15 +4,17 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/application"
 	"github.com/codeready-toolchain/registration-service/pkg/application/service"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
 	signupservice "github.com/codeready-toolchain/registration-service/pkg/signup/service"
 	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
 )
 
-// NewInClusterApplication creates a new in-cluster application.
-func NewInClusterApplication(client namespaced.Client) application.Application {
+// NewInClusterApplication creates a new in-cluster application, which publishes the signup lifecycle events
+// with the given publisher (if not nil).
+func NewInClusterApplication(client namespaced.Client, publisher *events.Publisher) application.Application {
 	return &InClusterApplication{
-		signupService:       signupservice.NewSignupService(client),
-		verificationService: verificationservice.NewVerificationService(client),
+		signupService:       signupservice.NewSignupService(client, signupservice.WithEventPublisher(publisher)),
+		verificationService: verificationservice.NewVerificationService(client, verificationservice.WithEventPublisher(publisher)),
 	}
 }
 
@@ -11,6 +11,10 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/middleware"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaces"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/phonepolicy"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/sender"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/gin-gonic/gin"
 
@@ -23,7 +27,7 @@ import (
 // proxyPort is the API Proxy Server port to be used to setup a route for the health checker for the proxy.
 func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Registry, nsClient namespaced.Client) error {
 	var err error
-	_, err = auth.InitializeDefaultTokenParser()
+	tokenParser, err := auth.InitializeDefaultTokenParser()
 	if err != nil {
 		return err
 	}
@@ -53,6 +57,11 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 
 	// Register all of the metrics in the standard registry.
 	reg.MustRegister(counter, histVec, inFlightGauge)
+	reg.MustRegister(tokenParser.Collectors()...)
+	reg.MustRegister(sender.Collectors()...)
+	reg.MustRegister(phonepolicy.Collectors()...)
+	reg.MustRegister(delivery.Collectors()...)
+	reg.MustRegister(events.Collectors()...)
 
 	srv.routesSetup.Do(func() {
 		// creating the controllers
@@ -63,6 +72,7 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		namespacesCtrl := controller.NewNamespacesController(namespaces.NewNamespacesManager(cluster.GetMemberClusters, nsClient, srv.application.SignupService()))
 		usernamesCtrl := controller.NewUsernames(nsClient)
 		uiConfigCtrl := controller.NewUIConfig()
+		deliveryStatusCtrl := controller.NewDeliveryStatus(nsClient)
 
 		// unsecured routes
 		unsecuredV1 := srv.router.Group("/api/v1")
@@ -75,10 +85,13 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		// segment keys endpoints
 		unsecuredV1.GET("/segment-write-key", analyticsCtrl.GetDevSpacesSegmentWriteKey)         // expose the devspaces segment key
 		unsecuredV1.GET("/analytics/segment-write-key", analyticsCtrl.GetSandboxSegmentWriteKey) // expose the sandbox segment key.We had the create a new analytics endpoint to keep backward compatibility with devspaces.
+		// delivery status callbacks of the notification providers, authenticated with the signatures of the providers
+		unsecuredV1.POST("/signup/verification/delivery-status/twilio", deliveryStatusCtrl.TwilioHandler)
+		unsecuredV1.POST("/signup/verification/delivery-status/aws", deliveryStatusCtrl.SNSHandler)
 
 		// create the auth middleware
 		var authMiddleware *middleware.JWTMiddleware
-		authMiddleware, err = middleware.NewAuthMiddleware()
+		authMiddleware, err = middleware.NewAuthMiddleware(nsClient)
 		if err != nil {
 			err = errs.Wrapf(err, "failed to init auth middleware")
 			return
@@ -99,11 +112,39 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		// requires a ctx body containing the country_code and phone_number
 		securedV1.PUT("/signup/verification", signupCtrl.InitVerificationHandler)
 		securedV1.GET("/signup", signupCtrl.GetHandler)
+		// requires the `confirmation` query parameter with the token returned by the first call to deactivate the user
+		securedV1.DELETE("/signup", signupCtrl.DeleteHandler)
+		securedV1.GET("/signup/export", signupCtrl.ExportHandler)
+		securedV1.POST("/signup/invitations", signupCtrl.CreateInvitationHandler)
+		securedV1.GET("/signup/invitations", signupCtrl.ListInvitationsHandler)
+		if srv.signupNotifier != nil {
+			securedV1.GET("/signup/events", controller.NewSignupStream(srv.application, srv.signupNotifier).GetHandler)
+		}
 		securedV1.GET("/signup/verification/:code", signupCtrl.VerifyPhoneCodeHandler) // TODO: also provide a `POST /signup/verification/phone-code` +deprecate this one + migrate UI?
 		securedV1.POST("/signup/verification/activation-code", signupCtrl.VerifyActivationCodeHandler)
+		securedV1.PUT("/signup/verification/email", signupCtrl.InitEmailVerificationHandler)
+		// requires a ctx body containing either the code or the token of the magic link
+		securedV1.POST("/signup/verification/email", signupCtrl.VerifyEmailHandler)
 		securedV1.GET("/usernames/:username", usernamesCtrl.GetHandler)
 		securedV1.GET("/uiconfig", uiConfigCtrl.GetHandler)
 
+		// machine API, for the automation tools authenticated with a client credentials token or a ServiceAccount token
+		var machineAuthMiddleware *middleware.MachineAuthMiddleware
+		machineAuthMiddleware, err = middleware.NewMachineAuthMiddleware(nsClient)
+		if err != nil {
+			err = errs.Wrapf(err, "failed to init machine auth middleware")
+			return
+		}
+		machineV1 := srv.router.Group("/api/v1/machine")
+		machineV1.Use(
+			middleware.InstrumentRoundTripperInFlight(inFlightGauge),
+			middleware.InstrumentRoundTripperCounter(counter),
+			middleware.InstrumentRoundTripperDuration(histVec),
+			machineAuthMiddleware.HandlerFunc(),
+			receivedTimeMw)
+		machineV1.GET("/signups/:username", middleware.RequireScope(auth.ScopeSignupsRead), signupCtrl.GetByUsernameHandler)
+		machineV1.GET("/usernames/:username", middleware.RequireScope(auth.ScopeUsernamesRead), usernamesCtrl.GetHandler)
+
 		// if we are in testing mode, we also add a secured health route for testing
 		if configuration.IsTestingMode() {
 			securedV1.GET("/auth_test", healthCheckCtrl.GetHandler)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/codeready-toolchain/registration-service/pkg/application"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
@@ -20,6 +21,13 @@ import (
 
 type ServerOption = func(server *RegistrationServer) // nolint:revive
 
+// WithSignupNotifier enables the endpoint streaming the status of the signups, which is notified of their changes by the given notifier
+func WithSignupNotifier(notifier *signup.ChangeNotifier) ServerOption {
+	return func(server *RegistrationServer) {
+		server.signupNotifier = notifier
+	}
+}
+
 // RegistrationServer bundles configuration, and HTTP server objects in a single
 // location.
 type RegistrationServer struct {
@@ -27,14 +35,21 @@ type RegistrationServer struct {
 	httpServer  *http.Server
 	routesSetup sync.Once
 	//applicationProducerFunc func() application.Application
-	application application.Application
+	application    application.Application
+	signupNotifier *signup.ChangeNotifier
 }
 
 // New creates a new RegistrationServer object with reasonable defaults.
-func New(application application.Application) *RegistrationServer {
+func New(application application.Application, opts ...ServerOption) *RegistrationServer {
 
 	gin.SetMode(gin.ReleaseMode)
 	ginRouter := gin.New()
+	// the `X-Forwarded-For` and `X-Real-IP` headers are only used to obtain the IP address of the client when they are
+	// set by a trusted proxy, otherwise the clients could choose the address used by the rate limits
+	if err := ginRouter.SetTrustedProxies(configuration.GetRegistrationServiceConfig().TrustedProxies()); err != nil {
+		log.Error(err, "invalid trusted proxies, no proxy is trusted")
+		_ = ginRouter.SetTrustedProxies(nil)
+	}
 	ginRouter.Use(
 		gin.LoggerWithConfig(gin.LoggerConfig{
 			Output:    gin.DefaultWriter,
@@ -74,6 +89,9 @@ func New(application application.Application) *RegistrationServer {
 		router:      ginRouter,
 		application: application,
 	}
+	for _, opt := range opts {
+		opt(srv)
+	}
 
 	gin.DefaultWriter = io.MultiWriter(os.Stdout)
 
@@ -86,11 +104,12 @@ func New(application application.Application) *RegistrationServer {
 		Handler:      srv.router,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
-			NextProtos: []string{"http/1.1"}, // disable HTTP/2 for now
 		},
+		Protocols: configuration.HTTPProtocols(),
 	}
 	if configuration.HTTPCompressResponses {
-		srv.router.Use(gzip.Gzip(gzip.DefaultCompression))
+		// the signup stream must be flushed as the events are sent
+		srv.router.Use(gzip.Gzip(gzip.DefaultCompression, gzip.WithExcludedPaths([]string{"/api/v1/signup/events"})))
 	}
 	return srv
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -110,6 +112,93 @@ func (s *TestServerSuite) TestServer() {
 	})
 }
 
+func (s *TestServerSuite) TestServerProtocols() {
+	// when
+	srv := server.New(util.PrepareInClusterApplication(s.T()))
+
+	// then
+	protocols := srv.HTTPServer().Protocols
+	require.NotNil(s.T(), protocols)
+	assert.True(s.T(), protocols.HTTP1())
+	assert.True(s.T(), protocols.HTTP2())
+	assert.True(s.T(), protocols.UnencryptedHTTP2())
+	assert.Empty(s.T(), srv.HTTPServer().TLSConfig.NextProtos)
+}
+
+func (s *TestServerSuite) TestClientIP() {
+	// clientIP returns the IP address of the client of a request sent by the given remote address with the given `X-Forwarded-For` header
+	clientIP := func(srv *server.RegistrationServer, remoteAddr, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "/client-ip", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rr := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(rr, req)
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+	newServer := func(trustedProxies string) *server.RegistrationServer {
+		s.SetSettings(map[string]string{
+			"server.trustedProxies": trustedProxies,
+		})
+		srv := server.New(util.PrepareInClusterApplication(s.T()))
+		srv.Engine().GET("/client-ip", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, ctx.ClientIP())
+		})
+		return srv
+	}
+
+	s.Run("no trusted proxy", func() {
+		// given
+		srv := newServer("")
+
+		// when
+		ip := clientIP(srv, "10.128.0.1:40000", "1.2.3.4")
+
+		// then
+		assert.Equal(s.T(), "10.128.0.1", ip)
+	})
+
+	s.Run("trusted proxy", func() {
+		// given
+		srv := newServer("10.128.0.0/14")
+
+		s.Run("address forwarded by the trusted proxy", func() {
+			// when
+			ip := clientIP(srv, "10.128.0.1:40000", "1.2.3.4")
+
+			// then
+			assert.Equal(s.T(), "1.2.3.4", ip)
+		})
+
+		s.Run("address set by the client and forwarded by the trusted proxy", func() {
+			// when
+			ip := clientIP(srv, "10.128.0.1:40000", "5.6.7.8, 1.2.3.4")
+
+			// then
+			assert.Equal(s.T(), "1.2.3.4", ip)
+		})
+
+		s.Run("address set by a client which is not a trusted proxy", func() {
+			// when
+			ip := clientIP(srv, "1.2.3.4:40000", "5.6.7.8")
+
+			// then
+			assert.Equal(s.T(), "1.2.3.4", ip)
+		})
+	})
+
+	s.Run("invalid trusted proxy", func() {
+		// given
+		srv := newServer("not-an-ip")
+
+		// when
+		ip := clientIP(srv, "10.128.0.1:40000", "1.2.3.4")
+
+		// then
+		assert.Equal(s.T(), "10.128.0.1", ip)
+	})
+}
+
 func startFakeProxy(t *testing.T) *http.Server {
 	// start server
 	mux := http.NewServeMux()
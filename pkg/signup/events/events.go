@@ -0,0 +1,125 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+
+	"github.com/google/uuid"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// types of the signup lifecycle events
+const (
+	// TypeCreated is published when a user signs up for the first time
+	TypeCreated = "com.openshift.dev.toolchain.usersignup.created"
+	// TypeReactivated is published when a deactivated user signs up again
+	TypeReactivated = "com.openshift.dev.toolchain.usersignup.reactivated"
+	// TypeVerified is published when a user verifies their account, with a verification code or an activation code
+	TypeVerified = "com.openshift.dev.toolchain.usersignup.verified"
+	// TypeProvisioned is published when the account of a user is ready
+	TypeProvisioned = "com.openshift.dev.toolchain.usersignup.provisioned"
+	// TypeDeactivated is published when the account of a user is deactivated
+	TypeDeactivated = "com.openshift.dev.toolchain.usersignup.deactivated"
+	// TypeBanned is published when a user is banned
+	TypeBanned = "com.openshift.dev.toolchain.usersignup.banned"
+)
+
+// Event is a signup lifecycle event, serialized in the structured content mode of CloudEvents 1.0
+type Event struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Data      `json:"data"`
+}
+
+// Data is the payload of the signup lifecycle events
+type Data struct {
+	// UserSignup is the name of the UserSignup resource
+	UserSignup        string `json:"userSignup"`
+	Username          string `json:"username"`
+	CompliantUsername string `json:"compliantUsername,omitempty"`
+	UserID            string `json:"userID,omitempty"`
+	AccountID         string `json:"accountID,omitempty"`
+	Email             string `json:"email,omitempty"`
+	// VerificationMethod is set in the `verified` events, and is one of `phone`, `email` or `activation-code`
+	VerificationMethod string `json:"verificationMethod,omitempty"`
+}
+
+// VerificationMethodActivationCode is the verification method of the users who verified their account with the activation code of an event
+const VerificationMethodActivationCode = "activation-code"
+
+// NewEvent returns a new event of the given type about the given UserSignup, with a random ID
+func NewEvent(eventType string, userSignup *toolchainv1alpha1.UserSignup) *Event {
+	return newEvent(uuid.NewString(), eventType, userSignup)
+}
+
+// NewVerifiedEvent returns a new `verified` event about the given UserSignup, verified with the given method
+func NewVerifiedEvent(userSignup *toolchainv1alpha1.UserSignup, method string) *Event {
+	event := NewEvent(TypeVerified, userSignup)
+	event.Data.VerificationMethod = method
+	return event
+}
+
+// NewTransitionEvent returns the event of the transition of the UserSignup from the old to the new version, if any.
+// The transitions are made by the host operator and observed by every replica of the registration service,
+// so the ID of the event is derived from the new version of the UserSignup to let the sinks drop the duplicates.
+func NewTransitionEvent(oldUserSignup, newUserSignup *toolchainv1alpha1.UserSignup) *Event {
+	eventType := lifecycleState(newUserSignup)
+	if eventType == "" || eventType == lifecycleState(oldUserSignup) {
+		return nil
+	}
+	id := sha256.Sum256([]byte(string(newUserSignup.UID) + "/" + newUserSignup.ResourceVersion + "/" + eventType))
+	return newEvent(hex.EncodeToString(id[:16]), eventType, newUserSignup)
+}
+
+func newEvent(id, eventType string, userSignup *toolchainv1alpha1.UserSignup) *Event {
+	return &Event{
+		SpecVersion:     "1.0",
+		ID:              id,
+		Source:          configuration.GetRegistrationServiceConfig().Events().Source(),
+		Type:            eventType,
+		Subject:         userSignup.Name,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data: Data{
+			UserSignup:        userSignup.Name,
+			Username:          userSignup.Spec.IdentityClaims.PreferredUsername,
+			CompliantUsername: userSignup.Status.CompliantUsername,
+			UserID:            userSignup.Spec.IdentityClaims.UserID,
+			AccountID:         userSignup.Spec.IdentityClaims.AccountID,
+			Email:             userSignup.Spec.IdentityClaims.Email,
+		},
+	}
+}
+
+// lifecycleState returns the type of the event published when the UserSignup reaches its current state,
+// or an empty string if the state is not published
+func lifecycleState(userSignup *toolchainv1alpha1.UserSignup) string {
+	switch userSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey] {
+	case toolchainv1alpha1.UserSignupStateLabelValueBanned:
+		return TypeBanned
+	case toolchainv1alpha1.UserSignupStateLabelValueDeactivated:
+		return TypeDeactivated
+	}
+	complete, found := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
+	if !found || complete.Status != apiv1.ConditionTrue {
+		return ""
+	}
+	switch complete.Reason {
+	case toolchainv1alpha1.UserSignupUserBannedReason:
+		return TypeBanned
+	case toolchainv1alpha1.UserSignupUserDeactivatedReason:
+		return TypeDeactivated
+	default:
+		return TypeProvisioned
+	}
+}
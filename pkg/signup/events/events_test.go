@@ -0,0 +1,115 @@
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvent(t *testing.T) {
+	// given
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.WithUserID("13349822"),
+		testusersignup.WithAccountID("45983711"),
+		testusersignup.WithCompliantUsername("johnny"))
+
+	// when
+	event := events.NewEvent(events.TypeCreated, userSignup)
+
+	// then
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, events.TypeCreated, event.Type)
+	assert.Equal(t, userSignup.Name, event.Subject)
+	assert.WithinDuration(t, time.Now(), event.Time, time.Second)
+	assert.Equal(t, "application/json", event.DataContentType)
+	assert.Equal(t, events.Data{
+		UserSignup:        userSignup.Name,
+		Username:          userSignup.Spec.IdentityClaims.PreferredUsername,
+		CompliantUsername: "johnny",
+		UserID:            "13349822",
+		AccountID:         "45983711",
+		Email:             userSignup.Spec.IdentityClaims.Email,
+	}, event.Data)
+	assert.NotEqual(t, event.ID, events.NewEvent(events.TypeCreated, userSignup).ID)
+}
+
+func TestNewTransitionEvent(t *testing.T) {
+	notReady := testusersignup.NewUserSignup(
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueNotReady),
+		testusersignup.SignupIncomplete(toolchainv1alpha1.UserSignupVerificationRequiredReason, ""))
+	approved := testusersignup.NewUserSignup(
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved),
+		testusersignup.SignupIncomplete(toolchainv1alpha1.UserSignupProvisioningSpaceReason, ""))
+	provisioned := testusersignup.NewUserSignup(
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved),
+		testusersignup.SignupComplete(""))
+	deactivated := testusersignup.NewUserSignup(
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueDeactivated),
+		testusersignup.SignupComplete(toolchainv1alpha1.UserSignupUserDeactivatedReason))
+	banned := testusersignup.NewUserSignup(
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueBanned),
+		testusersignup.SignupComplete(toolchainv1alpha1.UserSignupUserBannedReason))
+
+	for name, tc := range map[string]struct {
+		oldUserSignup *toolchainv1alpha1.UserSignup
+		newUserSignup *toolchainv1alpha1.UserSignup
+		expectedType  string
+	}{
+		"approved":               {oldUserSignup: notReady, newUserSignup: approved},
+		"provisioned":            {oldUserSignup: approved, newUserSignup: provisioned, expectedType: events.TypeProvisioned},
+		"still provisioned":      {oldUserSignup: provisioned, newUserSignup: provisioned},
+		"deactivated":            {oldUserSignup: provisioned, newUserSignup: deactivated, expectedType: events.TypeDeactivated},
+		"reactivated":            {oldUserSignup: deactivated, newUserSignup: notReady},
+		"banned":                 {oldUserSignup: provisioned, newUserSignup: banned, expectedType: events.TypeBanned},
+		"banned while not ready": {oldUserSignup: notReady, newUserSignup: banned, expectedType: events.TypeBanned},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			event := events.NewTransitionEvent(tc.oldUserSignup, tc.newUserSignup)
+
+			// then
+			if tc.expectedType == "" {
+				assert.Nil(t, event)
+				return
+			}
+			require.NotNil(t, event)
+			assert.Equal(t, tc.expectedType, event.Type)
+			assert.Equal(t, tc.newUserSignup.Name, event.Subject)
+		})
+	}
+
+	t.Run("same ID for the same transition", func(t *testing.T) {
+		// given
+		newUserSignup := provisioned.DeepCopy()
+		newUserSignup.UID = "8d2a6e52-3c3f-4d4b-9a8f-2f5b2c1d0e9f"
+		newUserSignup.ResourceVersion = "1234"
+
+		// when
+		event := events.NewTransitionEvent(approved, newUserSignup)
+		duplicate := events.NewTransitionEvent(approved, newUserSignup)
+
+		// then
+		assert.Equal(t, event.ID, duplicate.ID)
+
+		t.Run("different ID for another transition", func(t *testing.T) {
+			// given
+			deactivated := deactivated.DeepCopy()
+			deactivated.UID = newUserSignup.UID
+			deactivated.ResourceVersion = "1240"
+
+			// when
+			other := events.NewTransitionEvent(newUserSignup, deactivated)
+
+			// then
+			assert.NotEqual(t, event.ID, other.ID)
+		})
+	})
+}
@@ -0,0 +1,214 @@
+package events
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// headers of the deliveries
+const (
+	// IDHeader contains the ID of the event, which is the same for all the attempts of a delivery
+	IDHeader = "Webhook-Id"
+	// TimestampHeader contains the time of the attempt, in seconds since the Unix epoch
+	TimestampHeader = "Webhook-Timestamp"
+	// SignatureHeader contains the signature of the attempt, prefixed with its version (eg, `v1,<signature>`)
+	SignatureHeader = "Webhook-Signature"
+)
+
+// ContentType is the content type of the events sent in the structured content mode of CloudEvents
+const ContentType = "application/cloudevents+json; charset=UTF-8"
+
+// workers is the number of deliveries sent concurrently
+const workers = 4
+
+// delivery results
+const (
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultAbandoned = "abandoned"
+	resultDropped   = "dropped"
+)
+
+var (
+	outboxSizeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sandbox_signup_events_outbox_size",
+		Help: "Number of deliveries of signup lifecycle events waiting to be sent or retried",
+	})
+	deliveryLagHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sandbox_signup_events_delivery_lag_seconds",
+		Help:    "Time between the signup lifecycle events and their successful delivery to the webhooks",
+		Buckets: prometheus.ExponentialBuckets(0.05, 4, 10),
+	})
+	deliveriesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_signup_events_deliveries_total",
+		Help: "Number of attempts of deliveries of signup lifecycle events, by result (delivered, failed, abandoned or dropped when the outbox is full)",
+	}, []string{"result"})
+)
+
+// Collectors returns the Prometheus collectors exposing the state of the outbox of the signup lifecycle events
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{outboxSizeGauge, deliveryLagHistogram, deliveriesCounter}
+}
+
+// Sign returns the base64-encoded HMAC-SHA256 of `<id>.<timestamp>.<body>` with the given secret.
+// The webhooks should compare it with the signature in the SignatureHeader, and reject the old timestamps.
+func Sign(secret, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// delivery is an event waiting to be sent to a webhook
+type delivery struct {
+	event    *Event
+	body     []byte
+	webhook  configuration.EventWebhookConfig
+	attempts int
+}
+
+// Publisher sends the signup lifecycle events to the configured webhooks. The events are kept in an in-memory outbox
+// and sent in the background, and the failed deliveries are retried with an exponential backoff. The events are lost
+// if the registration service restarts before their delivery.
+type Publisher struct {
+	httpClient *http.Client
+	queue      workqueue.TypedDelayingInterface[*delivery]
+	pending    atomic.Int64
+}
+
+// NewPublisher creates a new Publisher sending the events with the given HTTP client
+func NewPublisher(httpClient *http.Client) *Publisher {
+	return &Publisher{
+		httpClient: httpClient,
+		queue:      workqueue.NewTypedDelayingQueue[*delivery](),
+	}
+}
+
+// Start sends the deliveries of the outbox in the background until the context is done
+func (p *Publisher) Start(ctx gocontext.Context) {
+	go func() {
+		<-ctx.Done()
+		p.queue.ShutDown()
+	}()
+	for range workers {
+		go func() {
+			for p.processNextItem(ctx) {
+			}
+		}()
+	}
+}
+
+// Publish adds a delivery of the event to the outbox for each webhook accepting its type.
+// A nil Publisher doesn't publish anything.
+func (p *Publisher) Publish(event *Event) {
+	if p == nil || event == nil {
+		return
+	}
+	cfg := configuration.GetRegistrationServiceConfig().Events()
+	webhooks, err := cfg.Webhooks()
+	if err != nil {
+		log.Errorf(nil, err, "unable to publish the '%s' event of the UserSignup '%s'", event.Type, event.Subject)
+		return
+	}
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhook.Accepts(event.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(event); err != nil {
+				log.Errorf(nil, err, "unable to publish the '%s' event of the UserSignup '%s'", event.Type, event.Subject)
+				return
+			}
+		}
+		if p.pending.Load() >= int64(cfg.OutboxSize()) {
+			deliveriesCounter.WithLabelValues(resultDropped).Inc()
+			log.Infof(nil, "outbox full, dropping the '%s' event of the UserSignup '%s'", event.Type, event.Subject)
+			continue
+		}
+		outboxSizeGauge.Set(float64(p.pending.Add(1)))
+		p.queue.Add(&delivery{
+			event:   event,
+			body:    body,
+			webhook: webhook,
+		})
+	}
+}
+
+func (p *Publisher) processNextItem(ctx gocontext.Context) bool {
+	d, shutdown := p.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer p.queue.Done(d)
+
+	err := p.send(ctx, d)
+	if err == nil {
+		deliveriesCounter.WithLabelValues(resultDelivered).Inc()
+		deliveryLagHistogram.Observe(time.Since(d.event.Time).Seconds())
+		outboxSizeGauge.Set(float64(p.pending.Add(-1)))
+		return true
+	}
+
+	cfg := configuration.GetRegistrationServiceConfig().Events()
+	d.attempts++
+	if d.attempts > cfg.MaxRetries() {
+		deliveriesCounter.WithLabelValues(resultAbandoned).Inc()
+		log.Errorf(nil, err, "unable to deliver the '%s' event '%s' to '%s', giving up", d.event.Type, d.event.ID, d.webhook.URL)
+		outboxSizeGauge.Set(float64(p.pending.Add(-1)))
+		return true
+	}
+	deliveriesCounter.WithLabelValues(resultFailed).Inc()
+	log.Errorf(nil, err, "unable to deliver the '%s' event '%s' to '%s', retrying", d.event.Type, d.event.ID, d.webhook.URL)
+	p.queue.AddAfter(d, backoff(d.attempts, cfg.RetryBaseDelay(), cfg.RetryMaxDelay()))
+	return true
+}
+
+// backoff returns the delay before the given attempt, which doubles after each attempt
+func backoff(attempts int, baseDelay, maxDelay time.Duration) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// send posts the event to the webhook, and returns an error if the webhook didn't accept it
+func (p *Publisher) send(ctx gocontext.Context, d *delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhook.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set(IDHeader, d.event.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "v1,"+Sign(d.webhook.Secret, d.event.ID, timestamp, d.body))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from the webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package events_test
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestPublisherSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunPublisherSuite(t *testing.T) {
+	suite.Run(t, &TestPublisherSuite{test.UnitTestSuite{}})
+}
+
+// startPublisher starts a new Publisher, which is stopped at the end of the test
+func (s *TestPublisherSuite) startPublisher() *events.Publisher {
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	s.T().Cleanup(cancel)
+	publisher := events.NewPublisher(&http.Client{Transport: &http.Transport{}})
+	publisher.Start(ctx)
+	return publisher
+}
+
+func (s *TestPublisherSuite) TestPublish() {
+	userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("johnny@kubesaw"))
+
+	s.Run("event delivered to the webhooks accepting its type", func() {
+		// given
+		crm := fake.NewEventSink(s.T(), "crm-secret")
+		marketing := fake.NewEventSink(s.T(), "marketing-secret")
+		s.SetSettings(map[string]string{
+			"events.webhooks": fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"},
+				{"url": "%s", "secret": "marketing-secret", "types": ["%s"]}]`, crm.URL, marketing.URL, events.TypeVerified),
+		})
+		publisher := s.startPublisher()
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+		publisher.Publish(events.NewVerifiedEvent(userSignup, "phone"))
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return len(crm.Received()) == 2 && len(marketing.Received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.ElementsMatch(s.T(), []string{events.TypeCreated, events.TypeVerified},
+			[]string{crm.Received()[0].Type, crm.Received()[1].Type})
+		verified := marketing.Received()[0]
+		assert.Equal(s.T(), events.TypeVerified, verified.Type)
+		assert.Equal(s.T(), "1.0", verified.SpecVersion)
+		assert.Equal(s.T(), "registration-service", verified.Source)
+		assert.Equal(s.T(), userSignup.Name, verified.Subject)
+		assert.Equal(s.T(), "phone", verified.Data.VerificationMethod)
+	})
+
+	s.Run("failed delivery retried", func() {
+		// given
+		crm := fake.NewEventSink(s.T(), "crm-secret")
+		crm.SetStatusCode(http.StatusServiceUnavailable)
+		s.SetSettings(map[string]string{
+			"events.webhooks":       fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"}]`, crm.URL),
+			"events.retryBaseDelay": "10ms",
+		})
+		publisher := s.startPublisher()
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return crm.Attempts() >= 2
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Empty(s.T(), crm.Received())
+
+		s.Run("delivered once the webhook is available", func() {
+			// when
+			crm.SetStatusCode(http.StatusOK)
+
+			// then
+			require.Eventually(s.T(), func() bool {
+				return len(crm.Received()) == 1
+			}, 5*time.Second, 10*time.Millisecond)
+		})
+	})
+
+	s.Run("delivery abandoned after the max retries", func() {
+		// given
+		crm := fake.NewEventSink(s.T(), "crm-secret")
+		crm.SetStatusCode(http.StatusInternalServerError)
+		s.SetSettings(map[string]string{
+			"events.webhooks":       fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"}]`, crm.URL),
+			"events.maxRetries":     "2",
+			"events.retryBaseDelay": "10ms",
+		})
+		publisher := s.startPublisher()
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return crm.Attempts() == 3
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Never(s.T(), func() bool {
+			return crm.Attempts() > 3
+		}, 200*time.Millisecond, 10*time.Millisecond)
+	})
+
+	s.Run("event dropped when the outbox is full", func() {
+		// given
+		crm := fake.NewEventSink(s.T(), "crm-secret")
+		s.SetSettings(map[string]string{
+			"events.webhooks":   fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"}]`, crm.URL),
+			"events.outboxSize": "1",
+		})
+		publisher := events.NewPublisher(&http.Client{Transport: &http.Transport{}})
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+		publisher.Publish(events.NewEvent(events.TypeReactivated, userSignup))
+		ctx, cancel := gocontext.WithCancel(gocontext.Background())
+		defer cancel()
+		publisher.Start(ctx)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return len(crm.Received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), events.TypeCreated, crm.Received()[0].Type)
+	})
+
+	s.Run("no webhooks", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		publisher := s.startPublisher()
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+
+		// then nothing happens
+	})
+
+	s.Run("nil publisher", func() {
+		// given
+		var publisher *events.Publisher
+
+		// when
+		publisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+
+		// then nothing happens
+	})
+}
@@ -0,0 +1,49 @@
+package events
+
+import (
+	gocontext "context"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// Watcher watches the UserSignups to publish the events of the transitions made by the host operator,
+// ie, when the users are provisioned, deactivated or banned
+type Watcher struct {
+	publisher *Publisher
+}
+
+// NewWatcher creates a new Watcher publishing the events with the given Publisher
+func NewWatcher(publisher *Publisher) *Watcher {
+	return &Watcher{
+		publisher: publisher,
+	}
+}
+
+// Start watches the UserSignups with the given informers. The UserSignups listed when the informer starts are ignored,
+// so that the events are not published again after a restart.
+func (w *Watcher) Start(ctx gocontext.Context, informers cache.Informers) error {
+	signupInformer, err := informers.GetInformer(ctx, &toolchainv1alpha1.UserSignup{})
+	if err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+	if _, err := signupInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldUserSignup, ok := oldObj.(*toolchainv1alpha1.UserSignup)
+			if !ok {
+				return
+			}
+			newUserSignup, ok := newObj.(*toolchainv1alpha1.UserSignup)
+			if !ok {
+				return
+			}
+			w.publisher.Publish(NewTransitionEvent(oldUserSignup, newUserSignup))
+		},
+	}); err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+	return nil
+}
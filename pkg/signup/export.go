@@ -0,0 +1,56 @@
+package signup
+
+import (
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+)
+
+// Export represents everything the registration service knows about a user, which is returned to the users
+// requesting a copy of their data
+type Export struct {
+	// The UserSignup resource name
+	Name string `json:"name"`
+	// The claims of the identity of the user, as received from the Identity Provider
+	IdentityClaims toolchainv1alpha1.IdentityClaimsEmbedded `json:"identityClaims"`
+	// The annotations of the UserSignup, without the pending verification code
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// The labels of the UserSignup
+	Labels map[string]string `json:"labels,omitempty"`
+	// The states of the UserSignup, eg. `approved` or `deactivated`
+	States []toolchainv1alpha1.UserSignupState `json:"states,omitempty"`
+	// The conditions of the UserSignup, which contain the history of its approval, provisioning and deactivation
+	Conditions []toolchainv1alpha1.Condition `json:"conditions,omitempty"`
+	// The compliant username of the user, if provisioned
+	CompliantUsername string `json:"compliantUsername,omitempty"`
+	// Verification contains the history of the verification of the user
+	Verification VerificationHistory `json:"verification"`
+	// Workspaces contains the Spaces the user has access to, with the role of the user in each of them
+	Workspaces []ExportedWorkspace `json:"workspaces,omitempty"`
+}
+
+// VerificationHistory represents the verification of a user, as recorded in the UserSignup
+type VerificationHistory struct {
+	// If true then the user still has to complete the verification
+	Required bool `json:"required"`
+	// The number of verification codes sent to the user in the current day
+	Counter string `json:"counter,omitempty"`
+	// The time the last verification was initiated, in RFC3339 format
+	InitTimestamp string `json:"initTimestamp,omitempty"`
+	// The time the last verification code was generated, in RFC3339 format
+	Timestamp string `json:"timestamp,omitempty"`
+	// The hash of the phone number the user was verified with
+	PhoneNumberHash string `json:"phoneNumberHash,omitempty"`
+	// The delivery status of the last verification code sent by SMS
+	DeliveryStatus string `json:"deliveryStatus,omitempty"`
+}
+
+// ExportedWorkspace represents a Space the user has access to through a SpaceBinding
+type ExportedWorkspace struct {
+	// The name of the Space
+	Name string `json:"name"`
+	// The role of the user in the Space, as set in the SpaceBinding
+	Role string `json:"role"`
+	// The name of the member cluster the Space is provisioned to
+	TargetCluster string `json:"targetCluster,omitempty"`
+	// The names of the namespaces provisioned for the Space
+	Namespaces []string `json:"namespaces,omitempty"`
+}
@@ -0,0 +1,278 @@
+package signup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+
+	"github.com/gin-gonic/gin"
+	apiv1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// InvitationCodePrefix is the prefix of the invitation codes, which distinguishes them from the social event codes
+	InvitationCodePrefix = "inv-"
+
+	// InvitationsAnnotationKey contains the invitations created by the user, in JSON format
+	InvitationsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "invitations"
+	// InvitationQuotaAnnotationKey can be set on a UserSignup to override the default number of invitations the user can create
+	InvitationQuotaAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "invitation-quota"
+	// InvitationLabelKeyPrefix is the prefix of the labels set on the UserSignup of the user who created an invitation,
+	// to find them when the invitation is redeemed. It is followed by the hash of the code.
+	InvitationLabelKeyPrefix = toolchainv1alpha1.LabelKeyPrefix + "invitation-"
+	// ReferredByLabelKey is set on the UserSignup of the user who redeemed an invitation, with the name of the UserSignup
+	// of the user who created it
+	ReferredByLabelKey = toolchainv1alpha1.LabelKeyPrefix + "referred-by"
+
+	// the label names can't be longer than 63 characters, so the hash of the code is truncated in the label key
+	invitationLabelHashLength = 40
+)
+
+// Invitation represents an invitation code created by a user to invite someone else
+type Invitation struct {
+	// The invitation code, which is only returned when the invitation is created
+	Code string `json:"code,omitempty"`
+	// The ID of the invitation, derived from the hash of the code
+	ID string `json:"id"`
+	// The time the invitation was created, in RFC3339 format
+	CreatedAt string `json:"createdAt"`
+	// The time after which the invitation can't be redeemed anymore, in RFC3339 format
+	ExpiresAt string `json:"expiresAt"`
+	// The username of the user who redeemed the invitation
+	RedeemedBy string `json:"redeemedBy,omitempty"`
+	// The time the invitation was redeemed, in RFC3339 format
+	RedeemedAt string `json:"redeemedAt,omitempty"`
+}
+
+// Invitations represents the invitations created by a user
+type Invitations struct {
+	// The number of invitations the user can create, including the ones already created
+	Quota int `json:"quota"`
+	// The number of invitations the user can still create
+	Remaining int `json:"remaining"`
+	// The invitations created by the user which were redeemed or are still valid
+	Items []Invitation `json:"items"`
+}
+
+// storedInvitation is an invitation as stored in the annotation of the UserSignup of the user who created it
+type storedInvitation struct {
+	CodeHash           string `json:"codeHash"`
+	CreatedAt          string `json:"createdAt"`
+	ExpiresAt          string `json:"expiresAt"`
+	RedeemedBy         string `json:"redeemedBy,omitempty"`
+	RedeemedByUsername string `json:"redeemedByUsername,omitempty"`
+	RedeemedAt         string `json:"redeemedAt,omitempty"`
+}
+
+func (i storedInvitation) expired(now time.Time) bool {
+	expiry, err := time.Parse(time.RFC3339, i.ExpiresAt)
+	return err != nil || now.After(expiry)
+}
+
+func (i storedInvitation) toInvitation() Invitation {
+	return Invitation{
+		ID:         i.CodeHash[:12],
+		CreatedAt:  i.CreatedAt,
+		ExpiresAt:  i.ExpiresAt,
+		RedeemedBy: i.RedeemedByUsername,
+		RedeemedAt: i.RedeemedAt,
+	}
+}
+
+// IsInvitationCode returns true if the given activation code is an invitation code rather than a social event code
+func IsInvitationCode(code string) bool {
+	return strings.HasPrefix(code, InvitationCodePrefix)
+}
+
+// HashInvitationCode returns the SHA-256 hash of the given invitation code, which is stored instead of the code itself
+func HashInvitationCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// InvitationLabelKey returns the key of the label set on the UserSignup of the user who created the invitation with the
+// given code hash
+func InvitationLabelKey(codeHash string) string {
+	return InvitationLabelKeyPrefix + codeHash[:invitationLabelHashLength]
+}
+
+// AddInvitation adds an invitation with the given code hash and expiry to the given UserSignup, after removing the invitations
+// which expired without being redeemed. It returns an error if the user already reached the given quota.
+func AddInvitation(userSignup *toolchainv1alpha1.UserSignup, codeHash string, ttl time.Duration, quota int) (Invitation, error) {
+	now := time.Now()
+	invitations, err := pruneInvitations(userSignup, now)
+	if err != nil {
+		return Invitation{}, err
+	}
+	if len(invitations) >= quota {
+		return Invitation{}, crterrors.NewForbiddenError("invitation quota exceeded",
+			fmt.Sprintf("no more than %d invitations can be created", quota))
+	}
+	invitation := storedInvitation{
+		CodeHash:  codeHash,
+		CreatedAt: now.UTC().Format(time.RFC3339),
+		ExpiresAt: now.Add(ttl).UTC().Format(time.RFC3339),
+	}
+	if err := setInvitations(userSignup, append(invitations, invitation)); err != nil {
+		return Invitation{}, err
+	}
+	return invitation.toInvitation(), nil
+}
+
+// ListInvitations returns the invitations created by the given user which were redeemed or are still valid
+func ListInvitations(userSignup *toolchainv1alpha1.UserSignup, quota int) (*Invitations, error) {
+	now := time.Now()
+	stored, err := getInvitations(userSignup)
+	if err != nil {
+		return nil, err
+	}
+	invitations := &Invitations{
+		Quota: quota,
+		Items: []Invitation{},
+	}
+	for _, invitation := range stored {
+		if invitation.RedeemedBy == "" && invitation.expired(now) {
+			continue
+		}
+		invitations.Items = append(invitations.Items, invitation.toInvitation())
+	}
+	invitations.Remaining = max(quota-len(invitations.Items), 0)
+	return invitations, nil
+}
+
+// RedeemInvitation validates the given invitation code and marks it as redeemed by the given UserSignup of the invited user,
+// which is labelled with the name of the UserSignup of the user who created the invitation and is exempted from the
+// phone verification. The UserSignup of the invited user is not updated, which is left to the caller. The invitation
+// can be redeemed again by the same user, in case the update of their UserSignup failed.
+func RedeemInvitation(ctx *gin.Context, cl namespaced.Client, code string, userSignup *toolchainv1alpha1.UserSignup) error {
+	codeHash := HashInvitationCode(code)
+	referrers := &toolchainv1alpha1.UserSignupList{}
+	if err := cl.List(ctx, referrers, client.InNamespace(cl.Namespace), client.HasLabels{InvitationLabelKey(codeHash)}); err != nil {
+		return crterrors.NewInternalError(err, "error retrieving the invitation")
+	}
+	for i := range referrers.Items {
+		referrer := &referrers.Items[i]
+		invitations, err := getInvitations(referrer)
+		if err != nil {
+			log.Error(ctx, err, fmt.Sprintf("invalid invitations in usersignup '%s'", referrer.Name))
+			continue
+		}
+		for j := range invitations {
+			if invitations[j].CodeHash != codeHash {
+				continue
+			}
+			if err := redeem(ctx, cl, referrer, invitations, j, userSignup); err != nil {
+				return err
+			}
+			if userSignup.Labels == nil {
+				userSignup.Labels = map[string]string{}
+			}
+			userSignup.Labels[ReferredByLabelKey] = referrer.Name
+			states.SetVerificationRequired(userSignup, false)
+			log.Info(ctx, fmt.Sprintf("invitation from usersignup '%s' redeemed by usersignup '%s'", referrer.Name, userSignup.Name))
+			return nil
+		}
+	}
+	log.Info(ctx, "invitation not found")
+	return crterrors.NewForbiddenError("invalid code", "the provided code is invalid")
+}
+
+func redeem(ctx *gin.Context, cl namespaced.Client, referrer *toolchainv1alpha1.UserSignup, invitations []storedInvitation, index int, userSignup *toolchainv1alpha1.UserSignup) error {
+	invitation := &invitations[index]
+	if invitation.RedeemedBy == userSignup.Name {
+		return nil
+	}
+	if invitation.RedeemedBy != "" {
+		return crterrors.NewForbiddenError("invalid code", "the provided code was already used")
+	}
+	if invitation.expired(time.Now()) {
+		return crterrors.NewForbiddenError("invalid code", "the provided code has expired")
+	}
+	if referrer.Name == userSignup.Name {
+		return crterrors.NewForbiddenError("invalid code", "the provided code can't be used by the user who created it")
+	}
+	if !IsProvisioned(referrer) {
+		log.Info(ctx, fmt.Sprintf("usersignup '%s' who created the invitation is not active anymore", referrer.Name))
+		return crterrors.NewForbiddenError("invalid code", "the provided code is invalid")
+	}
+
+	invitation.RedeemedBy = userSignup.Name
+	invitation.RedeemedByUsername = userSignup.Spec.IdentityClaims.PreferredUsername
+	invitation.RedeemedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := setInvitations(referrer, invitations); err != nil {
+		return crterrors.NewInternalError(err, "error redeeming the invitation")
+	}
+	if err := cl.Update(ctx, referrer); err != nil {
+		log.Error(ctx, err, fmt.Sprintf("error updating usersignup '%s'", referrer.Name))
+		return crterrors.NewInternalError(err, "error redeeming the invitation")
+	}
+	return nil
+}
+
+// IsProvisioned returns true if the given UserSignup is complete and is neither deactivated nor banned
+func IsProvisioned(userSignup *toolchainv1alpha1.UserSignup) bool {
+	completeCondition, found := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
+	return found && completeCondition.Status == apiv1.ConditionTrue && completeCondition.Reason == "" && !states.Deactivated(userSignup)
+}
+
+func getInvitations(userSignup *toolchainv1alpha1.UserSignup) ([]storedInvitation, error) {
+	value := userSignup.Annotations[InvitationsAnnotationKey]
+	if value == "" {
+		return nil, nil
+	}
+	var invitations []storedInvitation
+	if err := json.Unmarshal([]byte(value), &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func setInvitations(userSignup *toolchainv1alpha1.UserSignup, invitations []storedInvitation) error {
+	value, err := json.Marshal(invitations)
+	if err != nil {
+		return err
+	}
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	if userSignup.Labels == nil {
+		userSignup.Labels = map[string]string{}
+	}
+	userSignup.Annotations[InvitationsAnnotationKey] = string(value)
+	// the labels of the pruned invitations are removed
+	for key := range userSignup.Labels {
+		if strings.HasPrefix(key, InvitationLabelKeyPrefix) {
+			delete(userSignup.Labels, key)
+		}
+	}
+	for _, invitation := range invitations {
+		userSignup.Labels[InvitationLabelKey(invitation.CodeHash)] = "true"
+	}
+	return nil
+}
+
+// pruneInvitations removes the invitations which expired without being redeemed from the given UserSignup, and returns the others
+func pruneInvitations(userSignup *toolchainv1alpha1.UserSignup, now time.Time) ([]storedInvitation, error) {
+	invitations, err := getInvitations(userSignup)
+	if err != nil {
+		return nil, err
+	}
+	valid := make([]storedInvitation, 0, len(invitations))
+	for _, invitation := range invitations {
+		if invitation.RedeemedBy != "" || !invitation.expired(now) {
+			valid = append(valid, invitation)
+		}
+	}
+	return valid, nil
+}
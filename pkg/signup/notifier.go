@@ -0,0 +1,105 @@
+package signup
+
+import (
+	gocontext "context"
+	"fmt"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChangeNotifier notifies the subscribers when the UserSignup or the MasterUserRecord of a user change in the informer cache
+type ChangeNotifier struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+// NewChangeNotifier creates a new ChangeNotifier
+func NewChangeNotifier() *ChangeNotifier {
+	return &ChangeNotifier{
+		subscribers: map[string]map[chan struct{}]struct{}{},
+	}
+}
+
+// Start watches the UserSignups and the MasterUserRecords with the given informers
+func (n *ChangeNotifier) Start(ctx gocontext.Context, informers cache.Informers) error {
+	signupInformer, err := informers.GetInformer(ctx, &toolchainv1alpha1.UserSignup{})
+	if err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+	if _, err := signupInformer.AddEventHandler(n.handler(func(obj client.Object) string {
+		return obj.GetName()
+	})); err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+
+	murInformer, err := informers.GetInformer(ctx, &toolchainv1alpha1.MasterUserRecord{})
+	if err != nil {
+		return fmt.Errorf("unable to watch the MasterUserRecords: %w", err)
+	}
+	// the MasterUserRecords are labelled with the name of the UserSignup they were provisioned for
+	if _, err := murInformer.AddEventHandler(n.handler(func(obj client.Object) string {
+		return obj.GetLabels()[toolchainv1alpha1.MasterUserRecordOwnerLabelKey]
+	})); err != nil {
+		return fmt.Errorf("unable to watch the MasterUserRecords: %w", err)
+	}
+	return nil
+}
+
+// handler returns an event handler notifying the subscribers of the UserSignup returned by the given function
+func (n *ChangeNotifier) handler(userSignupName func(obj client.Object) string) toolscache.ResourceEventHandler {
+	notify := func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if o, ok := obj.(client.Object); ok {
+			n.Notify(userSignupName(o))
+		}
+	}
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: notify,
+		UpdateFunc: func(_, obj interface{}) {
+			notify(obj)
+		},
+		DeleteFunc: notify,
+	}
+}
+
+// Subscribe returns a channel receiving a value when the UserSignup with the given name or its MasterUserRecord change,
+// and a function to call to unsubscribe. The changes are coalesced while the previous ones were not received.
+func (n *ChangeNotifier) Subscribe(userSignupName string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subscribers[userSignupName] == nil {
+		n.subscribers[userSignupName] = map[chan struct{}]struct{}{}
+	}
+	n.subscribers[userSignupName][ch] = struct{}{}
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers[userSignupName], ch)
+		if len(n.subscribers[userSignupName]) == 0 {
+			delete(n.subscribers, userSignupName)
+		}
+	}
+}
+
+// Notify notifies the subscribers of the UserSignup with the given name, without blocking
+func (n *ChangeNotifier) Notify(userSignupName string) {
+	if userSignupName == "" {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subscribers[userSignupName] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package signup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeNotifier(t *testing.T) {
+	t.Run("subscribers notified", func(t *testing.T) {
+		// given
+		notifier := NewChangeNotifier()
+		first, unsubscribeFirst := notifier.Subscribe("ted")
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := notifier.Subscribe("ted")
+		defer unsubscribeSecond()
+		other, unsubscribeOther := notifier.Subscribe("bill")
+		defer unsubscribeOther()
+
+		// when
+		notifier.Notify("ted")
+
+		// then
+		assert.Len(t, first, 1)
+		assert.Len(t, second, 1)
+		assert.Empty(t, other)
+	})
+
+	t.Run("changes coalesced", func(t *testing.T) {
+		// given
+		notifier := NewChangeNotifier()
+		changes, unsubscribe := notifier.Subscribe("ted")
+		defer unsubscribe()
+
+		// when
+		notifier.Notify("ted")
+		notifier.Notify("ted")
+
+		// then
+		assert.Len(t, changes, 1)
+	})
+
+	t.Run("unsubscribed", func(t *testing.T) {
+		// given
+		notifier := NewChangeNotifier()
+		changes, unsubscribe := notifier.Subscribe("ted")
+
+		// when
+		unsubscribe()
+		notifier.Notify("ted")
+
+		// then
+		assert.Empty(t, changes)
+		assert.Empty(t, notifier.subscribers)
+	})
+
+	t.Run("objects without UserSignup ignored", func(t *testing.T) {
+		// given
+		notifier := NewChangeNotifier()
+		changes, unsubscribe := notifier.Subscribe("")
+		defer unsubscribe()
+
+		// when
+		notifier.Notify("")
+
+		// then
+		assert.Empty(t, changes)
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
+
+	"github.com/gin-gonic/gin"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// DeactivationConfirmationAnnotationKey contains the SHA-256 hash of the token which confirms the deactivation requested by the user
+	DeactivationConfirmationAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "deactivation-confirmation"
+	// DeactivationConfirmationExpiryAnnotationKey contains the time after which the deactivation can't be confirmed anymore, in RFC3339 format
+	DeactivationConfirmationExpiryAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "deactivation-confirmation-expiry"
+
+	deactivationTokenLength = 32
+)
+
+// RequestDeactivation generates the token that the specified user must provide to confirm the deactivation of their account.
+// Only the hash of the token is stored in the UserSignup, along with its expiry. Requesting the deactivation again
+// replaces the previous token.
+func (s *ServiceImpl) RequestDeactivation(ctx *gin.Context, username string) (*signup.DeactivationConfirmation, error) {
+	userSignup, err := s.getActiveUserSignup(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	rawToken := make([]byte, deactivationTokenLength)
+	if _, err := rand.Read(rawToken); err != nil {
+		return nil, crterrors.NewInternalError(err, "unable to generate the deactivation confirmation token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(rawToken)
+	expiry := time.Now().Add(configuration.GetRegistrationServiceConfig().SignupDeactivationConfirmationTTL()).UTC().Format(time.RFC3339)
+
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[DeactivationConfirmationAnnotationKey] = hashDeactivationToken(token)
+	userSignup.Annotations[DeactivationConfirmationExpiryAnnotationKey] = expiry
+	if err := s.Update(ctx, userSignup); err != nil {
+		log.Error(ctx, err, "error updating usersignup")
+		return nil, crterrors.NewInternalError(err, "error while requesting the deactivation")
+	}
+
+	log.Infof(ctx, "deactivation requested for usersignup '%s'", userSignup.Name)
+	return &signup.DeactivationConfirmation{
+		Token:     token,
+		ExpiresAt: expiry,
+	}, nil
+}
+
+// Deactivate deactivates the UserSignup of the specified user, as long as the given token matches the one returned
+// when they requested the deactivation and has not expired. The resources of the user are then deleted by the host operator.
+func (s *ServiceImpl) Deactivate(ctx *gin.Context, username, confirmationToken string) error {
+	userSignup, err := s.getActiveUserSignup(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	storedHash := userSignup.Annotations[DeactivationConfirmationAnnotationKey]
+	if storedHash == "" || subtle.ConstantTimeCompare([]byte(storedHash), []byte(hashDeactivationToken(confirmationToken))) != 1 {
+		log.Info(ctx, fmt.Sprintf("invalid deactivation confirmation token for usersignup '%s'", userSignup.Name))
+		return crterrors.NewForbiddenError("invalid confirmation token", "the deactivation could not be confirmed")
+	}
+	expiry, err := time.Parse(time.RFC3339, userSignup.Annotations[DeactivationConfirmationExpiryAnnotationKey])
+	if err != nil || time.Now().After(expiry) {
+		log.Info(ctx, fmt.Sprintf("expired deactivation confirmation token for usersignup '%s'", userSignup.Name))
+		return crterrors.NewForbiddenError("expired confirmation token", "the deactivation must be requested again")
+	}
+
+	delete(userSignup.Annotations, DeactivationConfirmationAnnotationKey)
+	delete(userSignup.Annotations, DeactivationConfirmationExpiryAnnotationKey)
+	states.SetDeactivated(userSignup, true)
+	if err := s.Update(ctx, userSignup); err != nil {
+		log.Error(ctx, err, "error updating usersignup")
+		return crterrors.NewInternalError(err, "error while deactivating the user")
+	}
+
+	log.Infof(ctx, "usersignup '%s' deactivated by the user", userSignup.Name)
+	return nil
+}
+
+// getActiveUserSignup returns the UserSignup of the specified user, or a NotFound error if it doesn't exist or if it is
+// deactivated, like the GetSignup function, or a Forbidden error if the user is banned
+func (s *ServiceImpl) getActiveUserSignup(ctx *gin.Context, username string) (*toolchainv1alpha1.UserSignup, error) {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), userSignup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return nil, crterrors.NewNotFoundError(err, "usersignup not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return nil, crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
+	}
+
+	completeCondition, found := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
+	completed := found && completeCondition.Status == apiv1.ConditionTrue
+	if states.Deactivated(userSignup) || (completed && completeCondition.Reason == toolchainv1alpha1.UserSignupUserDeactivatedReason) {
+		log.Info(ctx, fmt.Sprintf("usersignup '%s' is already deactivated", userSignup.Name))
+		return nil, crterrors.NewNotFoundError(fmt.Errorf("usersignup '%s' is deactivated", userSignup.Name), "usersignup not found")
+	}
+	if completed && completeCondition.Reason == toolchainv1alpha1.UserSignupUserBannedReason {
+		log.Info(ctx, fmt.Sprintf("usersignup '%s' is banned", userSignup.Name))
+		return nil, crterrors.NewForbiddenError("user banned", "the account is suspended")
+	}
+	return userSignup, nil
+}
+
+func hashDeactivationToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,168 @@
+package service_test
+
+import (
+	gocontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/service"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *TestSignupServiceSuite) TestDeactivate() {
+	s.ServiceConfiguration(true, "", 5)
+
+	requireErrorCode := func(err error, code int) {
+		e := &crterrors.Error{}
+		require.True(s.T(), errors.As(err, &e))
+		assert.Equal(s.T(), code, e.Code)
+	}
+
+	s.Run("deactivation confirmed", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		confirmation, err := application.SignupService().RequestDeactivation(ctx, username)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotEmpty(s.T(), confirmation.Token)
+		expiry, err := time.Parse(time.RFC3339, confirmation.ExpiresAt)
+		require.NoError(s.T(), err)
+		assert.WithinDuration(s.T(), time.Now().Add(10*time.Minute), expiry, time.Minute)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		assert.False(s.T(), states.Deactivated(updated))
+		// only the hash of the token is stored
+		assert.NotEmpty(s.T(), updated.Annotations[service.DeactivationConfirmationAnnotationKey])
+		assert.NotEqual(s.T(), confirmation.Token, updated.Annotations[service.DeactivationConfirmationAnnotationKey])
+		assert.Equal(s.T(), confirmation.ExpiresAt, updated.Annotations[service.DeactivationConfirmationExpiryAnnotationKey])
+
+		s.Run("invalid token", func() {
+			// when
+			err := application.SignupService().Deactivate(ctx, username, "invalid")
+
+			// then
+			requireErrorCode(err, http.StatusForbidden)
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+			assert.False(s.T(), states.Deactivated(updated))
+		})
+
+		s.Run("valid token", func() {
+			// when
+			err := application.SignupService().Deactivate(ctx, username, confirmation.Token)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+			assert.True(s.T(), states.Deactivated(updated))
+			assert.NotContains(s.T(), updated.Annotations, service.DeactivationConfirmationAnnotationKey)
+			assert.NotContains(s.T(), updated.Annotations, service.DeactivationConfirmationExpiryAnnotationKey)
+		})
+
+		s.Run("token used again", func() {
+			// when
+			err := application.SignupService().Deactivate(ctx, username, confirmation.Token)
+
+			// then
+			requireErrorCode(err, http.StatusNotFound)
+		})
+	})
+
+	s.Run("deactivation not requested", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := application.SignupService().Deactivate(ctx, username, "")
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+	})
+
+	s.Run("token expired", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		confirmation, err := application.SignupService().RequestDeactivation(ctx, username)
+		require.NoError(s.T(), err)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		updated.Annotations[service.DeactivationConfirmationExpiryAnnotationKey] = time.Now().Add(-time.Second).UTC().Format(time.RFC3339)
+		require.NoError(s.T(), fakeClient.Update(gocontext.TODO(), updated))
+
+		// when
+		err = application.SignupService().Deactivate(ctx, username, confirmation.Token)
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		assert.False(s.T(), states.Deactivated(updated))
+	})
+
+	s.Run("usersignup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().RequestDeactivation(ctx, "ted@kubesaw")
+
+		// then
+		requireErrorCode(err, http.StatusNotFound)
+	})
+
+	s.Run("usersignup already deactivated", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		userSignup.Status.Conditions = fake.Deactivated()
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().RequestDeactivation(ctx, username)
+
+		// then
+		requireErrorCode(err, http.StatusNotFound)
+	})
+
+	s.Run("user banned", func() {
+		// given
+		username := "ted@kubesaw"
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName(username),
+			testusersignup.ApprovedAutomaticallyAgo(time.Second))
+		userSignup.Status.Conditions = append(userSignup.Status.Conditions, toolchainv1alpha1.Condition{
+			Type:   toolchainv1alpha1.UserSignupComplete,
+			Status: apiv1.ConditionTrue,
+			Reason: toolchainv1alpha1.UserSignupUserBannedReason,
+		})
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().RequestDeactivation(ctx, username)
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+	})
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExportSignup returns everything the registration service knows about the specified user: the identity claims,
+// annotations, labels and verification history stored in the UserSignup, and the Spaces the user has access to.
+// Unlike GetSignup, the UserSignups of the deactivated and banned users are exported too.
+func (s *ServiceImpl) ExportSignup(ctx *gin.Context, username string) (*signup.Export, error) {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), userSignup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return nil, crterrors.NewNotFoundError(err, "usersignup not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return nil, crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
+	}
+
+	annotations := make(map[string]string, len(userSignup.Annotations))
+	for k, v := range userSignup.Annotations {
+		// the pending verification code and the deactivation confirmation are secrets, which are not exported
+		if k == toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey || k == DeactivationConfirmationAnnotationKey {
+			continue
+		}
+		annotations[k] = v
+	}
+
+	export := &signup.Export{
+		Name:              userSignup.Name,
+		IdentityClaims:    userSignup.Spec.IdentityClaims,
+		Annotations:       annotations,
+		Labels:            userSignup.Labels,
+		States:            userSignup.Spec.States,
+		Conditions:        userSignup.Status.Conditions,
+		CompliantUsername: userSignup.Status.CompliantUsername,
+		Verification: signup.VerificationHistory{
+			Required:        states.VerificationRequired(userSignup),
+			Counter:         userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey],
+			InitTimestamp:   userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey],
+			Timestamp:       userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationTimestampAnnotationKey],
+			PhoneNumberHash: userSignup.Labels[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey],
+			DeliveryStatus:  userSignup.Annotations[delivery.StatusAnnotationKey],
+		},
+	}
+
+	if userSignup.Status.CompliantUsername != "" {
+		workspaces, err := s.exportWorkspaces(ctx, userSignup.Status.CompliantUsername)
+		if err != nil {
+			log.Error(ctx, err, "error retrieving the workspaces")
+			return nil, crterrors.NewInternalError(err, fmt.Sprintf("error retrieving the workspaces of the user with username '%s'", username))
+		}
+		export.Workspaces = workspaces
+	}
+	return export, nil
+}
+
+// exportWorkspaces returns the Spaces bound to the MasterUserRecord with the given name, sorted by name
+func (s *ServiceImpl) exportWorkspaces(ctx *gin.Context, murName string) ([]signup.ExportedWorkspace, error) {
+	bindings := &toolchainv1alpha1.SpaceBindingList{}
+	if err := s.List(ctx, bindings, client.InNamespace(s.Namespace),
+		client.MatchingLabels{toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey: murName}); err != nil {
+		return nil, err
+	}
+
+	workspaces := make([]signup.ExportedWorkspace, 0, len(bindings.Items))
+	for _, binding := range bindings.Items {
+		workspace := signup.ExportedWorkspace{
+			Name: binding.Spec.Space,
+			Role: binding.Spec.SpaceRole,
+		}
+		space := &toolchainv1alpha1.Space{}
+		if err := s.Get(ctx, s.NamespacedName(binding.Spec.Space), space); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			// the Space may be being deleted, the binding is exported anyway
+		} else {
+			workspace.TargetCluster = space.Status.TargetCluster
+			for _, ns := range space.Status.ProvisionedNamespaces {
+				workspace.Namespaces = append(workspace.Namespaces, ns.Name)
+			}
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].Name < workspaces[j].Name
+	})
+	return workspaces, nil
+}
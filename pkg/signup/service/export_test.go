@@ -0,0 +1,92 @@
+package service_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestSignupServiceSuite) TestExportSignup() {
+	s.ServiceConfiguration(true, "", 5)
+
+	s.Run("export with workspaces", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "2")(userSignup)
+		testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456")(userSignup)
+		testusersignup.WithAnnotation(delivery.StatusAnnotationKey, "delivered")(userSignup)
+		testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, "fd276563a8232d16620da8ec85d0575f")(userSignup)
+		home := s.newSpace("ted")
+		shared := s.newSpace("alice")
+		// the binding of a Space being deleted
+		deleted := s.newSpaceBinding("ted", "bob")
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup, home, shared, deleted,
+			s.newSpaceBinding("ted", "ted"), fake.NewSpaceBinding("ted-alice", "ted", "alice", "viewer"))
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		export, err := application.SignupService().ExportSignup(ctx, username)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), userSignup.Name, export.Name)
+		assert.Equal(s.T(), userSignup.Spec.IdentityClaims, export.IdentityClaims)
+		assert.Equal(s.T(), "ted", export.CompliantUsername)
+		assert.Equal(s.T(), "2", export.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
+		assert.NotContains(s.T(), export.Annotations, toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey)
+		assert.Len(s.T(), export.Conditions, len(userSignup.Status.Conditions))
+		assert.Equal(s.T(), signup.VerificationHistory{
+			Counter:         "2",
+			PhoneNumberHash: "fd276563a8232d16620da8ec85d0575f",
+			DeliveryStatus:  "delivered",
+		}, export.Verification)
+		assert.Equal(s.T(), []signup.ExportedWorkspace{
+			{Name: "alice", Role: "viewer", TargetCluster: "member-123", Namespaces: []string{"alice-dev"}},
+			{Name: "bob", Role: "admin"},
+			{Name: "ted", Role: "admin", TargetCluster: "member-123", Namespaces: []string{"ted-dev"}},
+		}, export.Workspaces)
+	})
+
+	s.Run("deactivated user exported", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		userSignup.Status.Conditions = fake.Deactivated()
+		userSignup.Status.CompliantUsername = ""
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		export, err := application.SignupService().ExportSignup(ctx, username)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), userSignup.Name, export.Name)
+		assert.Empty(s.T(), export.Workspaces)
+	})
+
+	s.Run("usersignup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().ExportSignup(ctx, "ted@kubesaw")
+
+		// then
+		e := &crterrors.Error{}
+		require.True(s.T(), errors.As(err, &e))
+		assert.Equal(s.T(), http.StatusNotFound, e.Code)
+	})
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+
+	"github.com/gin-gonic/gin"
+)
+
+const invitationCodeLength = 10
+
+// CreateInvitation creates an invitation code which can be redeemed by someone else to sign up without the phone
+// verification. Only the provisioned users can create invitations, within the limit of their quota. The code is only
+// returned once, its hash is stored in the UserSignup of the user.
+func (s *ServiceImpl) CreateInvitation(ctx *gin.Context, username string) (*signup.Invitation, error) {
+	userSignup, err := s.getProvisionedUserSignup(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	rawCode := make([]byte, invitationCodeLength)
+	if _, err := rand.Read(rawCode); err != nil {
+		return nil, crterrors.NewInternalError(err, "unable to generate the invitation code")
+	}
+	code := signup.InvitationCodePrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rawCode))
+
+	cfg := configuration.GetRegistrationServiceConfig().Invitations()
+	invitation, err := signup.AddInvitation(userSignup, signup.HashInvitationCode(code), cfg.TTL(), invitationQuota(ctx, userSignup))
+	if err != nil {
+		if e := (&crterrors.Error{}); errors.As(err, &e) {
+			return nil, e
+		}
+		log.Error(ctx, err, "invalid invitations in usersignup")
+		return nil, crterrors.NewInternalError(err, "error while creating the invitation")
+	}
+	if err := s.Update(ctx, userSignup); err != nil {
+		log.Error(ctx, err, "error updating usersignup")
+		return nil, crterrors.NewInternalError(err, "error while creating the invitation")
+	}
+
+	log.Infof(ctx, "invitation created by usersignup '%s'", userSignup.Name)
+	invitation.Code = code
+	return &invitation, nil
+}
+
+// ListInvitations returns the invitations created by the specified user which were redeemed or are still valid,
+// along with their quota
+func (s *ServiceImpl) ListInvitations(ctx *gin.Context, username string) (*signup.Invitations, error) {
+	userSignup, err := s.getProvisionedUserSignup(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	invitations, err := signup.ListInvitations(userSignup, invitationQuota(ctx, userSignup))
+	if err != nil {
+		log.Error(ctx, err, "invalid invitations in usersignup")
+		return nil, crterrors.NewInternalError(err, "error while listing the invitations")
+	}
+	return invitations, nil
+}
+
+// getProvisionedUserSignup returns the UserSignup of the specified user, or a Forbidden error if the user is not provisioned yet
+func (s *ServiceImpl) getProvisionedUserSignup(ctx *gin.Context, username string) (*toolchainv1alpha1.UserSignup, error) {
+	userSignup, err := s.getActiveUserSignup(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if !signup.IsProvisioned(userSignup) {
+		log.Info(ctx, fmt.Sprintf("usersignup '%s' is not provisioned", userSignup.Name))
+		return nil, crterrors.NewForbiddenError("user not provisioned", "only the provisioned users can invite other users")
+	}
+	return userSignup, nil
+}
+
+// invitationQuota returns the number of invitations the given user can create, which is set in the annotation of their
+// UserSignup or in the configuration
+func invitationQuota(ctx *gin.Context, userSignup *toolchainv1alpha1.UserSignup) int {
+	if value, found := userSignup.Annotations[signup.InvitationQuotaAnnotationKey]; found {
+		quota, err := strconv.Atoi(value)
+		if err == nil {
+			return quota
+		}
+		log.Error(ctx, err, fmt.Sprintf("invalid invitation quota in usersignup '%s'", userSignup.Name))
+	}
+	return configuration.GetRegistrationServiceConfig().Invitations().MaxPerUser()
+}
@@ -0,0 +1,209 @@
+package service_test
+
+import (
+	gocontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *TestSignupServiceSuite) TestInvitations() {
+	s.SetSettings(map[string]string{
+		"signup.invitations.maxPerUser": "2",
+		"signup.invitations.ttl":        "24h",
+	})
+
+	requireErrorCode := func(err error, code int) {
+		e := &crterrors.Error{}
+		require.True(s.T(), errors.As(err, &e))
+		assert.Equal(s.T(), code, e.Code)
+	}
+	newSignupCtx := func(username, invitation string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, username)
+		ctx.Set(context.SubKey, "987654321")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		ctx.Request, _ = http.NewRequest(http.MethodPost, "/?invitation="+invitation, nil)
+		return ctx
+	}
+
+	s.Run("invitation created and redeemed", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		invitation, err := application.SignupService().CreateInvitation(ctx, username)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), strings.HasPrefix(invitation.Code, signup.InvitationCodePrefix))
+		assert.NotEmpty(s.T(), invitation.ID)
+		expiry, err := time.Parse(time.RFC3339, invitation.ExpiresAt)
+		require.NoError(s.T(), err)
+		assert.WithinDuration(s.T(), time.Now().Add(24*time.Hour), expiry, time.Minute)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		// only the hash of the code is stored
+		assert.NotContains(s.T(), updated.Annotations[signup.InvitationsAnnotationKey], invitation.Code)
+		assert.Equal(s.T(), "true", updated.Labels[signup.InvitationLabelKey(signup.HashInvitationCode(invitation.Code))])
+
+		s.Run("listed", func() {
+			// when
+			invitations, err := application.SignupService().ListInvitations(ctx, username)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 2, invitations.Quota)
+			assert.Equal(s.T(), 1, invitations.Remaining)
+			require.Len(s.T(), invitations.Items, 1)
+			assert.Equal(s.T(), invitation.ID, invitations.Items[0].ID)
+			assert.Empty(s.T(), invitations.Items[0].Code)
+			assert.Empty(s.T(), invitations.Items[0].RedeemedBy)
+		})
+
+		s.Run("redeemed at signup", func() {
+			// when
+			invited, err := application.SignupService().Signup(newSignupCtx("jsmith@kubesaw", invitation.Code))
+
+			// then
+			require.NoError(s.T(), err)
+			created := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(invited), created))
+			assert.Equal(s.T(), userSignup.Name, created.Labels[signup.ReferredByLabelKey])
+			assert.False(s.T(), states.VerificationRequired(created))
+			invitations, err := application.SignupService().ListInvitations(ctx, username)
+			require.NoError(s.T(), err)
+			require.Len(s.T(), invitations.Items, 1)
+			assert.Equal(s.T(), "jsmith@kubesaw", invitations.Items[0].RedeemedBy)
+			assert.NotEmpty(s.T(), invitations.Items[0].RedeemedAt)
+		})
+
+		s.Run("already redeemed", func() {
+			// when
+			_, err := application.SignupService().Signup(newSignupCtx("jane@kubesaw", invitation.Code))
+
+			// then
+			requireErrorCode(err, http.StatusForbidden)
+		})
+	})
+
+	s.Run("quota exceeded", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		for i := 0; i < 2; i++ {
+			_, err := application.SignupService().CreateInvitation(ctx, username)
+			require.NoError(s.T(), err)
+		}
+
+		// when
+		_, err := application.SignupService().CreateInvitation(ctx, username)
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+	})
+
+	s.Run("quota overridden for the user", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		testusersignup.WithAnnotation(signup.InvitationQuotaAnnotationKey, "0")(userSignup)
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().CreateInvitation(ctx, username)
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+		invitations, err := application.SignupService().ListInvitations(ctx, username)
+		require.NoError(s.T(), err)
+		assert.Zero(s.T(), invitations.Quota)
+		assert.Zero(s.T(), invitations.Remaining)
+	})
+
+	s.Run("expired invitation", func() {
+		// given
+		username, userSignup := s.newUserSignupComplete()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		invitation, err := application.SignupService().CreateInvitation(ctx, username)
+		require.NoError(s.T(), err)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		updated.Annotations[signup.InvitationsAnnotationKey] = strings.Replace(updated.Annotations[signup.InvitationsAnnotationKey],
+			invitation.ExpiresAt, time.Now().Add(-time.Second).UTC().Format(time.RFC3339), 1)
+		require.NoError(s.T(), fakeClient.Update(gocontext.TODO(), updated))
+
+		s.Run("not redeemed", func() {
+			// when
+			_, err := application.SignupService().Signup(newSignupCtx("jsmith@kubesaw", invitation.Code))
+
+			// then
+			requireErrorCode(err, http.StatusForbidden)
+		})
+
+		s.Run("not listed and not counted in the quota", func() {
+			// when
+			invitations, err := application.SignupService().ListInvitations(ctx, username)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Empty(s.T(), invitations.Items)
+			assert.Equal(s.T(), 2, invitations.Remaining)
+		})
+
+		s.Run("pruned when another invitation is created", func() {
+			// when
+			_, err := application.SignupService().CreateInvitation(ctx, username)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+			assert.NotContains(s.T(), updated.Labels, signup.InvitationLabelKey(signup.HashInvitationCode(invitation.Code)))
+		})
+	})
+
+	s.Run("invalid code", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		_, err := application.SignupService().Signup(newSignupCtx("jsmith@kubesaw", "inv-unknown"))
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+	})
+
+	s.Run("user not provisioned", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("ted@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		_, err := application.SignupService().CreateInvitation(ctx, "ted@kubesaw")
+
+		// then
+		requireErrorCode(err, http.StatusForbidden)
+	})
+}
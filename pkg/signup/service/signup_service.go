@@ -14,7 +14,9 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
 	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
@@ -31,6 +33,8 @@ import (
 const (
 	// NoSpaceKey is the query key for specifying whether the UserSignup should be created without a Space
 	NoSpaceKey = "no-space"
+	// InvitationKey is the query key for specifying the invitation code created by another user
+	InvitationKey = "invitation"
 )
 
 var ForbiddenBannedError = apierrors.NewForbidden(schema.GroupResource{}, "",
@@ -45,16 +49,28 @@ var annotationsToRetain = []string{
 type ServiceImpl struct { // nolint:revive
 	namespaced.Client
 	CaptchaChecker captcha.Assessor
+	EventPublisher *events.Publisher
 }
 
 type SignupServiceOption func(svc *ServiceImpl)
 
+// WithEventPublisher publishes the signup lifecycle events with the given publisher
+func WithEventPublisher(publisher *events.Publisher) SignupServiceOption {
+	return func(svc *ServiceImpl) {
+		svc.EventPublisher = publisher
+	}
+}
+
 // NewSignupService creates a service object for performing user signup-related activities.
-func NewSignupService(client namespaced.Client) *ServiceImpl {
-	return &ServiceImpl{
+func NewSignupService(client namespaced.Client, opts ...SignupServiceOption) *ServiceImpl {
+	svc := &ServiceImpl{
 		CaptchaChecker: captcha.Helper{},
 		Client:         client,
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
 
 // newUserSignup generates a new UserSignup resource with the specified username and available claims.
@@ -149,6 +165,20 @@ func (s *ServiceImpl) newUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSi
 			return nil, err
 		}
 		signup.UpdateUserSignupWithSocialEvent(event, userSignup)
+	} else {
+		// the users with a social event code bypass the waitlist, but not the users with an invitation code,
+		// which only skips the verification: they still have to wait for their turn to be approved
+		waitlistIfFull(ctx, s.Client, userSignup)
+	}
+
+	invitationCode := ctx.GetString(context.InvitationCode)
+	if invitationCode == "" {
+		invitationCode, _ = ctx.GetQuery(InvitationKey)
+	}
+	if invitationCode != "" {
+		if err := signup.RedeemInvitation(ctx, s.Client, invitationCode, userSignup); err != nil {
+			return nil, err
+		}
 	}
 
 	return userSignup, nil
@@ -222,16 +252,22 @@ func IsPhoneVerificationRequired(captchaChecker captcha.Assessor, ctx *gin.Conte
 		return true, -1, ""
 	}
 
+	// require verification if captcha token is invalid
+	if !assessment.Valid {
+		log.Info(ctx, fmt.Sprintf("the captcha token is invalid for the following reasons: %v", assessment.Reasons))
+		return true, -1, assessment.ID
+	}
+
 	// require verification if captcha score is too low
-	score := assessment.GetRiskAnalysis().GetScore()
+	score := assessment.Score
 	threshold := cfg.Verification().CaptchaScoreThreshold()
 	if score < threshold {
 		log.Info(ctx, fmt.Sprintf("the risk analysis score '%.1f' did not meet the expected threshold '%.1f'", score, threshold))
-		return true, score, assessment.GetName()
+		return true, score, assessment.ID
 	}
 
 	// verification not required, score is above threshold
-	return false, score, assessment.GetName()
+	return false, score, assessment.ID
 }
 
 func extractEmailHost(email string) string {
@@ -274,7 +310,11 @@ func (s *ServiceImpl) createUserSignup(ctx *gin.Context) (*toolchainv1alpha1.Use
 		return nil, err
 	}
 
-	return userSignup, s.Create(ctx, userSignup)
+	if err := s.Create(ctx, userSignup); err != nil {
+		return userSignup, err
+	}
+	s.EventPublisher.Publish(events.NewEvent(events.TypeCreated, userSignup))
+	return userSignup, nil
 }
 
 // reactivateUserSignup reactivates the deactivated UserSignup resource with the specified username
@@ -300,7 +340,11 @@ func (s *ServiceImpl) reactivateUserSignup(ctx *gin.Context, existing *toolchain
 	existing.Labels = newUserSignup.Labels
 	existing.Spec = newUserSignup.Spec
 
-	return existing, s.Update(ctx, existing)
+	if err := s.Update(ctx, existing); err != nil {
+		return existing, err
+	}
+	s.EventPublisher.Publish(events.NewEvent(events.TypeReactivated, existing))
+	return existing, nil
 }
 
 // GetSignup returns Signup resource which represents the corresponding K8s UserSignup
@@ -330,8 +374,17 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		if userSignup == nil || ctx == nil {
 			return nil
 		}
+		// the UserSignup is read-only for the machine principals (see the machine API), since they are not the user
+		if ctx.GetString(context.PrincipalKey) != "" {
+			return nil
+		}
 
 		updated := s.auditUserSignupAgainstClaims(ctx, userSignup)
+		admitted, err := admitFromWaitlist(ctx, cl, userSignup)
+		if err != nil {
+			return errs.Wrapf(err, "error when admitting UserSignup %s from the waitlist", userSignup.GetName())
+		}
+		updated = removeFromWaitlistIfApproved(userSignup) || admitted || updated
 
 		// If there is no need to update the UserSignup then break out of the loop here (by returning nil)
 		// otherwise update the UserSignup
@@ -375,8 +428,12 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		log.Info(nil, fmt.Sprintf("usersignup: %s is pending approval", userSignup.GetName()))
 
 		signupResponse.Status = signup.Status{
-			Reason:               toolchainv1alpha1.UserSignupPendingApprovalReason,
-			VerificationRequired: states.VerificationRequired(userSignup),
+			Reason:                     toolchainv1alpha1.UserSignupPendingApprovalReason,
+			VerificationRequired:       states.VerificationRequired(userSignup),
+			VerificationDeliveryStatus: verificationDeliveryStatus(userSignup),
+		}
+		if err := setWaitlistStatus(ctx, cl, userSignup, &signupResponse.Status); err != nil {
+			return nil, errs.Wrapf(err, "error when computing the waitlist position of UserSignup %s", userSignup.GetName())
 		}
 		return signupResponse, nil
 	}
@@ -388,9 +445,13 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		// UserSignup is not complete
 		log.Info(nil, fmt.Sprintf("usersignup: %s is not complete", userSignup.GetName()))
 		signupResponse.Status = signup.Status{
-			Reason:               completeCondition.Reason,
-			Message:              completeCondition.Message,
-			VerificationRequired: states.VerificationRequired(userSignup),
+			Reason:                     completeCondition.Reason,
+			Message:                    completeCondition.Message,
+			VerificationRequired:       states.VerificationRequired(userSignup),
+			VerificationDeliveryStatus: verificationDeliveryStatus(userSignup),
+		}
+		if err := setWaitlistStatus(ctx, cl, userSignup, &signupResponse.Status); err != nil {
+			return nil, errs.Wrapf(err, "error when computing the waitlist position of UserSignup %s", userSignup.GetName())
 		}
 		return signupResponse, nil
 	} else if completeCondition.Reason == toolchainv1alpha1.UserSignupUserDeactivatedReason {
@@ -419,10 +480,11 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 	ready := murCondition.Status == apiv1.ConditionTrue
 	log.Info(nil, fmt.Sprintf("mur ready condition is: %t", ready))
 	signupResponse.Status = signup.Status{
-		Ready:                ready,
-		Reason:               murCondition.Reason,
-		Message:              murCondition.Message,
-		VerificationRequired: states.VerificationRequired(userSignup),
+		Ready:                      ready,
+		Reason:                     murCondition.Reason,
+		Message:                    murCondition.Message,
+		VerificationRequired:       states.VerificationRequired(userSignup),
+		VerificationDeliveryStatus: verificationDeliveryStatus(userSignup),
 	}
 
 	if mur.Status.ProvisionedTime != nil {
@@ -508,6 +570,15 @@ func (s *ServiceImpl) auditUserSignupAgainstClaims(ctx *gin.Context, userSignup
 	return updated
 }
 
+// verificationDeliveryStatus returns the delivery status of the last verification code sent by SMS to the user,
+// as long as the verification is required
+func verificationDeliveryStatus(userSignup *toolchainv1alpha1.UserSignup) string {
+	if !states.VerificationRequired(userSignup) {
+		return ""
+	}
+	return userSignup.Annotations[delivery.StatusAnnotationKey]
+}
+
 // GetDefaultUserTarget retrieves the target cluster and the default namespace from the Space a user has access to.
 // If no spaceName is provided (assuming that this is the home space the target information should be taken from)
 // then the logic lists all Spaces user has access to and picks the first one.
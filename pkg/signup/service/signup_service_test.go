@@ -16,8 +16,11 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	errors2 "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
 	"github.com/codeready-toolchain/registration-service/pkg/signup/service"
 	"github.com/codeready-toolchain/registration-service/pkg/util"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
 	"github.com/codeready-toolchain/registration-service/test"
 	"github.com/codeready-toolchain/registration-service/test/fake"
 	testutil "github.com/codeready-toolchain/registration-service/test/util"
@@ -34,7 +37,6 @@ import (
 	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
 
-	recaptchapb "cloud.google.com/go/recaptchaenterprise/v2/apiv1/recaptchaenterprisepb"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
@@ -339,6 +341,77 @@ func (s *TestSignupServiceSuite) TestSignupNoSpaces() {
 	require.Equal(s.T(), "true", val.Annotations[toolchainv1alpha1.SkipAutoCreateSpaceAnnotationKey]) // skip auto create space annotation is set
 }
 
+func (s *TestSignupServiceSuite) TestSignupPublishesEvents() {
+	// given
+	sink := fake.NewEventSink(s.T(), "crm-secret")
+	s.SetSettings(map[string]string{
+		"events.webhooks": fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"}]`, sink.URL),
+	})
+	publisher := events.NewPublisher(&http.Client{Transport: &http.Transport{}})
+	publisherCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	publisher.Start(publisherCtx)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Set(context.UsernameKey, "jsmith@kubesaw")
+	ctx.Set(context.SubKey, "987654321")
+	ctx.Set(context.EmailKey, "jsmith@gmail.com")
+	ctx.Set(context.UserIDKey, "13349822")
+	fakeClient := commontest.NewFakeClient(s.T())
+	svc := service.NewSignupService(namespaced.NewClient(fakeClient, commontest.HostOperatorNs), service.WithEventPublisher(publisher))
+
+	// when
+	userSignup, err := svc.Signup(ctx)
+
+	// then
+	require.NoError(s.T(), err)
+	require.Eventually(s.T(), func() bool {
+		return len(sink.Received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	created := sink.Received()[0]
+	assert.Equal(s.T(), events.TypeCreated, created.Type)
+	assert.Equal(s.T(), userSignup.Name, created.Subject)
+	assert.Equal(s.T(), "jsmith@kubesaw", created.Data.Username)
+	assert.Equal(s.T(), "13349822", created.Data.UserID)
+	assert.Equal(s.T(), "jsmith@gmail.com", created.Data.Email)
+
+	s.Run("reactivated", func() {
+		// given
+		states.SetDeactivated(userSignup, true)
+		require.NoError(s.T(), fakeClient.Update(gocontext.TODO(), userSignup))
+		userSignup.Status.Conditions = fake.Deactivated()
+		require.NoError(s.T(), fakeClient.Status().Update(gocontext.TODO(), userSignup))
+
+		// when
+		_, err := svc.Signup(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Eventually(s.T(), func() bool {
+			return len(sink.Received()) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), events.TypeReactivated, sink.Received()[1].Type)
+	})
+
+	s.Run("no event when the signup fails", func() {
+		// given
+		fakeClient := commontest.NewFakeClient(s.T())
+		fakeClient.MockCreate = func(_ gocontext.Context, _ client.Object, _ ...client.CreateOption) error {
+			return errors.New("mock error")
+		}
+		svc := service.NewSignupService(namespaced.NewClient(fakeClient, commontest.HostOperatorNs), service.WithEventPublisher(publisher))
+
+		// when
+		_, err := svc.Signup(ctx)
+
+		// then
+		require.EqualError(s.T(), err, "mock error")
+		assert.Never(s.T(), func() bool {
+			return len(sink.Received()) > 2
+		}, 100*time.Millisecond, 10*time.Millisecond)
+	})
+}
+
 func (s *TestSignupServiceSuite) TestSignupWithCaptchaEnabled() {
 	commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
 
@@ -612,6 +685,7 @@ func (s *TestSignupServiceSuite) TestGetSignupStatusNotComplete() {
 		testusersignup.WithCompliantUsername("bill"),
 		testusersignup.SignupIncomplete("test_reason", "test_message"),
 		testusersignup.ApprovedAutomaticallyAgo(0),
+		testusersignup.WithAnnotation(delivery.StatusAnnotationKey, delivery.StatusFailed),
 	)
 	states.SetVerificationRequired(userSignupNotComplete, true)
 
@@ -631,6 +705,7 @@ func (s *TestSignupServiceSuite) TestGetSignupStatusNotComplete() {
 	require.Equal(s.T(), "test_reason", response.Status.Reason)
 	require.Equal(s.T(), "test_message", response.Status.Message)
 	require.True(s.T(), response.Status.VerificationRequired)
+	require.Equal(s.T(), "failed", response.Status.VerificationDeliveryStatus)
 	require.Empty(s.T(), response.ConsoleURL)
 	require.Empty(s.T(), response.CheDashboardURL)
 	require.Empty(s.T(), response.APIEndpoint)
@@ -667,6 +742,7 @@ func (s *TestSignupServiceSuite) TestGetSignupStatusNotComplete() {
 		require.Equal(s.T(), "mur_ready_reason", response.Status.Reason)
 		require.Equal(s.T(), "mur_ready_message", response.Status.Message)
 		require.False(s.T(), response.Status.VerificationRequired)
+		require.Empty(s.T(), response.Status.VerificationDeliveryStatus)
 		require.Equal(s.T(), "https://console.apps.member-123.com", response.ConsoleURL)
 		require.Equal(s.T(), "https://devspaces.apps.member-123.com", response.CheDashboardURL)
 		require.Equal(s.T(), "http://api.devcluster.openshift.com", response.APIEndpoint)
@@ -1172,6 +1248,18 @@ func (s *TestSignupServiceSuite) TestIsPhoneVerificationRequired() {
 			assert.Empty(s.T(), assessmentID)
 		})
 
+		s.Run("captcha token is invalid", func() {
+			s.OverrideApplicationDefault(
+				testconfig.RegistrationService().
+					Verification().Enabled(true).
+					Verification().CaptchaEnabled(true))
+
+			isVerificationRequired, score, assessmentID := service.IsPhoneVerificationRequired(&FakeCaptchaChecker{score: 1.0, invalid: true}, &gin.Context{Request: &http.Request{Header: http.Header{"Recaptcha-Token": []string{"123"}}}})
+			assert.True(s.T(), isVerificationRequired)
+			assert.InDelta(s.T(), float32(-1), score, 0.01)
+			assert.Equal(s.T(), "captcha-assessment-123", assessmentID)
+		})
+
 		s.Run("captcha is enabled but the score is too low", func() {
 			s.OverrideApplicationDefault(
 				testconfig.RegistrationService().
@@ -1252,6 +1340,24 @@ func (s *TestSignupServiceSuite) TestGetSignupUpdatesUserSignupIdentityClaims()
 		},
 	}
 
+	s.Run("not updated when read by a machine principal", func() {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(context.PrincipalKey, "onboarding-bot")
+		c.Set(context.UsernameKey, "cocochanel")
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup, mur)
+		fakeClient.MockUpdate = func(_ gocontext.Context, _ client.Object, _ ...client.UpdateOption) error {
+			return errors.New("the UserSignup must not be updated")
+		}
+
+		_, err := application.SignupService().GetSignup(c, username, true)
+		require.NoError(s.T(), err)
+
+		unmodified := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), unmodified)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), userSignup.Spec.IdentityClaims.PreferredUsername, unmodified.Spec.IdentityClaims.PreferredUsername)
+	})
+
 	s.Run("PreferredUsername property updated when set in context", func() {
 		c, _ := gin.CreateTestContext(httptest.NewRecorder())
 		c.Set(context.UsernameKey, "cocochanel")
@@ -1407,15 +1513,15 @@ func (s *TestSignupServiceSuite) newSpaceBinding(murName, spaceName string) *too
 }
 
 type FakeCaptchaChecker struct {
-	score  float32
-	result error
+	score   float32
+	invalid bool
+	result  error
 }
 
-func (c FakeCaptchaChecker) CompleteAssessment(_ *gin.Context, _ configuration.RegistrationServiceConfig, _ string) (*recaptchapb.Assessment, error) {
-	return &recaptchapb.Assessment{
-		RiskAnalysis: &recaptchapb.RiskAnalysis{
-			Score: c.score,
-		},
-		Name: "captcha-assessment-123",
+func (c FakeCaptchaChecker) CompleteAssessment(_ *gin.Context, _ configuration.RegistrationServiceConfig, _ string) (*captcha.Assessment, error) {
+	return &captcha.Assessment{
+		Score: c.score,
+		ID:    "captcha-assessment-123",
+		Valid: !c.invalid,
 	}, c.result
 }
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+
+	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// WaitlistedLabelKey is set on the UserSignups which were put on the waitlist because the member clusters were full,
+	// until they are admitted
+	WaitlistedLabelKey = toolchainv1alpha1.LabelKeyPrefix + "waitlisted"
+	// WaitlistedTimeAnnotationKey contains the time the user was put on the waitlist, in RFC3339 format with nanoseconds,
+	// which determines the order of the waitlist
+	WaitlistedTimeAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "waitlisted-time"
+)
+
+// unlimitedSpaces is the number of available spaces when the capacity of the member clusters is not limited or can't be determined
+const unlimitedSpaces = math.MaxInt
+
+// waitlistIfFull puts the given UserSignup at the end of the waitlist if it is enabled and the enabled member clusters
+// don't have enough capacity left for the users already on the waitlist and this new one, so that the new users don't
+// get ahead of the users on the waitlist.
+func waitlistIfFull(ctx *gin.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup) {
+	if !configuration.GetRegistrationServiceConfig().Waitlist().Enabled() {
+		return
+	}
+	available, err := availableSpaces(ctx, cl)
+	if err != nil {
+		log.Error(ctx, err, "unable to check the capacity of the member clusters")
+		return
+	}
+	waitlisted, err := listWaitlisted(ctx, cl)
+	if err != nil {
+		log.Error(ctx, err, "unable to list the users on the waitlist")
+		return
+	}
+	if available > len(waitlisted) {
+		return
+	}
+	log.Info(ctx, fmt.Sprintf("member clusters are full, putting usersignup '%s' on the waitlist", userSignup.Name))
+	if userSignup.Labels == nil {
+		userSignup.Labels = map[string]string{}
+	}
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Labels[WaitlistedLabelKey] = "true"
+	userSignup.Annotations[WaitlistedTimeAnnotationKey] = time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// availableSpaces returns the number of spaces which can still be placed on the enabled member clusters, according to
+// the capacity thresholds of their SpaceProvisionerConfigs and the usage reported in the ToolchainStatus, like the host
+// operator does when placing the spaces. The capacity is considered unlimited if it can't be determined.
+func availableSpaces(ctx *gin.Context, cl namespaced.Client) (int, error) {
+	spcs := &toolchainv1alpha1.SpaceProvisionerConfigList{}
+	if err := cl.List(ctx, spcs, client.InNamespace(cl.Namespace)); err != nil {
+		return 0, err
+	}
+	status := &toolchainv1alpha1.ToolchainStatus{}
+	if err := cl.Get(ctx, cl.NamespacedName("toolchain-status"), status); err != nil {
+		return 0, err
+	}
+	members := make(map[string]toolchainv1alpha1.Member, len(status.Status.Members))
+	for _, member := range status.Status.Members {
+		members[member.ClusterName] = member
+	}
+
+	enabled := 0
+	available := 0
+	for _, spc := range spcs.Items {
+		if !spc.Spec.Enabled {
+			continue
+		}
+		enabled++
+		member, found := members[spc.Spec.ToolchainCluster]
+		if !found {
+			return unlimitedSpaces, nil
+		}
+		if memberFull(member, spc.Spec.CapacityThresholds) {
+			continue
+		}
+		if spc.Spec.CapacityThresholds.MaxNumberOfSpaces == 0 {
+			return unlimitedSpaces, nil
+		}
+		available += int(spc.Spec.CapacityThresholds.MaxNumberOfSpaces) - member.SpaceCount // nolint:gosec
+	}
+	if enabled == 0 {
+		return unlimitedSpaces, nil
+	}
+	return available, nil
+}
+
+// memberFull returns true if the number of spaces or the memory usage of any node role of the given member cluster
+// reached the given thresholds
+func memberFull(member toolchainv1alpha1.Member, thresholds toolchainv1alpha1.SpaceProvisionerCapacityThresholds) bool {
+	if thresholds.MaxNumberOfSpaces > 0 && uint(member.SpaceCount) >= thresholds.MaxNumberOfSpaces { // nolint:gosec
+		return true
+	}
+	if thresholds.MaxMemoryUtilizationPercent > 0 {
+		for _, usage := range member.MemberStatus.ResourceUsage.MemoryUsagePerNodeRole {
+			if uint(usage) >= thresholds.MaxMemoryUtilizationPercent { // nolint:gosec
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// onWaitlist returns true if the given UserSignup was put on the waitlist and is not approved yet
+func onWaitlist(userSignup *toolchainv1alpha1.UserSignup) bool {
+	return userSignup.Labels[WaitlistedLabelKey] == "true" &&
+		!states.ApprovedManually(userSignup) &&
+		!condition.IsTrue(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupApproved)
+}
+
+// listWaitlisted returns the UserSignups which are on the waitlist
+func listWaitlisted(ctx *gin.Context, cl namespaced.Client) ([]toolchainv1alpha1.UserSignup, error) {
+	waitlisted := &toolchainv1alpha1.UserSignupList{}
+	if err := cl.List(ctx, waitlisted, client.InNamespace(cl.Namespace), client.MatchingLabels{WaitlistedLabelKey: "true"}); err != nil {
+		return nil, err
+	}
+	items := make([]toolchainv1alpha1.UserSignup, 0, len(waitlisted.Items))
+	for _, userSignup := range waitlisted.Items {
+		if onWaitlist(&userSignup) {
+			items = append(items, userSignup)
+		}
+	}
+	return items, nil
+}
+
+// waitlistPosition returns the position of the given UserSignup in the waitlist, starting at 1. The users are ordered by
+// the time they were put on the waitlist, then by name.
+func waitlistPosition(ctx *gin.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup) (int, error) {
+	waitlisted, err := listWaitlisted(ctx, cl)
+	if err != nil {
+		return 0, err
+	}
+	position := 1
+	for i := range waitlisted {
+		if waitlisted[i].Name != userSignup.Name && waitlistedBefore(&waitlisted[i], userSignup) {
+			position++
+		}
+	}
+	return position, nil
+}
+
+func waitlistedBefore(a, b *toolchainv1alpha1.UserSignup) bool {
+	timeA, errA := time.Parse(time.RFC3339Nano, a.Annotations[WaitlistedTimeAnnotationKey])
+	timeB, errB := time.Parse(time.RFC3339Nano, b.Annotations[WaitlistedTimeAnnotationKey])
+	if errA == nil && errB == nil && !timeA.Equal(timeB) {
+		return timeA.Before(timeB)
+	}
+	return a.Name < b.Name
+}
+
+// admitFromWaitlist approves the given UserSignup if it is on the waitlist and the enabled member clusters have enough
+// capacity for it and all the users ahead of it, so that the users are admitted in the order of the waitlist.
+// It only applies if the host operator approves the new signups automatically, and returns true if the UserSignup was updated.
+func admitFromWaitlist(ctx *gin.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup) (bool, error) {
+	if !onWaitlist(userSignup) || !configuration.GetRegistrationServiceConfig().Waitlist().AdmitInOrder() {
+		return false, nil
+	}
+	available, err := availableSpaces(ctx, cl)
+	if err != nil {
+		return false, err
+	}
+	position, err := waitlistPosition(ctx, cl, userSignup)
+	if err != nil {
+		return false, err
+	}
+	if position > available {
+		return false, nil
+	}
+	log.Info(ctx, fmt.Sprintf("admitting usersignup '%s' from position %d of the waitlist", userSignup.Name, position))
+	states.SetApprovedManually(userSignup, true)
+	return true, nil
+}
+
+// removeFromWaitlistIfApproved removes the waitlist label and annotation from the given UserSignup once it is approved,
+// and returns true if it was updated
+func removeFromWaitlistIfApproved(userSignup *toolchainv1alpha1.UserSignup) bool {
+	if _, waitlisted := userSignup.Labels[WaitlistedLabelKey]; !waitlisted || onWaitlist(userSignup) {
+		return false
+	}
+	delete(userSignup.Labels, WaitlistedLabelKey)
+	delete(userSignup.Annotations, WaitlistedTimeAnnotationKey)
+	return true
+}
+
+// setWaitlistStatus sets the position of the given UserSignup in the waitlist and its estimated wait in the given status,
+// if the user is on the waitlist
+func setWaitlistStatus(ctx *gin.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup, status *signup.Status) error {
+	if !onWaitlist(userSignup) {
+		return nil
+	}
+	position, err := waitlistPosition(ctx, cl, userSignup)
+	if err != nil {
+		return err
+	}
+	status.WaitlistPosition = position
+	status.EstimatedWaitSeconds = int64((time.Duration(position) * configuration.GetRegistrationServiceConfig().Waitlist().EstimatedWaitPerPosition()).Seconds())
+	return nil
+}
@@ -0,0 +1,371 @@
+package service_test
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/service"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	testsocialevent "github.com/codeready-toolchain/toolchain-common/pkg/test/socialevent"
+	testspc "github.com/codeready-toolchain/toolchain-common/pkg/test/spaceprovisionerconfig"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newToolchainStatusWithCapacity returns a ToolchainStatus with the given number of spaces per member cluster,
+// and the given memory usage of the worker nodes of all the member clusters
+func (s *TestSignupServiceSuite) newToolchainStatusWithCapacity(spaceCounts map[string]int, memoryUsage int) *toolchainv1alpha1.ToolchainStatus {
+	status := &toolchainv1alpha1.ToolchainStatus{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "toolchain-status",
+			Namespace: commontest.HostOperatorNs,
+		},
+	}
+	for name, count := range spaceCounts {
+		status.Status.Members = append(status.Status.Members, toolchainv1alpha1.Member{
+			ClusterName: name,
+			SpaceCount:  count,
+			MemberStatus: toolchainv1alpha1.MemberStatusStatus{
+				ResourceUsage: toolchainv1alpha1.ResourceUsage{
+					MemoryUsagePerNodeRole: map[string]int{"worker": memoryUsage},
+				},
+			},
+		})
+	}
+	return status
+}
+
+// newSpaceProvisionerConfig returns an enabled SpaceProvisionerConfig of the given member cluster with the given thresholds
+func newSpaceProvisionerConfig(member string, maxSpaces, maxMemory uint, opts ...testspc.CreateOption) *toolchainv1alpha1.SpaceProvisionerConfig {
+	return testspc.NewSpaceProvisionerConfig(member+"-spc", commontest.HostOperatorNs, append([]testspc.CreateOption{
+		testspc.ReferencingToolchainCluster(member),
+		testspc.Enabled(true),
+		testspc.MaxNumberOfSpaces(maxSpaces),
+		testspc.MaxMemoryUtilizationPercent(maxMemory),
+	}, opts...)...)
+}
+
+func (s *TestSignupServiceSuite) TestSignupWaitlist() {
+	newCtx := func(username string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, username)
+		ctx.Set(context.SubKey, "987654321")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		return ctx
+	}
+	settings := map[string]string{
+		"signup.waitlist.enabled": "true",
+	}
+	spcs := []client.Object{
+		newSpaceProvisionerConfig("member-1", 100, 80),
+		newSpaceProvisionerConfig("member-2", 100, 80),
+	}
+
+	s.Run("waitlisted when all the enabled members are full", func() {
+		for name, tc := range map[string]struct {
+			status *toolchainv1alpha1.ToolchainStatus
+			spcs   []client.Object
+		}{
+			"spaces": {
+				status: s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 150}, 10),
+				spcs:   spcs,
+			},
+			"memory": {
+				status: s.newToolchainStatusWithCapacity(map[string]int{"member-1": 10, "member-2": 20}, 80),
+				spcs:   spcs,
+			},
+			"full for different reasons": {
+				status: s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 10}, 85),
+				spcs:   spcs,
+			},
+			"member with capacity disabled": {
+				status: s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 10}, 10),
+				spcs: []client.Object{
+					newSpaceProvisionerConfig("member-1", 100, 80),
+					newSpaceProvisionerConfig("member-2", 100, 80, testspc.Enabled(false)),
+				},
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				s.SetSettings(settings)
+				fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{tc.status}, tc.spcs...)...)
+
+				// when
+				userSignup, err := application.SignupService().Signup(newCtx("jsmith@kubesaw"))
+
+				// then
+				require.NoError(s.T(), err)
+				created := &toolchainv1alpha1.UserSignup{}
+				require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+				assert.Equal(s.T(), "true", created.Labels[service.WaitlistedLabelKey])
+				assert.NotEmpty(s.T(), created.Annotations[service.WaitlistedTimeAnnotationKey])
+			})
+		}
+	})
+
+	s.Run("new users don't get ahead of the users on the waitlist", func() {
+		// capacity left for 2 more spaces on member-1
+		status := s.newToolchainStatusWithCapacity(map[string]int{"member-1": 98, "member-2": 100}, 10)
+
+		s.Run("waitlisted when the capacity left is taken by the users on the waitlist", func() {
+			// given
+			s.SetSettings(settings)
+			fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{status,
+				newWaitlistedUserSignup("first@kubesaw", time.Now().Add(-2*time.Hour)),
+				newWaitlistedUserSignup("second@kubesaw", time.Now().Add(-time.Hour))}, spcs...)...)
+
+			// when
+			userSignup, err := application.SignupService().Signup(newCtx("jsmith@kubesaw"))
+
+			// then
+			require.NoError(s.T(), err)
+			created := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+			assert.Equal(s.T(), "true", created.Labels[service.WaitlistedLabelKey])
+		})
+
+		s.Run("not waitlisted when there is capacity left for the users on the waitlist and the new one", func() {
+			// given
+			s.SetSettings(settings)
+			fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{status,
+				newWaitlistedUserSignup("first@kubesaw", time.Now().Add(-2*time.Hour))}, spcs...)...)
+
+			// when
+			userSignup, err := application.SignupService().Signup(newCtx("jsmith@kubesaw"))
+
+			// then
+			require.NoError(s.T(), err)
+			created := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+			assert.NotContains(s.T(), created.Labels, service.WaitlistedLabelKey)
+		})
+	})
+
+	s.Run("not waitlisted", func() {
+		for name, tc := range map[string]struct {
+			settings map[string]string
+			status   *toolchainv1alpha1.ToolchainStatus
+			spcs     []client.Object
+		}{
+			"capacity available": {
+				settings: settings,
+				status:   s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 99}, 10),
+				spcs:     spcs,
+			},
+			"waitlist disabled": {
+				settings: map[string]string{},
+				status:   s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 100}, 10),
+				spcs:     spcs,
+			},
+			"no thresholds": {
+				settings: settings,
+				status:   s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100}, 100),
+				spcs:     []client.Object{newSpaceProvisionerConfig("member-1", 0, 0)},
+			},
+			"no enabled members": {
+				settings: settings,
+				status:   s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100}, 10),
+				spcs:     []client.Object{newSpaceProvisionerConfig("member-1", 100, 80, testspc.Enabled(false))},
+			},
+			"capacity of an enabled member unknown": {
+				settings: settings,
+				status:   s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100}, 10),
+				spcs:     spcs,
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				s.SetSettings(tc.settings)
+				fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{tc.status}, tc.spcs...)...)
+
+				// when
+				userSignup, err := application.SignupService().Signup(newCtx("jsmith@kubesaw"))
+
+				// then
+				require.NoError(s.T(), err)
+				created := &toolchainv1alpha1.UserSignup{}
+				require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+				assert.NotContains(s.T(), created.Labels, service.WaitlistedLabelKey)
+			})
+		}
+
+		s.Run("no toolchainstatus", func() {
+			// given
+			s.SetSettings(settings)
+			fakeClient, application := testutil.PrepareInClusterApp(s.T(), spcs...)
+
+			// when
+			userSignup, err := application.SignupService().Signup(newCtx("jsmith@kubesaw"))
+
+			// then
+			require.NoError(s.T(), err)
+			created := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+			assert.NotContains(s.T(), created.Labels, service.WaitlistedLabelKey)
+		})
+
+		s.Run("social event code bypasses the waitlist", func() {
+			// given
+			s.SetSettings(settings)
+			event := testsocialevent.NewSocialEvent(commontest.HostOperatorNs, "event1")
+			fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{event,
+				s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 100}, 10)}, spcs...)...)
+			ctx := newCtx("jsmith@kubesaw")
+			ctx.Set(context.SocialEvent, "event1")
+
+			// when
+			userSignup, err := application.SignupService().Signup(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			created := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+			assert.NotContains(s.T(), created.Labels, service.WaitlistedLabelKey)
+		})
+	})
+
+	s.Run("invitation code does not bypass the waitlist", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"signup.waitlist.enabled":       "true",
+			"signup.invitations.maxPerUser": "1",
+		})
+		referrerName, referrer := s.newUserSignupComplete()
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), append([]client.Object{referrer,
+			s.newToolchainStatusWithCapacity(map[string]int{"member-1": 100, "member-2": 100}, 10)}, spcs...)...)
+		invitation, err := application.SignupService().CreateInvitation(newCtx(referrerName), referrerName)
+		require.NoError(s.T(), err)
+		ctx := newCtx("jsmith@kubesaw")
+		ctx.Request, _ = http.NewRequest(http.MethodPost, "/?invitation="+invitation.Code, nil)
+
+		// when
+		userSignup, err := application.SignupService().Signup(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		created := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), created))
+		assert.Equal(s.T(), "true", created.Labels[service.WaitlistedLabelKey])
+	})
+}
+
+// newWaitlistedUserSignup returns a pending UserSignup which was put on the waitlist at the given time
+func newWaitlistedUserSignup(name string, waitlistedAt time.Time) *toolchainv1alpha1.UserSignup {
+	return testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName(name),
+		testusersignup.WithLabel(service.WaitlistedLabelKey, "true"),
+		testusersignup.WithAnnotation(service.WaitlistedTimeAnnotationKey, waitlistedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+func (s *TestSignupServiceSuite) TestGetSignupWaitlisted() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("position and estimated wait", func() {
+		// given
+		s.SetSettings(map[string]string{"signup.waitlist.estimatedWaitPerPosition": "10m"})
+		first := newWaitlistedUserSignup("first@kubesaw", time.Now().Add(-3*time.Hour))
+		second := newWaitlistedUserSignup("second@kubesaw", time.Now().Add(-2*time.Hour))
+		third := newWaitlistedUserSignup("third@kubesaw", time.Now().Add(-time.Hour))
+		admitted := newWaitlistedUserSignup("admitted@kubesaw", time.Now().Add(-4*time.Hour))
+		states.SetApprovedManually(admitted, true)
+		_, application := testutil.PrepareInClusterApp(s.T(), third, first, second, admitted)
+
+		for username, position := range map[string]int{"first@kubesaw": 1, "second@kubesaw": 2, "third@kubesaw": 3} {
+			s.Run(username, func() {
+				// when
+				signup, err := application.SignupService().GetSignup(ctx, username, true)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), toolchainv1alpha1.UserSignupPendingApprovalReason, signup.Status.Reason)
+				assert.Equal(s.T(), position, signup.Status.WaitlistPosition)
+				assert.Equal(s.T(), int64(position*600), signup.Status.EstimatedWaitSeconds)
+			})
+		}
+	})
+
+	s.Run("users admitted in the order of the waitlist", func() {
+		// given
+		s.SetSettings(map[string]string{}, testconfig.AutomaticApproval().Enabled(true))
+		first := newWaitlistedUserSignup("first@kubesaw", time.Now().Add(-2*time.Hour))
+		second := newWaitlistedUserSignup("second@kubesaw", time.Now().Add(-time.Hour))
+		// capacity left for 1 more space on member-1
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), first, second,
+			s.newToolchainStatusWithCapacity(map[string]int{"member-1": 99}, 10),
+			newSpaceProvisionerConfig("member-1", 100, 80))
+
+		// when
+		secondSignup, err := application.SignupService().GetSignup(ctx, "second@kubesaw", true)
+		require.NoError(s.T(), err)
+		firstSignup, err := application.SignupService().GetSignup(ctx, "first@kubesaw", true)
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), 2, secondSignup.Status.WaitlistPosition)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(second), updated))
+		assert.False(s.T(), states.ApprovedManually(updated))
+		assert.Equal(s.T(), "true", updated.Labels[service.WaitlistedLabelKey])
+
+		assert.Zero(s.T(), firstSignup.Status.WaitlistPosition)
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(first), updated))
+		assert.True(s.T(), states.ApprovedManually(updated))
+		assert.NotContains(s.T(), updated.Labels, service.WaitlistedLabelKey)
+		assert.NotContains(s.T(), updated.Annotations, service.WaitlistedTimeAnnotationKey)
+	})
+
+	s.Run("users not admitted when the automatic approval is disabled", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		first := newWaitlistedUserSignup("first@kubesaw", time.Now().Add(-time.Hour))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), first,
+			s.newToolchainStatusWithCapacity(map[string]int{"member-1": 10}, 10),
+			newSpaceProvisionerConfig("member-1", 100, 80))
+
+		// when
+		signup, err := application.SignupService().GetSignup(ctx, "first@kubesaw", true)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, signup.Status.WaitlistPosition)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(first), updated))
+		assert.False(s.T(), states.ApprovedManually(updated))
+	})
+
+	s.Run("approved user removed from the waitlist", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		approved := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("approved@kubesaw"),
+			testusersignup.WithLabel(service.WaitlistedLabelKey, "true"),
+			testusersignup.WithAnnotation(service.WaitlistedTimeAnnotationKey, time.Now().UTC().Format(time.RFC3339Nano)),
+			testusersignup.ApprovedAutomaticallyAgo(time.Minute), testusersignup.SignupIncomplete("Provisioning", ""))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), approved)
+
+		// when
+		signup, err := application.SignupService().GetSignup(ctx, "approved@kubesaw", true)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Zero(s.T(), signup.Status.WaitlistPosition)
+		assert.Zero(s.T(), signup.Status.EstimatedWaitSeconds)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(approved), updated))
+		assert.NotContains(s.T(), updated.Labels, service.WaitlistedLabelKey)
+		assert.NotContains(s.T(), updated.Annotations, service.WaitlistedTimeAnnotationKey)
+	})
+}
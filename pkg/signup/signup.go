@@ -68,6 +68,25 @@ type Status struct {
 	// VerificationRequired is set to false when the user is ether exempt from phone verification or has already successfully passed the verification.
 	// Default value is false.
 	VerificationRequired bool `json:"verificationRequired"`
+	// VerificationDeliveryStatus is the delivery status of the last verification code sent by SMS, ie, `sent`, `delivered`
+	// or `failed` if the code could not be delivered (eg. the number is a landline), in which case the user should try another number.
+	// It is empty if no code was sent by SMS or if the verification is not required anymore.
+	VerificationDeliveryStatus string `json:"verificationDeliveryStatus,omitempty"`
+	// WaitlistPosition is the position of the user in the waitlist, starting at 1, when the user was put on it because
+	// the member clusters were full. The users are admitted in the order of the waitlist. It is 0 if the user is not on the waitlist.
+	WaitlistPosition int `json:"waitlistPosition,omitempty"`
+	// EstimatedWaitSeconds is the estimated time before the user leaves the waitlist, in seconds, based on the configured
+	// average time it takes to admit one user
+	EstimatedWaitSeconds int64 `json:"estimatedWaitSeconds,omitempty"`
+}
+
+// DeactivationConfirmation is returned when a user requests the deactivation of their account, which is done once they
+// confirm it with the token
+type DeactivationConfirmation struct {
+	// The token to provide to confirm the deactivation
+	Token string `json:"confirmationToken"`
+	// ExpiresAt is the date after which the token can't be used anymore, in RFC3339 format
+	ExpiresAt string `json:"expiresAt"`
 }
 
 // PollUpdateSignup will attempt to execute the provided updater function, and if it fails
@@ -2,87 +2,82 @@ package captcha
 
 import (
 	gocontext "context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
-	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/gin-gonic/gin"
-
-	recaptcha "cloud.google.com/go/recaptchaenterprise/v2/apiv1"
-	recaptchapb "cloud.google.com/go/recaptchaenterprise/v2/apiv1/recaptchaenterprisepb"
 )
 
-// recaptchaSignupAction is the action name corresponding to the token
-const recaptchaSignupAction = "SIGNUP"
+// Assessment is the result of the assessment of a captcha token, independently of the captcha provider
+type Assessment struct {
+	// ID identifies the assessment at the provider, so that it can be annotated later on (empty if not supported by the provider)
+	ID string
+	// Score is the likelihood that the interaction was legitimate, from 0.0 (very likely a bot) to 1.0 (very likely a human)
+	Score float32
+	// Reasons are the reasons given by the provider for the score, or for the invalidity of the token
+	Reasons []string
+	// Valid is false if the token was invalid (eg, expired, already used, or for another action or site)
+	Valid bool
+}
 
+// Assessor assesses the captcha tokens obtained by the clients.
+// An error is returned if the assessment could not be completed, but not if the token is invalid.
 type Assessor interface {
-	CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*recaptchapb.Assessment, error)
+	CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error)
 }
 
-type Helper struct{}
+// Annotation is the feedback given to the captcha provider about an assessment, once the outcome of the signup is known
+type Annotation string
 
-/*
-*
-* Creates an assessment to analyze the risk of a signup.
-*
-* @param ctx: The request context.
-* @param cfg: The Registration Service Configuration object.
-* @param token: The token obtained from the client on passing the reCAPTCHA Site Key.
-
-returns the assessment and nil if the assessment was successful, otherwise returns nil and the error.
-*/
-func (c Helper) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*recaptchapb.Assessment, error) {
-	gctx := gocontext.Background()
-	client, err := recaptcha.NewClient(gctx)
-	if err != nil {
-		return nil, fmt.Errorf("error creating reCAPTCHA client: %w", err)
-	}
-	defer client.Close()
+const (
+	// AnnotationLegitimate is the annotation of the assessments of the users who were approved and verified
+	AnnotationLegitimate Annotation = "LEGITIMATE"
+	// AnnotationFraudulent is the annotation of the assessments of the users who were banned
+	AnnotationFraudulent Annotation = "FRAUDULENT"
+)
 
-	// Set the properties of the event to be tracked.
-	event := &recaptchapb.Event{
-		ExpectedAction: recaptchaSignupAction,
-		Token:          token,
-		SiteKey:        cfg.Verification().CaptchaSiteKey(),
-	}
+// ErrAnnotationNotSupported is returned when the captcha provider doesn't support the annotation of the assessments
+var ErrAnnotationNotSupported = errors.New("the captcha provider does not support the annotation of the assessments")
 
-	assessment := &recaptchapb.Assessment{
-		Event: event,
-	}
+// Annotator annotates the assessments made by the captcha provider, so that it can improve its future assessments
+type Annotator interface {
+	AnnotateAssessment(ctx gocontext.Context, cfg configuration.RegistrationServiceConfig, assessmentID string, annotation Annotation) error
+}
 
-	// Build the assessment request.
-	request := &recaptchapb.CreateAssessmentRequest{
-		Assessment: assessment,
-		Parent:     fmt.Sprintf("projects/%s", cfg.Verification().CaptchaProjectID()),
-	}
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
 
-	response, err := client.CreateAssessment(
-		ctx,
-		request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create reCAPTCHA assessment")
-	}
+var assessors = map[string]Assessor{
+	configuration.CaptchaProviderRecaptcha: recaptchaAssessor{},
+	configuration.CaptchaProviderHCaptcha:  hcaptchaAssessor{httpClient: httpClient},
+	configuration.CaptchaProviderTurnstile: turnstileAssessor{httpClient: httpClient},
+	configuration.CaptchaProviderFake:      fakeAssessor{},
+}
 
-	// Check if the token is valid.
-	if !response.GetTokenProperties().GetValid() {
-		return nil, fmt.Errorf("the CreateAssessment() call failed because the token"+
-			" was invalid for the following reasons: %v",
-			response.GetTokenProperties().GetInvalidReason())
-	}
+var annotators = map[string]Annotator{
+	configuration.CaptchaProviderRecaptcha: recaptchaAssessor{},
+	configuration.CaptchaProviderFake:      fakeAssessor{},
+}
 
-	// Check if the expected action was executed.
-	if response.GetTokenProperties().GetAction() == recaptchaSignupAction {
-		// Get the risk score and the reason(s).
-		// For more information on interpreting the assessment,
-		// see: https://cloud.google.com/recaptcha-enterprise/docs/interpret-assessment
-		log.Info(ctx, fmt.Sprintf("reCAPTCHA assessment score: %.1f", response.GetRiskAnalysis().GetScore()))
+// Helper is an Assessor and an Annotator which delegates to the captcha provider given in the configuration
+type Helper struct{}
 
-		for _, reason := range response.GetRiskAnalysis().GetReasons() {
-			log.Info(ctx, fmt.Sprintf("Risk analysis reason: %s", reason.String()))
-		}
-		log.Info(ctx, fmt.Sprintf("Assessment Response: %+v", response))
-		return response, nil
+func (c Helper) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	assessor, found := assessors[cfg.Verification().CaptchaProvider()]
+	if !found {
+		return nil, fmt.Errorf("unknown captcha provider '%s'", cfg.Verification().CaptchaProvider())
 	}
+	return assessor.CompleteAssessment(ctx, cfg, token)
+}
 
-	return nil, fmt.Errorf("the action attribute in the reCAPTCHA token does not match the expected action to score")
+func (c Helper) AnnotateAssessment(ctx gocontext.Context, cfg configuration.RegistrationServiceConfig, assessmentID string, annotation Annotation) error {
+	annotator, found := annotators[cfg.Verification().CaptchaProvider()]
+	if !found {
+		return ErrAnnotationNotSupported
+	}
+	return annotator.AnnotateAssessment(ctx, cfg, assessmentID, annotation)
 }
@@ -0,0 +1,297 @@
+package captcha_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/registration-service/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestCaptchaSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunCaptchaSuite(t *testing.T) {
+	suite.Run(t, &TestCaptchaSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestCaptchaSuite) setSettings(environment string, data map[string]string) {
+	s.SetSettings(data, testconfig.RegistrationService().
+		Environment(environment).
+		Verification().CaptchaSiteKey("site-key"))
+}
+
+// newSiteverifyEndpoint starts a mock `siteverify` endpoint which returns the given response for the `valid-token`,
+// and an error for any other token
+func (s *TestCaptchaSuite) newSiteverifyEndpoint(expectedSiteKey string, response map[string]interface{}) *httptest.Server {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), "captcha-secret", r.FormValue("secret"))
+		assert.Equal(s.T(), expectedSiteKey, r.FormValue("sitekey"))
+		if r.FormValue("response") != "valid-token" {
+			response = map[string]interface{}{
+				"success":     false,
+				"error-codes": []string{"invalid-input-response"},
+			}
+		}
+		err := json.NewEncoder(w).Encode(response)
+		assert.NoError(s.T(), err)
+	}))
+	s.T().Cleanup(endpoint.Close)
+	return endpoint
+}
+
+func (s *TestCaptchaSuite) TestHCaptcha() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("valid token with risk score", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("site-key", map[string]interface{}{
+			"success":      true,
+			"score":        0.2,
+			"score_reason": []string{"safe"},
+		})
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "hcaptcha",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), assessment.Valid)
+		assert.InDelta(s.T(), float32(0.8), assessment.Score, 0.01)
+		assert.Equal(s.T(), []string{"safe"}, assessment.Reasons)
+	})
+
+	s.Run("valid token without risk score", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("site-key", map[string]interface{}{
+			"success": true,
+		})
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "hcaptcha",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), assessment.Valid)
+		assert.InDelta(s.T(), float32(1), assessment.Score, 0.01)
+	})
+
+	s.Run("invalid token", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("site-key", nil)
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "hcaptcha",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "invalid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.False(s.T(), assessment.Valid)
+		assert.Equal(s.T(), []string{"invalid-input-response"}, assessment.Reasons)
+	})
+
+	s.Run("no secret key", func() {
+		// given
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider": "hcaptcha",
+		})
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.EqualError(s.T(), err, "no captcha secret key configured")
+	})
+
+	s.Run("endpoint unavailable", func() {
+		// given
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer endpoint.Close()
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "hcaptcha",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.EqualError(s.T(), err, "failed to verify the captcha token: unexpected response status: 503 Service Unavailable")
+	})
+}
+
+func (s *TestCaptchaSuite) TestTurnstile() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("valid token", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("", map[string]interface{}{
+			"success": true,
+			"action":  "signup",
+		})
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "turnstile",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), assessment.Valid)
+		assert.InDelta(s.T(), float32(1), assessment.Score, 0.01)
+	})
+
+	s.Run("unexpected action", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("", map[string]interface{}{
+			"success": true,
+			"action":  "login",
+		})
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "turnstile",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "valid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.False(s.T(), assessment.Valid)
+		assert.Equal(s.T(), []string{"unexpected action 'login'"}, assessment.Reasons)
+	})
+
+	s.Run("invalid token", func() {
+		// given
+		endpoint := s.newSiteverifyEndpoint("", nil)
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider":  "turnstile",
+			"verification.captcha.secretKey": "captcha-secret",
+			"verification.captcha.verifyURL": endpoint.URL,
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "invalid-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.False(s.T(), assessment.Valid)
+	})
+}
+
+func (s *TestCaptchaSuite) TestFake() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("score given by the token", func() {
+		// given
+		s.setSettings("e2e-tests", map[string]string{
+			"verification.captcha.provider": "fake",
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "0.3")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), assessment.Valid)
+		assert.InDelta(s.T(), float32(0.3), assessment.Score, 0.01)
+		assert.NotEmpty(s.T(), assessment.ID)
+
+		s.Run("assessments are deterministic", func() {
+			// when
+			other, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "0.3")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), assessment, other)
+		})
+	})
+
+	s.Run("configured score", func() {
+		// given
+		s.setSettings("e2e-tests", map[string]string{
+			"verification.captcha.provider":  "fake",
+			"verification.captcha.fakeScore": "0.6",
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "any-token")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), assessment.Valid)
+		assert.InDelta(s.T(), float32(0.6), assessment.Score, 0.01)
+	})
+
+	s.Run("invalid token", func() {
+		// given
+		s.setSettings("e2e-tests", map[string]string{
+			"verification.captcha.provider": "fake",
+		})
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), captcha.FakeInvalidToken)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.False(s.T(), assessment.Valid)
+	})
+
+	s.Run("not allowed in production", func() {
+		// given
+		s.setSettings(configuration.DefaultEnvironment, map[string]string{
+			"verification.captcha.provider": "fake",
+		})
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "0.9")
+
+		// then
+		require.EqualError(s.T(), err, "the fake captcha provider cannot be used in the production environment")
+	})
+}
+
+func (s *TestCaptchaSuite) TestUnknownProvider() {
+	// given
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	s.setSettings(configuration.DefaultEnvironment, map[string]string{
+		"verification.captcha.provider": "unknown",
+	})
+
+	// when
+	_, err := captcha.Helper{}.CompleteAssessment(ctx, configuration.GetRegistrationServiceConfig(), "token")
+
+	// then
+	require.EqualError(s.T(), err, "unknown captcha provider 'unknown'")
+}
@@ -0,0 +1,55 @@
+package captcha
+
+import (
+	gocontext "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/gin-gonic/gin"
+)
+
+// FakeInvalidToken is the token considered as invalid by the fake provider
+const FakeInvalidToken = "invalid"
+
+// fakeAssessor makes deterministic assessments, for the e2e tests:
+// - the FakeInvalidToken is invalid
+// - a token which is a number is valid, with this number as its score (eg, `0.3`)
+// - any other token is valid, with the score configured for the fake provider
+type fakeAssessor struct{}
+
+func (a fakeAssessor) CompleteAssessment(_ *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	if cfg.IsProdEnvironment() {
+		return nil, errors.New("the fake captcha provider cannot be used in the production environment")
+	}
+	id := sha256.Sum256([]byte(token))
+	assessment := &Assessment{
+		ID: "fake-" + hex.EncodeToString(id[:8]),
+	}
+	if token == FakeInvalidToken {
+		assessment.Reasons = []string{"INVALID_TOKEN"}
+		return assessment, nil
+	}
+	assessment.Valid = true
+	if score, err := strconv.ParseFloat(token, 32); err == nil {
+		assessment.Score = float32(score)
+		return assessment, nil
+	}
+	assessment.Score = cfg.Verification().CaptchaFakeScore()
+	return assessment, nil
+}
+
+// AnnotateAssessment accepts any annotation of the assessments made by the fake provider
+func (a fakeAssessor) AnnotateAssessment(_ gocontext.Context, cfg configuration.RegistrationServiceConfig, assessmentID string, _ Annotation) error {
+	if cfg.IsProdEnvironment() {
+		return errors.New("the fake captcha provider cannot be used in the production environment")
+	}
+	if !strings.HasPrefix(assessmentID, "fake-") {
+		return fmt.Errorf("unknown assessment '%s'", assessmentID)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package captcha
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	signuppkg "github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AssessmentAnnotationAnnotationKey is set on the UserSignup once its captcha assessment was annotated, and contains the annotation
+	AssessmentAnnotationAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "captcha-assessment-annotation"
+	// AssessmentAnnotationErrorAnnotationKey is set on the UserSignup if its captcha assessment could not be annotated, and contains the error
+	AssessmentAnnotationErrorAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "captcha-assessment-annotation-error"
+
+	// maxFeedbackRetries is the number of times the annotation of an assessment is retried before giving up
+	maxFeedbackRetries = 5
+)
+
+// FeedbackReporter watches the UserSignups and the BannedUsers to report the outcome of the signups to the captcha provider:
+// the assessments of the banned users are annotated as fraudulent, and the ones of the approved and verified users as legitimate.
+// The outcome of the annotation is recorded on the UserSignup, so that each assessment is annotated only once.
+type FeedbackReporter struct {
+	client    namespaced.Client
+	annotator Annotator
+	queue     workqueue.TypedRateLimitingInterface[string]
+}
+
+// NewFeedbackReporter creates a new FeedbackReporter
+func NewFeedbackReporter(cl namespaced.Client, annotator Annotator) *FeedbackReporter {
+	return &FeedbackReporter{
+		client:    cl,
+		annotator: annotator,
+		queue:     workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+	}
+}
+
+// Start watches the UserSignups and the BannedUsers with the given informers,
+// and reports the feedback in the background until the context is done.
+func (r *FeedbackReporter) Start(ctx gocontext.Context, informers cache.Informers) error {
+	signupInformer, err := informers.GetInformer(ctx, &toolchainv1alpha1.UserSignup{})
+	if err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+	if _, err := signupInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: r.enqueueUserSignup,
+		UpdateFunc: func(_, obj interface{}) {
+			r.enqueueUserSignup(obj)
+		},
+	}); err != nil {
+		return fmt.Errorf("unable to watch the UserSignups: %w", err)
+	}
+
+	bannedUserInformer, err := informers.GetInformer(ctx, &toolchainv1alpha1.BannedUser{})
+	if err != nil {
+		return fmt.Errorf("unable to watch the BannedUsers: %w", err)
+	}
+	if _, err := bannedUserInformer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			r.enqueueBannedUser(ctx, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			r.enqueueBannedUser(ctx, obj)
+		},
+	}); err != nil {
+		return fmt.Errorf("unable to watch the BannedUsers: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		r.queue.ShutDown()
+	}()
+	go func() {
+		for r.processNextItem(ctx) {
+		}
+	}()
+	return nil
+}
+
+func (r *FeedbackReporter) enqueueUserSignup(obj interface{}) {
+	signup, ok := obj.(*toolchainv1alpha1.UserSignup)
+	if !ok || signup.Annotations[toolchainv1alpha1.UserSignupCaptchaAssessmentIDAnnotationKey] == "" {
+		return
+	}
+	r.queue.Add(signup.Name)
+}
+
+// enqueueBannedUser enqueues the UserSignups with the same email address as the banned user
+func (r *FeedbackReporter) enqueueBannedUser(ctx gocontext.Context, obj interface{}) {
+	bannedUser, ok := obj.(*toolchainv1alpha1.BannedUser)
+	if !ok || bannedUser.Labels[toolchainv1alpha1.BannedUserEmailHashLabelKey] == "" {
+		return
+	}
+	signups := &toolchainv1alpha1.UserSignupList{}
+	if err := r.client.List(ctx, signups, client.InNamespace(r.client.Namespace), client.MatchingLabels{
+		toolchainv1alpha1.UserSignupUserEmailHashLabelKey: bannedUser.Labels[toolchainv1alpha1.BannedUserEmailHashLabelKey],
+	}); err != nil {
+		log.Errorf(nil, err, "unable to list the UserSignups of the BannedUser '%s'", bannedUser.Name)
+		return
+	}
+	for i := range signups.Items {
+		r.enqueueUserSignup(&signups.Items[i])
+	}
+}
+
+func (r *FeedbackReporter) processNextItem(ctx gocontext.Context) bool {
+	name, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(name)
+
+	if err := r.Report(ctx, name); err != nil {
+		if r.queue.NumRequeues(name) < maxFeedbackRetries {
+			log.Errorf(nil, err, "unable to report the captcha feedback, retrying")
+			r.queue.AddRateLimited(name)
+			return true
+		}
+		log.Errorf(nil, err, "unable to report the captcha feedback, giving up")
+	}
+	r.queue.Forget(name)
+	return true
+}
+
+// Report annotates the captcha assessment of the UserSignup with the given name, if the outcome of the signup is known
+// and if it was not annotated yet. Fraudulent assessments are never annotated as legitimate afterwards.
+func (r *FeedbackReporter) Report(ctx gocontext.Context, name string) error {
+	signup := &toolchainv1alpha1.UserSignup{}
+	if err := r.client.Get(ctx, r.client.NamespacedName(name), signup); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	assessmentID := signup.Annotations[toolchainv1alpha1.UserSignupCaptchaAssessmentIDAnnotationKey]
+	if assessmentID == "" {
+		return nil
+	}
+
+	annotation, err := r.expectedAnnotation(ctx, signup)
+	if err != nil {
+		return err
+	}
+	reported := Annotation(signup.Annotations[AssessmentAnnotationAnnotationKey])
+	if annotation == "" || annotation == reported || reported == AnnotationFraudulent {
+		return nil
+	}
+
+	err = r.annotator.AnnotateAssessment(ctx, configuration.GetRegistrationServiceConfig(), assessmentID, annotation)
+	if errors.Is(err, ErrAnnotationNotSupported) {
+		return nil
+	}
+	if recordErr := r.record(name, annotation, err); recordErr != nil {
+		log.Errorf(nil, recordErr, "unable to record the captcha feedback on the UserSignup '%s'", name)
+		if err == nil {
+			return recordErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to annotate the captcha assessment of the UserSignup '%s' as %s: %w", name, annotation, err)
+	}
+	log.Infof(nil, "captcha assessment of the UserSignup '%s' annotated as %s", name, string(annotation))
+	return nil
+}
+
+// expectedAnnotation returns the annotation of the assessment according to the outcome of the signup,
+// or an empty annotation if the outcome is not known yet
+func (r *FeedbackReporter) expectedAnnotation(ctx gocontext.Context, signup *toolchainv1alpha1.UserSignup) (Annotation, error) {
+	if emailHash := signup.Labels[toolchainv1alpha1.UserSignupUserEmailHashLabelKey]; emailHash != "" {
+		bannedUsers := &toolchainv1alpha1.BannedUserList{}
+		if err := r.client.List(ctx, bannedUsers, client.InNamespace(r.client.Namespace),
+			client.MatchingLabels{toolchainv1alpha1.BannedUserEmailHashLabelKey: emailHash}); err != nil {
+			return "", err
+		}
+		if len(bannedUsers.Items) > 0 {
+			return AnnotationFraudulent, nil
+		}
+	}
+	if signup.Labels[toolchainv1alpha1.UserSignupStateLabelKey] == toolchainv1alpha1.UserSignupStateLabelValueApproved &&
+		!states.VerificationRequired(signup) {
+		return AnnotationLegitimate, nil
+	}
+	return "", nil
+}
+
+// record records the outcome of the annotation of the assessment on the UserSignup
+func (r *FeedbackReporter) record(name string, annotation Annotation, annotationErr error) error {
+	return signuppkg.PollUpdateSignup(nil, func() error {
+		signup := &toolchainv1alpha1.UserSignup{}
+		if err := r.client.Get(gocontext.TODO(), r.client.NamespacedName(name), signup); err != nil {
+			return err
+		}
+		if signup.Annotations == nil {
+			signup.Annotations = map[string]string{}
+		}
+		if annotationErr != nil {
+			signup.Annotations[AssessmentAnnotationErrorAnnotationKey] = annotationErr.Error()
+		} else {
+			signup.Annotations[AssessmentAnnotationAnnotationKey] = string(annotation)
+			delete(signup.Annotations, AssessmentAnnotationErrorAnnotationKey)
+		}
+		return r.client.Update(gocontext.TODO(), signup)
+	})
+}
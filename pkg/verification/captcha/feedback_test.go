@@ -0,0 +1,243 @@
+package captcha_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestFeedbackReporterSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunFeedbackReporterSuite(t *testing.T) {
+	suite.Run(t, &TestFeedbackReporterSuite{test.UnitTestSuite{}})
+}
+
+// fakeAnnotator records the annotations of the assessments, or returns the given error
+type fakeAnnotator struct {
+	err       error
+	annotated map[string]captcha.Annotation
+}
+
+func (a *fakeAnnotator) AnnotateAssessment(_ context.Context, _ configuration.RegistrationServiceConfig, assessmentID string, annotation captcha.Annotation) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.annotated == nil {
+		a.annotated = map[string]captcha.Annotation{}
+	}
+	a.annotated[assessmentID] = annotation
+	return nil
+}
+
+// approved sets the UserSignup state and the state label to `approved`
+func approved() testusersignup.Modifier {
+	return func(userSignup *toolchainv1alpha1.UserSignup) {
+		testusersignup.ApprovedManually()(userSignup)
+		testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)(userSignup)
+	}
+}
+
+func (s *TestFeedbackReporterSuite) TestReport() {
+	newReporter := func(annotator captcha.Annotator, objs ...client.Object) (*commontest.FakeClient, *captcha.FeedbackReporter) {
+		fakeClient := commontest.NewFakeClient(s.T(), objs...)
+		return fakeClient, captcha.NewFeedbackReporter(namespaced.NewClient(fakeClient, commontest.HostOperatorNs), annotator)
+	}
+	newSignup := func(modifiers ...testusersignup.Modifier) *toolchainv1alpha1.UserSignup {
+		return testusersignup.NewUserSignup(append([]testusersignup.Modifier{
+			testusersignup.WithEmail("johnny@kubesaw.io"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupCaptchaAssessmentIDAnnotationKey, "assessment-123"),
+		}, modifiers...)...)
+	}
+	assertAnnotations := func(fakeClient *commontest.FakeClient, name, expectedAnnotation, expectedError string) {
+		signup := &toolchainv1alpha1.UserSignup{}
+		err := fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: commontest.HostOperatorNs, Name: name}, signup)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), expectedAnnotation, signup.Annotations[captcha.AssessmentAnnotationAnnotationKey])
+		assert.Equal(s.T(), expectedError, signup.Annotations[captcha.AssessmentAnnotationErrorAnnotationKey])
+	}
+
+	s.Run("banned user is reported as fraudulent", func() {
+		// given
+		signup := newSignup(approved())
+		annotator := &fakeAnnotator{}
+		fakeClient, reporter := newReporter(annotator, signup, fake.NewBannedUser("banned", "johnny@kubesaw.io"))
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), map[string]captcha.Annotation{"assessment-123": captcha.AnnotationFraudulent}, annotator.annotated)
+		assertAnnotations(fakeClient, signup.Name, "FRAUDULENT", "")
+	})
+
+	s.Run("approved and verified user is reported as legitimate", func() {
+		// given
+		signup := newSignup(approved(),
+			testusersignup.WithAnnotation(captcha.AssessmentAnnotationErrorAnnotationKey, "previous error"))
+		annotator := &fakeAnnotator{}
+		fakeClient, reporter := newReporter(annotator, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), map[string]captcha.Annotation{"assessment-123": captcha.AnnotationLegitimate}, annotator.annotated)
+		assertAnnotations(fakeClient, signup.Name, "LEGITIMATE", "")
+	})
+
+	s.Run("outcome not known yet", func() {
+		for name, signup := range map[string]*toolchainv1alpha1.UserSignup{
+			"verification required": newSignup(testusersignup.VerificationRequiredAgo(time.Second)),
+			"not approved":          newSignup(),
+		} {
+			s.Run(name, func() {
+				// given
+				annotator := &fakeAnnotator{}
+				fakeClient, reporter := newReporter(annotator, signup)
+
+				// when
+				err := reporter.Report(context.TODO(), signup.Name)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Empty(s.T(), annotator.annotated)
+				assertAnnotations(fakeClient, signup.Name, "", "")
+			})
+		}
+	})
+
+	s.Run("already reported", func() {
+		// given
+		signup := newSignup(approved(),
+			testusersignup.WithAnnotation(captcha.AssessmentAnnotationAnnotationKey, "LEGITIMATE"))
+		annotator := &fakeAnnotator{}
+		_, reporter := newReporter(annotator, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), annotator.annotated)
+	})
+
+	s.Run("legitimate user banned afterwards", func() {
+		// given
+		signup := newSignup(approved(),
+			testusersignup.WithAnnotation(captcha.AssessmentAnnotationAnnotationKey, "LEGITIMATE"))
+		annotator := &fakeAnnotator{}
+		fakeClient, reporter := newReporter(annotator, signup, fake.NewBannedUser("banned", "johnny@kubesaw.io"))
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), map[string]captcha.Annotation{"assessment-123": captcha.AnnotationFraudulent}, annotator.annotated)
+		assertAnnotations(fakeClient, signup.Name, "FRAUDULENT", "")
+	})
+
+	s.Run("fraudulent user is never reported as legitimate", func() {
+		// given
+		signup := newSignup(approved(),
+			testusersignup.WithAnnotation(captcha.AssessmentAnnotationAnnotationKey, "FRAUDULENT"))
+		annotator := &fakeAnnotator{}
+		_, reporter := newReporter(annotator, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), annotator.annotated)
+	})
+
+	s.Run("no assessment", func() {
+		// given
+		signup := newSignup(approved(),
+			testusersignup.WithoutAnnotation(toolchainv1alpha1.UserSignupCaptchaAssessmentIDAnnotationKey))
+		annotator := &fakeAnnotator{}
+		_, reporter := newReporter(annotator, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), annotator.annotated)
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		_, reporter := newReporter(&fakeAnnotator{})
+
+		// when
+		err := reporter.Report(context.TODO(), "unknown")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("annotation not supported by the provider", func() {
+		// given
+		signup := newSignup(approved())
+		fakeClient, reporter := newReporter(&fakeAnnotator{err: captcha.ErrAnnotationNotSupported}, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		assertAnnotations(fakeClient, signup.Name, "", "")
+	})
+
+	s.Run("annotation failed", func() {
+		// given
+		signup := newSignup(approved())
+		fakeClient, reporter := newReporter(&fakeAnnotator{err: errors.New("service unavailable")}, signup)
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.EqualError(s.T(), err, "unable to annotate the captcha assessment of the UserSignup '"+signup.Name+"' as LEGITIMATE: service unavailable")
+		assertAnnotations(fakeClient, signup.Name, "", "service unavailable")
+	})
+
+	s.Run("result not recorded", func() {
+		// given
+		signup := newSignup(approved())
+		annotator := &fakeAnnotator{}
+		fakeClient, reporter := newReporter(annotator, signup)
+		fakeClient.MockUpdate = func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		err := reporter.Report(context.TODO(), signup.Name)
+
+		// then
+		require.EqualError(s.T(), err, "mock error")
+		// the annotation is retried with the same outcome
+		assert.Equal(s.T(), map[string]captcha.Annotation{"assessment-123": captcha.AnnotationLegitimate}, annotator.annotated)
+	})
+}
@@ -0,0 +1,45 @@
+package captcha
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+const hcaptchaVerifyURL = "https://api.hcaptcha.com/siteverify"
+
+// hcaptchaAssessor assesses the tokens with hCaptcha
+type hcaptchaAssessor struct {
+	httpClient *http.Client
+}
+
+func (a hcaptchaAssessor) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	verifyURL := cfg.Verification().CaptchaVerifyURL()
+	if verifyURL == "" {
+		verifyURL = hcaptchaVerifyURL
+	}
+	response, err := siteverify(ctx, a.httpClient, verifyURL, cfg.Verification().CaptchaSecretKey(), cfg.Verification().CaptchaSiteKey(), token)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return &Assessment{
+			Reasons: response.ErrorCodes,
+		}, nil
+	}
+
+	// the risk score is only provided by hCaptcha Enterprise, and it's the opposite of the score of the assessment
+	var score float32 = 1
+	if response.Score != nil {
+		score = 1 - *response.Score
+	}
+	log.Info(ctx, fmt.Sprintf("hCaptcha assessment score: %.1f", score))
+	return &Assessment{
+		Score:   score,
+		Reasons: response.ScoreReason,
+		Valid:   true,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package captcha
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+
+	recaptcha "cloud.google.com/go/recaptchaenterprise/v2/apiv1"
+	recaptchapb "cloud.google.com/go/recaptchaenterprise/v2/apiv1/recaptchaenterprisepb"
+	"google.golang.org/api/option"
+)
+
+// recaptchaSignupAction is the action name corresponding to the token
+const recaptchaSignupAction = "SIGNUP"
+
+// recaptchaAssessor assesses the tokens with Google reCAPTCHA Enterprise
+type recaptchaAssessor struct{}
+
+// newRecaptchaClient returns a new reCAPTCHA Enterprise client, authenticated with the service account
+// stored in the registration service secret (or with the default credentials if there is none)
+func newRecaptchaClient(ctx gocontext.Context, cfg configuration.RegistrationServiceConfig) (*recaptcha.Client, error) {
+	var opts []option.ClientOption
+	if credentials := cfg.Verification().CaptchaServiceAccountFileContents(); credentials != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(credentials)))
+	}
+	client, err := recaptcha.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating reCAPTCHA client: %w", err)
+	}
+	return client, nil
+}
+
+/*
+*
+* Creates an assessment to analyze the risk of a signup.
+*
+* @param ctx: The request context.
+* @param cfg: The Registration Service Configuration object.
+* @param token: The token obtained from the client on passing the reCAPTCHA Site Key.
+
+returns the assessment and nil if the assessment was completed, otherwise returns nil and the error.
+*/
+func (a recaptchaAssessor) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	client, err := newRecaptchaClient(gocontext.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	// Set the properties of the event to be tracked.
+	event := &recaptchapb.Event{
+		ExpectedAction: recaptchaSignupAction,
+		Token:          token,
+		SiteKey:        cfg.Verification().CaptchaSiteKey(),
+	}
+
+	assessment := &recaptchapb.Assessment{
+		Event: event,
+	}
+
+	// Build the assessment request.
+	request := &recaptchapb.CreateAssessmentRequest{
+		Assessment: assessment,
+		Parent:     fmt.Sprintf("projects/%s", cfg.Verification().CaptchaProjectID()),
+	}
+
+	response, err := client.CreateAssessment(
+		ctx,
+		request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reCAPTCHA assessment")
+	}
+
+	// Check if the token is valid.
+	if !response.GetTokenProperties().GetValid() {
+		return &Assessment{
+			ID:      response.GetName(),
+			Reasons: []string{response.GetTokenProperties().GetInvalidReason().String()},
+		}, nil
+	}
+
+	// Check if the expected action was executed.
+	if response.GetTokenProperties().GetAction() != recaptchaSignupAction {
+		return &Assessment{
+			ID:      response.GetName(),
+			Reasons: []string{"the action attribute in the reCAPTCHA token does not match the expected action to score"},
+		}, nil
+	}
+
+	// Get the risk score and the reason(s).
+	// For more information on interpreting the assessment,
+	// see: https://cloud.google.com/recaptcha-enterprise/docs/interpret-assessment
+	log.Info(ctx, fmt.Sprintf("reCAPTCHA assessment score: %.1f", response.GetRiskAnalysis().GetScore()))
+	reasons := make([]string, 0, len(response.GetRiskAnalysis().GetReasons()))
+	for _, reason := range response.GetRiskAnalysis().GetReasons() {
+		log.Info(ctx, fmt.Sprintf("Risk analysis reason: %s", reason.String()))
+		reasons = append(reasons, reason.String())
+	}
+	log.Info(ctx, fmt.Sprintf("Assessment Response: %+v", response))
+	return &Assessment{
+		ID:      response.GetName(),
+		Score:   response.GetRiskAnalysis().GetScore(),
+		Reasons: reasons,
+		Valid:   true,
+	}, nil
+}
+
+// AnnotateAssessment annotates the assessment with the given name (ie, `projects/{project}/assessments/{id}`)
+func (a recaptchaAssessor) AnnotateAssessment(ctx gocontext.Context, cfg configuration.RegistrationServiceConfig, assessmentID string, annotation Annotation) error {
+	value, found := recaptchapb.AnnotateAssessmentRequest_Annotation_value[string(annotation)]
+	if !found {
+		return fmt.Errorf("unknown annotation '%s'", annotation)
+	}
+	client, err := newRecaptchaClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	_, err = client.AnnotateAssessment(ctx, &recaptchapb.AnnotateAssessmentRequest{
+		Name:       assessmentID,
+		Annotation: recaptchapb.AnnotateAssessmentRequest_Annotation(value),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to annotate reCAPTCHA assessment: %w", err)
+	}
+	return nil
+}
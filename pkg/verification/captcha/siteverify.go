@@ -0,0 +1,60 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// siteverifyResponse is the response of the `siteverify` endpoints of hCaptcha and Turnstile
+type siteverifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+	// Action is the action of the widget (Turnstile only)
+	Action string `json:"action"`
+	// Score is the risk score, from 0.0 (no risk) to 1.0 (confirmed threat) (hCaptcha Enterprise only)
+	Score *float32 `json:"score"`
+	// ScoreReason are the reasons of the risk score (hCaptcha Enterprise only)
+	ScoreReason []string `json:"score_reason"`
+}
+
+// siteverify verifies the given token with the `siteverify` endpoint at the given URL
+func siteverify(ctx *gin.Context, httpClient *http.Client, verifyURL, secretKey, siteKey, token string) (*siteverifyResponse, error) {
+	if secretKey == "" {
+		return nil, errors.New("no captcha secret key configured")
+	}
+	form := url.Values{
+		"secret":   {secretKey},
+		"response": {token},
+	}
+	if siteKey != "" {
+		form.Set("sitekey", siteKey)
+	}
+	if ctx.Request != nil {
+		form.Set("remoteip", ctx.ClientIP())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the captcha token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to verify the captcha token: unexpected response status: %s", resp.Status)
+	}
+	result := &siteverifyResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to verify the captcha token: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package captcha
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/gin-gonic/gin"
+)
+
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// turnstileAssessor assesses the tokens with Cloudflare Turnstile.
+// Turnstile doesn't provide any score, so the valid tokens are given the maximum score.
+type turnstileAssessor struct {
+	httpClient *http.Client
+}
+
+func (a turnstileAssessor) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	verifyURL := cfg.Verification().CaptchaVerifyURL()
+	if verifyURL == "" {
+		verifyURL = turnstileVerifyURL
+	}
+	// the site key is not part of the verification request with Turnstile
+	response, err := siteverify(ctx, a.httpClient, verifyURL, cfg.Verification().CaptchaSecretKey(), "", token)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return &Assessment{
+			Reasons: response.ErrorCodes,
+		}, nil
+	}
+	// the action is optional, but it must be the signup action if it was set on the widget
+	if response.Action != "" && !strings.EqualFold(response.Action, recaptchaSignupAction) {
+		return &Assessment{
+			Reasons: []string{fmt.Sprintf("unexpected action '%s'", response.Action)},
+		}, nil
+	}
+	return &Assessment{
+		Score: 1,
+		Valid: true,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package delivery
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	signuppkg "github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StatusAnnotationKey is set on the UserSignups and contains the delivery status of the last verification code sent by SMS
+const StatusAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "verification-delivery-status"
+
+// MessageIDAnnotationKey is set on the UserSignups and contains the ID given by the provider to the last verification code
+// sent by SMS, so that the delivery status is only recorded for this message
+const MessageIDAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "verification-message-id"
+
+// delivery statuses of the verification codes
+const (
+	// StatusSent means that the provider accepted the message, and that its final status is not known yet
+	StatusSent = "sent"
+	// StatusDelivered means that the message was delivered to the phone of the user
+	StatusDelivered = "delivered"
+	// StatusFailed means that the message could not be delivered, eg. because the number is a landline or the carrier blocked it
+	StatusFailed = "failed"
+)
+
+// ErrInvalidSignature is returned when the signature of a callback doesn't match the one of the provider
+var ErrInvalidSignature = errors.New("invalid signature")
+
+var receivedStatusCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "sandbox_notification_delivery_status_total",
+	Help: "Number of final delivery statuses received from each provider, by status (delivered or failed)",
+}, []string{"provider", "status"})
+
+// Collectors returns the Prometheus collectors exposing the delivery statuses received from the providers
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{receivedStatusCounter}
+}
+
+// Recorder records the delivery status of the verification codes sent by SMS in the UserSignups
+type Recorder struct {
+	client namespaced.Client
+}
+
+// NewRecorder creates a new Recorder
+func NewRecorder(cl namespaced.Client) *Recorder {
+	return &Recorder{
+		client: cl,
+	}
+}
+
+// Record records the final delivery status of the message with the given ID, sent to the given phone number with the given provider,
+// in the UserSignup which is still waiting for the verification of this message. The statuses of the messages which were
+// replaced by a new verification code, or sent to the other UserSignups with the same phone number, are ignored.
+func (r *Recorder) Record(ctx *gin.Context, provider, e164PhoneNumber, messageID, status string) error {
+	receivedStatusCounter.WithLabelValues(provider, status).Inc()
+
+	userSignups := &toolchainv1alpha1.UserSignupList{}
+	if err := r.client.List(gocontext.TODO(), userSignups, client.InNamespace(r.client.Namespace),
+		client.MatchingLabels{toolchainv1alpha1.UserSignupUserPhoneHashLabelKey: hash.EncodeString(e164PhoneNumber)}); err != nil {
+		return fmt.Errorf("unable to list the UserSignups with the phone number: %w", err)
+	}
+
+	for _, userSignup := range userSignups.Items {
+		if !states.VerificationRequired(&userSignup) || userSignup.Annotations[MessageIDAnnotationKey] != messageID {
+			continue
+		}
+		name := userSignup.Name
+		err := signuppkg.PollUpdateSignup(ctx, func() error {
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			if err := r.client.Get(gocontext.TODO(), r.client.NamespacedName(name), userSignup); err != nil {
+				if apierrors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			}
+			// a new verification code may have been sent in the meantime
+			if userSignup.Annotations[MessageIDAnnotationKey] != messageID || userSignup.Annotations[StatusAnnotationKey] == status {
+				return nil
+			}
+			userSignup.Annotations[StatusAnnotationKey] = status
+			return r.client.Update(gocontext.TODO(), userSignup)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record the delivery status in the UserSignup '%s': %w", name, err)
+		}
+		log.Infof(ctx, "delivery status of the verification code of the UserSignup '%s': %s", name, status)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package delivery_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestDeliverySuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunDeliverySuite(t *testing.T) {
+	suite.Run(t, &TestDeliverySuite{test.UnitTestSuite{}})
+}
+
+func (s *TestDeliverySuite) TestRecord() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	phoneHash := hash.EncodeString("+441234567890")
+
+	s.Run("status recorded in the UserSignup waiting for the verification of the message", func() {
+		// given
+		waiting := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, phoneHash),
+			testusersignup.WithAnnotation(delivery.StatusAnnotationKey, delivery.StatusSent),
+			testusersignup.WithAnnotation(delivery.MessageIDAnnotationKey, "SM123"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		otherMessage := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jack@kubesaw"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, phoneHash),
+			testusersignup.WithAnnotation(delivery.StatusAnnotationKey, delivery.StatusSent),
+			testusersignup.WithAnnotation(delivery.MessageIDAnnotationKey, "SM456"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		verified := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jsmith@kubesaw"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, phoneHash))
+		otherNumber := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jane@kubesaw"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, hash.EncodeString("+611234567890")),
+			testusersignup.WithAnnotation(delivery.MessageIDAnnotationKey, "SM123"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient := commontest.NewFakeClient(s.T(), waiting, otherMessage, verified, otherNumber)
+		recorder := delivery.NewRecorder(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		err := recorder.Record(ctx, "twilio", "+441234567890", "SM123", delivery.StatusFailed)
+
+		// then
+		require.NoError(s.T(), err)
+		for userSignup, expected := range map[*toolchainv1alpha1.UserSignup]string{
+			waiting:      delivery.StatusFailed,
+			otherMessage: delivery.StatusSent,
+			verified:     "",
+			otherNumber:  "",
+		} {
+			actual := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(userSignup), actual))
+			assert.Equal(s.T(), expected, actual.Annotations[delivery.StatusAnnotationKey], "unexpected status for %s", userSignup.Name)
+		}
+	})
+
+	s.Run("error while listing the UserSignups", func() {
+		// given
+		fakeClient := commontest.NewFakeClient(s.T())
+		fakeClient.MockList = func(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+			return errors.New("mock error")
+		}
+		recorder := delivery.NewRecorder(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+
+		// when
+		err := recorder.Record(ctx, "twilio", "+441234567890", "SM123", delivery.StatusDelivered)
+
+		// then
+		require.EqualError(s.T(), err, "unable to list the UserSignups with the phone number: mock error")
+	})
+}
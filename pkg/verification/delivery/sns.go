@@ -0,0 +1,214 @@
+package delivery
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// types of the Amazon SNS messages
+const (
+	SNSTypeNotification             = "Notification"
+	SNSTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	SNSTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// snsHostMatcher matches the hosts of the Amazon SNS endpoints, which serve the signing certificates and the subscription URLs
+var snsHostMatcher = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// snsStatuses maps the statuses of the Amazon SNS SMS delivery logs to the delivery statuses
+var snsStatuses = map[string]string{
+	"SUCCESS": StatusDelivered,
+	"FAILURE": StatusFailed,
+}
+
+// SNSMessage is a message sent by Amazon SNS to an HTTP(S) subscription
+type SNSMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+}
+
+// stringToSign returns the string signed by Amazon SNS, which contains the fields of the message depending on its type
+func (m *SNSMessage) stringToSign() (string, error) {
+	var fields [][2]string
+	switch m.Type {
+	case SNSTypeNotification:
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [][2]string{{"Timestamp", m.Timestamp}, {"TopicArn", m.TopicArn}, {"Type", m.Type}}...)
+	case SNSTypeSubscriptionConfirmation, SNSTypeUnsubscribeConfirmation:
+		fields = [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}, {"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp}, {"Token", m.Token}, {"TopicArn", m.TopicArn}, {"Type", m.Type}}
+	default:
+		return "", fmt.Errorf("unsupported message type '%s'", m.Type)
+	}
+	b := &strings.Builder{}
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String(), nil
+}
+
+// SNSVerifier verifies the signatures of the messages sent by Amazon SNS, with the certificates downloaded from SNS
+type SNSVerifier struct {
+	HTTPClient *http.Client
+
+	mu    sync.RWMutex
+	certs map[string]*x509.Certificate
+}
+
+// NewSNSVerifier creates a new SNSVerifier which downloads the signing certificates with the given client
+func NewSNSVerifier(httpClient *http.Client) *SNSVerifier {
+	return &SNSVerifier{
+		HTTPClient: httpClient,
+		certs:      map[string]*x509.Certificate{},
+	}
+}
+
+// Verify returns ErrInvalidSignature if the message was not signed by Amazon SNS
+func (v *SNSVerifier) Verify(msg *SNSMessage) error {
+	var algorithm x509.SignatureAlgorithm
+	switch msg.SignatureVersion {
+	case "1":
+		algorithm = x509.SHA1WithRSA
+	case "2":
+		algorithm = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("%w: unsupported signature version '%s'", ErrInvalidSignature, msg.SignatureVersion)
+	}
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	toSign, err := msg.stringToSign()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	cert, err := v.certificate(msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	if err := cert.CheckSignature(algorithm, []byte(toSign), signature); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// ConfirmSubscription confirms the subscription of the endpoint to the topic of the given SubscriptionConfirmation message
+func (v *SNSVerifier) ConfirmSubscription(msg *SNSMessage) error {
+	if err := checkSNSURL(msg.SubscribeURL); err != nil {
+		return err
+	}
+	resp, err := v.HTTPClient.Get(msg.SubscribeURL)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to confirm the subscription, status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// certificate returns the certificate served at the given URL, which must be an Amazon SNS endpoint
+func (v *SNSVerifier) certificate(certURL string) (*x509.Certificate, error) {
+	if err := checkSNSURL(certURL); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	v.mu.RLock()
+	cert, found := v.certs[certURL]
+	v.mu.RUnlock()
+	if found {
+		return cert, nil
+	}
+
+	resp, err := v.HTTPClient.Get(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the signing certificate: %w", err)
+	}
+	defer drainAndClose(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download the signing certificate, status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the signing certificate: %w", err)
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("the signing certificate is not PEM encoded")
+	}
+	if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, fmt.Errorf("unable to parse the signing certificate: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.certs[certURL] = cert
+	return cert, nil
+}
+
+// drainAndClose reads the rest of the given response body before closing it, so that the connection can be reused
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64*1024))
+	_ = body.Close()
+}
+
+// checkSNSURL checks that the given URL is an HTTPS URL of an Amazon SNS endpoint
+func checkSNSURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || !snsHostMatcher.MatchString(u.Hostname()) {
+		return fmt.Errorf("'%s' is not an Amazon SNS URL", rawURL)
+	}
+	return nil
+}
+
+// snsDeliveryStatus is the SMS delivery status logged by Amazon SNS, and forwarded in the notifications of the topic
+type snsDeliveryStatus struct {
+	Notification struct {
+		MessageID string `json:"messageId"`
+	} `json:"notification"`
+	Delivery struct {
+		Destination string `json:"destination"`
+	} `json:"delivery"`
+	Status string `json:"status"`
+}
+
+// ParseSNSDeliveryStatus returns the phone number, the ID of the published message and the delivery status
+// of the SMS delivery log contained in the given notification message.
+func ParseSNSDeliveryStatus(message string) (string, string, string, error) {
+	status := &snsDeliveryStatus{}
+	if err := json.Unmarshal([]byte(message), status); err != nil {
+		return "", "", "", fmt.Errorf("invalid SMS delivery status: %w", err)
+	}
+	if status.Delivery.Destination == "" {
+		return "", "", "", errors.New("invalid SMS delivery status: missing destination")
+	}
+	if status.Notification.MessageID == "" {
+		return "", "", "", errors.New("invalid SMS delivery status: missing message ID")
+	}
+	return status.Delivery.Destination, status.Notification.MessageID, snsStatuses[status.Status], nil
+}
@@ -0,0 +1,239 @@
+package delivery_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const signingCertURL = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-0123456789.pem"
+
+// newSigningCert returns a self-signed certificate in PEM format and its private key
+func newSigningCert(t *testing.T) ([]byte, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key
+}
+
+// sign signs the notification message like Amazon SNS, with the given signature version
+func sign(t *testing.T, key *rsa.PrivateKey, msg *delivery.SNSMessage) {
+	var toSign string
+	if msg.Type == delivery.SNSTypeNotification {
+		toSign = "Message\n" + msg.Message + "\nMessageId\n" + msg.MessageID + "\nTimestamp\n" + msg.Timestamp +
+			"\nTopicArn\n" + msg.TopicArn + "\nType\n" + msg.Type + "\n"
+	} else {
+		toSign = "Message\n" + msg.Message + "\nMessageId\n" + msg.MessageID + "\nSubscribeURL\n" + msg.SubscribeURL +
+			"\nTimestamp\n" + msg.Timestamp + "\nToken\n" + msg.Token + "\nTopicArn\n" + msg.TopicArn + "\nType\n" + msg.Type + "\n"
+	}
+	var signature []byte
+	var err error
+	if msg.SignatureVersion == "1" {
+		digest := sha1.Sum([]byte(toSign)) // nolint:gosec
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, digest[:])
+	} else {
+		digest := sha256.Sum256([]byte(toSign))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	}
+	require.NoError(t, err)
+	msg.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+func newNotification(version string) *delivery.SNSMessage {
+	return &delivery.SNSMessage{
+		Type:             delivery.SNSTypeNotification,
+		MessageID:        "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:         "arn:aws:sns:us-east-1:123456789012:sms-delivery-status",
+		Message:          `{"notification":{"messageId":"6d7e2b3c"},"delivery":{"destination":"+441234567890"},"status":"FAILURE"}`,
+		Timestamp:        "2024-01-01T12:00:00.000Z",
+		SignatureVersion: version,
+		SigningCertURL:   signingCertURL,
+	}
+}
+
+func TestSNSVerifier(t *testing.T) {
+	certPEM, key := newSigningCert(t)
+	newVerifier := func() *delivery.SNSVerifier {
+		httpClient := &http.Client{Transport: &http.Transport{}}
+		gock.InterceptClient(httpClient)
+		gock.New("https://sns.us-east-1.amazonaws.com").
+			Get("/SimpleNotificationService-0123456789.pem").
+			Reply(http.StatusOK).
+			BodyString(string(certPEM))
+		return delivery.NewSNSVerifier(httpClient)
+	}
+
+	for _, version := range []string{"1", "2"} {
+		t.Run("valid signature version "+version, func(t *testing.T) {
+			// given
+			defer gock.Off()
+			verifier := newVerifier()
+			msg := newNotification(version)
+			sign(t, key, msg)
+
+			// when
+			err := verifier.Verify(msg)
+
+			// then
+			require.NoError(t, err)
+
+			t.Run("certificate is cached", func(t *testing.T) {
+				// when
+				err := verifier.Verify(msg)
+
+				// then
+				require.NoError(t, err)
+				assert.True(t, gock.IsDone())
+			})
+		})
+	}
+
+	t.Run("tampered message", func(t *testing.T) {
+		// given
+		defer gock.Off()
+		verifier := newVerifier()
+		msg := newNotification("2")
+		sign(t, key, msg)
+		msg.Message = `{"delivery":{"destination":"+611234567890"},"status":"SUCCESS"}`
+
+		// when
+		err := verifier.Verify(msg)
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("certificate not served by Amazon SNS", func(t *testing.T) {
+		// given
+		defer gock.Off()
+		verifier := newVerifier()
+		msg := newNotification("2")
+		msg.SigningCertURL = "https://sns.us-east-1.amazonaws.com.example.com/cert.pem"
+		sign(t, key, msg)
+
+		// when
+		err := verifier.Verify(msg)
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("unsupported signature version", func(t *testing.T) {
+		// given
+		defer gock.Off()
+		verifier := newVerifier()
+		msg := newNotification("3")
+
+		// when
+		err := verifier.Verify(msg)
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("subscription confirmed", func(t *testing.T) {
+		// given
+		defer gock.Off()
+		verifier := newVerifier()
+		msg := &delivery.SNSMessage{
+			Type:             delivery.SNSTypeSubscriptionConfirmation,
+			MessageID:        "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+			Token:            "token",
+			TopicArn:         "arn:aws:sns:us-east-1:123456789012:sms-delivery-status",
+			Message:          "You have chosen to subscribe to the topic",
+			SubscribeURL:     "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&Token=token",
+			Timestamp:        "2024-01-01T12:00:00.000Z",
+			SignatureVersion: "2",
+			SigningCertURL:   signingCertURL,
+		}
+		sign(t, key, msg)
+		gock.New("https://sns.us-east-1.amazonaws.com").
+			Get("/").
+			MatchParam("Action", "ConfirmSubscription").
+			Reply(http.StatusOK)
+
+		// when
+		err := verifier.Verify(msg)
+		require.NoError(t, err)
+		err = verifier.ConfirmSubscription(msg)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+
+		t.Run("subscription URL not served by Amazon SNS", func(t *testing.T) {
+			// given
+			msg.SubscribeURL = "https://example.com/?Action=ConfirmSubscription&Token=token"
+
+			// when
+			err := verifier.ConfirmSubscription(msg)
+
+			// then
+			require.EqualError(t, err, "'https://example.com/?Action=ConfirmSubscription&Token=token' is not an Amazon SNS URL")
+		})
+	})
+}
+
+func TestParseSNSDeliveryStatus(t *testing.T) {
+	t.Run("failure", func(t *testing.T) {
+		// when
+		phoneNumber, messageID, status, err := delivery.ParseSNSDeliveryStatus(newNotification("2").Message)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "+441234567890", phoneNumber)
+		assert.Equal(t, "6d7e2b3c", messageID)
+		assert.Equal(t, delivery.StatusFailed, status)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		// when
+		phoneNumber, messageID, status, err := delivery.ParseSNSDeliveryStatus(
+			`{"notification":{"messageId":"6d7e2b3c"},"delivery":{"destination":"+441234567890"},"status":"SUCCESS"}`)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "+441234567890", phoneNumber)
+		assert.Equal(t, "6d7e2b3c", messageID)
+		assert.Equal(t, delivery.StatusDelivered, status)
+	})
+
+	t.Run("missing destination", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseSNSDeliveryStatus(`{"notification":{"messageId":"6d7e2b3c"},"status":"SUCCESS"}`)
+
+		// then
+		require.EqualError(t, err, "invalid SMS delivery status: missing destination")
+	})
+
+	t.Run("missing message ID", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseSNSDeliveryStatus(`{"delivery":{"destination":"+441234567890"},"status":"SUCCESS"}`)
+
+		// then
+		require.EqualError(t, err, "invalid SMS delivery status: missing message ID")
+	})
+}
@@ -0,0 +1,39 @@
+package delivery
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+
+	"github.com/kevinburke/twilio-go"
+)
+
+// TwilioCallbackPath is the path of the Twilio status callback, relative to the configured callback URL
+const TwilioCallbackPath = "/twilio"
+
+// twilioStatuses maps the final statuses of the Twilio messages to the delivery statuses
+var twilioStatuses = map[string]string{
+	"delivered":   StatusDelivered,
+	"undelivered": StatusFailed,
+	"failed":      StatusFailed,
+}
+
+// ParseTwilioCallback verifies the `X-Twilio-Signature` of the status callback sent by Twilio to the given URL,
+// and returns the phone number of the message, its SID and its delivery status.
+// The status is empty if the message has not reached a final status yet (eg. `queued` or `sent`).
+func ParseTwilioCallback(req *http.Request, callbackURL, authToken string) (string, string, string, error) {
+	if err := req.ParseForm(); err != nil {
+		return "", "", "", err
+	}
+	// the signature is computed with the URL configured in the message, which may differ from the URL of the request
+	// when the registration service is behind a proxy
+	expected := twilio.GetExpectedTwilioSignature("", authToken, callbackURL, req.PostForm)
+	if callbackURL == "" || authToken == "" ||
+		!hmac.Equal([]byte(expected), []byte(req.Header.Get("X-Twilio-Signature"))) {
+		return "", "", "", ErrInvalidSignature
+	}
+	if req.PostForm.Get("MessageSid") == "" {
+		return "", "", "", errors.New("missing message SID")
+	}
+	return req.PostForm.Get("To"), req.PostForm.Get("MessageSid"), twilioStatuses[req.PostForm.Get("MessageStatus")], nil
+}
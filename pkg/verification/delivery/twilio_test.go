@@ -0,0 +1,84 @@
+package delivery_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+
+	"github.com/kevinburke/twilio-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTwilioCallback(t *testing.T) {
+	callbackURL := "https://registration.example.com/api/v1/signup/verification/delivery-status/twilio"
+	newRequest := func(messageSid, status, signature string) *http.Request {
+		form := url.Values{
+			"MessageSid":    {messageSid},
+			"MessageStatus": {status},
+			"To":            {"+441234567890"},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/signup/verification/delivery-status/twilio", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if signature == "" {
+			signature = twilio.GetExpectedTwilioSignature("", "auth-token", callbackURL, form)
+		}
+		req.Header.Set("X-Twilio-Signature", signature)
+		return req
+	}
+
+	for twilioStatus, expected := range map[string]string{
+		"delivered":   delivery.StatusDelivered,
+		"undelivered": delivery.StatusFailed,
+		"failed":      delivery.StatusFailed,
+		"sent":        "",
+		"queued":      "",
+	} {
+		t.Run(twilioStatus, func(t *testing.T) {
+			// when
+			phoneNumber, messageID, status, err := delivery.ParseTwilioCallback(newRequest("SM123", twilioStatus, ""), callbackURL, "auth-token")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "+441234567890", phoneNumber)
+			assert.Equal(t, "SM123", messageID)
+			assert.Equal(t, expected, status)
+		})
+	}
+
+	t.Run("invalid signature", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseTwilioCallback(newRequest("SM123", "failed", "invalid"), callbackURL, "auth-token")
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("signed with another token", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseTwilioCallback(newRequest("SM123", "failed", ""), callbackURL, "other-token")
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("callback URL not configured", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseTwilioCallback(newRequest("SM123", "failed", ""), "", "auth-token")
+
+		// then
+		require.ErrorIs(t, err, delivery.ErrInvalidSignature)
+	})
+
+	t.Run("missing message SID", func(t *testing.T) {
+		// when
+		_, _, _, err := delivery.ParseTwilioCallback(newRequest("", "failed", ""), callbackURL, "auth-token")
+
+		// then
+		require.EqualError(t, err, "missing message SID")
+	})
+}
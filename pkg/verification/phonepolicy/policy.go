@@ -0,0 +1,87 @@
+package phonepolicy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nyaruka/phonenumbers"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reasons of the rejections of the phone numbers
+const (
+	ReasonCountryNotAllowed = "country_not_allowed"
+	ReasonCountryDenied     = "country_denied"
+)
+
+// numberTypes are the names of the types of phone numbers which can be blocked in the configuration
+var numberTypes = map[phonenumbers.PhoneNumberType]string{
+	phonenumbers.FIXED_LINE:           "fixed_line",
+	phonenumbers.MOBILE:               "mobile",
+	phonenumbers.FIXED_LINE_OR_MOBILE: "fixed_line_or_mobile",
+	phonenumbers.TOLL_FREE:            "toll_free",
+	phonenumbers.PREMIUM_RATE:         "premium_rate",
+	phonenumbers.SHARED_COST:          "shared_cost",
+	phonenumbers.VOIP:                 "voip",
+	phonenumbers.PERSONAL_NUMBER:      "personal_number",
+	phonenumbers.PAGER:                "pager",
+	phonenumbers.UAN:                  "uan",
+	phonenumbers.VOICEMAIL:            "voicemail",
+	phonenumbers.UNKNOWN:              "unknown",
+}
+
+var rejectedNumbersCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "sandbox_phone_verification_rejected_numbers_total",
+	Help: "Number of phone numbers rejected for the verification, by country calling code and reason",
+}, []string{"country_code", "reason"})
+
+// Collectors returns the Prometheus collectors exposing the phone numbers rejected by the policy
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{rejectedNumbersCounter}
+}
+
+// Check returns a Forbidden error if the phone number cannot be used for the verification, because of its country
+// or of its type (eg. premium rate numbers), according to the configuration.
+func Check(ctx *gin.Context, number *phonenumbers.PhoneNumber) *crterrors.Error {
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	countryCode := strconv.Itoa(int(number.GetCountryCode()))
+	regionCode := phonenumbers.GetRegionCodeForNumber(number)
+
+	reject := func(reason, details string) *crterrors.Error {
+		log.Infof(ctx, "phone number with country code '%s' rejected: %s", countryCode, reason)
+		rejectedNumbersCounter.WithLabelValues(countryCode, reason).Inc()
+		return crterrors.NewForbiddenError("phone number not allowed", details)
+	}
+
+	if allowed := cfg.PhoneAllowedCountries(); len(allowed) > 0 && !matchesCountry(allowed, countryCode, regionCode) {
+		return reject(ReasonCountryNotAllowed, "phone numbers from this country cannot be used for the verification")
+	}
+	if matchesCountry(cfg.PhoneDeniedCountries(), countryCode, regionCode) {
+		return reject(ReasonCountryDenied, "phone numbers from this country cannot be used for the verification")
+	}
+
+	numberType := numberTypes[phonenumbers.GetNumberType(number)]
+	for _, blocked := range cfg.PhoneBlockedNumberTypes() {
+		if strings.EqualFold(blocked, numberType) {
+			return reject(numberType, fmt.Sprintf("%s numbers cannot be used for the verification", strings.ReplaceAll(numberType, "_", " ")))
+		}
+	}
+	return nil
+}
+
+// matchesCountry returns true if one of the given countries is the calling code or the region code of the phone number
+func matchesCountry(countries []string, countryCode, regionCode string) bool {
+	for _, country := range countries {
+		country = strings.TrimPrefix(country, "+")
+		if country == countryCode || strings.EqualFold(country, regionCode) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package phonepolicy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/verification/phonepolicy"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nyaruka/phonenumbers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestPolicySuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunPolicySuite(t *testing.T) {
+	suite.Run(t, &TestPolicySuite{test.UnitTestSuite{}})
+}
+
+// countRejections returns the number of phone numbers rejected with the given country code and reason, according to the metrics
+func (s *TestPolicySuite) countRejections(countryCode, reason string) float64 {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(phonepolicy.Collectors()...)
+	families, err := reg.Gather()
+	require.NoError(s.T(), err)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["country_code"] == countryCode && labels["reason"] == reason {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func (s *TestPolicySuite) TestCheck() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	parse := func(number string) *phonenumbers.PhoneNumber {
+		n, err := phonenumbers.Parse(number, "")
+		require.NoError(s.T(), err)
+		return n
+	}
+
+	s.Run("default policy", func() {
+		// given
+		s.SetSettings(map[string]string{})
+
+		s.Run("mobile number allowed", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+447400123456"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+
+		s.Run("premium rate number allowed", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+449098765432"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+
+		s.Run("voip number allowed", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+445612345678"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+	})
+
+	s.Run("blocked number types", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.phone.blockedNumberTypes": "premium_rate, voip, TOLL_FREE",
+		})
+
+		s.Run("premium rate number", func() {
+			// given
+			before := s.countRejections("44", "premium_rate")
+
+			// when
+			err := phonepolicy.Check(ctx, parse("+449098765432"))
+
+			// then
+			require.NotNil(s.T(), err)
+			assert.Equal(s.T(), http.StatusForbidden, err.Code)
+			require.EqualError(s.T(), err, "phone number not allowed: premium rate numbers cannot be used for the verification")
+			assert.InDelta(s.T(), before+1, s.countRejections("44", "premium_rate"), 0.01)
+		})
+
+		for number, reason := range map[string]string{
+			"+445612345678": "voip number",
+			"+18002345678":  "toll free number",
+		} {
+			s.Run(reason, func() {
+				// when
+				err := phonepolicy.Check(ctx, parse(number))
+
+				// then
+				require.EqualError(s.T(), err, "phone number not allowed: "+reason+"s cannot be used for the verification")
+			})
+		}
+
+		s.Run("mobile number allowed", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+447400123456"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+	})
+
+	s.Run("allowed countries", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.phone.allowedCountries": "+44,US",
+		})
+
+		s.Run("allowed by country code", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+447400123456"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+
+		s.Run("allowed by region code", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+12015550123"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+
+		s.Run("region of the same country code not allowed", func() {
+			// given
+			before := s.countRejections("1", phonepolicy.ReasonCountryNotAllowed)
+
+			// when
+			err := phonepolicy.Check(ctx, parse("+18762345678"))
+
+			// then
+			require.EqualError(s.T(), err, "phone number not allowed: phone numbers from this country cannot be used for the verification")
+			assert.InDelta(s.T(), before+1, s.countRejections("1", phonepolicy.ReasonCountryNotAllowed), 0.01)
+		})
+
+		s.Run("country not allowed", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+33612345678"))
+
+			// then
+			require.EqualError(s.T(), err, "phone number not allowed: phone numbers from this country cannot be used for the verification")
+		})
+	})
+
+	s.Run("denied countries", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.phone.deniedCountries": "33,jm",
+		})
+
+		for name, number := range map[string]string{
+			"denied by country code": "+33612345678",
+			"denied by region code":  "+18762345678",
+		} {
+			s.Run(name, func() {
+				// when
+				err := phonepolicy.Check(ctx, parse(number))
+
+				// then
+				require.NotNil(s.T(), err)
+				assert.Equal(s.T(), http.StatusForbidden, err.Code)
+				assert.Equal(s.T(), "phone number not allowed", err.Message)
+			})
+		}
+
+		s.Run("country not denied", func() {
+			// when
+			err := phonepolicy.Check(ctx, parse("+447400123456"))
+
+			// then
+			assert.Nil(s.T(), err)
+		})
+	})
+}
@@ -0,0 +1,380 @@
+package ratelimit
+
+import (
+	gocontext "context"
+	"strconv"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+
+	"github.com/gin-gonic/gin"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DimensionLabelKey is set on the Leases holding the rate limit counters, and contains the dimension of the counter
+	DimensionLabelKey = toolchainv1alpha1.LabelKeyPrefix + "verification-rate-limit"
+	// CountAnnotationKey is set on the Leases holding the rate limit counters, and contains the number of requests in the current window
+	CountAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "verification-rate-limit-count"
+
+	leaseNamePrefix = "verification-rate-limit-"
+	cleanupInterval = 10 * time.Minute
+)
+
+// dimensions of the rate limits
+const (
+	DimensionClientIP    = "client-ip"
+	DimensionPhoneNumber = "phone-number"
+	DimensionCountryCode = "country-code"
+	DimensionGlobal      = "global"
+)
+
+// Key identifies a rate limit counter, eg. the counter of the requests made from a given IP address
+type Key struct {
+	Dimension string
+	Value     string
+}
+
+// ClientIP returns the key of the counter of the requests made from the IP address of the client
+func ClientIP(ctx *gin.Context) Key {
+	key := Key{Dimension: DimensionClientIP}
+	if ctx.Request != nil {
+		key.Value = ctx.ClientIP()
+	}
+	return key
+}
+
+// PhoneNumber returns the key of the counter of the requests made for the given phone number
+func PhoneNumber(e164PhoneNumber string) Key {
+	return Key{Dimension: DimensionPhoneNumber, Value: hash.EncodeString(e164PhoneNumber)}
+}
+
+// CountryCode returns the key of the counter of the requests made for the phone numbers of the given country code
+func CountryCode(countryCode string) Key {
+	return Key{Dimension: DimensionCountryCode, Value: countryCode}
+}
+
+// Global returns the key of the counter of all the requests
+func Global() Key {
+	return Key{Dimension: DimensionGlobal}
+}
+
+// leaseName returns the name of the Lease holding the counter of the key
+func (k Key) leaseName() string {
+	if k.Dimension == DimensionGlobal {
+		return leaseNamePrefix + DimensionGlobal
+	}
+	return leaseNamePrefix + k.Dimension + "-" + hash.EncodeString(k.Value)
+}
+
+// limit returns the maximum number of requests allowed in a window for the dimension of the key, and the duration of the window.
+// A maximum of 0 means that the requests are not limited.
+func (k Key) limit(cfg configuration.VerificationConfig) (int, time.Duration) {
+	switch k.Dimension {
+	case DimensionClientIP:
+		return cfg.RateLimitClientIP(), cfg.RateLimitWindow()
+	case DimensionPhoneNumber:
+		return cfg.RateLimitPhoneNumber(), cfg.RateLimitWindow()
+	case DimensionCountryCode:
+		return cfg.RateLimitCountryCode(), cfg.RateLimitWindow()
+	case DimensionGlobal:
+		return cfg.RateLimitGlobalPerMinute(), time.Minute
+	}
+	return 0, 0
+}
+
+// Limiter limits the number of verification requests in fixed windows, per client IP address, phone number, country code
+// and globally, according to the configuration. The counters are stored in Leases in the host-operator namespace,
+// so that they are shared by all the replicas of the registration service. The number of counters is bounded: when the
+// maximum is reached, the requests which need a new counter are rejected until the windows of existing counters end.
+// The Leases must be read directly from the API server (and not from the cache of the client), so that the counters are
+// up to date.
+type Limiter struct {
+	client namespaced.Client
+}
+
+// NewLimiter creates a new Limiter
+func NewLimiter(cl namespaced.Client) *Limiter {
+	return &Limiter{
+		client: cl,
+	}
+}
+
+// Allow counts a request for each of the given keys, or returns a TooManyRequests error with the delay after which
+// the request can be retried if any of the limits has been reached. A rejected request is not counted.
+func (l *Limiter) Allow(ctx *gin.Context, keys ...Key) error {
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	now := time.Now()
+
+	// check all the limits before counting the request, so that a request rejected by one of the limits doesn't count towards the others
+	var limitedKeys []Key
+	var retryAfter time.Duration
+	newCounters := 0
+	for _, key := range keys {
+		maxRequests, window := key.limit(cfg)
+		if maxRequests <= 0 || (key.Dimension != DimensionGlobal && key.Value == "") {
+			continue
+		}
+		lease, err := l.getLease(key)
+		if err != nil {
+			log.Error(ctx, err, "unable to check the verification rate limit")
+			return crterrors.NewInternalError(err, "error while checking the rate limit")
+		}
+		if delay := remainingDelay(lease, maxRequests, window, now); delay > retryAfter {
+			log.Infof(ctx, "verification rate limit reached for the %s", key.Dimension)
+			retryAfter = delay
+		}
+		if lease == nil {
+			newCounters++
+		}
+		limitedKeys = append(limitedKeys, key)
+	}
+
+	if maxCounters := cfg.RateLimitMaxCounters(); retryAfter == 0 && newCounters > 0 && maxCounters > 0 {
+		delay, err := l.waitForCapacity(gocontext.TODO(), newCounters, maxCounters, now)
+		if err != nil {
+			log.Error(ctx, err, "unable to check the number of verification rate limit counters")
+			return crterrors.NewInternalError(err, "error while checking the rate limit")
+		}
+		if delay > 0 {
+			log.Infof(ctx, "maximum number of verification rate limit counters reached")
+			retryAfter = delay
+		}
+	}
+
+	taken := make([]takenCounter, 0, len(limitedKeys))
+	for _, key := range limitedKeys {
+		if retryAfter > 0 {
+			break
+		}
+		maxRequests, window := key.limit(cfg)
+		start, delay, err := l.take(key, maxRequests, window, now)
+		if err != nil {
+			log.Error(ctx, err, "unable to update the verification rate limit")
+			l.releaseAll(ctx, taken)
+			return crterrors.NewInternalError(err, "error while checking the rate limit")
+		}
+		if delay > 0 {
+			// another request reached the limit in the meantime
+			log.Infof(ctx, "verification rate limit reached for the %s", key.Dimension)
+			l.releaseAll(ctx, taken)
+		} else {
+			taken = append(taken, takenCounter{key: key, window: window, start: start})
+		}
+		retryAfter = delay
+	}
+
+	if retryAfter > 0 {
+		return crterrors.NewTooManyRequestsError("too many verification requests", "please try again later").WithRetryAfter(retryAfter)
+	}
+	return nil
+}
+
+// takenCounter is a counter incremented for a request, in the window starting at the given time
+type takenCounter struct {
+	key    Key
+	window time.Duration
+	start  time.Time
+}
+
+// take increments the counter of the key and returns the start of its current window, unless the limit has been reached
+// in which case it returns the delay until the end of the current window
+func (l *Limiter) take(key Key, maxRequests int, window time.Duration, now time.Time) (time.Time, time.Duration, error) {
+	var start time.Time
+	var retryAfter time.Duration
+	err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+		// another replica may have counted a request in the meantime
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, func() error {
+		lease, err := l.getLease(key)
+		if err != nil {
+			return err
+		}
+		if retryAfter = remainingDelay(lease, maxRequests, window, now); retryAfter > 0 {
+			return nil
+		}
+		start = now
+		if lease == nil {
+			lease = &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      key.leaseName(),
+					Namespace: l.client.Namespace,
+					Labels: map[string]string{
+						DimensionLabelKey: key.Dimension,
+					},
+				},
+			}
+			setWindow(lease, window, now, 1)
+			return l.client.Create(gocontext.TODO(), lease)
+		}
+		count := 0
+		if start, count = currentWindow(lease, window, now); count == 0 {
+			start = now
+		}
+		setWindow(lease, window, start, count+1)
+		return l.client.Update(gocontext.TODO(), lease)
+	})
+	return start, retryAfter, err
+}
+
+// releaseAll decrements the given counters, which were incremented for a request which is finally rejected,
+// so that the request is not counted. The counters which can't be decremented are logged.
+func (l *Limiter) releaseAll(ctx *gin.Context, taken []takenCounter) {
+	for _, counter := range taken {
+		if err := l.release(counter); err != nil {
+			log.Error(ctx, err, "unable to release the verification rate limit for the "+counter.key.Dimension)
+		}
+	}
+}
+
+// release decrements the counter of the key, if it is still in the window in which it was incremented
+func (l *Limiter) release(counter takenCounter) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := l.getLease(counter.key)
+		if err != nil || lease == nil {
+			return err
+		}
+		start, count := currentWindow(lease, counter.window, time.Now())
+		// the start of the window is stored with a precision of a microsecond
+		if count == 0 || !start.Truncate(time.Microsecond).Equal(counter.start.Truncate(time.Microsecond)) {
+			return nil
+		}
+		setWindow(lease, counter.window, start, count-1)
+		return l.client.Update(gocontext.TODO(), lease)
+	})
+}
+
+// getLease returns the Lease holding the counter of the key, or nil if it doesn't exist
+func (l *Limiter) getLease(key Key) (*coordinationv1.Lease, error) {
+	lease := &coordinationv1.Lease{}
+	if err := l.client.Get(gocontext.TODO(), l.client.NamespacedName(key.leaseName()), lease); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lease, nil
+}
+
+// StartCleanup periodically deletes the Leases of the windows which have ended, until the context is done
+func (l *Limiter) StartCleanup(ctx gocontext.Context) {
+	go wait.UntilWithContext(ctx, func(ctx gocontext.Context) {
+		if err := l.DeleteExpired(ctx); err != nil {
+			log.Error(nil, err, "unable to delete the expired verification rate limit counters")
+		}
+	}, cleanupInterval)
+}
+
+// DeleteExpired deletes the Leases of the windows which have ended
+func (l *Limiter) DeleteExpired(ctx gocontext.Context) error {
+	leases, err := l.listLeases(ctx)
+	if err != nil {
+		return err
+	}
+	_, _, err = l.deleteExpired(ctx, leases, time.Now())
+	return err
+}
+
+// waitForCapacity returns 0 if the given number of counters can be created without exceeding the maximum number of counters,
+// after deleting the Leases of the windows which have ended if needed. Otherwise, it returns the delay until the end of
+// the first window of the remaining Leases.
+func (l *Limiter) waitForCapacity(ctx gocontext.Context, newCounters, maxCounters int, now time.Time) (time.Duration, error) {
+	leases, err := l.listLeases(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(leases)+newCounters <= maxCounters {
+		return 0, nil
+	}
+	remaining, firstEnd, err := l.deleteExpired(ctx, leases, now)
+	if err != nil || remaining+newCounters <= maxCounters {
+		return 0, err
+	}
+	return firstEnd.Sub(now), nil
+}
+
+// listLeases returns the Leases holding the rate limit counters
+func (l *Limiter) listLeases(ctx gocontext.Context) ([]coordinationv1.Lease, error) {
+	leases := &coordinationv1.LeaseList{}
+	if err := l.client.List(ctx, leases, client.InNamespace(l.client.Namespace), client.HasLabels{DimensionLabelKey}); err != nil {
+		return nil, err
+	}
+	return leases.Items, nil
+}
+
+// deleteExpired deletes the given Leases whose window has ended, and returns the number of remaining Leases
+// and the end of the first window of the remaining Leases
+func (l *Limiter) deleteExpired(ctx gocontext.Context, leases []coordinationv1.Lease, now time.Time) (int, time.Time, error) {
+	remaining := 0
+	var firstEnd time.Time
+	for i := range leases {
+		lease := &leases[i]
+		window := windowOf(lease)
+		if start, count := currentWindow(lease, window, now); count > 0 {
+			remaining++
+			if end := start.Add(window); firstEnd.IsZero() || end.Before(firstEnd) {
+				firstEnd = end
+			}
+			continue
+		}
+		if err := l.client.Delete(ctx, lease); err != nil && !apierrors.IsNotFound(err) {
+			return 0, time.Time{}, err
+		}
+	}
+	return remaining, firstEnd, nil
+}
+
+// remainingDelay returns the delay until the end of the current window if the limit has been reached, or 0 otherwise
+func remainingDelay(lease *coordinationv1.Lease, maxRequests int, window time.Duration, now time.Time) time.Duration {
+	if lease == nil {
+		return 0
+	}
+	start, count := currentWindow(lease, window, now)
+	if count < maxRequests {
+		return 0
+	}
+	return start.Add(window).Sub(now)
+}
+
+// currentWindow returns the start of the window recorded in the Lease and its number of requests,
+// or a count of 0 if the window has ended
+func currentWindow(lease *coordinationv1.Lease, window time.Duration, now time.Time) (time.Time, int) {
+	if lease.Spec.AcquireTime == nil || !now.Before(lease.Spec.AcquireTime.Add(window)) {
+		return now, 0
+	}
+	count, err := strconv.Atoi(lease.Annotations[CountAnnotationKey])
+	if err != nil {
+		return now, 0
+	}
+	return lease.Spec.AcquireTime.Time, count
+}
+
+// setWindow records the window starting at the given time, and its number of requests in the Lease
+func setWindow(lease *coordinationv1.Lease, window time.Duration, start time.Time, count int) {
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[CountAnnotationKey] = strconv.Itoa(count)
+	windowSeconds := int32(window.Seconds())
+	lease.Spec.LeaseDurationSeconds = &windowSeconds
+	lease.Spec.AcquireTime = &metav1.MicroTime{Time: start}
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+}
+
+// windowOf returns the duration of the window recorded in the Lease
+func windowOf(lease *coordinationv1.Lease) time.Duration {
+	if lease.Spec.LeaseDurationSeconds == nil {
+		return 0
+	}
+	return time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+}
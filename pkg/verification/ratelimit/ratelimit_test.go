@@ -0,0 +1,393 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
+	"github.com/codeready-toolchain/registration-service/test"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestRateLimitSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunRateLimitSuite(t *testing.T) {
+	suite.Run(t, &TestRateLimitSuite{test.UnitTestSuite{}})
+}
+
+func newContext(remoteAddr string) *gin.Context {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPut, "/api/v1/signup/verification", nil)
+	ctx.Request.RemoteAddr = remoteAddr
+	return ctx
+}
+
+// requireTooManyRequests checks that the error is a TooManyRequests error to retry within the given window
+func requireTooManyRequests(t *testing.T, err error, window time.Duration) {
+	e := &crterrors.Error{}
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusTooManyRequests, e.Code)
+	assert.Equal(t, "too many verification requests: please try again later", e.Error())
+	assert.Greater(t, e.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, e.RetryAfter, window)
+}
+
+// counts returns the number of requests recorded in the rate limit counters, by dimension
+func (s *TestRateLimitSuite) counts(fakeClient *commontest.FakeClient) map[string]string {
+	leases := &coordinationv1.LeaseList{}
+	require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+	counts := map[string]string{}
+	for _, lease := range leases.Items {
+		counts[lease.Labels[ratelimit.DimensionLabelKey]] = lease.Annotations[ratelimit.CountAnnotationKey]
+	}
+	return counts
+}
+
+func (s *TestRateLimitSuite) TestAllow() {
+	newLimiter := func() (*commontest.FakeClient, *ratelimit.Limiter) {
+		fakeClient := commontest.NewFakeClient(s.T())
+		return fakeClient, ratelimit.NewLimiter(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	}
+
+	s.Run("not limited by default", func() {
+		// given
+		s.SetSettings(map[string]string{})
+		fakeClient, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+
+		for i := 0; i < 10; i++ {
+			// when
+			err := limiter.Allow(ctx, ratelimit.ClientIP(ctx), ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44"), ratelimit.Global())
+
+			// then
+			require.NoError(s.T(), err)
+		}
+		leases := &coordinationv1.LeaseList{}
+		require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+		assert.Empty(s.T(), leases.Items)
+	})
+
+	s.Run("limited by client IP", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.clientIP": "2",
+			"verification.rateLimit.window":   "30m",
+		})
+		_, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		otherCtx := newContext("10.0.0.2:1234")
+
+		// when
+		err1 := limiter.Allow(ctx, ratelimit.ClientIP(ctx))
+		err2 := limiter.Allow(ctx, ratelimit.ClientIP(ctx))
+		err3 := limiter.Allow(ctx, ratelimit.ClientIP(ctx))
+		otherErr := limiter.Allow(otherCtx, ratelimit.ClientIP(otherCtx))
+
+		// then
+		require.NoError(s.T(), err1)
+		require.NoError(s.T(), err2)
+		requireTooManyRequests(s.T(), err3, 30*time.Minute)
+		require.NoError(s.T(), otherErr)
+	})
+
+	s.Run("limited by phone number and country code", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.phoneNumber": "1",
+			"verification.rateLimit.countryCode": "2",
+		})
+		_, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+
+		// when
+		err1 := limiter.Allow(ctx, ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44"))
+		err2 := limiter.Allow(ctx, ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44"))
+		err3 := limiter.Allow(ctx, ratelimit.PhoneNumber("+440987654321"), ratelimit.CountryCode("44"))
+		err4 := limiter.Allow(ctx, ratelimit.PhoneNumber("+441111111111"), ratelimit.CountryCode("44"))
+		err5 := limiter.Allow(ctx, ratelimit.PhoneNumber("+611234567890"), ratelimit.CountryCode("61"))
+
+		// then
+		require.NoError(s.T(), err1)
+		requireTooManyRequests(s.T(), err2, time.Hour)
+		// the request rejected because of the phone number was not counted for the country code
+		require.NoError(s.T(), err3)
+		requireTooManyRequests(s.T(), err4, time.Hour)
+		require.NoError(s.T(), err5)
+	})
+
+	s.Run("limited globally", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.globalPerMinute": "1",
+		})
+		_, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		otherCtx := newContext("10.0.0.2:1234")
+
+		// when
+		err1 := limiter.Allow(ctx, ratelimit.ClientIP(ctx), ratelimit.Global())
+		err2 := limiter.Allow(otherCtx, ratelimit.ClientIP(otherCtx), ratelimit.Global())
+
+		// then
+		require.NoError(s.T(), err1)
+		requireTooManyRequests(s.T(), err2, time.Minute)
+	})
+
+	s.Run("new window", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.countryCode": "1",
+		})
+		fakeClient, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		require.NoError(s.T(), limiter.Allow(ctx, ratelimit.CountryCode("44")))
+		requireTooManyRequests(s.T(), limiter.Allow(ctx, ratelimit.CountryCode("44")), time.Hour)
+
+		// move the start of the window to the past
+		leases := &coordinationv1.LeaseList{}
+		require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+		require.Len(s.T(), leases.Items, 1)
+		lease := &leases.Items[0]
+		assert.Equal(s.T(), ratelimit.DimensionCountryCode, lease.Labels[ratelimit.DimensionLabelKey])
+		assert.Equal(s.T(), "1", lease.Annotations[ratelimit.CountAnnotationKey])
+		lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-61 * time.Minute)}
+		require.NoError(s.T(), fakeClient.Update(context.TODO(), lease))
+
+		// when
+		err := limiter.Allow(ctx, ratelimit.CountryCode("44"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("limited number of counters", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.clientIP":    "10",
+			"verification.rateLimit.window":      "30m",
+			"verification.rateLimit.maxCounters": "2",
+		})
+		fakeClient, limiter := newLimiter()
+		ctx1 := newContext("10.0.0.1:1234")
+		ctx2 := newContext("10.0.0.2:1234")
+		ctx3 := newContext("10.0.0.3:1234")
+		require.NoError(s.T(), limiter.Allow(ctx1, ratelimit.ClientIP(ctx1)))
+		require.NoError(s.T(), limiter.Allow(ctx2, ratelimit.ClientIP(ctx2)))
+
+		s.Run("new counter rejected", func() {
+			// when
+			err := limiter.Allow(ctx3, ratelimit.ClientIP(ctx3))
+
+			// then
+			requireTooManyRequests(s.T(), err, 30*time.Minute)
+			leases := &coordinationv1.LeaseList{}
+			require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+			assert.Len(s.T(), leases.Items, 2)
+		})
+
+		s.Run("existing counter still used", func() {
+			// when
+			err := limiter.Allow(ctx1, ratelimit.ClientIP(ctx1))
+
+			// then
+			require.NoError(s.T(), err)
+		})
+
+		s.Run("new counter created after the expired ones are deleted", func() {
+			// given
+			leases := &coordinationv1.LeaseList{}
+			require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+			lease := &leases.Items[0]
+			lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-31 * time.Minute)}
+			require.NoError(s.T(), fakeClient.Update(context.TODO(), lease))
+
+			// when
+			err := limiter.Allow(ctx3, ratelimit.ClientIP(ctx3))
+
+			// then
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+			assert.Len(s.T(), leases.Items, 2)
+		})
+	})
+
+	s.Run("no client IP", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.clientIP": "1",
+		})
+		_, limiter := newLimiter()
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err1 := limiter.Allow(ctx, ratelimit.ClientIP(ctx))
+		err2 := limiter.Allow(ctx, ratelimit.ClientIP(ctx))
+
+		// then
+		require.NoError(s.T(), err1)
+		require.NoError(s.T(), err2)
+	})
+
+	s.Run("concurrent update", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.countryCode": "2",
+		})
+		fakeClient, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		require.NoError(s.T(), limiter.Allow(ctx, ratelimit.CountryCode("44")))
+		conflicts := 0
+		fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if conflicts == 0 {
+				conflicts++
+				return apierrors.NewConflict(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, obj.GetName(), errors.New("modified"))
+			}
+			return fakeClient.Client.Update(ctx, obj, opts...)
+		}
+
+		// when
+		err := limiter.Allow(ctx, ratelimit.CountryCode("44"))
+
+		// then
+		require.NoError(s.T(), err)
+		leases := &coordinationv1.LeaseList{}
+		require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+		require.Len(s.T(), leases.Items, 1)
+		assert.Equal(s.T(), "2", leases.Items[0].Annotations[ratelimit.CountAnnotationKey])
+	})
+
+	s.Run("error while updating a counter", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.phoneNumber": "2",
+			"verification.rateLimit.countryCode": "2",
+		})
+		fakeClient, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		require.NoError(s.T(), limiter.Allow(ctx, ratelimit.CountryCode("44")))
+		fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if obj.(*coordinationv1.Lease).Labels[ratelimit.DimensionLabelKey] == ratelimit.DimensionCountryCode {
+				return errors.New("mock error")
+			}
+			return fakeClient.Client.Update(ctx, obj, opts...)
+		}
+
+		// when
+		err := limiter.Allow(ctx, ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44"))
+
+		// then
+		require.EqualError(s.T(), err, "mock error: error while checking the rate limit")
+		// the request was not counted for the phone number
+		assert.Equal(s.T(), map[string]string{
+			ratelimit.DimensionPhoneNumber: "0",
+			ratelimit.DimensionCountryCode: "1",
+		}, s.counts(fakeClient))
+	})
+
+	s.Run("limit reached by another request in the meantime", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.phoneNumber": "2",
+			"verification.rateLimit.countryCode": "2",
+		})
+		fakeClient, limiter := newLimiter()
+		ctx := newContext("10.0.0.1:1234")
+		require.NoError(s.T(), limiter.Allow(ctx, ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44")))
+		// another request is counted for the country code after the limits were checked
+		fakeClient.MockUpdate = func(updateCtx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+			if obj.(*coordinationv1.Lease).Labels[ratelimit.DimensionLabelKey] == ratelimit.DimensionPhoneNumber {
+				fakeClient.MockUpdate = nil
+				require.NoError(s.T(), limiter.Allow(ctx, ratelimit.CountryCode("44")))
+			}
+			return fakeClient.Client.Update(updateCtx, obj, opts...)
+		}
+
+		// when
+		err := limiter.Allow(ctx, ratelimit.PhoneNumber("+441234567890"), ratelimit.CountryCode("44"))
+
+		// then
+		requireTooManyRequests(s.T(), err, time.Hour)
+		// the rejected request was not counted for the phone number
+		assert.Equal(s.T(), map[string]string{
+			ratelimit.DimensionPhoneNumber: "1",
+			ratelimit.DimensionCountryCode: "2",
+		}, s.counts(fakeClient))
+	})
+
+	s.Run("error while reading the counter", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.rateLimit.globalPerMinute": "10",
+		})
+		fakeClient, limiter := newLimiter()
+		fakeClient.MockGet = func(_ context.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+			return errors.New("mock error")
+		}
+
+		// when
+		err := limiter.Allow(newContext("10.0.0.1:1234"), ratelimit.Global())
+
+		// then
+		require.EqualError(s.T(), err, "mock error: error while checking the rate limit")
+	})
+}
+
+func (s *TestRateLimitSuite) TestDeleteExpired() {
+	// given
+	s.SetSettings(map[string]string{
+		"verification.rateLimit.clientIP":        "10",
+		"verification.rateLimit.globalPerMinute": "10",
+	})
+	fakeClient := commontest.NewFakeClient(s.T(), &coordinationv1.Lease{
+		// a Lease which is not used for the rate limits
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "leader-election",
+			Namespace: commontest.HostOperatorNs,
+		},
+	})
+	limiter := ratelimit.NewLimiter(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	ctx := newContext("10.0.0.1:1234")
+	require.NoError(s.T(), limiter.Allow(ctx, ratelimit.ClientIP(ctx), ratelimit.Global()))
+
+	// end the window of the global counter
+	lease := &coordinationv1.Lease{}
+	require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: commontest.HostOperatorNs, Name: "verification-rate-limit-global"}, lease))
+	lease.Spec.AcquireTime = &metav1.MicroTime{Time: time.Now().Add(-2 * time.Minute)}
+	require.NoError(s.T(), fakeClient.Update(context.TODO(), lease))
+
+	// when
+	err := limiter.DeleteExpired(context.TODO())
+
+	// then
+	require.NoError(s.T(), err)
+	leases := &coordinationv1.LeaseList{}
+	require.NoError(s.T(), fakeClient.List(context.TODO(), leases))
+	dimensions := map[string]string{}
+	for _, lease := range leases.Items {
+		dimensions[lease.Name] = lease.Labels[ratelimit.DimensionLabelKey]
+	}
+	require.Len(s.T(), dimensions, 2)
+	assert.Contains(s.T(), dimensions, "leader-election")
+	assert.NotContains(s.T(), dimensions, "verification-rate-limit-global")
+	assert.Contains(s.T(), slices.Collect(maps.Values(dimensions)), ratelimit.DimensionClientIP)
+}
@@ -27,7 +27,7 @@ func NewAmazonSNSSender(cfg AWSSenderConfiguration) NotificationSender {
 	}
 }
 
-func (s *AmazonSNSSender) SendNotification(_ *gin.Context, content, phoneNumber, _ string) error {
+func (s *AmazonSNSSender) SendNotification(_ *gin.Context, content, phoneNumber, _ string) (string, error) {
 
 	// TODO add support for country-specific sender IDs if we ever decide to use Amazon SNS to send notifications
 
@@ -42,7 +42,7 @@ func (s *AmazonSNSSender) SendNotification(_ *gin.Context, content, phoneNumber,
 	)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	svc := sns.New(sess)
@@ -55,7 +55,7 @@ func (s *AmazonSNSSender) SendNotification(_ *gin.Context, content, phoneNumber,
 	smsType.SetDataType("String")
 	smsType.SetStringValue(s.Config.AWSSMSType())
 
-	_, err = svc.Publish(&sns.PublishInput{
+	output, err := svc.Publish(&sns.PublishInput{
 		Message:     &content,
 		PhoneNumber: &phoneNumber,
 		MessageAttributes: map[string]*sns.MessageAttributeValue{
@@ -65,8 +65,8 @@ func (s *AmazonSNSSender) SendNotification(_ *gin.Context, content, phoneNumber,
 	})
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return aws.StringValue(output.MessageId), nil
 }
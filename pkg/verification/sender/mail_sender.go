@@ -0,0 +1,10 @@
+package sender
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// MailSender sends the emails used to verify the email address of the users
+type MailSender interface {
+	SendMail(ctx *gin.Context, to, subject, body string) error
+}
@@ -0,0 +1,225 @@
+package sender
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/gin-gonic/gin"
+	"github.com/kevinburke/rest/resterror"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// names of the providers registered by CreateNotificationSender
+const (
+	twilioProvider = "twilio"
+	awsProvider    = "aws"
+)
+
+// ErrProviderUnavailable can be wrapped by the errors returned by the providers to trigger a failover to the next provider
+var ErrProviderUnavailable = errors.New("notification provider unavailable")
+
+var (
+	sentNotificationsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_notification_sender_requests_total",
+		Help: "Number of notifications sent with each provider, by result (success or failure)",
+	}, []string{"provider", "result"})
+	sendDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sandbox_notification_sender_duration_seconds",
+		Help:    "Time taken by each provider to send a notification",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+)
+
+// Collectors returns the Prometheus collectors exposing the success, failure and latency of the notification providers
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sentNotificationsCounter, sendDurationHistogram}
+}
+
+// Registry is a NotificationSender which sends the notifications with the providers registered by name,
+// in the order given by the configuration (see Route).
+// If a provider is unavailable, then the notification is sent with the next one.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]NotificationSender
+}
+
+// NewRegistry returns a new Registry with no provider
+func NewRegistry() *Registry {
+	return &Registry{
+		providers: map[string]NotificationSender{},
+	}
+}
+
+// Register registers the given provider with the given name, replacing the provider previously registered with the same name
+func (r *Registry) Register(name string, provider NotificationSender) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[strings.ToLower(name)] = provider
+}
+
+// Provider returns the provider registered with the given name
+func (r *Registry) Provider(name string) (NotificationSender, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, found := r.providers[strings.ToLower(name)]
+	return provider, found
+}
+
+// Route returns the names of the providers to use, in order, to send a notification to the given country:
+// the order given by the override configured for this country (see `NotificationSenderCountryOverrides`), if any.
+// Otherwise, the configured order, except for the countries which have a Twilio sender ID (see `TwilioSenderConfigs`),
+// for which Twilio comes first (if it is configured at all), so that the notifications come from that sender ID
+// as long as Twilio is available.
+func (r *Registry) Route(countryCode string) []string {
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	if senders, found := r.countryOverride(cfg, countryCode); found {
+		return senders
+	}
+	senders := cfg.NotificationSenders()
+	twilio := slices.IndexFunc(senders, func(name string) bool {
+		return strings.EqualFold(name, twilioProvider)
+	})
+	if twilio <= 0 || !hasTwilioSenderID(cfg.TwilioSenderConfigs(), countryCode) {
+		return senders
+	}
+	route := append([]string{senders[twilio]}, senders[:twilio]...)
+	return append(route, senders[twilio+1:]...)
+}
+
+// countryOverride returns the providers of the override configured for the given country. The overrides are ignored
+// (and the default order applies) if they are invalid, see CheckCountryOverrides.
+func (r *Registry) countryOverride(cfg configuration.VerificationConfig, countryCode string) ([]string, bool) {
+	overrides, err := r.CheckCountryOverrides(cfg)
+	if err != nil {
+		log.Error(nil, err, "ignoring the notification sender country overrides")
+		return nil, false
+	}
+	for _, override := range overrides {
+		if slices.Contains(override.CountryCodes, countryCode) {
+			return override.Senders, true
+		}
+	}
+	return nil, false
+}
+
+// CheckCountryOverrides returns the configured per-country orders of the providers, or an error if they can't be parsed
+// or refer to a provider which is not registered
+func (r *Registry) CheckCountryOverrides(cfg configuration.VerificationConfig) ([]configuration.NotificationSenderCountryOverride, error) {
+	overrides, err := cfg.NotificationSenderCountryOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		for _, name := range override.Senders {
+			if _, found := r.Provider(name); !found {
+				return nil, fmt.Errorf("invalid notification sender country overrides configuration: unknown notification provider '%s'", name)
+			}
+		}
+	}
+	return overrides, nil
+}
+
+// hasTwilioSenderID returns true if one of the given Twilio sender configs is for the given country
+func hasTwilioSenderID(senderConfigs []toolchainv1alpha1.TwilioSenderConfig, countryCode string) bool {
+	for _, senderConfig := range senderConfigs {
+		if slices.Contains(senderConfig.CountryCodes, countryCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// SendNotification sends the notification with the first available provider for the given country,
+// and returns the ID given to the message by this provider
+func (r *Registry) SendNotification(ctx *gin.Context, content, phoneNumber, countryCode string) (string, error) {
+	return r.send(ctx, countryCode, func(provider NotificationSender) (string, bool, error) {
+		messageID, err := provider.SendNotification(ctx, content, phoneNumber, countryCode)
+		return messageID, true, err
+	})
+}
+
+// SendVoiceCall reads the content during a call with the first available provider for the given country.
+// The providers which don't support voice calls are skipped.
+func (r *Registry) SendVoiceCall(ctx *gin.Context, content, phoneNumber, countryCode string) error {
+	_, err := r.send(ctx, countryCode, func(provider NotificationSender) (string, bool, error) {
+		caller, ok := provider.(VoiceCallSender)
+		if !ok {
+			return "", false, nil
+		}
+		return "", true, caller.SendVoiceCall(ctx, content, phoneNumber, countryCode)
+	})
+	return err
+}
+
+// send calls the providers routed for the given country in order, until one of them succeeds or fails with an error
+// which doesn't trigger a failover. The sendWith function returns the ID of the message, or false if the provider doesn't
+// support the notification.
+func (r *Registry) send(ctx *gin.Context, countryCode string, sendWith func(provider NotificationSender) (string, bool, error)) (string, error) {
+	var lastErr error
+	for _, name := range r.Route(countryCode) {
+		provider, found := r.Provider(name)
+		if !found {
+			log.Error(ctx, nil, fmt.Sprintf("unknown notification provider '%s'", name))
+			continue
+		}
+		start := time.Now()
+		messageID, supported, err := sendWith(provider)
+		if !supported {
+			continue
+		}
+		sendDurationHistogram.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err == nil {
+			sentNotificationsCounter.WithLabelValues(name, "success").Inc()
+			log.Infof(ctx, "notification sent with the '%s' provider", name)
+			return messageID, nil
+		}
+		sentNotificationsCounter.WithLabelValues(name, "failure").Inc()
+		if !isProviderUnavailable(err) {
+			return "", err
+		}
+		log.Error(ctx, err, fmt.Sprintf("the '%s' notification provider is unavailable, trying the next one", name))
+		lastErr = err
+	}
+	if lastErr == nil {
+		return "", fmt.Errorf("no notification provider available for country code '%s'", countryCode)
+	}
+	return "", lastErr
+}
+
+// isProviderUnavailable returns true if the error was caused by the transport or by a server error of the provider,
+// in which case the notification can be sent with another provider.
+func isProviderUnavailable(err error) bool {
+	if errors.Is(err, ErrProviderUnavailable) {
+		return true
+	}
+	// error returned by the Twilio API
+	var restErr *resterror.Error
+	if errors.As(err, &restErr) {
+		return restErr.Status >= 500
+	}
+	// errors returned by the AWS API
+	var awsRequestFailure awserr.RequestFailure
+	if errors.As(err, &awsRequestFailure) {
+		return awsRequestFailure.StatusCode() >= 500
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == request.ErrCodeRequestError || awsErr.Code() == request.ErrCodeResponseTimeout
+	}
+	// transport errors
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
@@ -0,0 +1,357 @@
+package sender_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/sender"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kevinburke/rest/resterror"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestRegistrySuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunRegistrySuite(t *testing.T) {
+	suite.Run(t, &TestRegistrySuite{test.UnitTestSuite{}})
+}
+
+// fakeProvider records the notifications it sends, or returns the given error
+type fakeProvider struct {
+	err  error
+	sent []string
+}
+
+func (p *fakeProvider) SendNotification(_ *gin.Context, content, phoneNumber, _ string) (string, error) {
+	if p.err != nil {
+		return "", p.err
+	}
+	p.sent = append(p.sent, fmt.Sprintf("%s:%s", phoneNumber, content))
+	return fmt.Sprintf("msg-%d", len(p.sent)), nil
+}
+
+// fakeVoiceProvider is a fakeProvider which can also make voice calls
+type fakeVoiceProvider struct {
+	fakeProvider
+	calls []string
+}
+
+func (p *fakeVoiceProvider) SendVoiceCall(_ *gin.Context, content, phoneNumber, _ string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.calls = append(p.calls, fmt.Sprintf("%s:%s", phoneNumber, content))
+	return nil
+}
+
+// countNotifications returns the number of notifications sent with the given provider and result, according to the metrics
+func (s *TestRegistrySuite) countNotifications(provider, result string) float64 {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(sender.Collectors()...)
+	families, err := reg.Gather()
+	require.NoError(s.T(), err)
+	for _, family := range families {
+		if family.GetName() != "sandbox_notification_sender_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["provider"] == provider && labels["result"] == result {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func (s *TestRegistrySuite) TestSendNotification() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	newRegistry := func(providers map[string]*fakeProvider) *sender.Registry {
+		registry := sender.NewRegistry()
+		for name, provider := range providers {
+			registry.Register(name, provider)
+		}
+		return registry
+	}
+
+	s.Run("first provider sends the notification", func() {
+		// given
+		first, second := "first-"+uuid.NewString(), "second-"+uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": first + "," + second,
+		})
+		firstProvider, secondProvider := &fakeProvider{}, &fakeProvider{}
+		registry := newRegistry(map[string]*fakeProvider{first: firstProvider, second: secondProvider})
+
+		// when
+		messageID, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "msg-1", messageID)
+		assert.Equal(s.T(), []string{"+441234567890:code: 123456"}, firstProvider.sent)
+		assert.Empty(s.T(), secondProvider.sent)
+		assert.InDelta(s.T(), float64(1), s.countNotifications(first, "success"), 0.01)
+	})
+
+	s.Run("failover to the next provider", func() {
+		for reason, err := range map[string]error{
+			"provider unavailable": fmt.Errorf("%w: maintenance", sender.ErrProviderUnavailable),
+			"server error":         &resterror.Error{Title: "service unavailable", Status: http.StatusServiceUnavailable},
+			"transport error":      &url.Error{Op: "Post", URL: "https://api.twilio.com", Err: errors.New("connection refused")},
+		} {
+			s.Run(reason, func() {
+				// given
+				first, second := "first-"+uuid.NewString(), "second-"+uuid.NewString()
+				s.SetSettings(map[string]string{
+					"verification.notificationSenders": first + "," + second,
+				})
+				secondProvider := &fakeProvider{}
+				registry := newRegistry(map[string]*fakeProvider{first: {err: err}, second: secondProvider})
+
+				// when
+				_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), []string{"+441234567890:code: 123456"}, secondProvider.sent)
+				assert.InDelta(s.T(), float64(1), s.countNotifications(first, "failure"), 0.01)
+				assert.InDelta(s.T(), float64(1), s.countNotifications(second, "success"), 0.01)
+			})
+		}
+	})
+
+	s.Run("no failover on client error", func() {
+		// given
+		first, second := "first-"+uuid.NewString(), "second-"+uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": first + "," + second,
+		})
+		secondProvider := &fakeProvider{}
+		registry := newRegistry(map[string]*fakeProvider{
+			first:  {err: &resterror.Error{Title: "invalid phone number", Status: http.StatusBadRequest}},
+			second: secondProvider,
+		})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+		// then
+		require.EqualError(s.T(), err, "invalid phone number")
+		assert.Empty(s.T(), secondProvider.sent)
+	})
+
+	s.Run("all providers unavailable", func() {
+		// given
+		first, second := "first-"+uuid.NewString(), "second-"+uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": first + "," + second,
+		})
+		registry := newRegistry(map[string]*fakeProvider{
+			first:  {err: fmt.Errorf("%w: first", sender.ErrProviderUnavailable)},
+			second: {err: fmt.Errorf("%w: second", sender.ErrProviderUnavailable)},
+		})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+		// then
+		require.EqualError(s.T(), err, "notification provider unavailable: second")
+	})
+
+	s.Run("unknown provider is skipped", func() {
+		// given
+		known := "known-" + uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": "unknown," + known,
+		})
+		knownProvider := &fakeProvider{}
+		registry := newRegistry(map[string]*fakeProvider{known: knownProvider})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Len(s.T(), knownProvider.sent, 1)
+	})
+
+	s.Run("no provider", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": "unknown",
+		})
+		registry := newRegistry(map[string]*fakeProvider{})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+
+		// then
+		require.EqualError(s.T(), err, "no notification provider available for country code '44'")
+	})
+
+	s.Run("twilio comes first for the countries with a twilio sender ID", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": "aws,twilio",
+		}, twilioSenderConfigs(toolchainv1alpha1.TwilioSenderConfig{SenderID: "Sandbox", CountryCodes: []string{"44", "49"}}))
+		twilio, aws := &fakeProvider{}, &fakeProvider{}
+		registry := newRegistry(map[string]*fakeProvider{"twilio": twilio, "aws": aws})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+		require.NoError(s.T(), err)
+		_, err = registry.SendNotification(ctx, "code: 654321", "+611234567890", "61")
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), []string{"twilio", "aws"}, registry.Route("49"))
+		assert.Equal(s.T(), []string{"aws", "twilio"}, registry.Route("1"))
+		assert.Equal(s.T(), []string{"+441234567890:code: 123456"}, twilio.sent)
+		assert.Equal(s.T(), []string{"+611234567890:code: 654321"}, aws.sent)
+
+		s.Run("not when twilio is not configured", func() {
+			// given
+			s.SetSettings(map[string]string{
+				"verification.notificationSenders": "aws",
+			}, twilioSenderConfigs(toolchainv1alpha1.TwilioSenderConfig{SenderID: "Sandbox", CountryCodes: []string{"44", "49"}}))
+
+			// then
+			assert.Equal(s.T(), []string{"aws"}, registry.Route("49"))
+		})
+	})
+
+	s.Run("country override", func() {
+		// given
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders":                "twilio,aws",
+			"verification.notificationSenderCountryOverrides": `[{"countryCodes": ["44", "49"], "senders": ["aws"]}]`,
+		}, twilioSenderConfigs(toolchainv1alpha1.TwilioSenderConfig{SenderID: "Sandbox", CountryCodes: []string{"44", "33"}}))
+		twilio, aws := &fakeProvider{}, &fakeProvider{}
+		registry := newRegistry(map[string]*fakeProvider{"twilio": twilio, "aws": aws})
+
+		// when
+		_, err := registry.SendNotification(ctx, "code: 123456", "+441234567890", "44")
+		require.NoError(s.T(), err)
+		_, err = registry.SendNotification(ctx, "code: 654321", "+611234567890", "61")
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), []string{"aws"}, registry.Route("49"))
+		assert.Equal(s.T(), []string{"twilio", "aws"}, registry.Route("33"))
+		assert.Equal(s.T(), []string{"+441234567890:code: 123456"}, aws.sent)
+		assert.Equal(s.T(), []string{"+611234567890:code: 654321"}, twilio.sent)
+	})
+
+	s.Run("invalid country overrides are ignored", func() {
+		for name, overrides := range map[string]string{
+			"unknown provider": `[{"countryCodes": ["44"], "senders": ["aws", "unknown"]}]`,
+			"invalid JSON":     `[{"countryCodes": ["44"]`,
+			"no senders":       `[{"countryCodes": ["44"]}]`,
+		} {
+			s.Run(name, func() {
+				// given
+				s.SetSettings(map[string]string{
+					"verification.notificationSenders":                "aws,twilio",
+					"verification.notificationSenderCountryOverrides": overrides,
+				}, twilioSenderConfigs(toolchainv1alpha1.TwilioSenderConfig{SenderID: "Sandbox", CountryCodes: []string{"44"}}))
+				registry := newRegistry(map[string]*fakeProvider{"twilio": {}, "aws": {}})
+
+				// when
+				_, err := registry.CheckCountryOverrides(configuration.GetRegistrationServiceConfig().Verification())
+
+				// then
+				require.ErrorContains(s.T(), err, "invalid notification sender country overrides configuration")
+				assert.Equal(s.T(), []string{"twilio", "aws"}, registry.Route("44"))
+				assert.Equal(s.T(), []string{"aws", "twilio"}, registry.Route("1"))
+			})
+		}
+	})
+}
+
+// twilioSenderConfigs sets the given Twilio sender configs in the ToolchainConfig
+type twilioSenderConfigs toolchainv1alpha1.TwilioSenderConfig
+
+func (c twilioSenderConfigs) Apply(config *toolchainv1alpha1.ToolchainConfig) {
+	config.Spec.Host.RegistrationService.Verification.TwilioSenderConfigs = []toolchainv1alpha1.TwilioSenderConfig{toolchainv1alpha1.TwilioSenderConfig(c)}
+}
+
+func (s *TestRegistrySuite) TestSendVoiceCall() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("providers without voice support are skipped", func() {
+		// given
+		smsOnly, voice := "sms-"+uuid.NewString(), "voice-"+uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": smsOnly + "," + voice,
+		})
+		smsOnlyProvider, voiceProvider := &fakeProvider{}, &fakeVoiceProvider{}
+		registry := sender.NewRegistry()
+		registry.Register(smsOnly, smsOnlyProvider)
+		registry.Register(voice, voiceProvider)
+
+		// when
+		err := registry.SendVoiceCall(ctx, "code: 1, 2, 3", "+441234567890", "44")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), smsOnlyProvider.sent)
+		assert.Empty(s.T(), voiceProvider.sent)
+		assert.Equal(s.T(), []string{"+441234567890:code: 1, 2, 3"}, voiceProvider.calls)
+		assert.InDelta(s.T(), float64(1), s.countNotifications(voice, "success"), 0.01)
+	})
+
+	s.Run("failover to the next provider", func() {
+		// given
+		first, second := "first-"+uuid.NewString(), "second-"+uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": first + "," + second,
+		})
+		secondProvider := &fakeVoiceProvider{}
+		registry := sender.NewRegistry()
+		registry.Register(first, &fakeVoiceProvider{fakeProvider: fakeProvider{err: fmt.Errorf("%w: maintenance", sender.ErrProviderUnavailable)}})
+		registry.Register(second, secondProvider)
+
+		// when
+		err := registry.SendVoiceCall(ctx, "code: 1, 2, 3", "+441234567890", "44")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), []string{"+441234567890:code: 1, 2, 3"}, secondProvider.calls)
+		assert.InDelta(s.T(), float64(1), s.countNotifications(first, "failure"), 0.01)
+	})
+
+	s.Run("no provider with voice support", func() {
+		// given
+		smsOnly := "sms-" + uuid.NewString()
+		s.SetSettings(map[string]string{
+			"verification.notificationSenders": smsOnly,
+		})
+		registry := sender.NewRegistry()
+		registry.Register(smsOnly, &fakeProvider{})
+
+		// when
+		err := registry.SendVoiceCall(ctx, "code: 1, 2, 3", "+441234567890", "44")
+
+		// then
+		require.EqualError(s.T(), err, "no notification provider available for country code '44'")
+	})
+}
@@ -2,23 +2,35 @@ package sender
 
 import (
 	"net/http"
-	"strings"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
 	"github.com/gin-gonic/gin"
 )
 
 type NotificationSender interface {
-	SendNotification(ctx *gin.Context, content, phoneNumber, countryCode string) error
+	// SendNotification sends the content by SMS to the given phone number, and returns the ID given by the provider
+	// to the message, which is used to match the delivery status callbacks
+	SendNotification(ctx *gin.Context, content, phoneNumber, countryCode string) (string, error)
+}
+
+// VoiceCallSender is implemented by the providers which can read a message to the users during a phone call
+type VoiceCallSender interface {
+	SendVoiceCall(ctx *gin.Context, content, phoneNumber, countryCode string) error
 }
 
 type NotificationSenderOption = func()
 
-func CreateNotificationSender(httpClient *http.Client) NotificationSender {
+// CreateNotificationSender returns a Registry with the `twilio` and `aws` providers.
+// Invalid per-country orders of the providers are reported here, and ignored when sending the notifications.
+func CreateNotificationSender(httpClient *http.Client) *Registry {
 	cfg := configuration.GetRegistrationServiceConfig()
-	if strings.ToLower(cfg.Verification().NotificationSender()) == "aws" {
-		return NewAmazonSNSSender(cfg.Verification())
+	registry := NewRegistry()
+	registry.Register(twilioProvider, NewTwilioSender(cfg.Verification(), httpClient))
+	registry.Register(awsProvider, NewAmazonSNSSender(cfg.Verification()))
+	if _, err := registry.CheckCountryOverrides(cfg.Verification()); err != nil {
+		log.Error(nil, err, "the notification sender country overrides are ignored")
 	}
-
-	return NewTwilioSender(cfg.Verification(), httpClient)
+	return registry
 }
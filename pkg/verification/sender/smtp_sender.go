@@ -0,0 +1,65 @@
+package sender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+type SMTPConfig interface {
+	SMTPHost() string
+	SMTPPort() int
+	SMTPUsername() string
+	SMTPPassword() string
+	SMTPFrom() string
+}
+
+type SMTPMailSender struct {
+	Config SMTPConfig
+}
+
+func NewSMTPSender(cfg SMTPConfig) MailSender {
+	return &SMTPMailSender{
+		Config: cfg,
+	}
+}
+
+func (s *SMTPMailSender) SendMail(ctx *gin.Context, to, subject, body string) error {
+	if s.Config.SMTPHost() == "" {
+		return errors.New("no SMTP server configured")
+	}
+	// prevent the injection of headers via the recipient or the subject
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return errors.New("invalid recipient or subject")
+	}
+
+	var auth smtp.Auth
+	if s.Config.SMTPUsername() != "" {
+		auth = smtp.PlainAuth("", s.Config.SMTPUsername(), s.Config.SMTPPassword(), s.Config.SMTPHost())
+	}
+	addr := net.JoinHostPort(s.Config.SMTPHost(), strconv.Itoa(s.Config.SMTPPort()))
+
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", s.Config.SMTPFrom())
+	fmt.Fprintf(msg, "To: %s\r\n", to)
+	fmt.Fprintf(msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(addr, auth, s.Config.SMTPFrom(), []string{to}, msg.Bytes()); err != nil {
+		log.Error(ctx, err, "error while sending email")
+		return err
+	}
+	return nil
+}
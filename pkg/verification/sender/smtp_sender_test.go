@@ -0,0 +1,149 @@
+package sender_test
+
+import (
+	"bufio"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/verification/sender"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type smtpConfig struct {
+	host string
+	port int
+}
+
+func (c smtpConfig) SMTPHost() string     { return c.host }
+func (c smtpConfig) SMTPPort() int        { return c.port }
+func (c smtpConfig) SMTPUsername() string { return "" }
+func (c smtpConfig) SMTPPassword() string { return "" }
+func (c smtpConfig) SMTPFrom() string     { return "noreply@sandbox.dev" }
+
+// startSMTPServer starts a minimal SMTP server which accepts a single email, and returns it via the given channel
+func startSMTPServer(t *testing.T, received chan<- string) smtpConfig {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) {
+			_, _ = conn.Write([]byte(line + "\r\n"))
+		}
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return smtpConfig{host: addr.IP.String(), port: addr.Port}
+}
+
+type TestSMTPSenderSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunSMTPSenderSuite(t *testing.T) {
+	suite.Run(t, &TestSMTPSenderSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestSMTPSenderSuite) TestSendMail() {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("email sent", func() {
+		// given
+		received := make(chan string, 1)
+		mailSender := sender.NewSMTPSender(startSMTPServer(s.T(), received))
+
+		// when
+		err := mailSender.SendMail(ctx, "johnny@kubesaw.io", "Developer Sandbox verification code", "Your code is 123456\nThanks")
+
+		// then
+		require.NoError(s.T(), err)
+		msg := <-received
+		assert.Contains(s.T(), msg, "From: noreply@sandbox.dev\r\n")
+		assert.Contains(s.T(), msg, "To: johnny@kubesaw.io\r\n")
+		assert.Contains(s.T(), msg, "Subject: Developer Sandbox verification code\r\n")
+		assert.Contains(s.T(), msg, "Content-Type: text/plain; charset=UTF-8\r\n")
+		assert.True(s.T(), strings.HasSuffix(msg, "\r\n\r\nYour code is 123456\r\nThanks\r\n"))
+	})
+
+	s.Run("header injection", func() {
+		// given
+		mailSender := sender.NewSMTPSender(smtpConfig{host: "localhost", port: 25})
+
+		// when
+		err := mailSender.SendMail(ctx, "johnny@kubesaw.io\r\nBcc: all@kubesaw.io", "subject", "body")
+
+		// then
+		require.EqualError(s.T(), err, "invalid recipient or subject")
+	})
+
+	s.Run("no server configured", func() {
+		// given
+		mailSender := sender.NewSMTPSender(smtpConfig{port: 25})
+
+		// when
+		err := mailSender.SendMail(ctx, "johnny@kubesaw.io", "subject", "body")
+
+		// then
+		require.EqualError(s.T(), err, "no SMTP server configured")
+	})
+
+	s.Run("server unavailable", func() {
+		// given
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(s.T(), err)
+		port := listener.Addr().(*net.TCPAddr).Port
+		require.NoError(s.T(), listener.Close())
+		mailSender := sender.NewSMTPSender(smtpConfig{host: "127.0.0.1", port: port})
+
+		// when
+		err = mailSender.SendMail(ctx, "johnny@kubesaw.io", "subject", "body")
+
+		// then
+		require.ErrorContains(s.T(), err, "127.0.0.1:"+strconv.Itoa(port))
+	})
+}
@@ -1,11 +1,16 @@
 package sender
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
 	"fmt"
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"net/http"
+	"net/url"
 
 	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
 	"github.com/gin-gonic/gin"
 	"github.com/kevinburke/twilio-go"
 )
@@ -15,6 +20,7 @@ type TwilioConfig interface {
 	TwilioAuthToken() string
 	TwilioFromNumber() string
 	TwilioSenderConfigs() []toolchainv1alpha1.TwilioSenderConfig
+	DeliveryStatusCallbackURL() string
 }
 
 type TwilioNotificationSender struct {
@@ -44,14 +50,19 @@ func NewTwilioSender(cfg TwilioConfig, httpClient *http.Client) NotificationSend
 	return sender
 }
 
-func (s *TwilioNotificationSender) SendNotification(ctx *gin.Context, content, phoneNumber, countryCode string) error {
+func (s *TwilioNotificationSender) SendNotification(ctx *gin.Context, content, phoneNumber, countryCode string) (string, error) {
 	client := twilio.NewClient(s.Config.TwilioAccountSID(), s.Config.TwilioAuthToken(), s.HTTPClient)
-	from, ok := s.SenderIDs[countryCode]
-	if !ok {
-		from = s.Config.TwilioFromNumber()
-	}
+	from := s.from(countryCode)
 
-	msg, err := client.Messages.SendMessage(from, phoneNumber, content, nil)
+	data := url.Values{}
+	data.Set("Body", content)
+	data.Set("From", from)
+	data.Set("To", phoneNumber)
+	if callbackURL := s.Config.DeliveryStatusCallbackURL(); callbackURL != "" {
+		// ask Twilio to report the final delivery status of the message
+		data.Set("StatusCallback", callbackURL+delivery.TwilioCallbackPath)
+	}
+	msg, err := client.Messages.Create(context.TODO(), data)
 	if err != nil {
 		if msg != nil {
 			log.Error(ctx, err, fmt.Sprintf("error while sending, code: %d message: %s", msg.ErrorCode, msg.ErrorMessage))
@@ -59,8 +70,38 @@ func (s *TwilioNotificationSender) SendNotification(ctx *gin.Context, content, p
 			log.Error(ctx, err, "unknown error while sending")
 		}
 
+		return "", err
+	}
+
+	return msg.Sid, nil
+}
+
+// SendVoiceCall calls the given phone number and reads the content with the Twilio text-to-speech.
+// The calls are always made from the configured phone number, since the alphanumeric sender IDs can only send messages.
+func (s *TwilioNotificationSender) SendVoiceCall(ctx *gin.Context, content, phoneNumber, _ string) error {
+	client := twilio.NewClient(s.Config.TwilioAccountSID(), s.Config.TwilioAuthToken(), s.HTTPClient)
+
+	twiml := &bytes.Buffer{}
+	twiml.WriteString("<Response><Say>")
+	if err := xml.EscapeText(twiml, []byte(content)); err != nil {
 		return err
 	}
+	twiml.WriteString("</Say></Response>")
 
+	data := url.Values{}
+	data.Set("From", s.Config.TwilioFromNumber())
+	data.Set("To", phoneNumber)
+	data.Set("Twiml", twiml.String())
+	if _, err := client.Calls.Create(context.TODO(), data); err != nil {
+		log.Error(ctx, err, "error while calling")
+		return err
+	}
 	return nil
 }
+
+func (s *TwilioNotificationSender) from(countryCode string) string {
+	if from, ok := s.SenderIDs[countryCode]; ok {
+		return from
+	}
+	return s.Config.TwilioFromNumber()
+}
@@ -20,6 +20,7 @@ type MockTwilioConfig struct {
 	AuthToken     string
 	FromNumber    string
 	SenderConfigs []toolchainv1alpha1.TwilioSenderConfig
+	CallbackURL   string
 }
 
 func (c *MockTwilioConfig) TwilioAccountSID() string {
@@ -38,6 +39,10 @@ func (c *MockTwilioConfig) TwilioSenderConfigs() []toolchainv1alpha1.TwilioSende
 	return c.SenderConfigs
 }
 
+func (c *MockTwilioConfig) DeliveryStatusCallbackURL() string {
+	return c.CallbackURL
+}
+
 func TestTwilioSenderID(t *testing.T) {
 
 	cfg := &MockTwilioConfig{
@@ -59,8 +64,8 @@ func TestTwilioSenderID(t *testing.T) {
 		defer gock.Off()
 
 		gock.New("https://api.twilio.com").
-			Reply(http.StatusNoContent).
-			BodyString("")
+			Reply(http.StatusCreated).
+			JSON(map[string]string{"sid": "SM123"})
 
 		var reqBody io.ReadCloser
 		obs := func(request *http.Request, _ gock.Mock) {
@@ -86,13 +91,18 @@ func TestTwilioSenderID(t *testing.T) {
 
 	t.Run("test country code in config", func(t *testing.T) {
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		var messageID string
 		reqValue := setupGockAndSendRequest(func(sender sender2.NotificationSender) error {
-			return sender.SendNotification(ctx, "Test Message", "+440000000000", "44")
+			var err error
+			messageID, err = sender.SendNotification(ctx, "Test Message", "+440000000000", "44")
+			return err
 		})
 
 		v, err := url.ParseQuery(reqValue)
 		require.NoError(t, err)
 
+		require.Equal(t, "SM123", messageID)
+
 		require.Equal(t, "Test Message", v.Get("Body"))
 		require.Equal(t, "RED HAT", v.Get("From"))
 		require.Equal(t, "+440000000000", v.Get("To"))
@@ -101,7 +111,8 @@ func TestTwilioSenderID(t *testing.T) {
 	t.Run("test country code not in config", func(t *testing.T) {
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
 		reqValue := setupGockAndSendRequest(func(sender sender2.NotificationSender) error {
-			return sender.SendNotification(ctx, "Test Message", "+611234567890", "61")
+			_, err := sender.SendNotification(ctx, "Test Message", "+611234567890", "61")
+			return err
 		})
 
 		v, err := url.ParseQuery(reqValue)
@@ -110,5 +121,65 @@ func TestTwilioSenderID(t *testing.T) {
 		require.Equal(t, "Test Message", v.Get("Body"))
 		require.Equal(t, "+13334445555", v.Get("From"))
 		require.Equal(t, "+611234567890", v.Get("To"))
+		require.Empty(t, v.Get("StatusCallback"))
+	})
+
+	t.Run("test delivery status callback", func(t *testing.T) {
+		cfg.CallbackURL = "https://registration.example.com/api/v1/signup/verification/delivery-status"
+		defer func() {
+			cfg.CallbackURL = ""
+		}()
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		reqValue := setupGockAndSendRequest(func(sender sender2.NotificationSender) error {
+			_, err := sender.SendNotification(ctx, "Test Message", "+611234567890", "61")
+			return err
+		})
+
+		v, err := url.ParseQuery(reqValue)
+		require.NoError(t, err)
+
+		require.Equal(t, "https://registration.example.com/api/v1/signup/verification/delivery-status/twilio", v.Get("StatusCallback"))
+	})
+}
+
+func TestTwilioVoiceCall(t *testing.T) {
+	// given
+	cfg := &MockTwilioConfig{
+		AccountSID: "TWILIO_SID_VALUE",
+		AuthToken:  "AUTH_TOKEN_VALUE",
+		FromNumber: "+13334445555",
+		SenderConfigs: []toolchainv1alpha1.TwilioSenderConfig{
+			{
+				SenderID:     "RED HAT",
+				CountryCodes: []string{"44"},
+			},
+		},
+	}
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	defer gock.Off()
+	gock.New("https://api.twilio.com").
+		Post("/2010-04-01/Accounts/TWILIO_SID_VALUE/Calls.json").
+		Reply(http.StatusCreated).
+		JSON(map[string]string{"sid": "CA123"})
+	var reqBody []byte
+	gock.Observe(func(request *http.Request, _ gock.Mock) {
+		var err error
+		reqBody, err = io.ReadAll(request.Body)
+		require.NoError(t, err)
 	})
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	sender := sender2.NewTwilioSender(cfg, httpClient)
+
+	// when
+	err := sender.(sender2.VoiceCallSender).SendVoiceCall(ctx, "Your code is 1, 2 & 3", "+440000000000", "44")
+
+	// then
+	require.NoError(t, err)
+	v, err := url.ParseQuery(string(reqBody))
+	require.NoError(t, err)
+	// calls are always made from the phone number, since the sender IDs can only be used for messages
+	require.Equal(t, "+13334445555", v.Get("From"))
+	require.Equal(t, "+440000000000", v.Get("To"))
+	require.Equal(t, "<Response><Say>Your code is 1, 2 &amp; 3</Say></Response>", v.Get("Twiml"))
 }
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+const (
+	// codeHashPrefix is the prefix of the verification codes stored as a salted HMAC-SHA256 in the UserSignups,
+	// followed by the salt and the HMAC of the code, both encoded in base64
+	codeHashPrefix = "hmac-sha256:"
+	codeSaltLength = 16
+)
+
+// errNoCodeHashKey is returned when the 'verification.codeHashKey' setting is not configured
+var errNoCodeHashKey = errors.New("no hash key for the verification codes: the 'verification.codeHashKey' setting is not configured")
+
+// CheckCodeHashKey returns an error if the verification is enabled but no hash key is configured for the verification codes,
+// in which case no verification code can be sent
+func CheckCodeHashKey(cfg configuration.VerificationConfig) error {
+	if cfg.Enabled() && cfg.VerificationCodeHashKey() == "" {
+		return errNoCodeHashKey
+	}
+	return nil
+}
+
+// hashVerificationCode returns the value of the verification code to store in the UserSignup: a salted HMAC of the code
+// keyed by the hash key. The code is never stored in plain text: an error is returned if there is no hash key.
+func hashVerificationCode(cfg configuration.VerificationConfig, code string) (string, error) {
+	key := cfg.VerificationCodeHashKey()
+	if key == "" {
+		return "", errNoCodeHashKey
+	}
+	salt := make([]byte, codeSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to generate the salt of the verification code: %w", err)
+	}
+	return codeHashPrefix + base64.RawStdEncoding.EncodeToString(salt) + ":" +
+		base64.RawStdEncoding.EncodeToString(codeHMAC(key, salt, code)), nil
+}
+
+// verificationCodeMatches compares the given code with the value stored in the UserSignup in constant time.
+// The comparison is case-insensitive, since the codes are generated with upper-case characters only.
+// The stored value may be a salted HMAC of the code, or the code itself if it was generated before the codes were hashed,
+// so that the codes sent before the migration can still be used until they expire.
+func verificationCodeMatches(cfg configuration.VerificationConfig, stored, code string) bool {
+	code = strings.ToUpper(code)
+	if !strings.HasPrefix(stored, codeHashPrefix) {
+		return stored != "" && subtle.ConstantTimeCompare([]byte(strings.ToUpper(stored)), []byte(code)) == 1
+	}
+	key := cfg.VerificationCodeHashKey()
+	if key == "" {
+		return false
+	}
+	salt64, hash64, found := strings.Cut(strings.TrimPrefix(stored, codeHashPrefix), ":")
+	if !found {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(salt64)
+	if err != nil {
+		return false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(hash64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(hash, codeHMAC(key, salt, code))
+}
+
+func codeHMAC(key string, salt []byte, code string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(salt)
+	mac.Write([]byte(code))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// magicLinkPayload is the payload of the token of the magic links
+type magicLinkPayload struct {
+	Username string `json:"u"`
+	Code     string `json:"c"`
+	// ExpiresAt is the Unix time after which the link is no longer valid
+	ExpiresAt int64 `json:"e"`
+}
+
+// InitEmailVerification sends a verification email to the email address of the specified user. The email contains
+// the verification code, and a magic link to verify the email address if configured. The UserSignup resource is
+// updated with the same annotations as for the phone verification, which are subject to the same limits.
+func (s *ServiceImpl) InitEmailVerification(ctx *gin.Context, username string) error {
+	if err := checkVerificationMethodAllowed(ctx, configuration.VerificationMethodEmail); err != nil {
+		return err
+	}
+
+	signup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return crterrors.NewNotFoundError(err, "usersignup not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
+	}
+
+	// check that verification is required before proceeding
+	if !states.VerificationRequired(signup) {
+		log.Info(ctx, fmt.Sprintf("email verification attempted for user without verification requirement: '%s'", signup.Name))
+		return crterrors.NewBadRequest("forbidden request", "verification code will not be sent")
+	}
+
+	emailAddress := signup.Spec.IdentityClaims.Email
+	if emailAddress == "" {
+		log.Info(ctx, fmt.Sprintf("email verification attempted for user without email address: '%s'", signup.Name))
+		return crterrors.NewBadRequest("no email address", "the account has no email address to verify")
+	}
+
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	rateLimitKeys := []ratelimit.Key{ratelimit.ClientIP(ctx)}
+	return s.sendVerificationCode(ctx, username, signup, configuration.VerificationMethodEmail, configuration.VerificationChannelEmail, map[string]string{}, rateLimitKeys, func(verificationCode string) (string, error) {
+		body := fmt.Sprintf(cfg.EmailMessageTemplate(), verificationCode)
+		link, err := magicLink(cfg, username, verificationCode)
+		if err != nil {
+			return "", err
+		}
+		if link != "" {
+			body += fmt.Sprintf("\n\nYou can also verify your email address by opening the following link: %s", link)
+		}
+		return "", s.MailService.SendMail(ctx, emailAddress, cfg.EmailSubject(), body)
+	})
+}
+
+// VerifyEmailCode validates the verification code sent to the user by email
+func (s *ServiceImpl) VerifyEmailCode(ctx *gin.Context, username, code string) error {
+	return s.verifyEmail(ctx, username, code, nil)
+}
+
+// VerifyEmailLink validates the token of the magic link sent to the user by email.
+// The token must have been issued to the given user, and it is valid until its own expiry
+// as long as the code that it contains is the current one.
+func (s *ServiceImpl) VerifyEmailLink(ctx *gin.Context, username, token string) error {
+	cfg := configuration.GetRegistrationServiceConfig().Verification()
+	payload, err := parseMagicLinkToken(cfg.EmailMagicLinkSigningKey(), token)
+	if err != nil {
+		log.Error(ctx, err, "invalid magic link token")
+		return crterrors.NewForbiddenError("invalid link", "the provided link is invalid")
+	}
+	if payload.Username != username {
+		log.Info(ctx, fmt.Sprintf("magic link issued to another user: '%s'", payload.Username))
+		return crterrors.NewForbiddenError("invalid link", "the provided link is invalid")
+	}
+	expiresAt := time.Unix(payload.ExpiresAt, 0)
+	return s.verifyEmail(ctx, username, payload.Code, &expiresAt)
+}
+
+// verifyEmail validates the code sent to the user by email, with the expiry of the magic link if the code was given by the link
+func (s *ServiceImpl) verifyEmail(ctx *gin.Context, username, code string, linkExpiry *time.Time) error {
+	if err := checkVerificationMethodAllowed(ctx, configuration.VerificationMethodEmail); err != nil {
+		return err
+	}
+
+	signup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return crterrors.NewNotFoundError(err, "user not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
+	}
+
+	if err := checkCaptchaScore(ctx, signup); err != nil {
+		return err
+	}
+
+	return s.verifyCode(ctx, username, signup, configuration.VerificationMethodEmail, code, linkExpiry)
+}
+
+// magicLink returns the link to verify the email address of the given user with the given code,
+// or an empty string if the magic links are not configured.
+func magicLink(cfg configuration.VerificationConfig, username, code string) (string, error) {
+	if cfg.EmailMagicLinkURL() == "" || cfg.EmailMagicLinkSigningKey() == "" {
+		return "", nil
+	}
+	link, err := url.Parse(cfg.EmailMagicLinkURL())
+	if err != nil {
+		return "", fmt.Errorf("invalid magic link URL: %w", err)
+	}
+	token, err := newMagicLinkToken(cfg.EmailMagicLinkSigningKey(), username, code, time.Now().Add(cfg.EmailMagicLinkExpiresIn()))
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// newMagicLinkToken returns a token made of the encoded payload and of its HMAC-SHA256 signature, separated by a dot
+func newMagicLinkToken(signingKey, username, code string, expiresAt time.Time) (string, error) {
+	payload, err := json.Marshal(magicLinkPayload{Username: username, Code: code, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", err
+	}
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(sign(signingKey, encodedPayload)), nil
+}
+
+func parseMagicLinkToken(signingKey, token string) (*magicLinkPayload, error) {
+	if signingKey == "" {
+		return nil, errors.New("no signing key configured for the magic links")
+	}
+	encodedPayload, encodedSignature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, errors.New("malformed token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	if !hmac.Equal(signature, sign(signingKey, encodedPayload)) {
+		return nil, errors.New("invalid token signature")
+	}
+	rawPayload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	payload := &magicLinkPayload{}
+	if err := json.Unmarshal(rawPayload, payload); err != nil {
+		return nil, fmt.Errorf("malformed token payload: %w", err)
+	}
+	return payload, nil
+}
+
+func sign(signingKey, data string) []byte {
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
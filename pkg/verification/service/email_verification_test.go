@@ -0,0 +1,391 @@
+package service_test
+
+import (
+	gocontext "context"
+	"errors"
+	"maps"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
+	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var magicLinkMatcher = regexp.MustCompile(`https://\S+`)
+
+func (s *TestVerificationServiceSuite) emailConfiguration(settings map[string]string) {
+	data := map[string]string{
+		"verification.methods":     "phone,email",
+		"verification.codeHashKey": "hash-key",
+	}
+	maps.Copy(data, settings)
+	s.SetSettings(data, testconfig.RegistrationService().
+		Verification().AttemptsAllowed(3).
+		Verification().DailyLimit(3).
+		Verification().CodeExpiresInMin(5))
+}
+
+func newEmailVerificationService(s *TestVerificationServiceSuite, initObjs ...client.Object) (*commontest.FakeClient, *verificationservice.ServiceImpl, *fake.MailSender) {
+	fakeClient := commontest.NewFakeClient(s.T(), initObjs...)
+	mailSender := &fake.MailSender{}
+	cl := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+	return fakeClient, &verificationservice.ServiceImpl{
+		Client:      cl,
+		MailService: mailSender,
+		RateLimiter: ratelimit.NewLimiter(cl),
+	}, mailSender
+}
+
+func (s *TestVerificationServiceSuite) TestInitEmailVerification() {
+	s.Run("verification code sent", func() {
+		// given
+		s.emailConfiguration(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithEmail("johnny@kubesaw.io"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.NoError(s.T(), err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "0", signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
+		assert.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
+		assert.NotEmpty(s.T(), signup.Annotations[toolchainv1alpha1.UserVerificationExpiryAnnotationKey])
+		assert.Equal(s.T(), "email", signup.Annotations[verificationservice.VerificationMethodAnnotationKey])
+		sent := mailSender.Sent()
+		require.Len(s.T(), sent, 1)
+		assert.Equal(s.T(), "johnny@kubesaw.io", sent[0].To)
+		assert.Equal(s.T(), "Developer Sandbox verification code", sent[0].Subject)
+		s.assertCodeSent(signup, sent[0].Body)
+	})
+
+	s.Run("email verification not allowed", func() {
+		// given
+		s.emailConfiguration(map[string]string{
+			"verification.methods": "phone",
+		})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, svc, mailSender := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "verification method not allowed: email verification is not available")
+		assert.Empty(s.T(), mailSender.Sent())
+	})
+
+	s.Run("daily limit exceeded", func() {
+		// given
+		s.emailConfiguration(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "3"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey, time.Now().Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, svc, mailSender := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "daily limit exceeded: cannot generate new verification code")
+		assert.Empty(s.T(), mailSender.Sent())
+	})
+
+	s.Run("mail not sent", func() {
+		// given
+		s.emailConfiguration(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		mailSender.Err = errors.New("connection refused")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "connection refused: error while sending verification code")
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+	})
+
+	s.Run("verification not required", func() {
+		// given
+		s.emailConfiguration(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"))
+		_, svc, _ := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "forbidden request: verification code will not be sent")
+	})
+}
+
+func (s *TestVerificationServiceSuite) TestVerifyEmail() {
+	settings := map[string]string{
+		"verification.email.magicLinkURL":        "https://sandbox.dev/verify-email?source=email",
+		"verification.email.magicLinkSigningKey": "signing-key",
+	}
+
+	// initVerification sends the verification email, and returns the code and the token of the magic link
+	initVerification := func(svc *verificationservice.ServiceImpl, mailSender *fake.MailSender, username string) (string, string) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		err := svc.InitEmailVerification(ctx, username)
+		require.NoError(s.T(), err)
+		sent := mailSender.Sent()
+		require.Len(s.T(), sent, 1)
+		link, err := url.Parse(magicLinkMatcher.FindString(sent[0].Body))
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "email", link.Query().Get("source"))
+		code := strings.TrimPrefix(strings.SplitN(sent[0].Body, "\n", 2)[0], "Your Developer Sandbox verification code is ")
+		return code, link.Query().Get("token")
+	}
+
+	newUserSignup := func() *toolchainv1alpha1.UserSignup {
+		return testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+	}
+
+	s.Run("with code", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := newUserSignup()
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		code, _ := initVerification(svc, mailSender, "johnny@kubesaw")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailCode(ctx, "johnny@kubesaw", code)
+
+		// then
+		require.NoError(s.T(), err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.False(s.T(), states.VerificationRequired(signup))
+		assert.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+		assert.Empty(s.T(), signup.Annotations[verificationservice.VerificationMethodAnnotationKey])
+	})
+
+	s.Run("with magic link", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := newUserSignup()
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		_, token := initVerification(svc, mailSender, "johnny@kubesaw")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailLink(ctx, "johnny@kubesaw", token)
+
+		// then
+		require.NoError(s.T(), err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.False(s.T(), states.VerificationRequired(signup))
+
+		s.Run("link can only be used once", func() {
+			// when
+			err := svc.VerifyEmailLink(ctx, "johnny@kubesaw", token)
+
+			// then
+			require.Error(s.T(), err)
+		})
+	})
+
+	s.Run("invalid code", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := newUserSignup()
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		initVerification(svc, mailSender, "johnny@kubesaw")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailCode(ctx, "johnny@kubesaw", "invalid")
+
+		// then
+		require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), states.VerificationRequired(signup))
+		assert.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
+	})
+
+	s.Run("invalid magic link", func() {
+		// given
+		s.emailConfiguration(settings)
+		_, svc, mailSender := newEmailVerificationService(s, newUserSignup())
+		_, token := initVerification(svc, mailSender, "johnny@kubesaw")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		s.Run("tampered token", func() {
+			// when
+			err := svc.VerifyEmailLink(ctx, "johnny@kubesaw", "x"+token)
+
+			// then
+			require.EqualError(s.T(), err, "invalid link: the provided link is invalid")
+		})
+
+		s.Run("token signed with another key", func() {
+			// given
+			s.emailConfiguration(map[string]string{
+				"verification.email.magicLinkSigningKey": "another-key",
+			})
+
+			// when
+			err := svc.VerifyEmailLink(ctx, "johnny@kubesaw", token)
+
+			// then
+			require.EqualError(s.T(), err, "invalid link: the provided link is invalid")
+		})
+
+		s.Run("token issued to another user", func() {
+			// given
+			s.emailConfiguration(settings)
+
+			// when
+			err := svc.VerifyEmailLink(ctx, "jsmith@kubesaw", token)
+
+			// then
+			require.EqualError(s.T(), err, "invalid link: the provided link is invalid")
+		})
+	})
+
+	s.Run("expired code", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456"),
+			testusersignup.WithAnnotation(verificationservice.VerificationMethodAnnotationKey, "email"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, time.Now().Add(-10*time.Second).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, svc, _ := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailCode(ctx, "johnny@kubesaw", "123456")
+
+		// then
+		require.EqualError(s.T(), err, "expired: verification code expired")
+	})
+
+	s.Run("code sent for the phone verification", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, time.Now().Add(time.Minute).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, _ := newEmailVerificationService(s, userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailCode(ctx, "johnny@kubesaw", "123456")
+
+		// then
+		require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), states.VerificationRequired(signup))
+		assert.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
+	})
+
+	s.Run("magic link valid after the code expired", func() {
+		// given
+		s.emailConfiguration(settings)
+		userSignup := newUserSignup()
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		_, token := initVerification(svc, mailSender, "johnny@kubesaw")
+		signup := &toolchainv1alpha1.UserSignup{}
+		err := fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		signup.Annotations[toolchainv1alpha1.UserVerificationExpiryAnnotationKey] = time.Now().Add(-10 * time.Second).Format(verificationservice.TimestampLayout)
+		err = fakeClient.Update(gocontext.TODO(), signup)
+		require.NoError(s.T(), err)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err = svc.VerifyEmailLink(ctx, "johnny@kubesaw", token)
+
+		// then
+		require.NoError(s.T(), err)
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.False(s.T(), states.VerificationRequired(signup))
+	})
+
+	s.Run("expired magic link", func() {
+		// given
+		s.emailConfiguration(map[string]string{
+			"verification.email.magicLinkURL":        "https://sandbox.dev/verify-email?source=email",
+			"verification.email.magicLinkSigningKey": "signing-key",
+			"verification.email.magicLinkExpiresIn":  "1ns",
+		})
+		userSignup := newUserSignup()
+		fakeClient, svc, mailSender := newEmailVerificationService(s, userSignup)
+		code, token := initVerification(svc, mailSender, "johnny@kubesaw")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.VerifyEmailLink(ctx, "johnny@kubesaw", token)
+
+		// then
+		require.EqualError(s.T(), err, "expired: verification link expired")
+
+		s.Run("code is still valid", func() {
+			// when
+			err := svc.VerifyEmailCode(ctx, "johnny@kubesaw", code)
+
+			// then
+			require.NoError(s.T(), err)
+			signup := &toolchainv1alpha1.UserSignup{}
+			err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+			require.NoError(s.T(), err)
+			assert.False(s.T(), states.VerificationRequired(signup))
+		})
+	})
+}
@@ -5,15 +5,20 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	signuppkg "github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
 	signupsvc "github.com/codeready-toolchain/registration-service/pkg/signup/service"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
 	"github.com/codeready-toolchain/registration-service/pkg/verification/sender"
 	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,8 +36,13 @@ import (
 )
 
 const (
-	codeCharset = "0123456789"
-	codeLength  = 6
+	// VoiceVerificationCounterAnnotationKey is set on the UserSignups and contains the number of verification codes sent
+	// by voice call within the last 24 hours. The codes sent by SMS or email are counted in the verification counter annotation.
+	VoiceVerificationCounterAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "verification-voice-counter"
+	// VerificationMethodAnnotationKey is set on the UserSignups and contains the method (`phone` or `email`) which the
+	// current verification code was sent for, so that the code is only accepted for that method.
+	// The codes sent before this annotation was introduced were all sent for the phone verification.
+	VerificationMethodAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "verification-method"
 
 	TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
 )
@@ -42,28 +52,52 @@ type ServiceImpl struct { // nolint:revive
 	namespaced.Client
 	HTTPClient          *http.Client
 	NotificationService sender.NotificationSender
+	VoiceCallService    sender.VoiceCallSender
+	MailService         sender.MailSender
 	SignupService       service.SignupService
+	RateLimiter         *ratelimit.Limiter
+	EventPublisher      *events.Publisher
 }
 
 type VerificationServiceOption func(svc *ServiceImpl)
 
+// WithEventPublisher publishes the signup lifecycle events with the given publisher
+func WithEventPublisher(publisher *events.Publisher) VerificationServiceOption {
+	return func(svc *ServiceImpl) {
+		svc.EventPublisher = publisher
+	}
+}
+
 // NewVerificationService creates a service object for performing user verification
-func NewVerificationService(client namespaced.Client) service.VerificationService {
+func NewVerificationService(client namespaced.Client, opts ...VerificationServiceOption) service.VerificationService {
 	httpClient := &http.Client{
 		Timeout:   30*time.Second + 500*time.Millisecond, // taken from twilio code
 		Transport: http.DefaultTransport,
 	}
-	return &ServiceImpl{
+	notificationSender := sender.CreateNotificationSender(httpClient)
+	svc := &ServiceImpl{
 		Client:              client,
-		NotificationService: sender.CreateNotificationSender(httpClient),
-		SignupService:       signupsvc.NewSignupService(client),
+		NotificationService: notificationSender,
+		VoiceCallService:    notificationSender,
+		MailService:         sender.NewSMTPSender(configuration.GetRegistrationServiceConfig().Verification()),
+		RateLimiter:         ratelimit.NewLimiter(client),
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	svc.SignupService = signupsvc.NewSignupService(client, signupsvc.WithEventPublisher(svc.EventPublisher))
+	return svc
 }
 
 // InitVerification sends a verification message to the specified user, using the Twilio service.  If successful,
-// the user will receive a verification SMS.  The UserSignup resource is updated with a number of annotations in order
-// to manage the phone verification process and protect against system abuse.
-func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumber, countryCode string) error {
+// the user will receive a verification SMS, or a voice call reading the code if the `voice` channel is requested.
+// The UserSignup resource is updated with a number of annotations in order to manage the phone verification process
+// and protect against system abuse.
+func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumber, countryCode, channel string) error {
+	if err := checkVerificationMethodAllowed(ctx, configuration.VerificationMethodPhone); err != nil {
+		return err
+	}
+
 	signup := &toolchainv1alpha1.UserSignup{}
 	if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
 		if apierrors.IsNotFound(err) {
@@ -75,7 +109,6 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 	}
 
 	labelValues := map[string]string{}
-	annotationValues := map[string]string{}
 
 	// check that verification is required before proceeding
 	if !states.VerificationRequired(signup) {
@@ -103,22 +136,54 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 	// Always set the phone hash label to indicate verification was initiated
 	labelValues[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey] = phoneHash
 
-	// get the verification counter (i.e. the number of times the user has initiated phone verification within
-	// the last 24 hours)
-	verificationCounter := signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey]
-	var counter int
 	cfg := configuration.GetRegistrationServiceConfig()
+	rateLimitKeys := []ratelimit.Key{
+		ratelimit.ClientIP(ctx),
+		ratelimit.PhoneNumber(e164PhoneNumber),
+		ratelimit.CountryCode(countryCode),
+		ratelimit.Global(),
+	}
+	return s.sendVerificationCode(ctx, username, signup, configuration.VerificationMethodPhone, channel, labelValues, rateLimitKeys, func(verificationCode string) (string, error) {
+		if channel == configuration.VerificationChannelVoice {
+			// spell out the code, so that the characters are read one by one
+			content := fmt.Sprintf(cfg.Verification().VoiceMessageTemplate(), strings.Join(strings.Split(verificationCode, ""), ", "))
+			return "", s.VoiceCallService.SendVoiceCall(ctx, content, e164PhoneNumber, countryCode)
+		}
+
+		// Generate the verification message with the new verification code
+		content := fmt.Sprintf(cfg.Verification().MessageTemplate(), verificationCode)
+
+		// Attempt to send notification
+		return s.NotificationService.SendNotification(ctx, content, e164PhoneNumber, countryCode)
+	})
+}
+
+// sendVerificationCode generates a new verification code and sends it to the user with the given function, unless the
+// daily limit of verification requests of the user for the channel or one of the rate limits of the given keys has been reached.
+// The function returns the ID given by the provider to the message sent by SMS, if any.
+// The UserSignup is always updated with the given labels, and with the annotations used to verify the code if it was sent successfully.
+// The delivery status of the previous SMS is removed when the code is sent with another channel.
+func (s *ServiceImpl) sendVerificationCode(ctx *gin.Context, username string, signup *toolchainv1alpha1.UserSignup, method, channel string,
+	labelValues map[string]string, rateLimitKeys []ratelimit.Key, send func(verificationCode string) (string, error)) error {
+	annotationValues := map[string]string{}
+	var annotationsToDelete []string
+	cfg := configuration.GetRegistrationServiceConfig()
+
+	// get the verification counter of the channel (i.e. the number of times the user has initiated verification
+	// with this channel within the last 24 hours)
+	counterAnnotationKey, dailyLimit := dailyLimitOf(cfg.Verification(), channel)
+	verificationCounter := signup.Annotations[counterAnnotationKey]
+	var counter int
 
-	dailyLimit := cfg.Verification().DailyLimit()
 	if verificationCounter != "" {
+		var err error
 		counter, err = strconv.Atoi(verificationCounter)
 		if err != nil {
 			// We shouldn't get an error here, but if we do, we should probably set verification counter to the daily
 			// limit so that we at least now have a valid value
 			log.Error(ctx, err, fmt.Sprintf("error converting annotation [%s] value [%s] to integer, on UserSignup: [%s]",
-				toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey,
-				signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey], signup.Name))
-			annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = strconv.Itoa(dailyLimit)
+				counterAnnotationKey, verificationCounter, signup.Name))
+			annotationValues[counterAnnotationKey] = strconv.Itoa(dailyLimit)
 			counter = dailyLimit
 		}
 	}
@@ -132,6 +197,7 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 		// Set a new timestamp
 		annotationValues[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey] = now.Format(TimestampLayout)
 		annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = "0"
+		annotationValues[VoiceVerificationCounterAnnotationKey] = "0"
 		counter = 0
 	}
 
@@ -139,41 +205,54 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 
 	// check if counter has exceeded the limit of daily limit - if at limit error out
 	if counter >= dailyLimit {
-		log.Error(ctx, err, fmt.Sprintf("%d attempts made. the daily limit of %d has been exceeded", counter, dailyLimit))
+		log.Error(ctx, nil, fmt.Sprintf("%d attempts made. the daily limit of %d has been exceeded", counter, dailyLimit))
 		initError = crterrors.NewForbiddenError("daily limit exceeded", "cannot generate new verification code")
+	} else if err := s.RateLimiter.Allow(ctx, rateLimitKeys...); err != nil {
+		initError = err
 	} else {
 		// generate verification code
-		verificationCode, err := generateVerificationCode()
+		verificationCode, err := generateVerificationCode(cfg.Verification())
+		if err != nil {
+			return crterrors.NewInternalError(err, "error while generating verification code")
+		}
+		// only the hash of the code is stored in the UserSignup, if a hash key is configured
+		storedCode, err := hashVerificationCode(cfg.Verification(), verificationCode)
 		if err != nil {
 			return crterrors.NewInternalError(err, "error while generating verification code")
 		}
 
-		// Generate the verification message with the new verification code
-		content := fmt.Sprintf(cfg.Verification().MessageTemplate(), verificationCode)
-
-		// Attempt to send notification
-		err = s.NotificationService.SendNotification(ctx, content, e164PhoneNumber, countryCode)
+		// Attempt to send the verification code
+		messageID, err := send(verificationCode)
 		if err != nil {
 			log.Error(ctx, err, "error while sending notification")
 			initError = crterrors.NewInternalError(err, "error while sending verification code")
 		} else {
 			// Notification sent successfully, set the verification annotations
 			annotationValues[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey] = "0"
-			annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = strconv.Itoa(counter + 1)
-			annotationValues[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey] = verificationCode
+			annotationValues[counterAnnotationKey] = strconv.Itoa(counter + 1)
+			annotationValues[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey] = storedCode
+			annotationValues[VerificationMethodAnnotationKey] = method
 			annotationValues[toolchainv1alpha1.UserVerificationExpiryAnnotationKey] = now.Add(
 				time.Duration(cfg.Verification().CodeExpiresInMin()) * time.Minute).Format(TimestampLayout)
+			if channel == configuration.VerificationChannelSMS {
+				// the final status is reported asynchronously by the provider, with the ID of the message
+				annotationValues[delivery.StatusAnnotationKey] = delivery.StatusSent
+				annotationValues[delivery.MessageIDAnnotationKey] = messageID
+			} else {
+				// the delivery status of a previous SMS doesn't apply to this code
+				annotationsToDelete = append(annotationsToDelete, delivery.StatusAnnotationKey, delivery.MessageIDAnnotationKey)
+			}
 		}
 	}
 
-	// Single update operation: always set phone hash label, set annotations only if notification was sent
+	// Single update operation: always set the labels, set annotations only if notification was sent
 	doUpdate := func() error {
 		signup := &toolchainv1alpha1.UserSignup{}
 		if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
 			return err
 		}
 
-		// Always set the phone hash label to indicate verification was initiated
+		// Always set the labels to indicate verification was initiated
 		if signup.Labels == nil {
 			signup.Labels = map[string]string{}
 		}
@@ -188,6 +267,9 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 		for k, v := range annotationValues {
 			signup.Annotations[k] = v
 		}
+		for _, k := range annotationsToDelete {
+			delete(signup.Annotations, k)
+		}
 
 		return s.Update(gocontext.TODO(), signup)
 	}
@@ -195,33 +277,62 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 	updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate)
 	if updateErr != nil {
 		log.Error(ctx, updateErr, "error updating UserSignup")
-		return errors.New("there was an error while updating your account - please wait a moment before " +
-			"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com for " +
-			"assistance: error while verifying phone code")
+		return newUpdateError(method)
 	}
 
 	return initError
 }
 
-func generateVerificationCode() (string, error) {
-	buf := make([]byte, codeLength)
-	if _, err := rand.Read(buf); err != nil {
-		return "", err
+// newUpdateError returns the error returned to the user when the UserSignup could not be updated
+func newUpdateError(method string) error {
+	return fmt.Errorf("there was an error while updating your account - please wait a moment before "+
+		"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com for "+
+		"assistance: error while verifying %s code", method)
+}
+
+// checkVerificationMethodAllowed returns a Forbidden error if the given verification method is not allowed in this deployment
+func checkVerificationMethodAllowed(ctx *gin.Context, method string) error {
+	if !configuration.GetRegistrationServiceConfig().Verification().VerificationMethodAllowed(method) {
+		log.Info(ctx, fmt.Sprintf("%s verification attempted while it is not allowed", method))
+		return crterrors.NewForbiddenError("verification method not allowed", fmt.Sprintf("%s verification is not available", method))
 	}
+	return nil
+}
 
-	charsetLen := len(codeCharset)
-	for i := 0; i < codeLength; i++ {
-		buf[i] = codeCharset[int(buf[i])%charsetLen]
+// dailyLimitOf returns the key of the annotation counting the verification codes sent with the given channel,
+// and the maximum number of codes which can be sent with this channel within 24 hours
+func dailyLimitOf(cfg configuration.VerificationConfig, channel string) (string, int) {
+	if channel == configuration.VerificationChannelVoice {
+		return VoiceVerificationCounterAnnotationKey, cfg.VoiceDailyLimit()
 	}
+	return toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, cfg.DailyLimit()
+}
 
-	return string(buf), nil
+// generateVerificationCode returns a random code with the configured length and characters.
+// Each character is picked uniformly from the charset, without the bias of a modulo on random bytes.
+func generateVerificationCode(cfg configuration.VerificationConfig) (string, error) {
+	charset := []rune(cfg.VerificationCodeCharset())
+	charsetLen := big.NewInt(int64(len(charset)))
+
+	code := make([]rune, cfg.VerificationCodeLength())
+	for i := range code {
+		n, err := rand.Int(rand.Reader, charsetLen)
+		if err != nil {
+			return "", err
+		}
+		code[i] = charset[n.Int64()]
+	}
+
+	return string(code), nil
 }
 
 // VerifyPhoneCode validates the user's phone verification code.  It updates the specified UserSignup value, so even
 // if an error is returned by this function the caller should still process changes to it
-func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (verificationErr error) {
+func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) error {
+	if err := checkVerificationMethodAllowed(ctx, configuration.VerificationMethodPhone); err != nil {
+		return err
+	}
 
-	cfg := configuration.GetRegistrationServiceConfig()
 	// If we can't even find the UserSignup, then die here
 	signup := &toolchainv1alpha1.UserSignup{}
 	if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
@@ -233,40 +344,52 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
 	}
 
+	if err := checkCaptchaScore(ctx, signup); err != nil {
+		return err
+	}
+
+	err := PhoneNumberAlreadyInUse(s.Client, username, signup.Labels[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey])
+	if err != nil {
+		log.Error(ctx, err, "phone number to verify already in use")
+		return crterrors.NewBadRequest("phone number already in use",
+			"the phone number provided for this signup is already in use by an active account")
+	}
+
+	return s.verifyCode(ctx, username, signup, configuration.VerificationMethodPhone, code, nil)
+}
+
+// checkCaptchaScore requires the manual approval of the signup if the captcha score of the user is too low,
+// unless it's a reactivation and low scores are allowed for reactivations.
+func checkCaptchaScore(ctx *gin.Context, signup *toolchainv1alpha1.UserSignup) error {
+	cfg := configuration.GetRegistrationServiceConfig()
 	// check if it's a reactivation
 	if activationCounterString, foundActivationCounter := signup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; foundActivationCounter && cfg.Verification().CaptchaAllowLowScoreReactivation() {
 		activationCounter, err := strconv.Atoi(activationCounterString)
 		if err != nil {
 			log.Error(ctx, err, "activation counter is not an integer value, checking required captcha score")
 			// require manual approval if captcha score below automatic verification threshold
-			if err = checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-				return err
-			}
+			return checkRequiredManualApproval(ctx, signup, cfg)
 		} else if activationCounter == 1 {
 			// check required captcha score if it's not a reactivation
-			if err = checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-				return err
-			}
-		}
-	} else {
-		// when allowLowScoreReactivation is not enabled or no activation counter found
-		// require manual approval if captcha score below automatic verification threshold for all users
-		if err := checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-			return err
+			return checkRequiredManualApproval(ctx, signup, cfg)
 		}
+		return nil
 	}
+	// when allowLowScoreReactivation is not enabled or no activation counter found
+	// require manual approval if captcha score below automatic verification threshold for all users
+	return checkRequiredManualApproval(ctx, signup, cfg)
+}
 
+// verifyCode checks the given code against the verification code stored in the annotations of the UserSignup,
+// with the limits on the number of attempts and on the expiry of the code. The code must have been sent for the given method.
+// If linkExpiry is not nil, then the code was given by a magic link, and the expiry of the link applies instead of the one of the code.
+// If the code is valid, then the signup doesn't require verification anymore.
+func (s *ServiceImpl) verifyCode(ctx *gin.Context, username string, signup *toolchainv1alpha1.UserSignup, method, code string, linkExpiry *time.Time) (verificationErr error) {
+	cfg := configuration.GetRegistrationServiceConfig()
 	annotationValues := map[string]string{}
 	annotationsToDelete := []string{}
 	unsetVerificationRequired := false
 
-	err := PhoneNumberAlreadyInUse(s.Client, username, signup.Labels[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey])
-	if err != nil {
-		log.Error(ctx, err, "phone number to verify already in use")
-		return crterrors.NewBadRequest("phone number already in use",
-			"the phone number provided for this signup is already in use by an active account")
-	}
-
 	now := time.Now()
 
 	attemptsMade, convErr := strconv.Atoi(signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
@@ -287,19 +410,26 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 	}
 
 	if verificationErr == nil {
-		// Parse the verification expiry timestamp
-		exp, parseErr := time.Parse(TimestampLayout, signup.Annotations[toolchainv1alpha1.UserVerificationExpiryAnnotationKey])
-		if parseErr != nil {
-			// If the verification expiry timestamp is corrupt or missing, then return an error
-			verificationErr = crterrors.NewInternalError(parseErr, "error parsing expiry timestamp")
-		} else if now.After(exp) {
-			// If it is now past the expiry timestamp for the verification code, return a 403 Forbidden error
-			verificationErr = crterrors.NewForbiddenError("expired", "verification code expired")
+		if linkExpiry != nil {
+			if now.After(*linkExpiry) {
+				verificationErr = crterrors.NewForbiddenError("expired", "verification link expired")
+			}
+		} else {
+			// Parse the verification expiry timestamp
+			exp, parseErr := time.Parse(TimestampLayout, signup.Annotations[toolchainv1alpha1.UserVerificationExpiryAnnotationKey])
+			if parseErr != nil {
+				// If the verification expiry timestamp is corrupt or missing, then return an error
+				verificationErr = crterrors.NewInternalError(parseErr, "error parsing expiry timestamp")
+			} else if now.After(exp) {
+				// If it is now past the expiry timestamp for the verification code, return a 403 Forbidden error
+				verificationErr = crterrors.NewForbiddenError("expired", "verification code expired")
+			}
 		}
 	}
 
 	if verificationErr == nil {
-		if code != signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey] {
+		if codeMethod(signup) != method ||
+			!verificationCodeMatches(cfg.Verification(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey], code) {
 			// The code doesn't match
 			attemptsMade++
 			annotationValues[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey] = strconv.Itoa(attemptsMade)
@@ -311,14 +441,19 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 		// If the code matches then set VerificationRequired to false, reset other verification annotations
 		unsetVerificationRequired = true
 		annotationsToDelete = append(annotationsToDelete, toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey)
+		annotationsToDelete = append(annotationsToDelete, VerificationMethodAnnotationKey)
 		annotationsToDelete = append(annotationsToDelete, toolchainv1alpha1.UserVerificationAttemptsAnnotationKey)
 		annotationsToDelete = append(annotationsToDelete, toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey)
+		annotationsToDelete = append(annotationsToDelete, VoiceVerificationCounterAnnotationKey)
+		annotationsToDelete = append(annotationsToDelete, delivery.StatusAnnotationKey)
+		annotationsToDelete = append(annotationsToDelete, delivery.MessageIDAnnotationKey)
 		annotationsToDelete = append(annotationsToDelete, toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey)
 		annotationsToDelete = append(annotationsToDelete, toolchainv1alpha1.UserVerificationExpiryAnnotationKey)
 	} else {
 		log.Error(ctx, verificationErr, "error validating verification code")
 	}
 
+	var verified *toolchainv1alpha1.UserSignup
 	doUpdate := func() error {
 		signup := &toolchainv1alpha1.UserSignup{}
 		if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
@@ -347,20 +482,32 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 			return err
 		}
 
+		if unsetVerificationRequired {
+			verified = signup
+		}
 		return nil
 	}
 
 	updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate)
 	if updateErr != nil {
 		log.Error(ctx, updateErr, "error updating UserSignup")
-		return errors.New("there was an error while updating your account - please wait a moment before " +
-			"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com for " +
-			"assistance: error while verifying phone code")
+		return newUpdateError(method)
 	}
 
+	if verified != nil {
+		s.EventPublisher.Publish(events.NewVerifiedEvent(verified, method))
+	}
 	return
 }
 
+// codeMethod returns the verification method which the current code of the given UserSignup was sent for
+func codeMethod(signup *toolchainv1alpha1.UserSignup) string {
+	if method := signup.Annotations[VerificationMethodAnnotationKey]; method != "" {
+		return method
+	}
+	return configuration.VerificationMethodPhone
+}
+
 // checkRequiredManualApproval compares the user captcha score with the configured required captcha score.
 // When the user score is lower than the required score an error is returned meaning that the user is considered "suspicious" and manual approval of the signup is required.
 func checkRequiredManualApproval(ctx *gin.Context, signup *toolchainv1alpha1.UserSignup, cfg configuration.RegistrationServiceConfig) error {
@@ -390,7 +537,11 @@ func (s *ServiceImpl) VerifyActivationCode(ctx *gin.Context, username, code stri
 	if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
 		if apierrors.IsNotFound(err) {
 			// signup user
-			ctx.Set(context.SocialEvent, code)
+			if signuppkg.IsInvitationCode(code) {
+				ctx.Set(context.InvitationCode, code)
+			} else {
+				ctx.Set(context.SocialEvent, code)
+			}
 			_, err = s.SignupService.Signup(ctx)
 			return err
 		}
@@ -402,6 +553,7 @@ func (s *ServiceImpl) VerifyActivationCode(ctx *gin.Context, username, code stri
 		return err
 	}
 	var errToReturn error
+	var verified *toolchainv1alpha1.UserSignup
 	doUpdate := func() error {
 		signup := &toolchainv1alpha1.UserSignup{}
 		if err := s.Get(gocontext.TODO(), s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
@@ -410,14 +562,11 @@ func (s *ServiceImpl) VerifyActivationCode(ctx *gin.Context, username, code stri
 		if signup.Annotations == nil {
 			signup.Annotations = map[string]string{}
 		}
-		event, err := signuppkg.GetAndValidateSocialEvent(ctx, s.Client, code)
-		if err != nil {
+		if err := s.applyActivationCode(ctx, code, signup); err != nil {
 			attemptsMade++
 			signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey] = strconv.Itoa(attemptsMade)
 			errToReturn = err
 		} else {
-			log.Infof(ctx, "approving user signup request with activation code '%s'", code)
-			signuppkg.UpdateUserSignupWithSocialEvent(event, signup)
 			delete(signup.Annotations, toolchainv1alpha1.UserVerificationAttemptsAnnotationKey)
 		}
 
@@ -425,6 +574,9 @@ func (s *ServiceImpl) VerifyActivationCode(ctx *gin.Context, username, code stri
 			return err
 		}
 
+		if errToReturn == nil {
+			verified = signup
+		}
 		return nil
 	}
 	if err := signuppkg.PollUpdateSignup(ctx, doUpdate); err != nil {
@@ -432,11 +584,28 @@ func (s *ServiceImpl) VerifyActivationCode(ctx *gin.Context, username, code stri
 		if errToReturn == nil {
 			errToReturn = err
 		}
+	} else if verified != nil {
+		s.EventPublisher.Publish(events.NewVerifiedEvent(verified, events.VerificationMethodActivationCode))
 	}
 
 	return errToReturn
 }
 
+// applyActivationCode updates the given UserSignup with the invitation or the social event matching the given activation code
+func (s *ServiceImpl) applyActivationCode(ctx *gin.Context, code string, signup *toolchainv1alpha1.UserSignup) error {
+	if signuppkg.IsInvitationCode(code) {
+		log.Info(ctx, "approving user signup request with an invitation code")
+		return signuppkg.RedeemInvitation(ctx, s.Client, code, signup)
+	}
+	event, err := signuppkg.GetAndValidateSocialEvent(ctx, s.Client, code)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "approving user signup request with activation code '%s'", code)
+	signuppkg.UpdateUserSignupWithSocialEvent(event, signup)
+	return nil
+}
+
 var (
 	md5Matcher = regexp.MustCompile("(?i)[a-f0-9]{32}$")
 )
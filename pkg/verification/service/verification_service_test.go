@@ -3,17 +3,27 @@ package service_test
 import (
 	"bytes"
 	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	signuppkg "github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/delivery"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/ratelimit"
 	senderpkg "github.com/codeready-toolchain/registration-service/pkg/verification/sender"
 	testutil "github.com/codeready-toolchain/registration-service/test/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -23,6 +33,7 @@ import (
 	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
 	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
@@ -71,9 +82,10 @@ func (s *TestVerificationServiceSuite) ServiceConfiguration(accountSID, authToke
 			Namespace: ns,
 		},
 		Data: map[string][]byte{
-			twilioSIDKey:        []byte(accountSID),
-			twilioTokenKey:      []byte(authToken),
-			twilioFromNumberKey: []byte(fromNumber),
+			twilioSIDKey:               []byte(accountSID),
+			twilioTokenKey:             []byte(authToken),
+			twilioFromNumberKey:        []byte(fromNumber),
+			"verification.codeHashKey": []byte("hash-key"),
 			// Set the following two values to manually test with AWS
 			awsAccessKeyIDKey:  []byte(""),
 			awsSecretAccessKey: []byte(""),
@@ -101,6 +113,19 @@ func (s *TestVerificationServiceSuite) ServiceConfiguration(accountSID, authToke
 	s.SetSecret(secret)
 }
 
+// assertCodeSent checks that the message contains a verification code, which is stored as a hash in the UserSignup
+func (s *TestVerificationServiceSuite) assertCodeSent(signup *toolchainv1alpha1.UserSignup, message string) {
+	require.Regexp(s.T(), `^Your Developer Sandbox verification code is [0-9]{6}$`, message)
+	s.assertStoredCode(signup, strings.TrimPrefix(message, "Your Developer Sandbox verification code is "))
+}
+
+// assertStoredCode checks that the verification code stored in the UserSignup is a hash of the given code
+func (s *TestVerificationServiceSuite) assertStoredCode(signup *toolchainv1alpha1.UserSignup, code string) {
+	storedCode := signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]
+	assert.True(s.T(), strings.HasPrefix(storedCode, "hmac-sha256:"))
+	assert.NotContains(s.T(), storedCode, code)
+}
+
 func (s *TestVerificationServiceSuite) TestInitVerification() {
 	s.ServiceConfiguration("xxx", "yyy", "CodeReady")
 
@@ -134,7 +159,7 @@ func (s *TestVerificationServiceSuite) TestInitVerification() {
 
 	// Test the init verification for the first UserSignup
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1")
+	err := application.VerificationService().InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
 	require.NoError(s.T(), err)
 
 	signup := &toolchainv1alpha1.UserSignup{}
@@ -151,9 +176,7 @@ func (s *TestVerificationServiceSuite) TestInitVerification() {
 
 	params, err := url.ParseQuery(reqValue)
 	require.NoError(s.T(), err)
-	require.Equal(s.T(), fmt.Sprintf("Your Developer Sandbox verification code is %s",
-		signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]),
-		params.Get("Body"))
+	s.assertCodeSent(signup, params.Get("Body"))
 	require.Equal(s.T(), "CodeReady", params.Get("From"))
 	require.Equal(s.T(), "+1NUMBER", params.Get("To"))
 
@@ -170,7 +193,7 @@ func (s *TestVerificationServiceSuite) TestInitVerification() {
 
 	ctx, _ = gin.CreateTestContext(httptest.NewRecorder())
 	// for the second usersignup
-	err = application.VerificationService().InitVerification(ctx, "jsmith@kubesaw", "+61NUMBER", "1")
+	err = application.VerificationService().InitVerification(ctx, "jsmith@kubesaw", "+61NUMBER", "1", configuration.VerificationChannelSMS)
 	require.NoError(s.T(), err)
 
 	signup2 := &toolchainv1alpha1.UserSignup{}
@@ -187,27 +210,205 @@ func (s *TestVerificationServiceSuite) TestInitVerification() {
 
 	params, err = url.ParseQuery(reqValue)
 	require.NoError(s.T(), err)
-	require.Equal(s.T(), fmt.Sprintf("Your Developer Sandbox verification code is %s",
-		signup2.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]),
-		params.Get("Body"))
+	s.assertCodeSent(signup2, params.Get("Body"))
 	require.Equal(s.T(), "CodeReady", params.Get("From"))
 	require.Equal(s.T(), "+61NUMBER", params.Get("To"))
 }
 
+func (s *TestVerificationServiceSuite) TestVerificationCodeHashing() {
+	now := time.Now()
+	configureHashKey := func(hashKey string) {
+		s.ServiceConfiguration("xxx", "yyy", "CodeReady")
+		s.SetSecret(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      testSecretName,
+				Namespace: commontest.HostOperatorNs,
+			},
+			Data: map[string][]byte{
+				twilioSIDKey:               []byte("xxx"),
+				twilioTokenKey:             []byte("yyy"),
+				twilioFromNumberKey:        []byte("CodeReady"),
+				"verification.codeHashKey": []byte(hashKey),
+			},
+		})
+	}
+
+	s.Run("code sent and stored as a hash", func() {
+		// given
+		configureHashKey("hash-key")
+		defer gock.Off()
+		gock.New("https://api.twilio.com").
+			Reply(http.StatusNoContent).
+			BodyString("")
+		var reqBody io.ReadCloser
+		gock.Observe(func(request *http.Request, _ gock.Mock) {
+			reqBody = request.Body
+		})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := application.VerificationService().InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+
+		// then
+		require.NoError(s.T(), err)
+		buf := new(bytes.Buffer)
+		_, err = buf.ReadFrom(reqBody)
+		require.NoError(s.T(), err)
+		params, err := url.ParseQuery(buf.String())
+		require.NoError(s.T(), err)
+		code := strings.TrimPrefix(params.Get("Body"), "Your Developer Sandbox verification code is ")
+		require.Len(s.T(), code, 6)
+
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		storedCode := signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]
+		assert.True(s.T(), strings.HasPrefix(storedCode, "hmac-sha256:"))
+		assert.NotContains(s.T(), storedCode, code)
+
+		s.Run("invalid code rejected", func() {
+			// when
+			err := application.VerificationService().VerifyPhoneCode(ctx, "johnny@kubesaw", storedCode)
+
+			// then
+			require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+		})
+
+		s.Run("code verified", func() {
+			// when
+			err := application.VerificationService().VerifyPhoneCode(ctx, "johnny@kubesaw", code)
+
+			// then
+			require.NoError(s.T(), err)
+			err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+			require.NoError(s.T(), err)
+			require.False(s.T(), states.VerificationRequired(signup))
+		})
+	})
+
+	s.Run("code not sent without hash key", func() {
+		// given
+		configureHashKey("")
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := application.VerificationService().InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+
+		// then
+		// the key is not derived from the credentials of the notification sender
+		require.EqualError(s.T(), err, "no hash key for the verification codes: the 'verification.codeHashKey' setting is not configured: error while generating verification code")
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+	})
+
+	s.Run("hash key required when the verification is enabled", func() {
+		// given
+		s.SetSettings(map[string]string{}, testconfig.RegistrationService().Verification().Enabled(true))
+
+		// when
+		err := verificationservice.CheckCodeHashKey(configuration.GetRegistrationServiceConfig().Verification())
+
+		// then
+		require.EqualError(s.T(), err, "no hash key for the verification codes: the 'verification.codeHashKey' setting is not configured")
+
+		s.Run("configured", func() {
+			// given
+			s.SetSettings(map[string]string{
+				"verification.codeHashKey": "hash-key",
+			}, testconfig.RegistrationService().Verification().Enabled(true))
+
+			// when
+			err := verificationservice.CheckCodeHashKey(configuration.GetRegistrationServiceConfig().Verification())
+
+			// then
+			require.NoError(s.T(), err)
+		})
+
+		s.Run("not when the verification is disabled", func() {
+			// given
+			s.SetSettings(map[string]string{}, testconfig.RegistrationService().Verification().Enabled(false))
+
+			// when
+			err := verificationservice.CheckCodeHashKey(configuration.GetRegistrationServiceConfig().Verification())
+
+			// then
+			require.NoError(s.T(), err)
+		})
+	})
+
+	s.Run("plain text code sent before the migration still accepted", func() {
+		// given
+		configureHashKey("hash-key")
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, now.Add(10*time.Second).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := application.VerificationService().VerifyPhoneCode(ctx, "johnny@kubesaw", "123456")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("hashed code rejected when the hash key changed", func() {
+		// given
+		configureHashKey("hash-key")
+		salt := []byte("salt-salt-salt-s")
+		mac := hmac.New(sha256.New, []byte("previous-hash-key"))
+		mac.Write(salt)
+		mac.Write([]byte("123456"))
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey,
+				"hmac-sha256:"+base64.RawStdEncoding.EncodeToString(salt)+":"+base64.RawStdEncoding.EncodeToString(mac.Sum(nil))),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, now.Add(10*time.Second).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := application.VerificationService().VerifyPhoneCode(ctx, "johnny@kubesaw", "123456")
+
+		// then
+		require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+	})
+}
+
 func (s *TestVerificationServiceSuite) TestNotificationSender() {
 	s.OverrideApplicationDefault(
 		testconfig.RegistrationService().
 			Verification().NotificationSender("aWs"))
 
-	sender := senderpkg.CreateNotificationSender(nil)
-	require.IsType(s.T(), &senderpkg.AmazonSNSSender{}, sender)
+	registry := senderpkg.CreateNotificationSender(nil)
+	require.Equal(s.T(), []string{"aws"}, registry.Route("1"))
+	aws, found := registry.Provider("aws")
+	require.True(s.T(), found)
+	require.IsType(s.T(), &senderpkg.AmazonSNSSender{}, aws)
+	twilio, found := registry.Provider("twilio")
+	require.True(s.T(), found)
+	require.IsType(s.T(), &senderpkg.TwilioNotificationSender{}, twilio)
 
 	s.OverrideApplicationDefault(
 		testconfig.RegistrationService().
 			Verification().NotificationSender(""))
 
-	sender = senderpkg.CreateNotificationSender(nil)
-	require.IsType(s.T(), &senderpkg.TwilioNotificationSender{}, sender)
+	require.Equal(s.T(), []string{"twilio"}, registry.Route("1"))
 }
 
 func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
@@ -244,7 +445,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
 		}
 
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 		require.EqualError(s.T(), err, "get failed: error retrieving usersignup with username 'johnny@kubesaw'", err.Error())
 	})
 
@@ -258,7 +459,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
 		}
 
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 		require.EqualError(s.T(), err, "there was an error while updating your account - please wait a moment before "+
 			"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com "+
 			"for assistance: error while verifying phone code")
@@ -278,7 +479,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
 		}
 
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 		require.NoError(s.T(), err)
 
 		signup := &toolchainv1alpha1.UserSignup{}
@@ -294,9 +495,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
 
 		params, err := url.ParseQuery(reqValue)
 		require.NoError(s.T(), err)
-		require.Equal(s.T(), fmt.Sprintf("Your Developer Sandbox verification code is %s",
-			signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]),
-			params.Get("Body"))
+		s.assertCodeSent(signup, params.Get("Body"))
 		require.Equal(s.T(), "CodeReady", params.Get("From"))
 		require.Equal(s.T(), "+1NUMBER", params.Get("To"))
 	})
@@ -319,7 +518,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationClientFailure() {
 		// when:
 		// InitVerification is called and notification sending fails
 		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-		err := application.VerificationService().InitVerification(ctx, userSignupWithoutPhoneHash.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+		err := application.VerificationService().InitVerification(ctx, userSignupWithoutPhoneHash.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 
 		// then
 		// The function should return an error because notification sending failed
@@ -369,7 +568,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationPassesWhenMaxCountRea
 	fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
 
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 	require.NoError(s.T(), err)
 
 	signup := &toolchainv1alpha1.UserSignup{}
@@ -385,9 +584,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationPassesWhenMaxCountRea
 
 	params, err := url.ParseQuery(reqValue)
 	require.NoError(s.T(), err)
-	require.Equal(s.T(), fmt.Sprintf("Your Developer Sandbox verification code is %s",
-		signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey]),
-		params.Get("Body"))
+	s.assertCodeSent(signup, params.Get("Body"))
 	require.Equal(s.T(), "CodeReady", params.Get("From"))
 	require.Equal(s.T(), "+1NUMBER", params.Get("To"))
 	require.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
@@ -410,7 +607,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenCountContain
 	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
 
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 	require.EqualError(s.T(), err, "daily limit exceeded: cannot generate new verification code")
 }
 
@@ -436,11 +633,233 @@ func (s *TestVerificationServiceSuite) TestInitVerificationFailsDailyCounterExce
 	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
 
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1")
+	err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+1NUMBER", "1", configuration.VerificationChannelSMS)
 	require.EqualError(s.T(), err, "daily limit exceeded: cannot generate new verification code", err.Error())
 	require.Empty(s.T(), userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
 }
 
+func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenRateLimited() {
+	// Setup gock to intercept calls made to the Twilio API
+	gock.New("https://api.twilio.com").
+		Times(1).
+		Reply(http.StatusNoContent).
+		BodyString("")
+	defer gock.Off()
+	s.SetSettings(map[string]string{
+		"verification.rateLimit.phoneNumber": "1",
+		"verification.codeHashKey":           "hash-key",
+	})
+
+	johnny := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johnny@kubesaw"),
+		testusersignup.VerificationRequiredAgo(time.Second))
+	jsmith := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("jsmith@kubesaw"),
+		testusersignup.VerificationRequiredAgo(time.Second))
+	fakeClient, application := testutil.PrepareInClusterApp(s.T(), johnny, jsmith)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	err := application.VerificationService().InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+	require.NoError(s.T(), err)
+
+	// when
+	err = application.VerificationService().InitVerification(ctx, "jsmith@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+
+	// then
+	e := &crterrors.Error{}
+	require.ErrorAs(s.T(), err, &e)
+	require.EqualError(s.T(), err, "too many verification requests: please try again later")
+	assert.Equal(s.T(), http.StatusTooManyRequests, e.Code)
+	assert.Greater(s.T(), e.RetryAfter, time.Duration(0))
+	// the verification code was not sent
+	require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(jsmith), jsmith))
+	assert.Empty(s.T(), jsmith.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+}
+
+func (s *TestVerificationServiceSuite) TestInitVerificationWithChannels() {
+	configureChannels := func(settings map[string]string) {
+		data := map[string]string{
+			"verification.phone.channels": "sms,voice",
+			"verification.codeHashKey":    "hash-key",
+		}
+		maps.Copy(data, settings)
+		s.SetSettings(data, testconfig.RegistrationService().
+			Verification().DailyLimit(3))
+	}
+	newService := func(initObjs ...client.Object) (*commontest.FakeClient, *verificationservice.ServiceImpl, *fake.NotificationSender) {
+		fakeClient := commontest.NewFakeClient(s.T(), initObjs...)
+		notificationSender := &fake.NotificationSender{}
+		cl := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+		return fakeClient, &verificationservice.ServiceImpl{
+			Client:              cl,
+			NotificationService: notificationSender,
+			VoiceCallService:    notificationSender,
+			RateLimiter:         ratelimit.NewLimiter(cl),
+		}, notificationSender
+	}
+
+	s.Run("verification code sent by voice call", func() {
+		// given
+		configureChannels(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, notificationSender := newService(userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelVoice)
+
+		// then
+		require.NoError(s.T(), err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		sent := notificationSender.Sent()
+		require.Len(s.T(), sent, 1)
+		spelledCode := regexp.MustCompile(`code is ((?:[0-9], ){5}[0-9])\.`).FindStringSubmatch(sent[0].Content)
+		require.Len(s.T(), spelledCode, 2)
+		code := strings.ReplaceAll(spelledCode[1], ", ", "")
+		s.assertStoredCode(signup, code)
+		assert.Equal(s.T(), []fake.Notification{{
+			Channel:     "voice",
+			PhoneNumber: "+1NUMBER",
+			CountryCode: "1",
+			Content: fmt.Sprintf("Your Developer Sandbox verification code is %[1]s. Once again, your verification code is %[1]s.",
+				spelledCode[1]),
+		}}, sent)
+		assert.Equal(s.T(), "1", signup.Annotations[verificationservice.VoiceVerificationCounterAnnotationKey])
+		assert.Equal(s.T(), "0", signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
+		// the delivery status is only tracked for the SMS
+		assert.NotContains(s.T(), signup.Annotations, delivery.StatusAnnotationKey)
+		assert.NotContains(s.T(), signup.Annotations, delivery.MessageIDAnnotationKey)
+
+		s.Run("code verified", func() {
+			// when
+			err := svc.VerifyPhoneCode(ctx, "johnny@kubesaw", code)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+			assert.False(s.T(), states.VerificationRequired(signup))
+			assert.NotContains(s.T(), signup.Annotations, verificationservice.VoiceVerificationCounterAnnotationKey)
+		})
+	})
+
+	s.Run("voice call after a failed SMS delivery", func() {
+		// given
+		configureChannels(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "1"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey, time.Now().Format(verificationservice.TimestampLayout)),
+			testusersignup.WithAnnotation(delivery.StatusAnnotationKey, delivery.StatusFailed),
+			testusersignup.WithAnnotation(delivery.MessageIDAnnotationKey, "SM123"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, notificationSender := newService(userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelVoice)
+
+		// then
+		require.NoError(s.T(), err)
+		sent := notificationSender.Sent()
+		require.Len(s.T(), sent, 1)
+		assert.Equal(s.T(), "voice", sent[0].Channel)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.Equal(s.T(), "1", signup.Annotations[verificationservice.VoiceVerificationCounterAnnotationKey])
+		// the status of the failed SMS doesn't apply to the code sent by voice call
+		assert.NotContains(s.T(), signup.Annotations, delivery.StatusAnnotationKey)
+		assert.NotContains(s.T(), signup.Annotations, delivery.MessageIDAnnotationKey)
+	})
+
+	s.Run("separate daily limits per channel", func() {
+		// given
+		configureChannels(map[string]string{
+			"verification.voice.dailyLimit": "1",
+		})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(verificationservice.VoiceVerificationCounterAnnotationKey, "1"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "2"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey, time.Now().Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, notificationSender := newService(userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		voiceErr := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelVoice)
+		smsErr := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+
+		// then
+		require.EqualError(s.T(), voiceErr, "daily limit exceeded: cannot generate new verification code")
+		require.NoError(s.T(), smsErr)
+		sent := notificationSender.Sent()
+		require.Len(s.T(), sent, 1)
+		assert.Equal(s.T(), "sms", sent[0].Channel)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.Equal(s.T(), "1", signup.Annotations[verificationservice.VoiceVerificationCounterAnnotationKey])
+		assert.Equal(s.T(), "3", signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
+		assert.Equal(s.T(), delivery.StatusSent, signup.Annotations[delivery.StatusAnnotationKey])
+		assert.Equal(s.T(), sent[0].MessageID, signup.Annotations[delivery.MessageIDAnnotationKey])
+	})
+
+	s.Run("configured code length and charset", func() {
+		// given
+		configureChannels(map[string]string{
+			"verification.code.length":  "8",
+			"verification.code.charset": "ABCDEFGHJKLMNPQRSTUVWXYZ23456789",
+		})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, notificationSender := newService(userSignup)
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelSMS)
+
+		// then
+		require.NoError(s.T(), err)
+		sent := notificationSender.Sent()
+		require.Len(s.T(), sent, 1)
+		assert.Regexp(s.T(), `^Your Developer Sandbox verification code is [A-HJ-NP-Z2-9]{8}$`, sent[0].Content)
+
+		s.Run("code verified case-insensitively", func() {
+			// when
+			err := svc.VerifyPhoneCode(ctx, "johnny@kubesaw", strings.ToLower(strings.TrimPrefix(sent[0].Content, "Your Developer Sandbox verification code is ")))
+
+			// then
+			require.NoError(s.T(), err)
+			signup := &toolchainv1alpha1.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+			assert.False(s.T(), states.VerificationRequired(signup))
+		})
+	})
+
+	s.Run("sender error", func() {
+		// given
+		configureChannels(map[string]string{})
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, svc, notificationSender := newService(userSignup)
+		notificationSender.Err = errors.New("call failed")
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		err := svc.InitVerification(ctx, "johnny@kubesaw", "+1NUMBER", "1", configuration.VerificationChannelVoice)
+
+		// then
+		require.EqualError(s.T(), err, "call failed: error while sending verification code")
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.Equal(s.T(), "0", signup.Annotations[verificationservice.VoiceVerificationCounterAnnotationKey])
+		assert.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+	})
+}
+
 func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenPhoneNumberInUse() {
 	// Setup gock to intercept calls made to the Twilio API
 	gock.New("https://api.twilio.com").
@@ -468,7 +887,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenPhoneNumberI
 	fakeClient, application := testutil.PrepareInClusterApp(s.T(), alphaUserSignup, bravoUserSignup)
 
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, bravoUserSignup.Spec.IdentityClaims.PreferredUsername, e164PhoneNumber, "1")
+	err := application.VerificationService().InitVerification(ctx, bravoUserSignup.Spec.IdentityClaims.PreferredUsername, e164PhoneNumber, "1", configuration.VerificationChannelSMS)
 	require.Error(s.T(), err)
 	require.Equal(s.T(), "phone number already in use: cannot register using phone number: +19875551122", err.Error())
 
@@ -486,8 +905,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationOKWhenPhoneNumberInUs
 		Reply(http.StatusNoContent).
 		BodyString("")
 	defer gock.Off()
-	// call override config to ensure the factory option takes effect
-	s.OverrideApplicationDefault()
+	s.ServiceConfiguration("xxx", "yyy", "CodeReady")
 
 	e164PhoneNumber := "+19875553344"
 
@@ -508,7 +926,7 @@ func (s *TestVerificationServiceSuite) TestInitVerificationOKWhenPhoneNumberInUs
 	fakeClient, application := testutil.PrepareInClusterApp(s.T(), alphaUserSignup, bravoUserSignup)
 
 	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
-	err := application.VerificationService().InitVerification(ctx, bravoUserSignup.Spec.IdentityClaims.PreferredUsername, e164PhoneNumber, "1")
+	err := application.VerificationService().InitVerification(ctx, bravoUserSignup.Spec.IdentityClaims.PreferredUsername, e164PhoneNumber, "1", configuration.VerificationChannelSMS)
 	require.NoError(s.T(), err)
 
 	// Reload bravoUserSignup
@@ -572,6 +990,31 @@ func (s *TestVerificationServiceSuite) TestVerifyPhoneCode() {
 		require.False(s.T(), states.VerificationRequired(signup))
 	})
 
+	s.Run("when verification code was sent by email", func() {
+
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johny@kubesaw"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, "+1NUMBER"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456"),
+			testusersignup.WithAnnotation(verificationservice.VerificationMethodAnnotationKey, "email"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, now.Add(10*time.Second).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		err := application.VerificationService().VerifyPhoneCode(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "123456")
+		require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+
+		signup := &toolchainv1alpha1.UserSignup{}
+		err = fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup)
+		require.NoError(s.T(), err)
+
+		require.True(s.T(), states.VerificationRequired(signup))
+		require.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
+	})
+
 	s.Run("when verification code is invalid", func() {
 
 		userSignup := testusersignup.NewUserSignup(
@@ -872,6 +1315,132 @@ func (s *TestVerificationServiceSuite) testVerifyActivationCode(targetCluster st
 
 }
 
+func (s *TestVerificationServiceSuite) TestVerifyActivationCodeWithInvitation() {
+	// given
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	newReferrer := func() *toolchainv1alpha1.UserSignup {
+		referrer := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("ted@kubesaw"),
+			testusersignup.ApprovedAutomaticallyAgo(time.Second),
+			testusersignup.SignupComplete(""))
+		_, err := signuppkg.AddInvitation(referrer, signuppkg.HashInvitationCode("inv-abcdef"), time.Hour, 1)
+		require.NoError(s.T(), err)
+		return referrer
+	}
+
+	s.Run("invitation redeemed", func() {
+		// given
+		referrer := newReferrer()
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jane@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), referrer, userSignup)
+
+		// when
+		err := application.VerificationService().VerifyActivationCode(ctx, "jane@kubesaw", "inv-abcdef")
+
+		// then
+		require.NoError(s.T(), err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		require.False(s.T(), states.VerificationRequired(signup))
+		assert.Equal(s.T(), referrer.Name, signup.Labels[signuppkg.ReferredByLabelKey])
+		assert.NotContains(s.T(), signup.Labels, toolchainv1alpha1.SocialEventUserSignupLabelKey)
+	})
+
+	s.Run("invalid invitation counted as an attempt", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jane@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), newReferrer(), userSignup)
+
+		// when
+		err := application.VerificationService().VerifyActivationCode(ctx, "jane@kubesaw", "inv-unknown")
+
+		// then
+		require.EqualError(s.T(), err, "invalid code: the provided code is invalid")
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		require.True(s.T(), states.VerificationRequired(signup))
+		assert.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserVerificationAttemptsAnnotationKey])
+	})
+}
+
+func (s *TestVerificationServiceSuite) TestVerificationPublishesEvents() {
+	// given
+	sink := fake.NewEventSink(s.T(), "crm-secret")
+	s.SetSettings(map[string]string{
+		"events.webhooks": fmt.Sprintf(`[{"url": "%s", "secret": "crm-secret"}]`, sink.URL),
+	})
+	publisher := events.NewPublisher(&http.Client{Transport: &http.Transport{}})
+	publisherCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	publisher.Start(publisherCtx)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("phone verified", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationAttemptsAnnotationKey, "0"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey, "123456"),
+			testusersignup.WithAnnotation(toolchainv1alpha1.UserVerificationExpiryAnnotationKey, time.Now().Add(10*time.Second).Format(verificationservice.TimestampLayout)),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		fakeClient := commontest.NewFakeClient(s.T(), userSignup)
+		svc := verificationservice.NewVerificationService(namespaced.NewClient(fakeClient, commontest.HostOperatorNs),
+			verificationservice.WithEventPublisher(publisher))
+
+		// when
+		err := svc.VerifyPhoneCode(ctx, "johnny@kubesaw", "123456")
+
+		// then
+		require.NoError(s.T(), err)
+		require.Eventually(s.T(), func() bool {
+			return len(sink.Received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		event := sink.Received()[0]
+		assert.Equal(s.T(), events.TypeVerified, event.Type)
+		assert.Equal(s.T(), userSignup.Name, event.Subject)
+		assert.Equal(s.T(), configuration.VerificationMethodPhone, event.Data.VerificationMethod)
+
+		s.Run("no event for an invalid code", func() {
+			// when
+			err := svc.VerifyPhoneCode(ctx, "johnny@kubesaw", "654321")
+
+			// then
+			require.Error(s.T(), err)
+			assert.Never(s.T(), func() bool {
+				return len(sink.Received()) > 1
+			}, 100*time.Millisecond, 10*time.Millisecond)
+		})
+	})
+
+	s.Run("activation code verified", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("jane@kubesaw"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+		event := testsocialevent.NewSocialEvent(commontest.HostOperatorNs, "event")
+		fakeClient := commontest.NewFakeClient(s.T(), userSignup, event)
+		svc := verificationservice.NewVerificationService(namespaced.NewClient(fakeClient, commontest.HostOperatorNs),
+			verificationservice.WithEventPublisher(publisher))
+
+		// when
+		err := svc.VerifyActivationCode(ctx, "jane@kubesaw", event.Name)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Eventually(s.T(), func() bool {
+			return len(sink.Received()) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		verified := sink.Received()[1]
+		assert.Equal(s.T(), events.TypeVerified, verified.Type)
+		assert.Equal(s.T(), userSignup.Name, verified.Subject)
+		assert.Equal(s.T(), events.VerificationMethodActivationCode, verified.Data.VerificationMethod)
+	})
+}
+
 func (s *TestVerificationServiceSuite) TestPhoneNumberAlreadyInUse() {
 	bannedUser := &toolchainv1alpha1.BannedUser{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,74 @@
+package fake
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/signup/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// EventSink is a webhook which keeps the signup lifecycle events it received in memory.
+// It verifies the signature of the deliveries with its secret, and rejects them if its status code is changed.
+type EventSink struct {
+	*httptest.Server
+	Secret     string
+	statusCode int
+	mu         sync.Mutex
+	received   []events.Event
+	attempts   int
+}
+
+// NewEventSink starts a new EventSink, which is closed at the end of the test
+func NewEventSink(t *testing.T, secret string) *EventSink {
+	sink := &EventSink{
+		Secret:     secret,
+		statusCode: http.StatusOK,
+	}
+	sink.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, events.ContentType, r.Header.Get("Content-Type"))
+		assert.Equal(t, "v1,"+events.Sign(sink.Secret, r.Header.Get(events.IDHeader), r.Header.Get(events.TimestampHeader), body),
+			r.Header.Get(events.SignatureHeader))
+
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		sink.attempts++
+		if sink.statusCode != http.StatusOK {
+			w.WriteHeader(sink.statusCode)
+			return
+		}
+		event := events.Event{}
+		assert.NoError(t, json.Unmarshal(body, &event))
+		sink.received = append(sink.received, event)
+	}))
+	t.Cleanup(sink.Close)
+	return sink
+}
+
+// SetStatusCode changes the status code of the replies
+func (s *EventSink) SetStatusCode(statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = statusCode
+}
+
+// Received returns the events received so far
+func (s *EventSink) Received() []events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]events.Event{}, s.received...)
+}
+
+// Attempts returns the number of deliveries attempted so far, including the rejected ones
+func (s *EventSink) Attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts
+}
@@ -0,0 +1,38 @@
+package fake
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mail is an email sent with the fake MailSender
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender is a MailSender which keeps the sent emails in memory, or returns the given error
+type MailSender struct {
+	Err  error
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func (m *MailSender) SendMail(_ *gin.Context, to, subject, body string) error {
+	if m.Err != nil {
+		return m.Err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, Mail{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Sent returns the emails sent so far
+func (m *MailSender) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail{}, m.sent...)
+}
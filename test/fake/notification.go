@@ -0,0 +1,55 @@
+package fake
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notification is an SMS or a voice call sent with the fake NotificationSender
+type Notification struct {
+	Channel     string
+	MessageID   string
+	PhoneNumber string
+	CountryCode string
+	Content     string
+}
+
+// NotificationSender is a NotificationSender and a VoiceCallSender which keeps the sent SMS and voice calls in memory,
+// or returns the given error
+type NotificationSender struct {
+	Err  error
+	mu   sync.Mutex
+	sent []Notification
+}
+
+// SendNotification keeps the SMS, and returns its message ID, which is SM1 for the first notification sent, SM2 for the second one, etc.
+func (n *NotificationSender) SendNotification(_ *gin.Context, content, phoneNumber, countryCode string) (string, error) {
+	return n.record(Notification{Channel: "sms", PhoneNumber: phoneNumber, CountryCode: countryCode, Content: content})
+}
+
+func (n *NotificationSender) SendVoiceCall(_ *gin.Context, content, phoneNumber, countryCode string) error {
+	_, err := n.record(Notification{Channel: "voice", PhoneNumber: phoneNumber, CountryCode: countryCode, Content: content})
+	return err
+}
+
+func (n *NotificationSender) record(notification Notification) (string, error) {
+	if n.Err != nil {
+		return "", n.Err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if notification.Channel == "sms" {
+		notification.MessageID = fmt.Sprintf("SM%d", len(n.sent)+1)
+	}
+	n.sent = append(n.sent, notification)
+	return notification.MessageID, nil
+}
+
+// Sent returns the SMS and voice calls sent so far
+func (n *NotificationSender) Sent() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Notification{}, n.sent...)
+}
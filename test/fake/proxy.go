@@ -48,6 +48,21 @@ func (m *SignupService) GetSignup(_ *gin.Context, username string, _ bool) (*sig
 func (m *SignupService) Signup(_ *gin.Context) (*toolchainv1alpha1.UserSignup, error) {
 	return nil, nil
 }
+func (m *SignupService) RequestDeactivation(_ *gin.Context, _ string) (*signup.DeactivationConfirmation, error) {
+	return nil, nil
+}
+func (m *SignupService) Deactivate(_ *gin.Context, _, _ string) error {
+	return nil
+}
+func (m *SignupService) ExportSignup(_ *gin.Context, _ string) (*signup.Export, error) {
+	return nil, nil
+}
+func (m *SignupService) CreateInvitation(_ *gin.Context, _ string) (*signup.Invitation, error) {
+	return nil, nil
+}
+func (m *SignupService) ListInvitations(_ *gin.Context, _ string) (*signup.Invitations, error) {
+	return nil, nil
+}
 func (m *SignupService) UpdateUserSignup(_ *toolchainv1alpha1.UserSignup) (*toolchainv1alpha1.UserSignup, error) {
 	return nil, nil
 }
@@ -15,6 +15,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -73,15 +74,35 @@ func (s *UnitTestSuite) SetSecret(secret *corev1.Secret) {
 	if err == nil {
 		err = s.ConfigClient.Delete(context.TODO(), sec)
 		require.NoError(s.T(), err)
+	} else {
+		// only proceed to create the secret if it was not found
+		require.True(s.T(), errors.IsNotFound(err), "unexpected error")
 	}
 
-	require.True(s.T(), errors.IsNotFound(err), "unexpected error")
 	err = s.ConfigClient.Create(context.TODO(), secret)
 	require.NoError(s.T(), err)
 	// set client
 	configuration.SetClient(s.ConfigClient)
 }
 
+// SetSettings overrides the configuration with the given options, and sets the given settings in the secret of the
+// registration service
+func (s *UnitTestSuite) SetSettings(settings map[string]string, opts ...testconfig.ToolchainConfigOption) {
+	s.OverrideApplicationDefault(append(opts, testconfig.RegistrationService().
+		Verification().Secret().Ref("registration-service-secret"))...)
+	data := make(map[string][]byte, len(settings))
+	for k, v := range settings {
+		data[k] = []byte(v)
+	}
+	s.SetSecret(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registration-service-secret",
+			Namespace: test.HostOperatorNs,
+		},
+		Data: data,
+	})
+}
+
 func (s *UnitTestSuite) DefaultConfig() configuration.RegistrationServiceConfig {
 	// use a new configuration client to fully reset configuration
 	s.ConfigClient = test.NewFakeClient(s.T())
@@ -17,6 +17,6 @@ func PrepareInClusterApplication(t *testing.T, objects ...client.Object) applica
 
 func PrepareInClusterApp(t *testing.T, objects ...client.Object) (*commontest.FakeClient, application.Application) {
 	fakeClient := commontest.NewFakeClient(t, objects...)
-	app := server.NewInClusterApplication(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	app := server.NewInClusterApplication(namespaced.NewClient(fakeClient, commontest.HostOperatorNs), nil)
 	return fakeClient, app
 }